@@ -0,0 +1,208 @@
+package admin
+
+import (
+	"automata/client/payeer"
+	"automata/config"
+	"automata/strategy"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// API inspects and steers the strategies of a Runner:
+//
+//	GET  /strategies                         every strategy with quotes, balances and pauses
+//	GET  /strategies/{name}                  one strategy
+//	POST /strategies/{name}/pause?side=buy   stop placing on a side (buy, sell or both)
+//	POST /strategies/{name}/resume?side=buy  place on a side again
+//	POST /strategies/{name}/cancel           cancel every open order of the strategy
+//	POST /strategies/{name}/balances         fetch the balances again
+//	POST /strategies/{name}/params           change parameters, the body is a JSON object merged into the strategy config
+//	POST /cancel                             cancel the orders of every strategy
+//	GET  /market                             Binance reference tickers with their age and the halt state
+type API struct {
+	Runner *strategy.Runner
+	Market *strategy.Market
+	// Params merges patch into the config of the named strategy and applies
+	// it like a config reload; nil disables parameter changes.
+	Params func(name string, patch json.RawMessage) ([]config.Change, error)
+	// CancelTimeout bounds a cancel request; Payeer refuses to cancel orders
+	// younger than a minute, so it defaults to 2m.
+	CancelTimeout time.Duration
+}
+
+// StrategyView is what the API reports about a strategy.
+type StrategyView struct {
+	strategy.Status
+	Paused   map[payeer.Action]bool    `json:"paused,omitempty"`
+	Quotes   []strategy.Quote          `json:"quotes,omitempty"`
+	Balances map[string]payeer.Balance `json:"balances,omitempty"`
+}
+
+type MarketView struct {
+	Time       time.Time                  `json:"time"`
+	Tickers    map[string]strategy.Ticker `json:"tickers"`
+	Halted     bool                       `json:"halted"`
+	HaltReason string                     `json:"haltReason,omitempty"`
+}
+
+func (a *API) Register(s *Server) {
+	if a.CancelTimeout == 0 {
+		a.CancelTimeout = 2 * time.Minute
+	}
+	s.Handle("GET /strategies", http.HandlerFunc(a.list))
+	s.Handle("GET /strategies/{name}", a.withStrategy(a.get))
+	s.Handle("POST /strategies/{name}/pause", a.withStrategy(a.pause(true)))
+	s.Handle("POST /strategies/{name}/resume", a.withStrategy(a.pause(false)))
+	s.Handle("POST /strategies/{name}/cancel", a.withStrategy(a.cancel))
+	s.Handle("POST /strategies/{name}/balances", a.withStrategy(a.balances))
+	s.Handle("POST /strategies/{name}/params", http.HandlerFunc(a.params))
+	s.Handle("POST /cancel", http.HandlerFunc(a.cancelAll))
+	s.Handle("GET /market", http.HandlerFunc(a.market))
+}
+
+func (a *API) view(status strategy.Status) StrategyView {
+	view := StrategyView{Status: status}
+	instance, _ := a.Runner.Strategy(status.Name)
+	if controllable, ok := instance.(strategy.Controllable); ok {
+		view.Paused = controllable.Pauses().Snapshot()
+		view.Quotes = controllable.Quotes()
+		view.Balances = controllable.Balances()
+	}
+	return view
+}
+
+func (a *API) list(w http.ResponseWriter, r *http.Request) {
+	views := []StrategyView{}
+	for _, status := range a.Runner.Statuses() {
+		views = append(views, a.view(status))
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+func (a *API) withStrategy(handle func(w http.ResponseWriter, r *http.Request, name string, s strategy.Controllable)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		instance, ok := a.Runner.Strategy(name)
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Errorf("no strategy %q", name))
+			return
+		}
+		controllable, ok := instance.(strategy.Controllable)
+		if !ok {
+			writeError(w, http.StatusNotImplemented, fmt.Errorf("strategy %q cannot be controlled", name))
+			return
+		}
+		handle(w, r, name, controllable)
+	})
+}
+
+func (a *API) get(w http.ResponseWriter, r *http.Request, name string, s strategy.Controllable) {
+	for _, status := range a.Runner.Statuses() {
+		if status.Name == name {
+			writeJSON(w, http.StatusOK, a.view(status))
+			return
+		}
+	}
+}
+
+func (a *API) pause(paused bool) func(w http.ResponseWriter, r *http.Request, name string, s strategy.Controllable) {
+	return func(w http.ResponseWriter, r *http.Request, name string, s strategy.Controllable) {
+		var actions []payeer.Action
+		switch side := r.URL.Query().Get("side"); side {
+		case "buy":
+			actions = []payeer.Action{payeer.ACTION_BUY}
+		case "sell":
+			actions = []payeer.Action{payeer.ACTION_SELL}
+		case "", "both":
+			actions = []payeer.Action{payeer.ACTION_BUY, payeer.ACTION_SELL}
+		default:
+			writeError(w, http.StatusBadRequest, fmt.Errorf("side must be buy, sell or both, got %q", side))
+			return
+		}
+		for _, action := range actions {
+			s.Pauses().Set(action, paused)
+			slog.Warn("[Admin] Strategy side paused", "strategy", name, "action", action, "paused", paused)
+		}
+		writeJSON(w, http.StatusOK, s.Pauses().Snapshot())
+	}
+}
+
+func (a *API) cancel(w http.ResponseWriter, r *http.Request, name string, s strategy.Controllable) {
+	ctx, cancel := context.WithTimeout(r.Context(), a.CancelTimeout)
+	defer cancel()
+	slog.Warn("[Admin] Cancelling every order of the strategy", "strategy", name)
+	if err := s.CancelAll(ctx); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.Quotes())
+}
+
+func (a *API) cancelAll(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), a.CancelTimeout)
+	defer cancel()
+	slog.Warn("[Admin] Cancelling every order of every strategy")
+	var errs []error
+	for _, status := range a.Runner.Statuses() {
+		instance, _ := a.Runner.Strategy(status.Name)
+		if controllable, ok := instance.(strategy.Controllable); ok {
+			if err := controllable.CancelAll(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", status.Name, err))
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"cancelled": true})
+}
+
+func (a *API) balances(w http.ResponseWriter, r *http.Request, name string, s strategy.Controllable) {
+	s.RefreshBalances()
+	writeJSON(w, http.StatusOK, s.Balances())
+}
+
+func (a *API) params(w http.ResponseWriter, r *http.Request) {
+	if a.Params == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("parameter changes are not supported by this process"))
+		return
+	}
+	patch, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil || !json.Valid(patch) {
+		writeError(w, http.StatusBadRequest, errors.New("body must be a JSON object"))
+		return
+	}
+	changes, err := a.Params(r.PathValue("name"), patch)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, changes)
+}
+
+func (a *API) market(w http.ResponseWriter, r *http.Request) {
+	halted, reason := a.Market.Halted()
+	writeJSON(w, http.StatusOK, MarketView{
+		Time:       time.Now(),
+		Tickers:    a.Market.Tickers(),
+		Halted:     halted,
+		HaltReason: reason,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
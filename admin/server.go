@@ -0,0 +1,58 @@
+// Package admin serves the operator HTTP API of a trading process. Every
+// request must carry the configured token as "Authorization: Bearer <token>".
+package admin
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type Server struct {
+	addr  string
+	token string
+	mux   *http.ServeMux
+}
+
+func NewServer(addr, token string) (*Server, error) {
+	if token == "" {
+		return nil, errors.New("admin API needs a token")
+	}
+	return &Server{addr: addr, token: token, mux: http.NewServeMux()}, nil
+}
+
+// Handle registers handler for pattern behind the token check.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, s.authorize(handler))
+}
+
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			slog.Warn("[Admin] Unauthorized request", "method", r.Method, "path", r.URL.Path, "remote", r.RemoteAddr)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		slog.Info("[Admin] Request", "method", r.Method, "path", r.URL.Path, "query", r.URL.RawQuery, "remote", r.RemoteAddr)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Start serves in the background; a failing listener is logged.
+func (s *Server) Start() {
+	server := &http.Server{
+		Addr:              s.addr,
+		Handler:           s.mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		slog.Info("[Admin] Listening", "addr", s.addr)
+		if err := server.ListenAndServe(); err != nil {
+			slog.Error("[Admin] Server stopped", "error", err)
+		}
+	}()
+}
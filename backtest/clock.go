@@ -0,0 +1,19 @@
+package backtest
+
+import "time"
+
+// Clock is the simulated time of a backtest. It only moves forward, to the
+// time of the event being replayed.
+type Clock struct {
+	now time.Time
+}
+
+func (c *Clock) Now() time.Time {
+	return c.now
+}
+
+func (c *Clock) Set(t time.Time) {
+	if t.After(c.now) {
+		c.now = t
+	}
+}
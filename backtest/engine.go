@@ -0,0 +1,140 @@
+package backtest
+
+import (
+	"automata/client/binance"
+	"automata/client/payeer"
+	"automata/client/payeer/paper"
+	"automata/msync"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type Options struct {
+	Info       *payeer.InfoResponse
+	Balances   map[string]decimal.Decimal
+	Strategies []Strategy
+	// Horizon after a fill at which the reference mid is compared with the fill price
+	MarkoutHorizon time.Duration
+}
+
+// Env is what a strategy sees on every step: the simulated clock, the paper
+// exchange and the market data replayed so far.
+type Env struct {
+	Clock    *Clock
+	Exchange *paper.Exchange
+	Info     *payeer.InfoResponse
+	Books    *msync.MuMap[payeer.Pair, payeer.PairsOrderInfo]
+	Tickers  *msync.MuMap[binance.Symbol, binance.OrderBookTickerStreamResult]
+}
+
+type Strategy interface {
+	Name() string
+	// Pairs returns the traded Payeer pairs with their Binance reference symbols.
+	Pairs() map[payeer.Pair]binance.Symbol
+	// Step runs one iteration of the strategy loops at the current simulated time.
+	Step(env *Env)
+}
+
+type Engine struct {
+	options  *Options
+	env      *Env
+	mids     map[binance.Symbol][]midPoint
+	events   int
+	start    time.Time
+	balances map[string]decimal.Decimal
+}
+
+type midPoint struct {
+	time time.Time
+	mid  decimal.Decimal
+}
+
+func NewEngine(options *Options) *Engine {
+	if options.MarkoutHorizon == 0 {
+		options.MarkoutHorizon = time.Minute
+	}
+	clock := &Clock{}
+	exchange := paper.NewExchange(nil, &paper.Options{
+		Balances: options.Balances,
+		Clock:    clock.Now,
+	})
+	exchange.SetInfo(options.Info)
+	return &Engine{
+		options:  options,
+		mids:     make(map[binance.Symbol][]midPoint),
+		balances: options.Balances,
+		env: &Env{
+			Clock:    clock,
+			Exchange: exchange,
+			Info:     options.Info,
+			Books:    msync.NewMuMap[payeer.Pair, payeer.PairsOrderInfo](),
+			Tickers:  msync.NewMuMap[binance.Symbol, binance.OrderBookTickerStreamResult](),
+		},
+	}
+}
+
+// Run replays the source through the strategies and builds the report.
+func (e *Engine) Run(source Source) (*Report, error) {
+	for {
+		event, err := source.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if e.events == 0 {
+			e.start = event.Time
+		}
+		e.events++
+		e.env.Clock.Set(event.Time)
+		e.apply(event)
+		for _, strategy := range e.options.Strategies {
+			strategy.Step(e.env)
+		}
+	}
+	return e.report(), nil
+}
+
+func (e *Engine) apply(event *Event) {
+	switch event.Kind {
+	case EVENT_PAYEER_BOOK:
+		if event.Book == nil {
+			return
+		}
+		e.env.Books.Set(event.Pair, *event.Book)
+		e.env.Exchange.Observe(event.Pair, *event.Book)
+	case EVENT_PAYEER_TRADES:
+		e.env.Exchange.ObserveTrades(event.Pair, event.Trades)
+	case EVENT_BINANCE_TICKER:
+		if event.Ticker == nil {
+			return
+		}
+		symbol := event.Symbol
+		if symbol == "" {
+			symbol = event.Ticker.Symbol
+		}
+		e.env.Tickers.Set(symbol, *event.Ticker)
+		bid, errBid := decimal.NewFromString(event.Ticker.BidPrice)
+		ask, errAsk := decimal.NewFromString(event.Ticker.AskPrice)
+		if errBid == nil && errAsk == nil {
+			e.mids[symbol] = append(e.mids[symbol], midPoint{
+				time: event.Time,
+				mid:  bid.Add(ask).Div(decimal.NewFromInt(2)),
+			})
+		}
+	}
+}
+
+// midAt returns the last reference mid known at the time.
+func (e *Engine) midAt(symbol binance.Symbol, t time.Time) (decimal.Decimal, bool) {
+	points := e.mids[symbol]
+	i := sort.Search(len(points), func(i int) bool { return points[i].time.After(t) })
+	if i == 0 {
+		return decimal.Zero, false
+	}
+	return points[i-1].mid, true
+}
@@ -0,0 +1,66 @@
+package backtest
+
+import (
+	"automata/client/binance"
+	"automata/client/payeer"
+	"automata/recorder"
+	"fmt"
+	"time"
+)
+
+type recordedSource struct {
+	reader *recorder.Reader
+}
+
+// NewRecordedSource replays a recorder directory. Events are stamped with the
+// receive time, which is what a live strategy would have seen.
+func NewRecordedSource(reader *recorder.Reader) Source {
+	return &recordedSource{reader: reader}
+}
+
+func (s *recordedSource) Next() (*Event, error) {
+	for {
+		record, err := s.reader.Next()
+		if err != nil {
+			return nil, err
+		}
+		event, err := toEvent(record)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s %s: %w", record.Venue, record.Kind, record.Symbol, err)
+		}
+		if event != nil {
+			return event, nil
+		}
+	}
+}
+
+func toEvent(record *recorder.Record) (*Event, error) {
+	event := &Event{Time: time.Unix(0, record.RecvTime)}
+	switch {
+	case record.Venue == recorder.VENUE_PAYEER && record.Kind == recorder.KIND_BOOK:
+		var book payeer.PairsOrderInfo
+		if err := record.Decode(&book); err != nil {
+			return nil, err
+		}
+		event.Kind = EVENT_PAYEER_BOOK
+		event.Pair = payeer.Pair(record.Symbol)
+		event.Book = &book
+	case record.Venue == recorder.VENUE_PAYEER && record.Kind == recorder.KIND_TRADES:
+		event.Kind = EVENT_PAYEER_TRADES
+		event.Pair = payeer.Pair(record.Symbol)
+		if err := record.Decode(&event.Trades); err != nil {
+			return nil, err
+		}
+	case record.Venue == recorder.VENUE_BINANCE && record.Kind == recorder.KIND_TICKER:
+		var ticker binance.OrderBookTickerStreamResult
+		if err := record.Decode(&ticker); err != nil {
+			return nil, err
+		}
+		event.Kind = EVENT_BINANCE_TICKER
+		event.Symbol = binance.Symbol(record.Symbol)
+		event.Ticker = &ticker
+	default:
+		return nil, nil
+	}
+	return event, nil
+}
@@ -0,0 +1,238 @@
+package backtest
+
+import (
+	"automata/client/binance"
+	"automata/client/payeer"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var bps = decimal.NewFromInt(10000)
+
+type InventoryPoint struct {
+	Time     time.Time       `json:"time"`
+	Position decimal.Decimal `json:"position"`
+}
+
+type PairReport struct {
+	Pair   payeer.Pair    `json:"pair"`
+	Symbol binance.Symbol `json:"symbol"`
+
+	OrdersPlaced   int             `json:"ordersPlaced"`
+	OrdersFilled   int             `json:"ordersFilled"`
+	AmountPlaced   decimal.Decimal `json:"amountPlaced"`
+	AmountFilled   decimal.Decimal `json:"amountFilled"`
+	FillRate       decimal.Decimal `json:"fillRate"`
+	AmountFillRate decimal.Decimal `json:"amountFillRate"`
+
+	BuyAmount  decimal.Decimal `json:"buyAmount"`
+	SellAmount decimal.Decimal `json:"sellAmount"`
+	BuyValue   decimal.Decimal `json:"buyValue"`
+	SellValue  decimal.Decimal `json:"sellValue"`
+
+	// Fees are expressed in the quote asset, base fees are valued at the fill price
+	MakerFees decimal.Decimal `json:"makerFees"`
+	TakerFees decimal.Decimal `json:"takerFees"`
+
+	Position  decimal.Decimal `json:"position"`
+	MarkPrice decimal.Decimal `json:"markPrice"`
+	PnL       decimal.Decimal `json:"pnl"`
+
+	// Amount-weighted markout of fills against the reference mid after the horizon.
+	// Negative values mean the fills were adversely selected.
+	MarkoutBps    decimal.Decimal  `json:"markoutBps"`
+	AdverseFills  int              `json:"adverseFills"`
+	Fills         int              `json:"fills"`
+	InventoryPath []InventoryPoint `json:"inventoryPath"`
+}
+
+type Report struct {
+	Start          time.Time                  `json:"start"`
+	End            time.Time                  `json:"end"`
+	Events         int                        `json:"events"`
+	MarkoutHorizon time.Duration              `json:"markoutHorizon"`
+	Pairs          []*PairReport              `json:"pairs"`
+	Balances       map[string]payeer.Balance  `json:"balances"`
+	StartBalances  map[string]decimal.Decimal `json:"startBalances"`
+}
+
+type reportFill struct {
+	time   time.Time
+	action payeer.Action
+	payeer.OrderStatusTrade
+}
+
+func (e *Engine) report() *Report {
+	pairs := make(map[payeer.Pair]binance.Symbol)
+	for _, strategy := range e.options.Strategies {
+		for pair, symbol := range strategy.Pairs() {
+			pairs[pair] = symbol
+		}
+	}
+	reports := make(map[payeer.Pair]*PairReport, len(pairs))
+	for pair, symbol := range pairs {
+		reports[pair] = &PairReport{Pair: pair, Symbol: symbol}
+	}
+
+	fills := make(map[payeer.Pair][]reportFill)
+	for _, order := range e.env.Exchange.History() {
+		r, ok := reports[order.Pair]
+		if !ok {
+			continue
+		}
+		r.OrdersPlaced++
+		r.AmountPlaced = r.AmountPlaced.Add(decimal.RequireFromString(order.Amount))
+		processed := decimal.RequireFromString(order.AmountProcessed)
+		if processed.IsPositive() {
+			r.OrdersFilled++
+			r.AmountFilled = r.AmountFilled.Add(processed)
+		}
+		for _, trade := range order.Trades {
+			fills[order.Pair] = append(fills[order.Pair], reportFill{
+				time:             time.Unix(trade.Date, 0),
+				action:           order.Action,
+				OrderStatusTrade: trade,
+			})
+		}
+	}
+
+	end := e.env.Clock.Now()
+	for pair, r := range reports {
+		if r.OrdersPlaced > 0 {
+			r.FillRate = decimal.NewFromInt(int64(r.OrdersFilled)).Div(decimal.NewFromInt(int64(r.OrdersPlaced)))
+		}
+		if r.AmountPlaced.IsPositive() {
+			r.AmountFillRate = r.AmountFilled.Div(r.AmountPlaced)
+		}
+		pairFills := fills[pair]
+		slices.SortStableFunc(pairFills, func(a, b reportFill) int { return a.time.Compare(b.time) })
+
+		cash := decimal.Zero
+		markoutSum := decimal.Zero
+		markoutWeight := decimal.Zero
+		for _, fill := range pairFills {
+			price := decimal.RequireFromString(fill.Price)
+			amount := decimal.RequireFromString(fill.Amount)
+			value := decimal.RequireFromString(fill.Value)
+			fee := feeOf(&fill.OrderStatusTrade)
+			feeInQuote := fee
+			if fill.action == payeer.ACTION_BUY {
+				feeInQuote = fee.Mul(price)
+				r.BuyAmount = r.BuyAmount.Add(amount)
+				r.BuyValue = r.BuyValue.Add(value)
+				r.Position = r.Position.Add(amount.Sub(fee))
+				cash = cash.Sub(value)
+			} else {
+				r.SellAmount = r.SellAmount.Add(amount)
+				r.SellValue = r.SellValue.Add(value)
+				r.Position = r.Position.Sub(amount)
+				cash = cash.Add(value.Sub(fee))
+			}
+			if fill.IsMaker {
+				r.MakerFees = r.MakerFees.Add(feeInQuote)
+			} else {
+				r.TakerFees = r.TakerFees.Add(feeInQuote)
+			}
+			r.Fills++
+			r.InventoryPath = append(r.InventoryPath, InventoryPoint{Time: fill.time, Position: r.Position})
+
+			mid, ok := e.midAt(r.Symbol, fill.time.Add(e.options.MarkoutHorizon))
+			if !ok || !price.IsPositive() {
+				continue
+			}
+			markout := mid.Sub(price).Div(price).Mul(bps)
+			if fill.action == payeer.ACTION_SELL {
+				markout = markout.Neg()
+			}
+			if markout.IsNegative() {
+				r.AdverseFills++
+			}
+			markoutSum = markoutSum.Add(markout.Mul(amount))
+			markoutWeight = markoutWeight.Add(amount)
+		}
+		if markoutWeight.IsPositive() {
+			r.MarkoutBps = markoutSum.Div(markoutWeight).Round(2)
+		}
+		if mark, ok := e.midAt(r.Symbol, end); ok {
+			r.MarkPrice = mark
+			r.PnL = cash.Add(r.Position.Mul(mark))
+		}
+	}
+
+	list := make([]*PairReport, 0, len(reports))
+	for _, r := range reports {
+		list = append(list, r)
+	}
+	slices.SortFunc(list, func(a, b *PairReport) int {
+		if a.Pair < b.Pair {
+			return -1
+		}
+		if a.Pair > b.Pair {
+			return 1
+		}
+		return 0
+	})
+
+	balances, _ := e.env.Exchange.Balance()
+	return &Report{
+		Start:          e.start,
+		End:            end,
+		Events:         e.events,
+		MarkoutHorizon: e.options.MarkoutHorizon,
+		Pairs:          list,
+		Balances:       balances.Balances,
+		StartBalances:  e.balances,
+	}
+}
+
+func feeOf(trade *payeer.OrderStatusTrade) decimal.Decimal {
+	commission := trade.TakerCommission
+	if trade.IsMaker {
+		commission = trade.MakerCommission
+	}
+	fee, err := decimal.NewFromString(commission)
+	if err != nil {
+		return decimal.Zero
+	}
+	return fee
+}
+
+func (r *Report) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "Backtest %s .. %s (%s), %d events, markout horizon %s\n", r.Start.Format(time.RFC3339), r.End.Format(time.RFC3339), r.End.Sub(r.Start), r.Events, r.MarkoutHorizon)
+	for _, p := range r.Pairs {
+		fmt.Fprintf(w, "\n%s (reference %s)\n", p.Pair, p.Symbol)
+		fmt.Fprintf(w, "  orders placed/filled   %d / %d (fill rate %s, amount fill rate %s)\n", p.OrdersPlaced, p.OrdersFilled, p.FillRate.StringFixed(3), p.AmountFillRate.StringFixed(3))
+		fmt.Fprintf(w, "  bought                 %s for %s\n", p.BuyAmount.String(), p.BuyValue.String())
+		fmt.Fprintf(w, "  sold                   %s for %s\n", p.SellAmount.String(), p.SellValue.String())
+		fmt.Fprintf(w, "  fees maker/taker       %s / %s %s\n", p.MakerFees.StringFixed(6), p.TakerFees.StringFixed(6), p.Pair.Quote())
+		fmt.Fprintf(w, "  position               %s %s marked at %s\n", p.Position.String(), p.Pair.Base(), p.MarkPrice.String())
+		fmt.Fprintf(w, "  pnl                    %s %s\n", p.PnL.StringFixed(6), p.Pair.Quote())
+		fmt.Fprintf(w, "  markout                %s bps over %d fills, %d adverse\n", p.MarkoutBps.String(), p.Fills, p.AdverseFills)
+	}
+	fmt.Fprintln(w, "\nBalances")
+	for asset, balance := range r.Balances {
+		fmt.Fprintf(w, "  %-6s %f (start %s)\n", asset, balance.Total, r.StartBalances[asset].String())
+	}
+}
+
+// WriteInventoryCsv writes the inventory path of every pair as time,pair,position rows.
+func (r *Report) WriteInventoryCsv(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"time", "pair", "position"}); err != nil {
+		return err
+	}
+	for _, p := range r.Pairs {
+		for _, point := range p.InventoryPath {
+			if err := writer.Write([]string{point.Time.Format(time.RFC3339), string(p.Pair), point.Position.String()}); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,149 @@
+package backtest
+
+import (
+	"automata/client/binance"
+	"automata/client/payeer"
+	"bufio"
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+type EventKind string
+
+const (
+	EVENT_PAYEER_BOOK    EventKind = "payeer_book"
+	EVENT_PAYEER_TRADES  EventKind = "payeer_trades"
+	EVENT_BINANCE_TICKER EventKind = "binance_ticker"
+)
+
+type Event struct {
+	Time   time.Time                            `json:"time"`
+	Kind   EventKind                            `json:"kind"`
+	Pair   payeer.Pair                          `json:"pair,omitempty"`
+	Symbol binance.Symbol                       `json:"symbol,omitempty"`
+	Book   *payeer.PairsOrderInfo               `json:"book,omitempty"`
+	Trades []payeer.TradesTrade                 `json:"trades,omitempty"`
+	Ticker *binance.OrderBookTickerStreamResult `json:"ticker,omitempty"`
+}
+
+// Source yields events in time order and returns io.EOF when exhausted.
+type Source interface {
+	Next() (*Event, error)
+}
+
+type jsonlSource struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewJsonlSource reads one JSON encoded Event per line.
+func NewJsonlSource(r io.Reader) Source {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	return &jsonlSource{scanner: scanner}
+}
+
+func (s *jsonlSource) Next() (*Event, error) {
+	for s.scanner.Scan() {
+		s.line++
+		if len(s.scanner.Bytes()) == 0 {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(s.scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("line %d: %w", s.line, err)
+		}
+		return &event, nil
+	}
+	if err := s.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+type sliceSource struct {
+	events []*Event
+}
+
+func NewSliceSource(events []*Event) Source {
+	return &sliceSource{events: events}
+}
+
+func (s *sliceSource) Next() (*Event, error) {
+	if len(s.events) == 0 {
+		return nil, io.EOF
+	}
+	event := s.events[0]
+	s.events = s.events[1:]
+	return event, nil
+}
+
+// Merge time-aligns several sources into one. Events with equal times keep
+// the order of the sources they came from.
+func Merge(sources ...Source) Source {
+	return &mergedSource{sources: sources}
+}
+
+type mergedSource struct {
+	sources []Source
+	heads   eventHeap
+	started bool
+}
+
+func (m *mergedSource) Next() (*Event, error) {
+	if !m.started {
+		m.started = true
+		for i := range m.sources {
+			if err := m.advance(i); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if m.heads.Len() == 0 {
+		return nil, io.EOF
+	}
+	head := heap.Pop(&m.heads).(sourceHead)
+	if err := m.advance(head.source); err != nil {
+		return nil, err
+	}
+	return head.event, nil
+}
+
+func (m *mergedSource) advance(source int) error {
+	event, err := m.sources[source].Next()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	heap.Push(&m.heads, sourceHead{event: event, source: source})
+	return nil
+}
+
+type sourceHead struct {
+	event  *Event
+	source int
+}
+
+type eventHeap []sourceHead
+
+func (h eventHeap) Len() int { return len(h) }
+func (h eventHeap) Less(i, j int) bool {
+	if h[i].event.Time.Equal(h[j].event.Time) {
+		return h[i].source < h[j].source
+	}
+	return h[i].event.Time.Before(h[j].event.Time)
+}
+func (h eventHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *eventHeap) Push(x any)   { *h = append(*h, x.(sourceHead)) }
+func (h *eventHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
@@ -0,0 +1,304 @@
+package backtest
+
+import (
+	"automata/client/binance"
+	"automata/client/payeer"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Payeer does not allow cancelling an order during its first minute
+const minOrderLifetime = time.Minute
+
+/*
+** Shares
+ */
+
+type SharesShare struct {
+	ID                string
+	Action            payeer.Action
+	Pair              payeer.Pair
+	BinanceSymbol     binance.Symbol
+	Share             decimal.Decimal
+	BinancePriceRatio decimal.Decimal
+	LoopInterval      time.Duration
+}
+
+type shareOrder struct {
+	id    int
+	price decimal.Decimal
+	time  time.Time
+}
+
+// SharesStrategy replays the runShareLoop logic of cmd/payeer_shares: every share
+// keeps one order at the elevated Binance price and replaces it when that price moves.
+type SharesStrategy struct {
+	shares  []SharesShare
+	orders  map[string]shareOrder
+	lastRun map[string]time.Time
+}
+
+func NewSharesStrategy(shares []SharesShare) *SharesStrategy {
+	return &SharesStrategy{
+		shares:  shares,
+		orders:  make(map[string]shareOrder),
+		lastRun: make(map[string]time.Time),
+	}
+}
+
+func (s *SharesStrategy) Name() string {
+	return "shares"
+}
+
+func (s *SharesStrategy) Pairs() map[payeer.Pair]binance.Symbol {
+	pairs := make(map[payeer.Pair]binance.Symbol)
+	for _, share := range s.shares {
+		pairs[share.Pair] = share.BinanceSymbol
+	}
+	return pairs
+}
+
+func (s *SharesStrategy) Step(env *Env) {
+	now := env.Clock.Now()
+	for i := range s.shares {
+		share := &s.shares[i]
+		if now.Sub(s.lastRun[share.ID]) < share.LoopInterval {
+			continue
+		}
+		s.lastRun[share.ID] = now
+
+		order, ok := s.orders[share.ID]
+		if !ok {
+			s.tryPlaceOrder(env, share)
+			continue
+		}
+		if now.Sub(order.time) < minOrderLifetime {
+			continue
+		}
+		details := orderDetails(env, order.id)
+		if details == nil || details.Status == payeer.ORDER_STATUS_SUCCESS || details.Status == payeer.ORDER_STATUS_CANCELED {
+			delete(s.orders, share.ID)
+			continue
+		}
+		price, ok := s.resolvePrice(env, share)
+		if !ok || price.Equal(order.price) {
+			continue
+		}
+		rsp, err := env.Exchange.CancelOrder(&payeer.CancelOrderRequest{OrderId: order.id})
+		if err == nil && rsp.Success {
+			delete(s.orders, share.ID)
+		}
+	}
+}
+
+func (s *SharesStrategy) resolvePrice(env *Env, share *SharesShare) (decimal.Decimal, bool) {
+	ticker, ok := env.Tickers.Get(share.BinanceSymbol)
+	if !ok {
+		return decimal.Zero, false
+	}
+	book, ok := env.Books.Get(share.Pair)
+	if !ok {
+		return decimal.Zero, false
+	}
+	// Recorded books never contain our own simulated orders, so there is nothing to skip
+	return payeer.ResolvePriceWithElevation(env.Info.PairInfo(share.Pair), share.Action, share.BinancePriceRatio, &ticker, &book, nil), true
+}
+
+func (s *SharesStrategy) tryPlaceOrder(env *Env, share *SharesShare) {
+	price, ok := s.resolvePrice(env, share)
+	if !ok {
+		return
+	}
+	pairInfo := env.Info.PairInfo(share.Pair)
+	if pairInfo == nil {
+		return
+	}
+
+	mainAssetName := share.Pair.Quote()
+	roundMainAsset := pairInfo.RoundValue
+	if share.Action == payeer.ACTION_SELL {
+		mainAssetName = share.Pair.Base()
+		roundMainAsset = pairInfo.RoundAmount
+	}
+	balances, _ := env.Exchange.Balance()
+	balance, ok := balances.Balances[mainAssetName]
+	if !ok {
+		return
+	}
+	mainAssetQty := roundMainAsset(decimal.NewFromFloat(balance.Total).Mul(share.Share))
+	if decimal.NewFromFloat(balance.Available).LessThan(mainAssetQty) {
+		return
+	}
+	amount := pairInfo.AmountFor(share.Action, mainAssetQty, price)
+	if amount.LessThan(decimal.NewFromFloat(pairInfo.MinAmount)) {
+		return
+	}
+	rsp, err := env.Exchange.PlaceOrder(&payeer.PostOrderRequest{
+		Pair:   share.Pair,
+		Type:   payeer.ORDER_TYPE_LIMIT,
+		Action: share.Action,
+		Amount: amount.String(),
+		Price:  price.String(),
+	})
+	if err != nil || !rsp.Success {
+		slog.Debug("[Backtest] Share order rejected", "share", share.ID, "response", rsp, "error", err)
+		return
+	}
+	s.orders[share.ID] = shareOrder{id: rsp.OrderId, price: price, time: env.Clock.Now()}
+}
+
+/*
+** Value offset
+ */
+
+type ValueOffsetOptions struct {
+	Pair                   payeer.Pair
+	SelectorConfig         *payeer.PayeerPriceSelectorConfig
+	Amount                 decimal.Decimal
+	ReplacementValueOffset decimal.Decimal
+	BuyEnabled             bool
+	SellEnabled            bool
+	PlaceInterval          time.Duration
+}
+
+// ValueOffsetStrategy replays the PlaceOrderLoop and CheckAndCancelLoop of cmd/payeer.
+type ValueOffsetStrategy struct {
+	options   *ValueOffsetOptions
+	selector  *payeer.PayeerPriceSelector
+	orders    map[int]payeer.OrderParams
+	times     map[int]time.Time
+	lastPlace map[payeer.Action]time.Time
+}
+
+func NewValueOffsetStrategy(options *ValueOffsetOptions) *ValueOffsetStrategy {
+	if options.PlaceInterval == 0 {
+		options.PlaceInterval = time.Second * 2
+	}
+	return &ValueOffsetStrategy{
+		options:   options,
+		orders:    make(map[int]payeer.OrderParams),
+		times:     make(map[int]time.Time),
+		lastPlace: make(map[payeer.Action]time.Time),
+	}
+}
+
+func (s *ValueOffsetStrategy) Name() string {
+	return "value-offset"
+}
+
+func (s *ValueOffsetStrategy) Pairs() map[payeer.Pair]binance.Symbol {
+	return map[payeer.Pair]binance.Symbol{s.options.Pair: s.options.SelectorConfig.Symbol}
+}
+
+func (s *ValueOffsetStrategy) Step(env *Env) {
+	if s.selector == nil {
+		s.selector = payeer.NewPayeerPriceSelector(s.options.SelectorConfig, env.Tickers)
+		s.selector.SetInfo(env.Info)
+	}
+	book, ok := env.Books.Get(s.options.Pair)
+	if !ok {
+		return
+	}
+	if s.options.BuyEnabled {
+		s.placeOrder(env, payeer.ACTION_BUY, &book)
+	}
+	if s.options.SellEnabled {
+		s.placeOrder(env, payeer.ACTION_SELL, &book)
+	}
+	s.checkAndCancel(env, &book)
+}
+
+func (s *ValueOffsetStrategy) placeOrder(env *Env, action payeer.Action, book *payeer.PairsOrderInfo) {
+	now := env.Clock.Now()
+	if now.Sub(s.lastPlace[action]) < s.options.PlaceInterval {
+		return
+	}
+	s.lastPlace[action] = now
+	for _, params := range s.orders {
+		if params.Action == action {
+			return
+		}
+	}
+	pair := s.options.Pair
+	selection := s.selector.SelectPair(pair, action, book)
+	if !selection.Ok {
+		return
+	}
+	price := selection.Price
+	pairInfo := env.Info.PairInfo(pair)
+	amount := pairInfo.RoundAmount(s.options.Amount)
+	balances, _ := env.Exchange.Balance()
+	spent := pair.Base()
+	if action == payeer.ACTION_BUY {
+		spent = pair.Quote()
+	}
+	if decimal.NewFromFloat(balances.Balances[spent].Available).LessThan(pairInfo.Required(action, amount, price)) {
+		return
+	}
+	rsp, err := env.Exchange.PlaceOrder(&payeer.PostOrderRequest{
+		Pair:   pair,
+		Type:   payeer.ORDER_TYPE_LIMIT,
+		Action: action,
+		Amount: amount.String(),
+		Price:  price.String(),
+	})
+	if err != nil || !rsp.Success {
+		slog.Debug("[Backtest] Value offset order rejected", "action", action, "response", rsp, "error", err)
+		return
+	}
+	s.orders[rsp.OrderId] = rsp.Params
+	s.times[rsp.OrderId] = now
+}
+
+func (s *ValueOffsetStrategy) checkAndCancel(env *Env, book *payeer.PairsOrderInfo) {
+	now := env.Clock.Now()
+	ticker, ok := env.Tickers.Get(s.options.SelectorConfig.Symbol)
+	if !ok {
+		return
+	}
+	config := s.options.SelectorConfig
+	makerFee := env.Info.PairInfo(s.options.Pair).MakerFee()
+	for orderId, params := range s.orders {
+		if now.Sub(s.times[orderId]) < minOrderLifetime {
+			continue
+		}
+		details := orderDetails(env, orderId)
+		if details == nil || decimal.RequireFromString(details.ValueRemaining).IsZero() {
+			s.forget(orderId)
+			continue
+		}
+		price := decimal.RequireFromString(params.Price)
+		cancel := payeer.TopValueOffset(price, book, params.Action).GreaterThan(s.options.ReplacementValueOffset)
+		if params.Action == payeer.ACTION_BUY {
+			binPrice := decimal.RequireFromString(ticker.BidPrice)
+			cancel = cancel || !price.Div(binPrice).LessThan(config.Edge.Limit(params.Action, makerFee, config.BidMaxBinancePriceRatio))
+		} else {
+			binPrice := decimal.RequireFromString(ticker.AskPrice)
+			cancel = cancel || !price.Div(binPrice).GreaterThan(config.Edge.Limit(params.Action, makerFee, config.AskMinBinancePriceRatio))
+		}
+		if !cancel {
+			continue
+		}
+		rsp, err := env.Exchange.CancelOrder(&payeer.CancelOrderRequest{OrderId: orderId})
+		if err == nil && (rsp.Success || rsp.Error.Code == payeer.ERR_INVALID_STATUS_FOR_REFUND) {
+			s.forget(orderId)
+		}
+	}
+}
+
+func (s *ValueOffsetStrategy) forget(orderId int) {
+	delete(s.orders, orderId)
+	delete(s.times, orderId)
+}
+
+func orderDetails(env *Env, orderId int) *payeer.OrderDetails {
+	rsp, err := env.Exchange.OrderStatus(&payeer.OrderStatusRequest{OrderId: orderId})
+	if err != nil || !rsp.Success {
+		slog.Warn("[Backtest] Order status not found", "orderId", strconv.Itoa(orderId))
+		return nil
+	}
+	return &rsp.Order
+}
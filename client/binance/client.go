@@ -33,13 +33,15 @@ func NewClient() *Client {
 }
 
 func (b *Client) SubscribeTicker(symbol Symbol, interval time.Duration) chan OrderBookTickerStreamResult {
-	url := baseStreamUrl + "/ws/" + strings.ToLower(string(symbol)) + "@bookTicker"
+	stream := strings.ToLower(string(symbol)) + "@bookTicker"
+	url := baseStreamUrl + "/ws/" + stream
 	slog.Debug("[BinanceClient] SubscribeTicker called", "url", url)
 	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
 	if err != nil {
 		slog.Error("[BinanceClient] Failed to dial stream", "error", err)
 		os.Exit(1)
 	}
+	wsConnects.Inc(stream)
 	orders := make(chan OrderBookTickerStreamResult, 1024)
 	go func() {
 		now := time.Now()
@@ -50,6 +52,7 @@ func (b *Client) SubscribeTicker(symbol Symbol, interval time.Duration) chan Ord
 				os.Exit(1)
 			}
 			slog.Debug("[BinanceClient] Received ws message", "symbol", string(symbol), "message", string(msg))
+			wsMessages.Inc(stream)
 			var result OrderBookTickerStreamResult
 			err = json.Unmarshal(msg, &result)
 			if err != nil {
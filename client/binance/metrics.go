@@ -0,0 +1,24 @@
+package binance
+
+import (
+	"automata/metrics"
+	"time"
+)
+
+var (
+	wsConnects = metrics.NewCounterVec("binance_ws_connects_total", "Binance stream connections made, the first one included.", "stream")
+	wsMessages = metrics.NewCounterVec("binance_ws_messages_total", "Binance stream messages received.", "stream")
+	wsLag      = metrics.NewGaugeVec("binance_ws_lag_seconds", "Delay between the Binance event time and the receipt of the last message, for streams carrying one.", "stream")
+)
+
+func init() {
+	metrics.Register(wsConnects)
+	metrics.Register(wsMessages)
+	metrics.Register(wsLag)
+}
+
+func observeLag(stream string, eventTime int64) {
+	if eventTime > 0 {
+		wsLag.Set(time.Since(time.UnixMilli(eventTime)).Seconds(), stream)
+	}
+}
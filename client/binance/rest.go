@@ -0,0 +1,191 @@
+package binance
+
+import (
+	"automata/client"
+	httpclient "automata/http_client"
+	"automata/signer"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const baseRestUrl = "https://api.binance.com/api/v3"
+
+type OrderSide string
+
+const (
+	SIDE_BUY  OrderSide = "BUY"
+	SIDE_SELL OrderSide = "SELL"
+)
+
+type TimeInForce string
+
+const (
+	// TIME_IN_FORCE_IOC fills what it can at the limit price or better and
+	// expires the rest.
+	TIME_IN_FORCE_IOC TimeInForce = "IOC"
+	TIME_IN_FORCE_GTC TimeInForce = "GTC"
+)
+
+// OrderRequest is a limit order.
+type OrderRequest struct {
+	Symbol      Symbol
+	Side        OrderSide
+	TimeInForce TimeInForce
+	Quantity    decimal.Decimal
+	Price       decimal.Decimal
+	// ClientOrderId is echoed back in the response and the order queries.
+	ClientOrderId string
+}
+
+type OrderFill struct {
+	Price           string `json:"price"`
+	Qty             string `json:"qty"`
+	Commission      string `json:"commission"`
+	CommissionAsset string `json:"commissionAsset"`
+	TradeId         int64  `json:"tradeId"`
+}
+
+// OrderResponse is the FULL response of a new order.
+type OrderResponse struct {
+	Symbol              Symbol      `json:"symbol"`
+	OrderId             int64       `json:"orderId"`
+	ClientOrderId       string      `json:"clientOrderId"`
+	TransactTime        int64       `json:"transactTime"`
+	Price               string      `json:"price"`
+	OrigQty             string      `json:"origQty"`
+	ExecutedQty         string      `json:"executedQty"`
+	CummulativeQuoteQty string      `json:"cummulativeQuoteQty"`
+	Status              string      `json:"status"`
+	Side                OrderSide   `json:"side"`
+	Fills               []OrderFill `json:"fills"`
+}
+
+type BookTicker struct {
+	Symbol   Symbol `json:"symbol"`
+	BidPrice string `json:"bidPrice"`
+	BidQty   string `json:"bidQty"`
+	AskPrice string `json:"askPrice"`
+	AskQty   string `json:"askQty"`
+}
+
+type symbolFilter struct {
+	FilterType  string `json:"filterType"`
+	TickSize    string `json:"tickSize"`
+	MinQty      string `json:"minQty"`
+	StepSize    string `json:"stepSize"`
+	MinNotional string `json:"minNotional"`
+}
+
+type exchangeInfoResponse struct {
+	Symbols []struct {
+		Symbol     Symbol         `json:"symbol"`
+		BaseAsset  string         `json:"baseAsset"`
+		QuoteAsset string         `json:"quoteAsset"`
+		Filters    []symbolFilter `json:"filters"`
+	} `json:"symbols"`
+}
+
+// SymbolFilters are the trading rules of a symbol that an order has to meet.
+type SymbolFilters struct {
+	Symbol      Symbol
+	BaseAsset   string
+	QuoteAsset  string
+	TickSize    decimal.Decimal
+	MinQty      decimal.Decimal
+	StepSize    decimal.Decimal
+	MinNotional decimal.Decimal
+}
+
+// RestClient places orders through the signed REST API. Unlike the MEXC
+// client it does not check orders against the risk limits, risk depends on
+// this package; callers check them before PlaceOrder.
+type RestClient struct {
+	httpClient *httpclient.HttpClient
+	secret     string
+}
+
+func NewRestClient(apiKey string, secret string) *RestClient {
+	headers := make(http.Header)
+	headers.Set("X-MBX-APIKEY", apiKey)
+	httpClient := httpclient.NewHttpClient(baseRestUrl)
+	httpClient.SetHeaders(headers)
+	return &RestClient{
+		httpClient: httpClient,
+		secret:     secret,
+	}
+}
+
+// PlaceOrder sends a limit order and returns its executions.
+func (c *RestClient) PlaceOrder(order *OrderRequest) (*OrderResponse, error) {
+	qb := client.NewQueryBuilder()
+	qb.Add("symbol", order.Symbol)
+	qb.Add("side", order.Side)
+	qb.Add("type", "LIMIT")
+	qb.Add("timeInForce", order.TimeInForce)
+	qb.Add("quantity", order.Quantity.String())
+	qb.Add("price", order.Price.String())
+	if order.ClientOrderId != "" {
+		qb.Add("newClientOrderId", order.ClientOrderId)
+	}
+	qb.Add("newOrderRespType", "FULL")
+	var rsp OrderResponse
+	if err := c.httpClient.Post("/order?"+c.signQuery(qb), &rsp); err != nil {
+		slog.Error("[BinanceClient] Failed to place order", "error", err)
+		return nil, err
+	}
+	slog.Debug("[BinanceClient] Order placed", "order", rsp)
+	return &rsp, nil
+}
+
+// BookTicker returns the best bid and ask of symbol.
+func (c *RestClient) BookTicker(symbol Symbol) (*BookTicker, error) {
+	var ticker BookTicker
+	if err := c.httpClient.Get("/ticker/bookTicker?symbol="+string(symbol), &ticker); err != nil {
+		slog.Error("[BinanceClient] Failed to get book ticker", "symbol", symbol, "error", err)
+		return nil, err
+	}
+	return &ticker, nil
+}
+
+// Filters returns the tick size, lot size and minimum notional of symbol.
+func (c *RestClient) Filters(symbol Symbol) (*SymbolFilters, error) {
+	var info exchangeInfoResponse
+	if err := c.httpClient.Get("/exchangeInfo?symbol="+string(symbol), &info); err != nil {
+		slog.Error("[BinanceClient] Failed to get exchange info", "symbol", symbol, "error", err)
+		return nil, err
+	}
+	filters := &SymbolFilters{Symbol: symbol}
+	for _, s := range info.Symbols {
+		if s.Symbol != symbol {
+			continue
+		}
+		filters.BaseAsset, filters.QuoteAsset = s.BaseAsset, s.QuoteAsset
+		for _, f := range s.Filters {
+			switch f.FilterType {
+			case "PRICE_FILTER":
+				filters.TickSize, _ = decimal.NewFromString(f.TickSize)
+			case "LOT_SIZE":
+				filters.MinQty, _ = decimal.NewFromString(f.MinQty)
+				filters.StepSize, _ = decimal.NewFromString(f.StepSize)
+			case "NOTIONAL", "MIN_NOTIONAL":
+				filters.MinNotional, _ = decimal.NewFromString(f.MinNotional)
+			}
+		}
+	}
+	if filters.BaseAsset == "" {
+		return nil, fmt.Errorf("unknown symbol %s", symbol)
+	}
+	return filters, nil
+}
+
+func (c *RestClient) signQuery(qb *client.QueryBuilder) string {
+	qb.Add("recvWindow", 5000)
+	qb.Add("timestamp", strconv.FormatInt(time.Now().UnixMilli(), 10))
+	qb.Add("signature", signer.Sign([]byte(qb.String()), []byte(c.secret)))
+	return qb.String()
+}
@@ -0,0 +1,71 @@
+package binance
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const reconnectDelay = time.Second * 2
+
+func (b *Client) SubscribeTrades(symbol Symbol) chan TradeStreamResult {
+	trades := make(chan TradeStreamResult, 1024)
+	stream := strings.ToLower(string(symbol)) + "@trade"
+	subscribeStream(stream, func(msg []byte) {
+		var result TradeStreamResult
+		if err := json.Unmarshal(msg, &result); err != nil {
+			slog.Warn("[BinanceClient] Failed to unmarshal ws message as TradeStreamResult:", "error", err)
+			return
+		}
+		observeLag(stream, result.EventTime)
+		trades <- result
+	})
+	return trades
+}
+
+// SubscribeDepth streams the top levels (5, 10 or 20) of the book every 100ms.
+func (b *Client) SubscribeDepth(symbol Symbol, levels int) chan PartialDepthStreamResult {
+	depths := make(chan PartialDepthStreamResult, 1024)
+	stream := strings.ToLower(string(symbol)) + "@depth" + strconv.Itoa(levels) + "@100ms"
+	subscribeStream(stream, func(msg []byte) {
+		var result PartialDepthStreamResult
+		if err := json.Unmarshal(msg, &result); err != nil {
+			slog.Warn("[BinanceClient] Failed to unmarshal ws message as PartialDepthStreamResult:", "error", err)
+			return
+		}
+		depths <- result
+	})
+	return depths
+}
+
+// subscribeStream reads the raw stream and redials it whenever the connection breaks.
+func subscribeStream(stream string, handle func(msg []byte)) {
+	url := baseStreamUrl + "/ws/" + stream
+	go func() {
+		for {
+			slog.Debug("[BinanceClient] Dialing stream", "url", url)
+			conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+			if err != nil {
+				slog.Error("[BinanceClient] Failed to dial stream. Retrying...", "stream", stream, "error", err)
+				time.Sleep(reconnectDelay)
+				continue
+			}
+			wsConnects.Inc(stream)
+			for {
+				_, msg, err := conn.ReadMessage()
+				if err != nil {
+					slog.Error("[BinanceClient] Failed to read ws message. Reconnecting...", "stream", stream, "error", err)
+					break
+				}
+				wsMessages.Inc(stream)
+				handle(msg)
+			}
+			conn.Close()
+			time.Sleep(reconnectDelay)
+		}
+	}()
+}
@@ -72,3 +72,26 @@ type OrderBookTickerStreamResult struct {
 	AskPrice    string `json:"a"`
 	AskQuantity string `json:"A"`
 }
+
+// Trade Streams
+//
+// Stream Name: <symbol>@trade
+type TradeStreamResult struct {
+	EventType    string `json:"e"`
+	EventTime    int64  `json:"E"`
+	Symbol       Symbol `json:"s"`
+	TradeId      int64  `json:"t"`
+	Price        string `json:"p"`
+	Quantity     string `json:"q"`
+	TradeTime    int64  `json:"T"`
+	IsBuyerMaker bool   `json:"m"`
+}
+
+// Partial Book Depth Streams
+//
+// Stream Name: <symbol>@depth<levels>@100ms
+type PartialDepthStreamResult struct {
+	LastUpdateId int64       `json:"lastUpdateId"`
+	Bids         [][2]string `json:"bids"`
+	Asks         [][2]string `json:"asks"`
+}
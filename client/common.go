@@ -3,7 +3,7 @@ package client
 import (
 	"encoding/json"
 	"io"
-	"log"
+	"log/slog"
 )
 
 func readJson(r io.Reader, data any) error {
@@ -11,10 +11,7 @@ func readJson(r io.Reader, data any) error {
 	if err != nil {
 		return err
 	}
-	log.Println("[JsonReader] Reading response: " + string(body))
-
-	// decoder := json.NewDecoder(r)
-	// err := decoder.Decode(data)
+	slog.Debug("[JsonReader] Reading response", "body", string(body))
 
 	err = json.Unmarshal(body, data)
 	if err != nil {
@@ -3,6 +3,7 @@ package mexc
 import (
 	"automata/client"
 	httpclient "automata/http_client"
+	"automata/risk"
 	"encoding/json"
 	"log/slog"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/shopspring/decimal"
 )
 
 const (
@@ -23,6 +25,8 @@ const (
 )
 
 type Client struct {
+	// Risk, when set, checks every order before it is sent.
+	Risk               *risk.Manager
 	wsConn             *websocket.Conn
 	apiKey             string
 	httpClient         *httpclient.HttpClient
@@ -41,6 +45,7 @@ func NewClient(apiKey string, secret string) *Client {
 	headers.Set("Content-Type", "application/json")
 	httpClient := httpclient.NewHttpClient("https://api.mexc.com/api/v3")
 	httpClient.SetHeaders(headers)
+	httpClient.Observe = observeRequest
 	qm := newQueryMaker(secret)
 	lkm := &listenKeyManager{
 		httpClient: httpClient,
@@ -62,6 +67,7 @@ func NewClient(apiKey string, secret string) *Client {
 func (m *Client) Start() {
 	m.lkm.Start()
 	m.wsConnect()
+	wsConnects.Inc("private")
 	params := []string{
 		wsDealsEndpoint,
 		wsBalanceEndpoint,
@@ -89,6 +95,7 @@ func (m *Client) Start() {
 				slog.Warn("[MexcClient] Failed to unmarshal ws message as wsResponse:", "error", err)
 				continue
 			}
+			observeMessage("private", wsResponse.Endpoint, wsResponse.Timestamp)
 			switch wsResponse.Endpoint {
 			case "":
 				continue
@@ -255,6 +262,9 @@ func (m *Client) Balances() (map[client.Symbol]client.Balance, error) {
 }
 
 func (m *Client) PlaceOrder(order *client.Order) error {
+	if err := m.Risk.CheckOrder(riskOrder(order)); err != nil {
+		return err
+	}
 	query := m.qm.getOrderQuery(order)
 	err := m.httpClient.Post("/order?"+query, &order)
 	if err != nil {
@@ -265,6 +275,22 @@ func (m *Client) PlaceOrder(order *client.Order) error {
 	return nil
 }
 
+func riskOrder(order *client.Order) risk.Order {
+	base, quote, _ := risk.SplitSymbol(string(order.Symbol))
+	side := risk.SIDE_BUY
+	if order.Side == client.SellOrderSide {
+		side = risk.SIDE_SELL
+	}
+	return risk.Order{
+		Venue:  "mexc",
+		Base:   base,
+		Quote:  quote,
+		Side:   side,
+		Amount: decimal.NewFromFloat(order.OrigQty),
+		Price:  decimal.NewFromFloat(order.Price),
+	}
+}
+
 func (m *Client) OrderBookTicker(symbol client.Symbol) (*client.OrderBookTicker, error) {
 	var tickerJson orderBookTicker
 	err := m.httpClient.Get("/ticker/bookTicker?"+m.qm.getSymbolQuery(symbol), &tickerJson)
@@ -281,6 +307,34 @@ func (m *Client) OrderBookTicker(symbol client.Symbol) (*client.OrderBookTicker,
 	return ticker, nil
 }
 
+// QueryOrder returns the executed quantity and value of an order.
+func (m *Client) QueryOrder(symbol client.Symbol, orderId string) (*client.OrderStatus, error) {
+	var statusJson orderStatus
+	err := m.httpClient.Get("/order?"+m.qm.getQueryOrderQuery(symbol, orderId), &statusJson)
+	if err != nil {
+		slog.Error("[MexcClient] Failed to query order", "error", err)
+		return nil, err
+	}
+	status, err := statusJson.toOrderStatus()
+	if err != nil {
+		slog.Error("[MexcClient] Failed to convert order status json to struct", "error", err)
+		return nil, err
+	}
+	return status, nil
+}
+
+// SymbolRules returns the price and quantity steps and the minimum order of
+// symbol.
+func (m *Client) SymbolRules(symbol client.Symbol) (*client.SymbolRules, error) {
+	var info exchangeInfoResponse
+	err := m.httpClient.Get("/exchangeInfo?symbol="+string(symbol), &info)
+	if err != nil {
+		slog.Error("[MexcClient] Failed to get exchange info", "error", err)
+		return nil, err
+	}
+	return info.toSymbolRules(symbol)
+}
+
 func (m *Client) CancelOrder(symbol client.Symbol, orderId string) error {
 	var order *client.Order
 	err := m.httpClient.Delete("/order?"+m.qm.getCancelOrderQuery(symbol, orderId), &order)
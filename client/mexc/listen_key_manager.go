@@ -2,7 +2,8 @@ package mexc
 
 import (
 	httpclient "automata/http_client"
-	"log"
+	"log/slog"
+	"os"
 	"sync"
 	"time"
 )
@@ -16,19 +17,14 @@ type listenKeyManager struct {
 
 func (l *listenKeyManager) setListenKey() error {
 	listenKey, err := l.getListenKey()
-	if err != nil {
-		log.Fatal("failed to post listen key", err)
-	}
-	if listenKey == "" {
+	if err == nil && listenKey == "" {
 		listenKey, err = l.postListenKey()
-		if err != nil {
-			log.Fatal("failed to post listen key", err)
-		}
-	} else {
+	} else if err == nil {
 		err = l.putListenKey(listenKey)
-		if err != nil {
-			log.Fatal("failed to put listen key", err)
-		}
+	}
+	if err != nil {
+		slog.Error("[ListenKeyManager] Failed to set up listen key, exiting", "error", err)
+		os.Exit(1)
 	}
 	l.mu.Lock()
 	defer l.mu.Unlock()
@@ -58,7 +54,7 @@ func (l *listenKeyManager) getListenKey() (string, error) {
 	}
 	err := l.httpClient.Get("/userDataStream?"+l.qm.defaultSignature(), &response)
 	if err != nil {
-		log.Println("[ListenKeyManager] Failed to get listen keys: ", response.ListenKey)
+		slog.Error("[ListenKeyManager] Failed to get listen keys", "error", err)
 		return "", err
 	}
 	if len(response.ListenKey) == 0 {
@@ -73,10 +69,10 @@ func (l *listenKeyManager) postListenKey() (string, error) {
 	}
 	err := l.httpClient.Post("/userDataStream?"+l.qm.defaultSignature(), &response)
 	if err != nil {
-		log.Println("[ListenKeyManager] Failed to create listen key: ", response.ListenKey)
+		slog.Error("[ListenKeyManager] Failed to create listen key", "error", err)
 		return "", err
 	}
-	log.Println("[ListenKeyManager] Listen key created: ", response.ListenKey)
+	slog.Info("[ListenKeyManager] Listen key created")
 	return response.ListenKey, nil
 }
 
@@ -86,9 +82,9 @@ func (l *listenKeyManager) putListenKey(key string) error {
 	}
 	err := l.httpClient.Put("/userDataStream?"+l.qm.getListenKeyQuery(key), &response)
 	if err != nil {
-		log.Println("[ListenKeyManager] Failed to put listen key: ", err)
+		slog.Error("[ListenKeyManager] Failed to put listen key", "error", err)
 		return err
 	}
-	log.Println("[ListenKeyManager] Listen key made keep-alive: ", response.ListenKey)
+	slog.Debug("[ListenKeyManager] Listen key made keep-alive")
 	return nil
 }
@@ -0,0 +1,47 @@
+package mexc
+
+import (
+	"automata/metrics"
+	"strings"
+	"time"
+)
+
+var (
+	requestDuration = metrics.NewHistogramVec("mexc_request_duration_seconds", "Latency of MEXC REST requests by endpoint.", metrics.LatencyBuckets, "method", "endpoint")
+	requestErrors   = metrics.NewCounterVec("mexc_request_errors_total", "Failed MEXC REST requests by endpoint.", "method", "endpoint")
+	wsConnects      = metrics.NewCounterVec("mexc_ws_connects_total", "MEXC websocket connections made, the first one included.", "stream")
+	wsMessages      = metrics.NewCounterVec("mexc_ws_messages_total", "MEXC websocket messages by channel.", "stream", "channel")
+	wsLag           = metrics.NewGaugeVec("mexc_ws_lag_seconds", "Delay between the MEXC timestamp and the receipt of the last message by channel.", "stream", "channel")
+)
+
+func init() {
+	metrics.Register(requestDuration)
+	metrics.Register(requestErrors)
+	metrics.Register(wsConnects)
+	metrics.Register(wsMessages)
+	metrics.Register(wsLag)
+}
+
+func observeRequest(method, endpoint string, started time.Time, err error) {
+	requestDuration.Since(started, method, endpoint)
+	if err != nil {
+		requestErrors.Inc(method, endpoint)
+	}
+}
+
+// observeMessage counts a message of endpoint; the channel label drops the
+// symbol and parameters so that it stays bounded.
+func observeMessage(stream, endpoint string, timestamp int64) {
+	if endpoint == "" {
+		return
+	}
+	channel, _, _ := strings.Cut(endpoint, "@")
+	if rest, ok := strings.CutPrefix(endpoint, channel+"@"); ok {
+		name, _, _ := strings.Cut(rest, "@")
+		channel += "@" + name
+	}
+	wsMessages.Inc(stream, channel)
+	if timestamp > 0 {
+		wsLag.Set(time.Since(time.UnixMilli(timestamp)).Seconds(), stream, channel)
+	}
+}
@@ -0,0 +1,147 @@
+package mexc
+
+import (
+	"automata/client"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const reconnectDelay = time.Second * 2
+
+// PublicStream subscribes to market data channels which do not require a listen key.
+type PublicStream struct {
+	symbols      []client.Symbol
+	depthLevel   int
+	TickerStream chan *client.OrderBookTicker
+	DealStream   chan *client.Deal
+	DepthStream  chan *client.PartialDepth
+}
+
+func NewPublicStream(symbols []client.Symbol, depthLevel int) *PublicStream {
+	return &PublicStream{
+		symbols:      symbols,
+		depthLevel:   depthLevel,
+		TickerStream: make(chan *client.OrderBookTicker, 1024),
+		DealStream:   make(chan *client.Deal, 1024),
+		DepthStream:  make(chan *client.PartialDepth, 1024),
+	}
+}
+
+func (p *PublicStream) Start() {
+	params := []string{}
+	for _, symbol := range p.symbols {
+		params = append(params,
+			"spot@public.bookTicker.v3.api@"+string(symbol),
+			"spot@public.deals.v3.api@"+string(symbol),
+			getPartialBookDepthStreamEndpoint(symbol, p.depthLevel),
+		)
+	}
+	go func() {
+		for {
+			p.listen(params)
+			time.Sleep(reconnectDelay)
+		}
+	}()
+}
+
+func (p *PublicStream) listen(params []string) {
+	conn, _, err := websocket.DefaultDialer.Dial(baseWsUrl, nil)
+	if err != nil {
+		slog.Error("[MexcPublicStream] Failed to dial ws. Retrying...", "error", err)
+		return
+	}
+	defer conn.Close()
+	wsConnects.Inc("public")
+	if err := conn.WriteJSON(map[string]any{"method": "SUBSCRIPTION", "params": params}); err != nil {
+		slog.Error("[MexcPublicStream] Failed to subscribe. Retrying...", "error", err)
+		return
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(time.Second * 29)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := conn.WriteJSON(map[string]string{"method": "PING"}); err != nil {
+					slog.Error("[MexcPublicStream] Failed to ping ws:", "error", err)
+					return
+				}
+			}
+		}
+	}()
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			slog.Error("[MexcPublicStream] Failed to read ws message. Reconnecting...", "error", err)
+			return
+		}
+		var wsResponse wsResponse
+		if err := json.Unmarshal(message, &wsResponse); err != nil {
+			slog.Warn("[MexcPublicStream] Failed to unmarshal ws message as wsResponse:", "error", err)
+			continue
+		}
+		observeMessage("public", wsResponse.Endpoint, wsResponse.Timestamp)
+		switch {
+		case wsResponse.Endpoint == "":
+			continue
+		case strings.HasPrefix(wsResponse.Endpoint, "spot@public.bookTicker.v3.api@"):
+			p.handleTicker(message)
+		case strings.HasPrefix(wsResponse.Endpoint, "spot@public.deals.v3.api@"):
+			p.handleDeals(message)
+		case strings.HasPrefix(wsResponse.Endpoint, "spot@public.limit.depth.v3.api@"):
+			p.handleDepth(message)
+		}
+	}
+}
+
+func (p *PublicStream) handleTicker(message []byte) {
+	var tickerResponse wsTickerResponse
+	if err := json.Unmarshal(message, &tickerResponse); err != nil {
+		slog.Warn("[MexcPublicStream] Failed to unmarshal wsTickerResponse:", "error", err)
+		return
+	}
+	ticker, err := tickerResponse.toTicker()
+	if err != nil || ticker == nil {
+		slog.Warn("[MexcPublicStream] Failed to convert ticker to client.OrderBookTicker:", "error", err)
+		return
+	}
+	p.TickerStream <- ticker
+}
+
+func (p *PublicStream) handleDeals(message []byte) {
+	var dealsMsg wsPublicDealsMessage
+	if err := json.Unmarshal(message, &dealsMsg); err != nil {
+		slog.Warn("[MexcPublicStream] Failed to unmarshal wsPublicDealsMessage:", "error", err)
+		return
+	}
+	deals, err := dealsMsg.toDeals()
+	if err != nil {
+		slog.Warn("[MexcPublicStream] Failed to convert deals to client.Deal:", "error", err)
+		return
+	}
+	for i := range deals {
+		p.DealStream <- &deals[i]
+	}
+}
+
+func (p *PublicStream) handleDepth(message []byte) {
+	var depthMsg wsPartialDepthMessage
+	if err := json.Unmarshal(message, &depthMsg); err != nil || depthMsg.Timestamp == 0 {
+		slog.Warn("[MexcPublicStream] Failed to unmarshal wsPartialDepthMessage:", "error", err)
+		return
+	}
+	depth, err := depthMsg.toPartialDepth()
+	if err != nil {
+		slog.Warn("[MexcPublicStream] Failed to convert json to PartialDepth:", "error", err)
+		return
+	}
+	p.DepthStream <- depth
+}
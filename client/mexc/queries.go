@@ -40,6 +40,13 @@ func (q *queryMaker) getSymbolQuery(symbol client.Symbol) string {
 	return q.signQuery(qb)
 }
 
+func (q *queryMaker) getQueryOrderQuery(symbol client.Symbol, orderId string) string {
+	qb := client.NewQueryBuilder()
+	qb.Add("symbol", symbol)
+	qb.Add("orderId", orderId)
+	return q.signQuery(qb)
+}
+
 func (q *queryMaker) getCancelOrderQuery(symbol client.Symbol, orderId string) string {
 	qb := client.NewQueryBuilder()
 	qb.Add("symbol", symbol)
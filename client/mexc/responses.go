@@ -2,6 +2,8 @@ package mexc
 
 import (
 	"automata/client"
+	"fmt"
+	"math"
 	"strconv"
 	"time"
 )
@@ -9,6 +11,70 @@ import (
 type accountResponse struct {
 	Balances []client.Balance `json:"balances"`
 }
+type orderStatus struct {
+	Symbol              client.Symbol `json:"symbol"`
+	OrderId             string        `json:"orderId"`
+	Status              string        `json:"status"`
+	ExecutedQty         string        `json:"executedQty"`
+	CummulativeQuoteQty string        `json:"cummulativeQuoteQty"`
+}
+
+func (o *orderStatus) toOrderStatus() (*client.OrderStatus, error) {
+	executedQty, err := strconv.ParseFloat(o.ExecutedQty, 64)
+	if err != nil {
+		return nil, err
+	}
+	cummulativeQuoteQty, err := strconv.ParseFloat(o.CummulativeQuoteQty, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &client.OrderStatus{
+		Symbol:              o.Symbol,
+		Id:                  o.OrderId,
+		Status:              o.Status,
+		ExecutedQty:         executedQty,
+		CummulativeQuoteQty: cummulativeQuoteQty,
+	}, nil
+}
+
+type exchangeInfoResponse struct {
+	Symbols []struct {
+		Symbol               client.Symbol `json:"symbol"`
+		BaseAsset            string        `json:"baseAsset"`
+		QuoteAsset           string        `json:"quoteAsset"`
+		BaseAssetPrecision   int           `json:"baseAssetPrecision"`
+		QuotePrecision       int           `json:"quotePrecision"`
+		BaseSizePrecision    string        `json:"baseSizePrecision"`
+		QuoteAmountPrecision string        `json:"quoteAmountPrecision"`
+	} `json:"symbols"`
+}
+
+func (e *exchangeInfoResponse) toSymbolRules(symbol client.Symbol) (*client.SymbolRules, error) {
+	for _, s := range e.Symbols {
+		if s.Symbol != symbol {
+			continue
+		}
+		minAmount, err := strconv.ParseFloat(s.BaseSizePrecision, 64)
+		if err != nil {
+			return nil, err
+		}
+		minNotional, err := strconv.ParseFloat(s.QuoteAmountPrecision, 64)
+		if err != nil {
+			return nil, err
+		}
+		return &client.SymbolRules{
+			Symbol:      s.Symbol,
+			BaseAsset:   s.BaseAsset,
+			QuoteAsset:  s.QuoteAsset,
+			PriceTick:   math.Pow10(-s.QuotePrecision),
+			AmountStep:  math.Pow10(-s.BaseAssetPrecision),
+			MinAmount:   minAmount,
+			MinNotional: minNotional,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown symbol %s", symbol)
+}
+
 type orderBookTicker struct {
 	Symbol      client.Symbol `json:"symbol"`
 	BidPrice    string        `json:"bidPrice"`
@@ -97,7 +163,8 @@ type wsDealResponse struct {
 }
 
 type wsResponse struct {
-	Endpoint string `json:"c"`
+	Endpoint  string `json:"c"`
+	Timestamp int64  `json:"t"`
 }
 
 type wsTicker struct {
@@ -137,9 +204,48 @@ func (w *wsTickerResponse) toTicker() (*client.OrderBookTicker, error) {
 		BidQuantity: bidQuantity,
 		AskPrice:    askPrice,
 		AskQuantity: askQuantity,
+		Timestamp:   time.UnixMilli(int64(w.Timestamp)),
 	}, nil
 }
 
+// WS PUBLIC DEALS
+type wsPublicDeal struct {
+	TradeType int    `json:"S"`
+	Price     string `json:"p"`
+	Quantity  string `json:"v"`
+	TradeTime int64  `json:"t"`
+}
+
+type wsPublicDealsMessage struct {
+	Endpoint string        `json:"c"`
+	Symbol   client.Symbol `json:"s"`
+	Data     struct {
+		Deals []wsPublicDeal `json:"deals"`
+	} `json:"d"`
+}
+
+func (m *wsPublicDealsMessage) toDeals() ([]client.Deal, error) {
+	deals := make([]client.Deal, 0, len(m.Data.Deals))
+	for _, d := range m.Data.Deals {
+		price, err := strconv.ParseFloat(d.Price, 64)
+		if err != nil {
+			return nil, err
+		}
+		quantity, err := strconv.ParseFloat(d.Quantity, 64)
+		if err != nil {
+			return nil, err
+		}
+		deals = append(deals, client.Deal{
+			Symbol:    m.Symbol,
+			TradeType: d.TradeType,
+			Price:     price,
+			Quantity:  quantity,
+			TradeTime: time.UnixMilli(d.TradeTime),
+		})
+	}
+	return deals, nil
+}
+
 // WS ACCOUNT UPDATES
 
 type wsAccountUpdate struct {
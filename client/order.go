@@ -18,6 +18,9 @@ type OrderType string
 const (
 	LimitOrderType  OrderType = "LIMIT"
 	MarketOrderType OrderType = "MARKET"
+	// ImmediateOrCancelOrderType fills what it can at the limit price or
+	// better and cancels the rest.
+	ImmediateOrCancelOrderType OrderType = "IMMEDIATE_OR_CANCEL"
 )
 
 type jsonorder struct {
@@ -0,0 +1,25 @@
+package payeer
+
+type PublicApi interface {
+	Info() (*InfoResponse, error)
+	Orders(pairs []Pair) (*OrdersResponse, error)
+	Trades(pairs []Pair) (*TradesResponse, error)
+	Tickers(pairs []Pair) (*TickersResponse, error)
+}
+
+// PrivateApi is the set of signed account methods. It is implemented by Client
+// for live trading and by paper.Exchange for simulated execution.
+type PrivateApi interface {
+	PlaceOrder(req *PostOrderRequest) (*PostOrderResponse, error)
+	OrderStatus(req *OrderStatusRequest) (*OrderStatusResponse, error)
+	Balance() (*BalanceResponse, error)
+	CancelOrder(req *CancelOrderRequest) (*CancelOrderResponse, error)
+	MyOrders(req *MyOrdersRequest) (*MyOrdersResponse, error)
+}
+
+type Api interface {
+	PublicApi
+	PrivateApi
+}
+
+var _ Api = (*Client)(nil)
@@ -0,0 +1,86 @@
+package payeer
+
+import (
+	"automata/client/binance"
+	"log/slog"
+
+	"github.com/shopspring/decimal"
+)
+
+// STAGE_ELEVATION names ResolvePriceWithElevation in a Selection.
+const STAGE_ELEVATION = "resolvePriceWithElevation"
+
+type PriceAmount struct {
+	Price  decimal.Decimal `json:"price"`
+	Amount decimal.Decimal `json:"amount"`
+}
+
+// ResolvePriceWithElevation multiplies the binance price by the ratio and moves it
+// one tick ahead of the first book level behind it, skipping our own orders.
+// The price is rounded to the tick and price limits of pairInfo, which may be
+// nil when the pair info is unknown.
+func ResolvePriceWithElevation(
+	pairInfo *PairInfo,
+	action Action,
+	binancePriceRatio decimal.Decimal,
+	binanceTickersData *binance.OrderBookTickerStreamResult,
+	ordersData *PairsOrderInfo,
+	myOrders []PriceAmount,
+) decimal.Decimal {
+	var binancePrice decimal.Decimal
+	var orders []OrdersOrder
+	if action == ACTION_BUY {
+		orders = ordersData.Bids
+		binancePrice = decimal.RequireFromString(binanceTickersData.BidPrice)
+	} else {
+		orders = ordersData.Asks
+		binancePrice = decimal.RequireFromString(binanceTickersData.AskPrice)
+	}
+
+	price := binancePrice.Mul(binancePriceRatio)
+
+	slog.Info("[PayeerPriceSelector] binance price multiplied", "action", action, "original", binancePrice.String(), "ratio", binancePriceRatio.String(), "multiplied", price.String())
+
+	var priceFound func(orderPrice decimal.Decimal) bool
+	var elevate func(orderPrice decimal.Decimal) decimal.Decimal
+
+	shouldSkip := func(price decimal.Decimal, amount decimal.Decimal) bool {
+		for _, myOrder := range myOrders {
+			if price.Equal(myOrder.Price) && amount.Equal(myOrder.Amount) {
+				return true
+			}
+		}
+		return false
+	}
+
+	tick := pairInfo.Tick()
+	if action == ACTION_BUY {
+		priceFound = func(orderPrice decimal.Decimal) bool { return orderPrice.LessThan(price) }
+		elevate = func(orderPrice decimal.Decimal) decimal.Decimal { return orderPrice.Add(tick) }
+	} else {
+		priceFound = func(orderPrice decimal.Decimal) bool { return orderPrice.GreaterThan(price) }
+		elevate = func(orderPrice decimal.Decimal) decimal.Decimal { return orderPrice.Sub(tick) }
+	}
+
+	for i, order := range orders {
+		orderPrice := decimal.RequireFromString(order.Price)
+		orderAmount := decimal.RequireFromString(order.Amount)
+		if !shouldSkip(orderPrice, orderAmount) && priceFound(orderPrice) {
+			price = elevate(orderPrice)
+			for j := i - 1; j >= 0; j-- {
+				topPrice := decimal.RequireFromString(orders[j].Price)
+				topAmount := decimal.RequireFromString(orders[j].Amount)
+				if shouldSkip(topPrice, topAmount) {
+					continue
+				}
+				if topPrice.Equal(price) {
+					price = elevate(price)
+				} else {
+					break
+				}
+			}
+			break
+		}
+	}
+	return pairInfo.RoundPrice(action, price)
+}
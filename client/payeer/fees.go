@@ -0,0 +1,107 @@
+package payeer
+
+import (
+	"log/slog"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	one     = decimal.NewFromInt(1)
+	hundred = decimal.NewFromInt(100)
+	bps     = decimal.NewFromInt(10000)
+)
+
+// Edge is the net edge a quote has to earn once its fill is hedged on the
+// reference venue, after the Payeer maker fee and the fee of the hedge.
+type Edge struct {
+	TargetBps decimal.Decimal `json:"targetBps"`
+	// HedgeFeePercent is the taker fee of the hedge venue. It is not configured
+	// with the edge, strategies set it from the hedge config with WithHedgeFee;
+	// journal entries keep it to replay the ratio.
+	HedgeFeePercent decimal.Decimal `json:"hedgeFeePercent,omitempty"`
+}
+
+// WithHedgeFee returns a copy of e with the hedge fee set; nil stays nil.
+func (e *Edge) WithHedgeFee(percent decimal.Decimal) *Edge {
+	if e == nil {
+		return nil
+	}
+	copied := *e
+	copied.HedgeFeePercent = percent
+	return &copied
+}
+
+// Ratio returns the price boundary relative to the reference price: the
+// highest bid over the reference bid, or the lowest ask over the reference ask,
+// that still earns the target edge. Both venues take their fee from the asset
+// received. A buy at P receives 1-makerFee base, hedged by a sell at B it
+// earns B(1-makerFee)(1-hedgeFee) - P; a sell at P receives P(1-makerFee), the
+// hedge buys 1/(1-hedgeFee) at A to get the base back and it earns
+// P(1-makerFee) - A/(1-hedgeFee). Either has to reach TargetBps of P.
+func (e *Edge) Ratio(action Action, makerFee decimal.Decimal) decimal.Decimal {
+	hedgeFee := e.HedgeFeePercent.Div(hundred)
+	edge := e.TargetBps.Div(bps)
+	if action == ACTION_SELL {
+		return one.Div(one.Sub(hedgeFee).Mul(one.Sub(makerFee).Sub(edge)))
+	}
+	return one.Sub(makerFee).Mul(one.Sub(hedgeFee)).Div(one.Add(edge))
+}
+
+// Limit returns the stricter of ratio and the edge ratio: the lower for a buy
+// and the higher for a sell. A nil e returns ratio.
+func (e *Edge) Limit(action Action, makerFee decimal.Decimal, ratio decimal.Decimal) decimal.Decimal {
+	if e == nil {
+		return ratio
+	}
+	edgeRatio := e.Ratio(action, makerFee)
+	limit := decimal.Min(ratio, edgeRatio)
+	if action == ACTION_SELL {
+		limit = decimal.Max(ratio, edgeRatio)
+	}
+	if !limit.Equal(ratio) {
+		slog.Debug("[PayeerPriceSelector] ratio tightened to the target edge", "action", action, "ratio", ratio.String(), "edgeRatio", edgeRatio.String(), "targetBps", e.TargetBps.String())
+	}
+	return limit
+}
+
+// MakerFee returns the maker fee of the pair as a fraction, zero when the pair
+// info is unknown.
+func (p *PairInfo) MakerFee() decimal.Decimal {
+	if p == nil {
+		return decimal.Zero
+	}
+	return decimal.NewFromFloat(p.FeeMakerPercent).Div(hundred)
+}
+
+// TakerFee returns the taker fee of the pair as a fraction, zero when the pair
+// info is unknown.
+func (p *PairInfo) TakerFee() decimal.Decimal {
+	if p == nil {
+		return decimal.Zero
+	}
+	return decimal.NewFromFloat(p.FeeTakerPercent).Div(hundred)
+}
+
+// Required returns the balance an order spends, quote for a buy and base for a
+// sell. Payeer takes the fee from the asset received, so the spent balance is
+// the bare amount or value.
+func (p *PairInfo) Required(action Action, amount decimal.Decimal, price decimal.Decimal) decimal.Decimal {
+	if action == ACTION_SELL {
+		return amount
+	}
+	required := amount.Mul(price)
+	if p == nil {
+		return required
+	}
+	return required.RoundCeil(int32(p.ValuePrecision))
+}
+
+// AmountFor returns the largest amount whose Required balance fits in budget.
+func (p *PairInfo) AmountFor(action Action, budget decimal.Decimal, price decimal.Decimal) decimal.Decimal {
+	amount := budget
+	if action == ACTION_BUY {
+		amount = amount.Div(price)
+	}
+	return p.RoundAmount(amount)
+}
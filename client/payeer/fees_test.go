@@ -0,0 +1,61 @@
+package payeer
+
+import "testing"
+
+func TestRequired(t *testing.T) {
+	withFees := *ethBtc
+	withFees.FeeMakerPercent = 0.2
+	withFees.FeeTakerPercent = 0.2
+	tests := []struct {
+		name   string
+		info   *PairInfo
+		action Action
+		amount string
+		price  string
+		want   string
+	}{
+		{name: "buy spends the value", info: ethBtc, action: ACTION_BUY, amount: "2", price: "0.05123", want: "0.10246"},
+		{name: "buy rounds the value up", info: ethBtc, action: ACTION_BUY, amount: "0.0007", price: "0.05123", want: "0.000036"},
+		{name: "sell spends the amount", info: ethBtc, action: ACTION_SELL, amount: "2", price: "0.05123", want: "2"},
+		{name: "buy fee taken from the base received", info: &withFees, action: ACTION_BUY, amount: "2", price: "0.05123", want: "0.10246"},
+		{name: "sell fee taken from the quote received", info: &withFees, action: ACTION_SELL, amount: "2", price: "0.05123", want: "2"},
+		{name: "unknown pair", action: ACTION_BUY, amount: "0.0007", price: "0.05123", want: "0.000035861"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.info.Required(tt.action, d(tt.amount), d(tt.price)); !got.Equal(d(tt.want)) {
+				t.Errorf("Required(%s, %s) = %s, want %s", tt.amount, tt.price, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAmountFor(t *testing.T) {
+	withFees := *ethBtc
+	withFees.FeeMakerPercent = 0.2
+	withFees.FeeTakerPercent = 0.2
+	tests := []struct {
+		name   string
+		info   *PairInfo
+		action Action
+		budget string
+		price  string
+		want   string
+	}{
+		{name: "buy", info: ethBtc, action: ACTION_BUY, budget: "0.1", price: "0.05123", want: "1.9519"},
+		{name: "sell", info: ethBtc, action: ACTION_SELL, budget: "1.23456", price: "0.05123", want: "1.2345"},
+		{name: "buy with fees", info: &withFees, action: ACTION_BUY, budget: "0.1", price: "0.05123", want: "1.9519"},
+		{name: "sell with fees", info: &withFees, action: ACTION_SELL, budget: "1.23456", price: "0.05123", want: "1.2345"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount := tt.info.AmountFor(tt.action, d(tt.budget), d(tt.price))
+			if !amount.Equal(d(tt.want)) {
+				t.Errorf("AmountFor(%s, %s) = %s, want %s", tt.budget, tt.price, amount, tt.want)
+			}
+			if required := tt.info.Required(tt.action, amount, d(tt.price)); required.GreaterThan(d(tt.budget)) {
+				t.Errorf("Required(%s) = %s, over the budget %s", amount, required, tt.budget)
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package fetcher
 import (
 	"automata/client/payeer"
 	"automata/msync"
+	"automata/risk"
+	"errors"
 	"log/slog"
 	"os"
 	"time"
@@ -11,14 +13,22 @@ import (
 const POINTS_LOG_OFFSET = 100
 
 type Fetcher struct {
-	payeerClient   *payeer.Client
+	// Risk, when set, checks every order and cancel before it is sent.
+	Risk *risk.Manager
+	// OnError, when set, is told about every failed request, e.g. to trip a
+	// circuit breaker on repeated exchange errors.
+	OnError func(method string, err error)
+	// OnRejected, when set, is told about every error response, including the
+	// expected ones such as insufficient funds.
+	OnRejected     func(method string, code payeer.ResponseErrorCode)
+	payeerClient   payeer.Api
 	curWPoints     *msync.Mu[int]
 	lastWTimestamp *msync.Mu[time.Time]
 	lastWPoints    *msync.Mu[int]
 }
 
 func NewFetcher(
-	payeerClient *payeer.Client,
+	payeerClient payeer.Api,
 ) *Fetcher {
 	return &Fetcher{
 		payeerClient:   payeerClient,
@@ -45,6 +55,7 @@ func (s *Fetcher) MyOrders() map[string]payeer.MyOrdersOrder {
 		ordersRsp, err := s.payeerClient.MyOrders(&payeer.MyOrdersRequest{})
 		if err != nil {
 			slog.Error("[PayeerFetcher] MyOrders HTTP error. Retrying...", "error", err)
+			s.reportError("MyOrders", err)
 			continue
 		}
 
@@ -62,6 +73,7 @@ func (s *Fetcher) OrderDetails(orderId int) *payeer.OrderDetails {
 		orderStatusRsp, err := s.payeerClient.OrderStatus(&payeer.OrderStatusRequest{OrderId: orderId})
 		if err != nil {
 			slog.Error("[PayeerFetcher] Order details HTTP error. Retrying...", "error", err)
+			s.reportError("OrderStatus", err)
 			continue
 		}
 		s.updateWeights(5)
@@ -73,7 +85,26 @@ func (s *Fetcher) OrderDetails(orderId int) *payeer.OrderDetails {
 	}
 }
 
+// TryOrderDetails asks for the order once and returns a failed request or an
+// error response instead of retrying or exiting.
+func (s *Fetcher) TryOrderDetails(orderId int) (*payeer.OrderDetails, error) {
+	rsp, err := s.payeerClient.OrderStatus(&payeer.OrderStatusRequest{OrderId: orderId})
+	if err != nil {
+		s.reportError("OrderStatus", err)
+		return nil, err
+	}
+	s.updateWeights(5)
+	if !rsp.Success {
+		s.reportResponseError("OrderStatus", rsp.Error)
+		return nil, responseError(rsp.Error)
+	}
+	return &rsp.Order, nil
+}
+
 func (s *Fetcher) PlaceOrder(action payeer.Action, pair payeer.Pair, amount string, price string) *payeer.PostOrderResponse {
+	if err := s.Risk.CheckOrder(risk.PayeerOrder(action, pair, amount, price)); err != nil {
+		return &payeer.PostOrderResponse{BaseResponse: riskRejected(err)}
+	}
 	for {
 		rsp, err := s.payeerClient.PlaceOrder(&payeer.PostOrderRequest{
 			Pair:   pair,
@@ -84,29 +115,50 @@ func (s *Fetcher) PlaceOrder(action payeer.Action, pair payeer.Pair, amount stri
 		})
 		if err != nil {
 			slog.Error("[PayeerFetcher] Place order HTTP error. Retrying...", "error", err)
+			s.reportError("PlaceOrder", err)
 			continue
 		}
 		s.updateWeights(5)
 		if !rsp.Success {
 			slog.Error("[PayeerFetcher] Place order response - no success", "response", rsp)
+			s.reportResponseError("PlaceOrder", rsp.Error)
 		}
 		slog.Info("[PayeerFetcher] Order placed:", "order", rsp)
 		return rsp
 	}
 }
 
+// TryPlaceOrder sends the order once and returns a failed request instead of
+// retrying. Unlike PlaceOrder it leaves the risk check to the caller, which
+// journals a rejection with the rest of its decision.
+func (s *Fetcher) TryPlaceOrder(request *payeer.PostOrderRequest) (*payeer.PostOrderResponse, error) {
+	rsp, err := s.payeerClient.PlaceOrder(request)
+	if err != nil {
+		s.reportError("PlaceOrder", err)
+		return nil, err
+	}
+	s.updateWeights(5)
+	if !rsp.Success {
+		s.reportResponseError("PlaceOrder", rsp.Error)
+	}
+	return rsp, nil
+}
+
 func (s *Fetcher) CancelOrder(orderId int) *payeer.CancelOrderResponse {
+	s.Risk.WaitCancel()
 	for {
 		rsp, err := s.payeerClient.CancelOrder(&payeer.CancelOrderRequest{
 			OrderId: orderId,
 		})
 		if err != nil {
 			slog.Error("[PayeerFetcher] Cancel order HTTP error. Retrying...", "error", err)
+			s.reportError("CancelOrder", err)
 			continue
 		}
 		s.updateWeights(10)
 		if !rsp.Success {
 			slog.Error("[PayeerFetcher] Cancel order response - no success", "error", rsp.Error)
+			s.reportResponseError("CancelOrder", rsp.Error)
 			return rsp
 		}
 		slog.Info("[PayeerFetcher] Order canceled", "orderId", orderId)
@@ -114,11 +166,28 @@ func (s *Fetcher) CancelOrder(orderId int) *payeer.CancelOrderResponse {
 	}
 }
 
+// TryCancelOrder waits for the cancel rate limit, sends the cancel once and
+// returns a failed request instead of retrying.
+func (s *Fetcher) TryCancelOrder(orderId int) (*payeer.CancelOrderResponse, error) {
+	s.Risk.WaitCancel()
+	rsp, err := s.payeerClient.CancelOrder(&payeer.CancelOrderRequest{OrderId: orderId})
+	if err != nil {
+		s.reportError("CancelOrder", err)
+		return nil, err
+	}
+	s.updateWeights(10)
+	if !rsp.Success {
+		s.reportResponseError("CancelOrder", rsp.Error)
+	}
+	return rsp, nil
+}
+
 func (s *Fetcher) Balance() map[string]payeer.Balance {
 	for {
 		balance, err := s.payeerClient.Balance()
 		if err != nil {
 			slog.Error("[PayeerFetcher] Balance response HTTP error. Retrying...", "error", err)
+			s.reportError("Balance", err)
 			continue
 		}
 		s.updateWeights(10)
@@ -131,11 +200,28 @@ func (s *Fetcher) Balance() map[string]payeer.Balance {
 	}
 }
 
+// TryBalance asks for the balances once and returns a failed request or an
+// error response instead of retrying or exiting.
+func (s *Fetcher) TryBalance() (map[string]payeer.Balance, error) {
+	rsp, err := s.payeerClient.Balance()
+	if err != nil {
+		s.reportError("Balance", err)
+		return nil, err
+	}
+	s.updateWeights(10)
+	if !rsp.Success {
+		s.reportResponseError("Balance", rsp.Error)
+		return nil, responseError(rsp.Error)
+	}
+	return rsp.Balances, nil
+}
+
 func (s *Fetcher) OrdersByPairs(pairs []payeer.Pair) map[payeer.Pair]payeer.PairsOrderInfo {
 	for {
 		orders, err := s.payeerClient.Orders(pairs)
 		if err != nil {
 			slog.Error("[PayeerFetcher] Orders response HTTP error. Retrying...", "error", err)
+			s.reportError("Orders", err)
 			continue
 		}
 		s.updateWeights(len(pairs))
@@ -152,6 +238,7 @@ func (s *Fetcher) Orders(pair payeer.Pair) payeer.PairsOrderInfo {
 		orders, err := s.payeerClient.Orders([]payeer.Pair{pair})
 		if err != nil {
 			slog.Error("[PayeerFetcher] Orders response HTTP error. Retrying...", "error", err)
+			s.reportError("Orders", err)
 			continue
 		}
 		s.updateWeights(1)
@@ -163,6 +250,38 @@ func (s *Fetcher) Orders(pair payeer.Pair) payeer.PairsOrderInfo {
 	}
 }
 
+// TryOrders asks for the order books of pairs once and returns a failed
+// request or an error response instead of retrying or exiting.
+func (s *Fetcher) TryOrders(pairs []payeer.Pair) (map[payeer.Pair]payeer.PairsOrderInfo, error) {
+	rsp, err := s.payeerClient.Orders(pairs)
+	if err != nil {
+		s.reportError("Orders", err)
+		return nil, err
+	}
+	s.updateWeights(len(pairs))
+	if !rsp.Success {
+		s.reportResponseError("Orders", rsp.Error)
+		return nil, responseError(rsp.Error)
+	}
+	return rsp.Pairs, nil
+}
+
+// TryTickers asks for the price statistics of pairs once and returns a failed
+// request or an error response instead of retrying or exiting.
+func (s *Fetcher) TryTickers(pairs []payeer.Pair) (map[payeer.Pair]payeer.Ticker, error) {
+	rsp, err := s.payeerClient.Tickers(pairs)
+	if err != nil {
+		s.reportError("Tickers", err)
+		return nil, err
+	}
+	s.updateWeights(1)
+	if !rsp.Success {
+		s.reportResponseError("Tickers", rsp.Error)
+		return nil, responseError(rsp.Error)
+	}
+	return rsp.Pairs, nil
+}
+
 func (s *Fetcher) updateWeights(count int) {
 	now := time.Now()
 	if now.Sub(s.lastWTimestamp.Get()).Minutes() > 1 {
@@ -174,8 +293,40 @@ func (s *Fetcher) updateWeights(count int) {
 			return value - count
 		})
 	}
+	weightRemaining.Set(float64(s.curWPoints.Get()))
 	if s.curWPoints.Get()/POINTS_LOG_OFFSET != s.lastWPoints.Get()/POINTS_LOG_OFFSET || s.curWPoints.Get() < 100 {
 		s.lastWPoints.Set(s.curWPoints.Get())
 		slog.Info("[PayeerFetcher] Weights info", "remaining/min", s.curWPoints.Get())
 	}
 }
+
+func riskRejected(err error) payeer.BaseResponse {
+	return payeer.BaseResponse{
+		Error: payeer.ResponseError{Code: payeer.ERR_RISK_REJECTED, Message: err.Error()},
+	}
+}
+
+func responseError(rspErr payeer.ResponseError) error {
+	return errors.New(string(rspErr.Code))
+}
+
+func (s *Fetcher) reportError(method string, err error) {
+	if s.OnError != nil {
+		s.OnError(method, err)
+	}
+}
+
+// reportResponseError reports error responses other than the ones strategies
+// expect and handle, like insufficient funds or an already closed order.
+func (s *Fetcher) reportResponseError(method string, rspErr payeer.ResponseError) {
+	responseErrors.Inc(method, string(rspErr.Code))
+	if s.OnRejected != nil {
+		s.OnRejected(method, rspErr.Code)
+	}
+	switch rspErr.Code {
+	case payeer.ERR_INSUFFICIENT_FUNDS, payeer.ERR_INSUFFICIENT_VOLUME, payeer.ERR_INVALID_STATUS_FOR_REFUND,
+		payeer.ERR_MIN_AMOUNT, payeer.ERR_MIN_VALUE, payeer.ERR_RISK_REJECTED:
+		return
+	}
+	s.reportError(method, responseError(rspErr))
+}
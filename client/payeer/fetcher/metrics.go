@@ -0,0 +1,13 @@
+package fetcher
+
+import "automata/metrics"
+
+var (
+	weightRemaining = metrics.NewGaugeVec("payeer_weight_remaining", "Request weight left in the current minute as counted by the fetcher.")
+	responseErrors  = metrics.NewCounterVec("payeer_response_errors_total", "Error responses to order placements and cancels by method and error code.", "method", "code")
+)
+
+func init() {
+	metrics.Register(weightRemaining)
+	metrics.Register(responseErrors)
+}
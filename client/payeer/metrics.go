@@ -0,0 +1,25 @@
+package payeer
+
+import (
+	"automata/metrics"
+	"time"
+)
+
+var (
+	requestDuration = metrics.NewHistogramVec("payeer_request_duration_seconds", "Latency of Payeer API requests by endpoint.", metrics.LatencyBuckets, "endpoint")
+	requestErrors   = metrics.NewCounterVec("payeer_request_errors_total", "Payeer API requests that failed without a decodable response, by endpoint.", "endpoint")
+)
+
+func init() {
+	metrics.Register(requestDuration)
+	metrics.Register(requestErrors)
+}
+
+// observe records a request to endpoint; use it deferred with the named error
+// result.
+func observe(endpoint string, started time.Time, err *error) {
+	requestDuration.Since(started, endpoint)
+	if *err != nil {
+		requestErrors.Inc(endpoint)
+	}
+}
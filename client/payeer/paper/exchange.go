@@ -0,0 +1,789 @@
+package paper
+
+import (
+	"automata/client/payeer"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var hundred = decimal.NewFromInt(100)
+
+type Options struct {
+	// Initial virtual balances by asset, e.g. {"USDT": "1000", "ETH": "0.5"}.
+	Balances map[string]decimal.Decimal
+	// Clock returns the current time. Defaults to time.Now; backtests pass a simulated clock.
+	Clock func() time.Time
+}
+
+// Exchange is a paper-trading execution backend. Public market data is passed
+// through to the wrapped PublicApi (or pushed with Observe/ObserveTrades when
+// replaying recorded books), while private methods are simulated against the
+// last known book of every pair.
+type Exchange struct {
+	public payeer.PublicApi
+	clock  func() time.Time
+
+	mu    sync.Mutex
+	info  *payeer.InfoResponse
+	books map[payeer.Pair]payeer.PairsOrderInfo
+	// seenTrades holds the date of every trade observed per pair, so that a
+	// trade repeated by the next response is not matched twice.
+	seenTrades map[payeer.Pair]map[string]int64
+	balances   map[string]*balance
+	orders     map[int]*order
+	nextId     int
+	tradeSeq   int
+}
+
+var _ payeer.Api = (*Exchange)(nil)
+
+func NewExchange(public payeer.PublicApi, options *Options) *Exchange {
+	clock := options.Clock
+	if clock == nil {
+		clock = time.Now
+	}
+	balances := make(map[string]*balance, len(options.Balances))
+	for asset, total := range options.Balances {
+		balances[asset] = &balance{total: total}
+	}
+	return &Exchange{
+		public:     public,
+		clock:      clock,
+		books:      make(map[payeer.Pair]payeer.PairsOrderInfo),
+		seenTrades: make(map[payeer.Pair]map[string]int64),
+		balances:   balances,
+		orders:     make(map[int]*order),
+		nextId:     1,
+	}
+}
+
+/*
+** Public API
+ */
+
+func (e *Exchange) Info() (*payeer.InfoResponse, error) {
+	e.mu.Lock()
+	info := e.info
+	e.mu.Unlock()
+	if info != nil {
+		return info, nil
+	}
+	info, err := e.public.Info()
+	if err != nil {
+		return nil, err
+	}
+	e.SetInfo(info)
+	return info, nil
+}
+
+func (e *Exchange) Orders(pairs []payeer.Pair) (*payeer.OrdersResponse, error) {
+	rsp, err := e.public.Orders(pairs)
+	if err != nil || !rsp.Success {
+		return rsp, err
+	}
+	for pair, book := range rsp.Pairs {
+		e.Observe(pair, book)
+	}
+	return rsp, nil
+}
+
+func (e *Exchange) Trades(pairs []payeer.Pair) (*payeer.TradesResponse, error) {
+	rsp, err := e.public.Trades(pairs)
+	if err != nil || !rsp.Success {
+		return rsp, err
+	}
+	for pair, trades := range rsp.Trades {
+		e.ObserveTrades(pair, trades)
+	}
+	return rsp, nil
+}
+
+func (e *Exchange) Tickers(pairs []payeer.Pair) (*payeer.TickersResponse, error) {
+	return e.public.Tickers(pairs)
+}
+
+// SetInfo provides pair precisions, limits and fees without calling the wrapped PublicApi.
+func (e *Exchange) SetInfo(info *payeer.InfoResponse) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.info = info
+}
+
+// Observe feeds a fresh order book snapshot and matches resting orders against it.
+func (e *Exchange) Observe(pair payeer.Pair, book payeer.PairsOrderInfo) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.books[pair] = book
+	for _, id := range e.openOrderIds(pair) {
+		e.matchRestingByBook(e.orders[id], &book)
+	}
+}
+
+// ObserveTrades feeds public trades of the pair. Trades that went through the
+// price level of a resting order first consume the queue ahead of it, then fill it.
+// Once trades of a pair are observed they alone move orders up the queue, see
+// matchRestingByBook.
+func (e *Exchange) ObserveTrades(pair payeer.Pair, trades []payeer.TradesTrade) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	seen, ok := e.seenTrades[pair]
+	if !ok {
+		seen = make(map[string]int64)
+		e.seenTrades[pair] = seen
+	}
+	fresh := make([]payeer.TradesTrade, 0, len(trades))
+	for _, trade := range trades {
+		if _, ok := seen[trade.Id]; ok {
+			continue
+		}
+		seen[trade.Id] = trade.Date
+		fresh = append(fresh, trade)
+	}
+	pruneTrades(seen, trades)
+	slices.SortFunc(fresh, func(a, b payeer.TradesTrade) int {
+		if a.Date != b.Date {
+			return int(a.Date - b.Date)
+		}
+		return strings.Compare(a.Id, b.Id)
+	})
+	for _, trade := range fresh {
+		for _, id := range e.openOrderIds(pair) {
+			e.matchRestingByTrade(e.orders[id], &trade)
+		}
+	}
+}
+
+// pruneTrades forgets the trades older than the oldest one of the latest
+// response: the exchange returns the latest trades, so those never come back.
+func pruneTrades(seen map[string]int64, trades []payeer.TradesTrade) {
+	if len(trades) == 0 {
+		return
+	}
+	oldest := trades[0].Date
+	for _, trade := range trades {
+		oldest = min(oldest, trade.Date)
+	}
+	for id, date := range seen {
+		if date < oldest {
+			delete(seen, id)
+		}
+	}
+}
+
+// History returns the details of every order placed so far, oldest first.
+func (e *Exchange) History() []payeer.OrderDetails {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ids := make([]int, 0, len(e.orders))
+	for id := range e.orders {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	history := make([]payeer.OrderDetails, 0, len(ids))
+	for _, id := range ids {
+		history = append(history, e.details(e.orders[id]))
+	}
+	return history
+}
+
+/*
+** Private API
+ */
+
+func (e *Exchange) PlaceOrder(req *payeer.PostOrderRequest) (*payeer.PostOrderResponse, error) {
+	if _, err := e.Info(); err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	pairInfo, ok := e.info.Pairs[req.Pair]
+	if !ok {
+		return failedPlace(payeer.ERR_INVALID_PARAMETER), nil
+	}
+	amount, err := decimal.NewFromString(req.Amount)
+	if err != nil || !amount.IsPositive() {
+		return failedPlace(payeer.ERR_INVALID_PARAMETER), nil
+	}
+	if code := pairInfo.CheckMinimum(amount, decimal.Zero); code != "" {
+		return failedPlace(code), nil
+	}
+
+	o := &order{
+		pair:   req.Pair,
+		action: req.Action,
+		typ:    req.Type,
+		status: payeer.ORDER_STATUS_WAITING,
+		amount: amount,
+		date:   e.clock(),
+	}
+
+	switch req.Type {
+	case payeer.ORDER_TYPE_LIMIT:
+		price, err := decimal.NewFromString(req.Price)
+		if err != nil || !price.IsPositive() {
+			return failedPlace(payeer.ERR_INCORRECT_PRICE), nil
+		}
+		if pairInfo.CheckPrice(price) != nil {
+			return failedPlace(payeer.ERR_INCORRECT_PRICE), nil
+		}
+		if code := pairInfo.CheckMinimum(amount, price); code != "" {
+			return failedPlace(code), nil
+		}
+		value := amount.Mul(price)
+		o.price = price
+		if !e.hold(o, value) {
+			return failedPlace(payeer.ERR_INSUFFICIENT_FUNDS), nil
+		}
+	case payeer.ORDER_TYPE_MARKET:
+		book, ok := e.books[req.Pair]
+		if !ok {
+			return failedPlace(payeer.ERR_INSUFFICIENT_VOLUME), nil
+		}
+		levels := book.Asks
+		if req.Action == payeer.ACTION_SELL {
+			levels = book.Bids
+		}
+		swept, value := sweepable(levels, req.Action, amount, nil)
+		if swept.LessThan(amount) {
+			return failedPlace(payeer.ERR_INSUFFICIENT_VOLUME), nil
+		}
+		if !e.hasAvailable(o, value) {
+			return failedPlace(payeer.ERR_INSUFFICIENT_FUNDS), nil
+		}
+	default:
+		return failedPlace(payeer.ERR_INVALID_PARAMETER), nil
+	}
+
+	o.id = e.nextId
+	e.nextId++
+	e.orders[o.id] = o
+
+	e.matchOnPlacement(o)
+	if o.typ == payeer.ORDER_TYPE_LIMIT && o.remaining().IsPositive() {
+		e.joinQueue(o)
+	}
+
+	slog.Info("[PaperExchange] Order placed", "orderId", o.id, "pair", o.pair, "action", o.action, "type", o.typ, "amount", o.amount.String(), "price", o.price.String(), "filled", o.processed.String())
+
+	return &payeer.PostOrderResponse{
+		BaseResponse: payeer.BaseResponse{Success: true},
+		OrderId:      o.id,
+		Params: payeer.OrderParams{
+			Pair:   o.pair,
+			Type:   o.typ,
+			Action: o.action,
+			Amount: o.amount.String(),
+			Price:  o.price.String(),
+			Value:  o.amount.Mul(o.price).StringFixed(e.valuePrecision(o.pair)),
+		},
+	}, nil
+}
+
+func (e *Exchange) OrderStatus(req *payeer.OrderStatusRequest) (*payeer.OrderStatusResponse, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	o, ok := e.orders[req.OrderId]
+	if !ok {
+		return &payeer.OrderStatusResponse{BaseResponse: failure(payeer.ERR_INVALID_PARAMETER)}, nil
+	}
+	return &payeer.OrderStatusResponse{
+		BaseResponse: payeer.BaseResponse{Success: true},
+		Order:        e.details(o),
+	}, nil
+}
+
+func (e *Exchange) Balance() (*payeer.BalanceResponse, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	balances := make(map[string]payeer.Balance, len(e.balances))
+	for asset, b := range e.balances {
+		balances[asset] = payeer.Balance{
+			Total:     b.total.InexactFloat64(),
+			Available: b.total.Sub(b.hold).InexactFloat64(),
+			Hold:      b.hold.InexactFloat64(),
+		}
+	}
+	return &payeer.BalanceResponse{
+		BaseResponse: payeer.BaseResponse{Success: true},
+		Balances:     balances,
+	}, nil
+}
+
+func (e *Exchange) CancelOrder(req *payeer.CancelOrderRequest) (*payeer.CancelOrderResponse, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	o, ok := e.orders[req.OrderId]
+	if !ok {
+		return &payeer.CancelOrderResponse{BaseResponse: failure(payeer.ERR_INVALID_PARAMETER)}, nil
+	}
+	if !o.isOpen() {
+		return &payeer.CancelOrderResponse{BaseResponse: failure(payeer.ERR_INVALID_STATUS_FOR_REFUND)}, nil
+	}
+	e.release(o, o.remaining())
+	o.status = payeer.ORDER_STATUS_CANCELED
+	slog.Info("[PaperExchange] Order canceled", "orderId", o.id, "filled", o.processed.String())
+	return &payeer.CancelOrderResponse{BaseResponse: payeer.BaseResponse{Success: true}}, nil
+}
+
+func (e *Exchange) MyOrders(req *payeer.MyOrdersRequest) (*payeer.MyOrdersResponse, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var pairs []payeer.Pair
+	if req.Pairs != "" {
+		for _, pair := range strings.Split(req.Pairs, ",") {
+			pairs = append(pairs, payeer.Pair(pair))
+		}
+	}
+	orders := make(map[string]payeer.MyOrdersOrder)
+	for _, o := range e.orders {
+		if !o.isOpen() {
+			continue
+		}
+		if len(pairs) > 0 && !slices.Contains(pairs, o.pair) {
+			continue
+		}
+		if req.Action != "" && req.Action != o.action {
+			continue
+		}
+		d := e.details(o)
+		orders[d.Id] = payeer.MyOrdersOrder{
+			Id:              d.Id,
+			Date:            d.Date,
+			Pair:            d.Pair,
+			Action:          d.Action,
+			Type:            d.Type,
+			Amount:          d.Amount,
+			Price:           d.Price,
+			Value:           d.Value,
+			AmountProcessed: d.AmountProcessed,
+			AmountRemaining: d.AmountRemaining,
+			ValueProcessed:  d.ValueProcessed,
+			ValueRemaining:  d.ValueRemaining,
+			IsCreatedByApi:  true,
+		}
+	}
+	return &payeer.MyOrdersResponse{
+		BaseResponse: payeer.BaseResponse{Success: true},
+		Orders:       orders,
+	}, nil
+}
+
+/*
+** Matching
+ */
+
+// matchOnPlacement executes the crossing part of a new order as taker against the current book.
+func (e *Exchange) matchOnPlacement(o *order) {
+	book, ok := e.books[o.pair]
+	if !ok {
+		return
+	}
+	// The levels are copied: the book shares its arrays with the Orders
+	// response the caller holds.
+	levels := slices.Clone(book.Asks)
+	if o.action == payeer.ACTION_SELL {
+		levels = slices.Clone(book.Bids)
+	}
+	var limit *decimal.Decimal
+	if o.typ == payeer.ORDER_TYPE_LIMIT {
+		limit = &o.price
+	}
+	for i := range levels {
+		if !o.remaining().IsPositive() {
+			break
+		}
+		price := decimal.RequireFromString(levels[i].Price)
+		if limit != nil && !crosses(o.action, price, *limit) {
+			break
+		}
+		available := decimal.RequireFromString(levels[i].Amount)
+		fillAmount := decimal.Min(available, o.remaining())
+		if !fillAmount.IsPositive() {
+			continue
+		}
+		e.fill(o, fillAmount, price, false)
+		// Consume the liquidity so it is not matched twice before the next snapshot
+		levels[i].Amount = available.Sub(fillAmount).String()
+	}
+	if o.action == payeer.ACTION_SELL {
+		book.Bids = levels
+	} else {
+		book.Asks = levels
+	}
+	e.books[o.pair] = book
+	if o.typ == payeer.ORDER_TYPE_MARKET {
+		// Payeer cancels the unfilled part of a market order
+		if o.remaining().IsPositive() {
+			o.status = payeer.ORDER_STATUS_CANCELED
+		}
+	}
+}
+
+// joinQueue places a resting order behind everything already at its price level.
+func (e *Exchange) joinQueue(o *order) {
+	book := e.books[o.pair]
+	o.levelAmount = levelAmount(sameSide(o.action, &book), o.price)
+	o.queueAhead = o.levelAmount
+}
+
+func (e *Exchange) matchRestingByBook(o *order, book *payeer.PairsOrderInfo) {
+	// The opposite side reached our price: whoever did it traded with us first
+	crossing, _ := sweepable(oppositeSide(o.action, book), o.action, o.remaining(), &o.price)
+	if crossing.IsPositive() {
+		e.fill(o, crossing, o.price, true)
+	}
+	if !o.isOpen() {
+		return
+	}
+	// Volume leaving our level ahead of us moves us up the queue. With trades
+	// observed the shrinkage is mostly those same trades, which
+	// matchRestingByTrade counted already, so only an emptied level counts.
+	levels := sameSide(o.action, book)
+	newAmount := levelAmount(levels, o.price)
+	if _, traded := e.seenTrades[o.pair]; !traded && newAmount.LessThan(o.levelAmount) {
+		o.queueAhead = decimal.Max(decimal.Zero, o.queueAhead.Sub(o.levelAmount.Sub(newAmount)))
+	}
+	if newAmount.IsZero() {
+		o.queueAhead = decimal.Zero
+	}
+	o.levelAmount = newAmount
+}
+
+func (e *Exchange) matchRestingByTrade(o *order, trade *payeer.TradesTrade) {
+	if trade.Date < o.date.Unix() {
+		return
+	}
+	// The taker side of a trade is the opposite of the resting order side
+	if payeer.Action(trade.Type) == o.action {
+		return
+	}
+	price := decimal.RequireFromString(trade.Price)
+	if !crosses(o.action, price, o.price) {
+		return
+	}
+	amount := decimal.RequireFromString(trade.Amount)
+	if price.Equal(o.price) {
+		consumed := decimal.Min(amount, o.queueAhead)
+		o.queueAhead = o.queueAhead.Sub(consumed)
+		amount = amount.Sub(consumed)
+	} else {
+		// The trade went through our level, so it has been swept including us
+		o.queueAhead = decimal.Zero
+		amount = o.remaining()
+	}
+	fillAmount := decimal.Min(amount, o.remaining())
+	if fillAmount.IsPositive() {
+		e.fill(o, fillAmount, o.price, true)
+	}
+}
+
+func (e *Exchange) fill(o *order, amount decimal.Decimal, price decimal.Decimal, isMaker bool) {
+	pairInfo := e.info.Pairs[o.pair]
+	amountPrec := int32(pairInfo.AmountPrecision)
+	valuePrec := e.valuePrecision(o.pair)
+	feePercent := pairInfo.FeeTakerPercent
+	if isMaker {
+		feePercent = pairInfo.FeeMakerPercent
+	}
+	feeRate := decimal.NewFromFloat(feePercent).Div(hundred)
+	value := amount.Mul(price).Round(valuePrec)
+
+	base := e.balance(o.pair.Base())
+	quote := e.balance(o.pair.Quote())
+	var fee decimal.Decimal
+	if o.action == payeer.ACTION_BUY {
+		fee = amount.Mul(feeRate).RoundUp(amountPrec)
+		if o.typ == payeer.ORDER_TYPE_LIMIT {
+			quote.hold = quote.hold.Sub(amount.Mul(o.price))
+		}
+		quote.total = quote.total.Sub(value)
+		base.total = base.total.Add(amount.Sub(fee))
+	} else {
+		fee = value.Mul(feeRate).RoundUp(valuePrec)
+		if o.typ == payeer.ORDER_TYPE_LIMIT {
+			base.hold = base.hold.Sub(amount)
+		}
+		base.total = base.total.Sub(amount)
+		quote.total = quote.total.Add(value.Sub(fee))
+	}
+
+	e.tradeSeq++
+	o.trades = append(o.trades, fill{
+		id:      strconv.Itoa(e.tradeSeq),
+		date:    e.clock(),
+		price:   price,
+		amount:  amount,
+		value:   value,
+		fee:     fee,
+		isMaker: isMaker,
+	})
+	o.processed = o.processed.Add(amount)
+	o.valueProcessed = o.valueProcessed.Add(value)
+	if o.remaining().IsZero() {
+		o.status = payeer.ORDER_STATUS_SUCCESS
+	} else {
+		o.status = payeer.ORDER_STATUS_PROCESSING
+	}
+
+	slog.Info("[PaperExchange] Order filled", "orderId", o.id, "pair", o.pair, "action", o.action, "amount", amount.String(), "price", price.String(), "fee", fee.String(), "maker", isMaker, "status", o.status,
+		"base", base.total.String(), "quote", quote.total.String())
+}
+
+/*
+** Balances
+ */
+
+type balance struct {
+	total decimal.Decimal
+	hold  decimal.Decimal
+}
+
+func (b *balance) available() decimal.Decimal {
+	return b.total.Sub(b.hold)
+}
+
+func (e *Exchange) balance(asset string) *balance {
+	b, ok := e.balances[asset]
+	if !ok {
+		b = &balance{}
+		e.balances[asset] = b
+	}
+	return b
+}
+
+func (e *Exchange) hasAvailable(o *order, value decimal.Decimal) bool {
+	if o.action == payeer.ACTION_BUY {
+		return e.balance(o.pair.Quote()).available().GreaterThanOrEqual(value)
+	}
+	return e.balance(o.pair.Base()).available().GreaterThanOrEqual(o.amount)
+}
+
+func (e *Exchange) hold(o *order, value decimal.Decimal) bool {
+	if !e.hasAvailable(o, value) {
+		return false
+	}
+	if o.action == payeer.ACTION_BUY {
+		quote := e.balance(o.pair.Quote())
+		quote.hold = quote.hold.Add(value)
+	} else {
+		base := e.balance(o.pair.Base())
+		base.hold = base.hold.Add(o.amount)
+	}
+	return true
+}
+
+func (e *Exchange) release(o *order, amount decimal.Decimal) {
+	if o.typ != payeer.ORDER_TYPE_LIMIT {
+		return
+	}
+	if o.action == payeer.ACTION_BUY {
+		quote := e.balance(o.pair.Quote())
+		quote.hold = quote.hold.Sub(amount.Mul(o.price))
+	} else {
+		base := e.balance(o.pair.Base())
+		base.hold = base.hold.Sub(amount)
+	}
+}
+
+/*
+** Orders
+ */
+
+type fill struct {
+	id      string
+	date    time.Time
+	price   decimal.Decimal
+	amount  decimal.Decimal
+	value   decimal.Decimal
+	fee     decimal.Decimal
+	isMaker bool
+}
+
+type order struct {
+	id             int
+	date           time.Time
+	pair           payeer.Pair
+	action         payeer.Action
+	typ            payeer.OrderType
+	status         payeer.OrderStatus
+	amount         decimal.Decimal
+	price          decimal.Decimal
+	processed      decimal.Decimal
+	valueProcessed decimal.Decimal
+	queueAhead     decimal.Decimal
+	levelAmount    decimal.Decimal
+	trades         []fill
+}
+
+func (o *order) remaining() decimal.Decimal {
+	return o.amount.Sub(o.processed)
+}
+
+func (o *order) isOpen() bool {
+	return o.status == payeer.ORDER_STATUS_WAITING || o.status == payeer.ORDER_STATUS_PROCESSING
+}
+
+func (e *Exchange) openOrderIds(pair payeer.Pair) []int {
+	ids := []int{}
+	for id, o := range e.orders {
+		if o.pair == pair && o.typ == payeer.ORDER_TYPE_LIMIT && o.isOpen() {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func (e *Exchange) details(o *order) payeer.OrderDetails {
+	valuePrec := e.valuePrecision(o.pair)
+	trades := make(payeer.OrderDetailsTrades, len(o.trades))
+	for _, t := range o.trades {
+		trade := payeer.OrderStatusTrade{
+			Id:      t.id,
+			Date:    t.date.Unix(),
+			Status:  payeer.TRADE_STATUS_SUCCESS,
+			Price:   t.price.String(),
+			Amount:  t.amount.String(),
+			Value:   t.value.StringFixed(valuePrec),
+			IsMaker: t.isMaker,
+			IsTaker: !t.isMaker,
+		}
+		if t.isMaker {
+			trade.MakerCommission = t.fee.String()
+		} else {
+			trade.TakerCommission = t.fee.String()
+		}
+		trades[t.id] = trade
+	}
+	remaining := o.remaining()
+	valueRemaining := decimal.Zero
+	if o.isOpen() {
+		valueRemaining = remaining.Mul(o.price).Round(valuePrec)
+	} else {
+		remaining = decimal.Zero
+	}
+	avgPrice := decimal.Zero
+	if o.processed.IsPositive() {
+		avgPrice = o.valueProcessed.Div(o.processed)
+	}
+	return payeer.OrderDetails{
+		Id:              strconv.Itoa(o.id),
+		Date:            o.date.Unix(),
+		Pair:            o.pair,
+		Action:          o.action,
+		Type:            o.typ,
+		Status:          o.status,
+		Amount:          o.amount.String(),
+		Price:           o.price.String(),
+		Value:           o.amount.Mul(o.price).StringFixed(valuePrec),
+		AmountProcessed: o.processed.String(),
+		AmountRemaining: remaining.String(),
+		ValueProcessed:  o.valueProcessed.StringFixed(valuePrec),
+		ValueRemaining:  valueRemaining.StringFixed(valuePrec),
+		AveragePrice:    avgPrice.StringFixed(valuePrec),
+		Trades:          trades,
+	}
+}
+
+func (e *Exchange) valuePrecision(pair payeer.Pair) int32 {
+	if e.info == nil {
+		return 2
+	}
+	return int32(e.info.Pairs[pair].ValuePrecision)
+}
+
+/*
+** Helpers
+ */
+
+func failure(code payeer.ResponseErrorCode) payeer.BaseResponse {
+	return payeer.BaseResponse{Success: false, Error: payeer.ResponseError{Code: code}}
+}
+
+func failedPlace(code payeer.ResponseErrorCode) *payeer.PostOrderResponse {
+	return &payeer.PostOrderResponse{BaseResponse: failure(code)}
+}
+
+// crosses reports whether an order of the action at limit would trade with a level at price.
+func crosses(action payeer.Action, price decimal.Decimal, limit decimal.Decimal) bool {
+	if action == payeer.ACTION_BUY {
+		return price.LessThanOrEqual(limit)
+	}
+	return price.GreaterThanOrEqual(limit)
+}
+
+func oppositeAction(action payeer.Action) payeer.Action {
+	if action == payeer.ACTION_BUY {
+		return payeer.ACTION_SELL
+	}
+	return payeer.ACTION_BUY
+}
+
+func sameSide(action payeer.Action, book *payeer.PairsOrderInfo) []payeer.OrdersOrder {
+	if action == payeer.ACTION_BUY {
+		return book.Bids
+	}
+	return book.Asks
+}
+
+func oppositeSide(action payeer.Action, book *payeer.PairsOrderInfo) []payeer.OrdersOrder {
+	return sameSide(oppositeAction(action), book)
+}
+
+func levelAmount(levels []payeer.OrdersOrder, price decimal.Decimal) decimal.Decimal {
+	for _, level := range levels {
+		if decimal.RequireFromString(level.Price).Equal(price) {
+			return decimal.RequireFromString(level.Amount)
+		}
+	}
+	return decimal.Zero
+}
+
+// sweepable returns the amount and value an order of the action can take from
+// the opposite side levels up to the requested amount. When limit is set, only
+// levels crossing it are counted.
+func sweepable(levels []payeer.OrdersOrder, action payeer.Action, amount decimal.Decimal, limit *decimal.Decimal) (decimal.Decimal, decimal.Decimal) {
+	swept := decimal.Zero
+	value := decimal.Zero
+	for _, level := range levels {
+		if swept.GreaterThanOrEqual(amount) {
+			break
+		}
+		price := decimal.RequireFromString(level.Price)
+		if limit != nil && !crosses(action, price, *limit) {
+			break
+		}
+		take := decimal.Min(decimal.RequireFromString(level.Amount), amount.Sub(swept))
+		swept = swept.Add(take)
+		value = value.Add(take.Mul(price))
+	}
+	return swept, value
+}
+
+// ParseBalances parses virtual balances in the "USDT=1000,ETH=0.5" form.
+func ParseBalances(str string) (map[string]decimal.Decimal, error) {
+	balances := make(map[string]decimal.Decimal)
+	if str == "" {
+		return balances, nil
+	}
+	for _, item := range strings.Split(str, ",") {
+		asset, amount, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid balance %q, expected ASSET=AMOUNT", item)
+		}
+		value, err := decimal.NewFromString(amount)
+		if err != nil {
+			return nil, fmt.Errorf("invalid balance amount for %s: %w", asset, err)
+		}
+		balances[strings.TrimSpace(asset)] = value
+	}
+	return balances, nil
+}
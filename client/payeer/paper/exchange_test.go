@@ -0,0 +1,272 @@
+package paper
+
+import (
+	"automata/client/payeer"
+	"automata/internal/dectest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var d = dectest.D
+
+var start = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+const btcUsdt payeer.Pair = "BTC_USDT"
+
+func testExchange(balances map[string]decimal.Decimal, feePercent float64) *Exchange {
+	e := NewExchange(nil, &Options{
+		Balances: balances,
+		Clock:    func() time.Time { return start },
+	})
+	e.SetInfo(&payeer.InfoResponse{Pairs: map[payeer.Pair]payeer.PairInfo{
+		btcUsdt: {
+			PricePrecision:  2,
+			AmountPrecision: 4,
+			ValuePrecision:  2,
+			MinAmount:       0.0001,
+			MinValue:        0.01,
+			FeeMakerPercent: feePercent,
+			FeeTakerPercent: feePercent,
+		},
+	}})
+	return e
+}
+
+// levels parses "price:amount" book levels.
+func levels(specs ...string) []payeer.OrdersOrder {
+	levels := make([]payeer.OrdersOrder, len(specs))
+	for i, spec := range specs {
+		price, amount, _ := strings.Cut(spec, ":")
+		levels[i] = payeer.OrdersOrder{Price: price, Amount: amount}
+	}
+	return levels
+}
+
+// sold is a public trade whose taker sold, as it trades with resting buys.
+func sold(id, amount, price string) payeer.TradesTrade {
+	return payeer.TradesTrade{Id: id, Date: start.Unix(), Type: payeer.OrderType(payeer.ACTION_SELL), Amount: amount, Price: price}
+}
+
+// restingBuy places a buy of 1 BTC at 100 behind a bid level of 2 BTC.
+func restingBuy(t *testing.T, e *Exchange) *order {
+	t.Helper()
+	e.Observe(btcUsdt, payeer.PairsOrderInfo{Bids: levels("100:2", "99:5"), Asks: levels("101:5")})
+	rsp, err := e.PlaceOrder(&payeer.PostOrderRequest{Pair: btcUsdt, Type: payeer.ORDER_TYPE_LIMIT, Action: payeer.ACTION_BUY, Amount: "1", Price: "100"})
+	if err != nil || !rsp.Success {
+		t.Fatalf("placing the buy: %v %+v", err, rsp)
+	}
+	o := e.orders[rsp.OrderId]
+	if !o.queueAhead.Equal(d("2")) {
+		t.Fatalf("queue ahead after placing = %s, want 2", o.queueAhead)
+	}
+	return o
+}
+
+func TestMatchRestingByBook(t *testing.T) {
+	tests := []struct {
+		name string
+		// trades are observed before the books.
+		trades        []payeer.TradesTrade
+		books         []payeer.PairsOrderInfo
+		wantQueue     string
+		wantProcessed string
+	}{
+		{
+			name:          "level shrinking moves up the queue",
+			books:         []payeer.PairsOrderInfo{{Bids: levels("100:0.5", "99:5"), Asks: levels("101:5")}},
+			wantQueue:     "0.5",
+			wantProcessed: "0",
+		},
+		{
+			name:          "level growing keeps the place",
+			books:         []payeer.PairsOrderInfo{{Bids: levels("100:3", "99:5"), Asks: levels("101:5")}},
+			wantQueue:     "2",
+			wantProcessed: "0",
+		},
+		{
+			name: "shrinking twice counts both",
+			books: []payeer.PairsOrderInfo{
+				{Bids: levels("100:1.5", "99:5"), Asks: levels("101:5")},
+				{Bids: levels("100:1.2", "99:5"), Asks: levels("101:5")},
+			},
+			wantQueue:     "1.2",
+			wantProcessed: "0",
+		},
+		{
+			name:          "emptied level clears the queue",
+			books:         []payeer.PairsOrderInfo{{Bids: levels("99:5"), Asks: levels("101:5")}},
+			wantQueue:     "0",
+			wantProcessed: "0",
+		},
+		{
+			name:          "ask at the price fills",
+			books:         []payeer.PairsOrderInfo{{Bids: levels("100:2", "99:5"), Asks: levels("100:0.4", "101:5")}},
+			wantQueue:     "2",
+			wantProcessed: "0.4",
+		},
+		{
+			name:  "asks through the price fill the rest",
+			books: []payeer.PairsOrderInfo{{Bids: levels("99:5"), Asks: levels("99.5:3")}},
+			// The filled order leaves the queue as it was
+			wantQueue:     "2",
+			wantProcessed: "1",
+		},
+		{
+			name:          "shrinkage of traded volume is not counted twice",
+			trades:        []payeer.TradesTrade{sold("1", "1.5", "100")},
+			books:         []payeer.PairsOrderInfo{{Bids: levels("100:0.5", "99:5"), Asks: levels("101:5")}},
+			wantQueue:     "0.5",
+			wantProcessed: "0",
+		},
+		{
+			name:          "cancels ahead are ignored once trades are observed",
+			trades:        []payeer.TradesTrade{},
+			books:         []payeer.PairsOrderInfo{{Bids: levels("100:0.5", "99:5"), Asks: levels("101:5")}},
+			wantQueue:     "2",
+			wantProcessed: "0",
+		},
+		{
+			name:          "emptied level clears the queue with trades observed",
+			trades:        []payeer.TradesTrade{sold("1", "1", "100")},
+			books:         []payeer.PairsOrderInfo{{Bids: levels("99:5"), Asks: levels("101:5")}},
+			wantQueue:     "0",
+			wantProcessed: "0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testExchange(map[string]decimal.Decimal{"USDT": d("1000")}, 0)
+			o := restingBuy(t, e)
+			if tt.trades != nil {
+				e.ObserveTrades(btcUsdt, tt.trades)
+			}
+			for _, book := range tt.books {
+				e.Observe(btcUsdt, book)
+			}
+			if !o.queueAhead.Equal(d(tt.wantQueue)) {
+				t.Errorf("queue ahead = %s, want %s", o.queueAhead, tt.wantQueue)
+			}
+			if !o.processed.Equal(d(tt.wantProcessed)) {
+				t.Errorf("processed = %s, want %s", o.processed, tt.wantProcessed)
+			}
+		})
+	}
+}
+
+func TestMatchRestingByTrade(t *testing.T) {
+	before := sold("0", "3", "100")
+	before.Date = start.Unix() - 1
+	bought := sold("1", "3", "100")
+	bought.Type = payeer.OrderType(payeer.ACTION_BUY)
+	tests := []struct {
+		name string
+		// batches are the responses observed one after the other.
+		batches       [][]payeer.TradesTrade
+		wantQueue     string
+		wantProcessed string
+	}{
+		{
+			name:          "trade at the price consumes the queue",
+			batches:       [][]payeer.TradesTrade{{sold("1", "1.5", "100")}},
+			wantQueue:     "0.5",
+			wantProcessed: "0",
+		},
+		{
+			name:          "trade beyond the queue fills",
+			batches:       [][]payeer.TradesTrade{{sold("1", "2.6", "100")}},
+			wantQueue:     "0",
+			wantProcessed: "0.6",
+		},
+		{
+			name:          "trade through the price fills all",
+			batches:       [][]payeer.TradesTrade{{sold("1", "0.1", "99")}},
+			wantQueue:     "0",
+			wantProcessed: "1",
+		},
+		{
+			name:          "trade above the price is ignored",
+			batches:       [][]payeer.TradesTrade{{sold("1", "3", "101")}},
+			wantQueue:     "2",
+			wantProcessed: "0",
+		},
+		{
+			name:          "buy taker is ignored",
+			batches:       [][]payeer.TradesTrade{{bought}},
+			wantQueue:     "2",
+			wantProcessed: "0",
+		},
+		{
+			name:          "trade before the placement is ignored",
+			batches:       [][]payeer.TradesTrade{{before}},
+			wantQueue:     "2",
+			wantProcessed: "0",
+		},
+		{
+			name:          "trade repeated by the next response counts once",
+			batches:       [][]payeer.TradesTrade{{sold("1", "1.5", "100")}, {sold("1", "1.5", "100"), sold("2", "0.2", "100")}},
+			wantQueue:     "0.3",
+			wantProcessed: "0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testExchange(map[string]decimal.Decimal{"USDT": d("1000")}, 0)
+			o := restingBuy(t, e)
+			for _, trades := range tt.batches {
+				e.ObserveTrades(btcUsdt, trades)
+			}
+			if !o.queueAhead.Equal(d(tt.wantQueue)) {
+				t.Errorf("queue ahead = %s, want %s", o.queueAhead, tt.wantQueue)
+			}
+			if !o.processed.Equal(d(tt.wantProcessed)) {
+				t.Errorf("processed = %s, want %s", o.processed, tt.wantProcessed)
+			}
+		})
+	}
+}
+
+func TestPruneTrades(t *testing.T) {
+	seen := map[string]int64{"1": 100, "2": 200, "3": 300}
+	pruneTrades(seen, nil)
+	if len(seen) != 3 {
+		t.Errorf("seen after an empty response = %v, want all 3", seen)
+	}
+	pruneTrades(seen, []payeer.TradesTrade{{Id: "3", Date: 300}, {Id: "2", Date: 200}, {Id: "4", Date: 400}})
+	if _, ok := seen["1"]; ok || len(seen) != 2 {
+		t.Errorf("seen = %v, want 2 and 3", seen)
+	}
+}
+
+// TestFeeOnReceivedAsset checks that, like Payeer, the fee is taken from the
+// asset received: an order needs only its bare value or amount.
+func TestFeeOnReceivedAsset(t *testing.T) {
+	tests := []struct {
+		name      string
+		action    payeer.Action
+		balances  map[string]decimal.Decimal
+		wantBase  string
+		wantQuote string
+	}{
+		{name: "buy", action: payeer.ACTION_BUY, balances: map[string]decimal.Decimal{"USDT": d("100")}, wantBase: "0.998", wantQuote: "0"},
+		{name: "sell", action: payeer.ACTION_SELL, balances: map[string]decimal.Decimal{"BTC": d("1")}, wantBase: "0", wantQuote: "99.8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testExchange(tt.balances, 0.2)
+			e.Observe(btcUsdt, payeer.PairsOrderInfo{Bids: levels("100:5"), Asks: levels("100:5")})
+			rsp, err := e.PlaceOrder(&payeer.PostOrderRequest{Pair: btcUsdt, Type: payeer.ORDER_TYPE_LIMIT, Action: tt.action, Amount: "1", Price: "100"})
+			if err != nil || !rsp.Success {
+				t.Fatalf("placing with the bare balance: %v %+v", err, rsp)
+			}
+			if base := e.balance("BTC"); !base.total.Equal(d(tt.wantBase)) || !base.hold.IsZero() {
+				t.Errorf("BTC = %s hold %s, want %s", base.total, base.hold, tt.wantBase)
+			}
+			if quote := e.balance("USDT"); !quote.total.Equal(d(tt.wantQuote)) || !quote.hold.IsZero() {
+				t.Errorf("USDT = %s hold %s, want %s", quote.total, quote.hold, tt.wantQuote)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package payeer
+
+import (
+	"automata/client/binance"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/shopspring/decimal"
+)
+
+// StageConfig names a registered selector stage and holds its parameters.
+type StageConfig struct {
+	Stage  string          `json:"stage"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// PipelineConfig is a chain of stages and the fallback chains tried in order
+// when a stage of the previous chain rejects the price. Every chain starts
+// from a zero price.
+type PipelineConfig struct {
+	// Symbol is the Binance reference of the stages, the selector symbol
+	// when empty.
+	Symbol    binance.Symbol  `json:"symbol,omitempty"`
+	Stages    []StageConfig   `json:"stages"`
+	Fallbacks [][]StageConfig `json:"fallbacks,omitempty"`
+}
+
+/* ** Registry */
+
+type stageBuilder func(params json.RawMessage) (PayeerPipeFn, error)
+
+var stageBuilders = map[string]stageBuilder{}
+
+// RegisterStage makes a stage available to pipeline configs under name. The
+// params are decoded strictly into P and validated with its Validate method,
+// if it has one, before build is called.
+func RegisterStage[P any](name string, build func(params *P) (PayeerPipeFn, error)) {
+	if _, ok := stageBuilders[name]; ok {
+		panic("payeer: stage " + name + " registered twice")
+	}
+	stageBuilders[name] = func(raw json.RawMessage) (PayeerPipeFn, error) {
+		params := new(P)
+		if len(raw) > 0 && string(raw) != "null" {
+			decoder := json.NewDecoder(bytes.NewReader(raw))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(params); err != nil {
+				return nil, err
+			}
+		}
+		if validator, ok := any(params).(interface{ Validate() error }); ok {
+			if err := validator.Validate(); err != nil {
+				return nil, err
+			}
+		}
+		return build(params)
+	}
+}
+
+// StageNames lists the registered stages in alphabetical order.
+func StageNames() []string {
+	names := make([]string, 0, len(stageBuilders))
+	for name := range stageBuilders {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// NewStageConfig marshals params into a StageConfig, for building pipelines in code.
+func NewStageConfig(name string, params any) StageConfig {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		panic(err)
+	}
+	return StageConfig{Stage: name, Params: raw}
+}
+
+/* ** Pipeline */
+
+// Pipeline is a compiled PipelineConfig.
+type Pipeline struct {
+	Symbol binance.Symbol
+	chains [][]namedStage
+}
+
+func NewPipeline(config *PipelineConfig) (*Pipeline, error) {
+	if len(config.Stages) == 0 {
+		return nil, errors.New("stages: at least one stage is required")
+	}
+	pipeline := &Pipeline{Symbol: config.Symbol}
+	chains := append([][]StageConfig{config.Stages}, config.Fallbacks...)
+	for i, chain := range chains {
+		path := fmt.Sprintf("fallbacks[%d]", i-1)
+		if i == 0 {
+			path = "stages"
+		}
+		if len(chain) == 0 {
+			return nil, fmt.Errorf("%s: at least one stage is required", path)
+		}
+		stages := make([]namedStage, 0, len(chain))
+		for j, stage := range chain {
+			build, ok := stageBuilders[stage.Stage]
+			if !ok {
+				return nil, fmt.Errorf("%s[%d]: unknown stage %q, expected one of %v", path, j, stage.Stage, StageNames())
+			}
+			fn, err := build(stage.Params)
+			if err != nil {
+				return nil, fmt.Errorf("%s[%d] %s: %w", path, j, stage.Stage, err)
+			}
+			stages = append(stages, namedStage{stage.Stage, fn})
+		}
+		pipeline.chains = append(pipeline.chains, stages)
+	}
+	return pipeline, nil
+}
+
+// run tries the chains in order and returns the price of the first one that
+// every stage accepts.
+func (p *Pipeline) run(pctx *PayeerPriceSelectorContext, selection *Selection) (bool, decimal.Decimal) {
+	var ok bool
+	var price decimal.Decimal
+	for i, chain := range p.chains {
+		ok, price = pipe(pctx, selection, i, chain...)
+		if ok {
+			break
+		}
+	}
+	return ok, price
+}
+
+/* ** Stages */
+
+const (
+	STAGE_SELECT_BY_VALUE_OFFSET  = "selectByValueOffset"
+	STAGE_SELECT_BY_ELEVATION     = "selectByElevation"
+	STAGE_FILTER_BY_BINANCE_PRICE = "filterByBinancePrice"
+	STAGE_FILTER_BY_WMA_RATIO     = "filterByWmaRatio"
+	STAGE_SELECT_BY_BINANCE_RATIO = "selectByBinanceRatio"
+)
+
+type ValueOffsetParams struct {
+	// ValueOffset is the quote value allowed ahead of the price.
+	ValueOffset decimal.Decimal `json:"valueOffset"`
+}
+
+func (p *ValueOffsetParams) Validate() error {
+	if p.ValueOffset.IsNegative() {
+		return errors.New("valueOffset must not be negative")
+	}
+	return nil
+}
+
+type ElevationParams struct {
+	// PriceFraction is the maximum step over the selected level, as a fraction of price.
+	PriceFraction decimal.Decimal `json:"priceFraction"`
+}
+
+func (p *ElevationParams) Validate() error {
+	if p.PriceFraction.IsNegative() || p.PriceFraction.GreaterThan(decimal.RequireFromString("0.01")) {
+		return errors.New("priceFraction must be within [0, 0.01]")
+	}
+	return nil
+}
+
+type BinanceRatioParams struct {
+	// Ratio to the Binance bid for a buy or to the Binance ask for a sell.
+	Ratio decimal.Decimal `json:"ratio"`
+}
+
+func (p *BinanceRatioParams) Validate() error {
+	if !p.Ratio.IsPositive() {
+		return errors.New("ratio must be positive")
+	}
+	return nil
+}
+
+type WmaRatioParams struct {
+	// MaxSurplus is how far the price may be ahead of the weighted mean of the
+	// top levels, as a fraction of it.
+	MaxSurplus decimal.Decimal `json:"maxSurplus"`
+	// Take limits the mean to this many levels, unlimited when 0.
+	Take int `json:"take"`
+	// TakeAmount limits the mean to the levels up to this base amount,
+	// unlimited when 0.
+	TakeAmount decimal.Decimal `json:"takeAmount"`
+}
+
+func (p *WmaRatioParams) Validate() error {
+	if p.MaxSurplus.IsNegative() || p.MaxSurplus.GreaterThan(decimal.NewFromInt(1)) {
+		return errors.New("maxSurplus must be within [0, 1]")
+	}
+	if p.Take < 0 {
+		return fmt.Errorf("take must not be negative, got %d", p.Take)
+	}
+	if p.TakeAmount.IsNegative() {
+		return errors.New("takeAmount must not be negative")
+	}
+	return nil
+}
+
+func init() {
+	RegisterStage(STAGE_SELECT_BY_VALUE_OFFSET, func(p *ValueOffsetParams) (PayeerPipeFn, error) {
+		return func(pctx *PayeerPriceSelectorContext, prevPrice decimal.Decimal) (bool, decimal.Decimal) {
+			return selectByValueOffset(pctx, prevPrice, p.ValueOffset)
+		}, nil
+	})
+	RegisterStage(STAGE_SELECT_BY_ELEVATION, func(p *ElevationParams) (PayeerPipeFn, error) {
+		return func(pctx *PayeerPriceSelectorContext, prevPrice decimal.Decimal) (bool, decimal.Decimal) {
+			return selectByElevation(pctx, prevPrice, p.PriceFraction)
+		}, nil
+	})
+	RegisterStage(STAGE_FILTER_BY_BINANCE_PRICE, func(p *BinanceRatioParams) (PayeerPipeFn, error) {
+		return func(pctx *PayeerPriceSelectorContext, prevPrice decimal.Decimal) (bool, decimal.Decimal) {
+			return filterByBinancePrice(pctx, prevPrice, p.Ratio)
+		}, nil
+	})
+	RegisterStage(STAGE_FILTER_BY_WMA_RATIO, func(p *WmaRatioParams) (PayeerPipeFn, error) {
+		return func(pctx *PayeerPriceSelectorContext, prevPrice decimal.Decimal) (bool, decimal.Decimal) {
+			return filterByWmaRatio(pctx, prevPrice, p)
+		}, nil
+	})
+	RegisterStage(STAGE_SELECT_BY_BINANCE_RATIO, func(p *BinanceRatioParams) (PayeerPipeFn, error) {
+		return func(pctx *PayeerPriceSelectorContext, prevPrice decimal.Decimal) (bool, decimal.Decimal) {
+			return selectByBinanceRatio(pctx, prevPrice, p.Ratio)
+		}, nil
+	})
+}
@@ -0,0 +1,117 @@
+package payeer
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// defaultPricePrecision is assumed for a pair whose info is unknown; it is the
+// precision of the USDT and RUB quoted pairs.
+const defaultPricePrecision = 2
+
+// Tick returns the price step of the pair. A nil p has the step of the
+// default precision.
+func (p *PairInfo) Tick() decimal.Decimal {
+	if p == nil {
+		return decimal.New(1, -defaultPricePrecision)
+	}
+	return decimal.New(1, -int32(p.PricePrecision))
+}
+
+// RoundPrice rounds price to the tick, down for a buy and up for a sell so the
+// order never gets more aggressive than asked, and clamps it to the
+// MinPrice/MaxPrice of the pair.
+func (p *PairInfo) RoundPrice(action Action, price decimal.Decimal) decimal.Decimal {
+	if p == nil {
+		return price
+	}
+	precision := int32(p.PricePrecision)
+	if action == ACTION_SELL {
+		price = price.RoundCeil(precision)
+	} else {
+		price = price.RoundFloor(precision)
+	}
+	if minPrice, ok := p.minPrice(); ok && price.LessThan(minPrice) {
+		price = minPrice
+	}
+	if maxPrice, ok := p.maxPrice(); ok && price.GreaterThan(maxPrice) {
+		price = maxPrice
+	}
+	return price
+}
+
+// CheckPrice returns an error when Payeer would reject price as
+// INCORRECT_PRICE: off the tick or outside MinPrice/MaxPrice.
+func (p *PairInfo) CheckPrice(price decimal.Decimal) error {
+	if !price.IsPositive() {
+		return fmt.Errorf("price %s is not positive", price)
+	}
+	if p == nil {
+		return nil
+	}
+	if !price.Equal(price.Truncate(int32(p.PricePrecision))) {
+		return fmt.Errorf("price %s is finer than the tick %s", price, p.Tick())
+	}
+	if minPrice, ok := p.minPrice(); ok && price.LessThan(minPrice) {
+		return fmt.Errorf("price %s is below the minimum %s", price, minPrice)
+	}
+	if maxPrice, ok := p.maxPrice(); ok && price.GreaterThan(maxPrice) {
+		return fmt.Errorf("price %s is above the maximum %s", price, maxPrice)
+	}
+	return nil
+}
+
+// RoundAmount rounds amount down to the amount precision of the pair.
+func (p *PairInfo) RoundAmount(amount decimal.Decimal) decimal.Decimal {
+	if p == nil {
+		return amount
+	}
+	return amount.RoundFloor(int32(p.AmountPrecision))
+}
+
+// RoundValue rounds value down to the value precision of the pair.
+func (p *PairInfo) RoundValue(value decimal.Decimal) decimal.Decimal {
+	if p == nil {
+		return value
+	}
+	return value.RoundFloor(int32(p.ValuePrecision))
+}
+
+// CheckMinimum returns the error Payeer rejects an order of amount at price
+// with when it is below MinAmount or MinValue, empty when it passes. A zero
+// price, as of a market order, skips the value check.
+func (p *PairInfo) CheckMinimum(amount, price decimal.Decimal) ResponseErrorCode {
+	if p == nil {
+		return ""
+	}
+	if amount.LessThan(decimal.NewFromFloat(p.MinAmount)) {
+		return ERR_MIN_AMOUNT
+	}
+	if price.IsPositive() && amount.Mul(price).LessThan(decimal.NewFromFloat(p.MinValue)) {
+		return ERR_MIN_VALUE
+	}
+	return ""
+}
+
+func (p *PairInfo) minPrice() (decimal.Decimal, bool) {
+	minPrice, err := decimal.NewFromString(p.MinPrice)
+	return minPrice, err == nil && minPrice.IsPositive()
+}
+
+func (p *PairInfo) maxPrice() (decimal.Decimal, bool) {
+	maxPrice, err := decimal.NewFromString(p.MaxPrice)
+	return maxPrice, err == nil && maxPrice.IsPositive()
+}
+
+// PairInfo returns the info of pair, nil when it is unknown.
+func (r *InfoResponse) PairInfo(pair Pair) *PairInfo {
+	if r == nil {
+		return nil
+	}
+	info, ok := r.Pairs[pair]
+	if !ok {
+		return nil
+	}
+	return &info
+}
@@ -0,0 +1,122 @@
+package payeer
+
+import (
+	"automata/internal/dectest"
+	"testing"
+)
+
+var d = dectest.D
+
+// ethBtc has the precisions of ETH_BTC on Payeer.
+var ethBtc = &PairInfo{
+	PricePrecision:  5,
+	AmountPrecision: 4,
+	ValuePrecision:  6,
+	MinPrice:        "0.001",
+	MaxPrice:        "1",
+	MinAmount:       0.001,
+	MinValue:        0.0001,
+}
+
+func TestTick(t *testing.T) {
+	var unknown *PairInfo
+	if tick := unknown.Tick(); !tick.Equal(d("0.01")) {
+		t.Errorf("tick of an unknown pair = %s, want 0.01", tick)
+	}
+	if tick := ethBtc.Tick(); !tick.Equal(d("0.00001")) {
+		t.Errorf("tick = %s, want 0.00001", tick)
+	}
+}
+
+func TestRoundPrice(t *testing.T) {
+	tests := []struct {
+		name   string
+		info   *PairInfo
+		action Action
+		price  string
+		want   string
+	}{
+		{name: "buy on the tick", info: ethBtc, action: ACTION_BUY, price: "0.05123", want: "0.05123"},
+		{name: "sell on the tick", info: ethBtc, action: ACTION_SELL, price: "0.05123", want: "0.05123"},
+		{name: "buy rounds down", info: ethBtc, action: ACTION_BUY, price: "0.051239", want: "0.05123"},
+		{name: "sell rounds up", info: ethBtc, action: ACTION_SELL, price: "0.051231", want: "0.05124"},
+		{name: "buy just below the tick", info: ethBtc, action: ACTION_BUY, price: "0.0512399999", want: "0.05123"},
+		{name: "sell just above the tick", info: ethBtc, action: ACTION_SELL, price: "0.0512300001", want: "0.05124"},
+		{name: "clamped to the minimum", info: ethBtc, action: ACTION_BUY, price: "0.0009", want: "0.001"},
+		{name: "rounded up to the minimum", info: ethBtc, action: ACTION_SELL, price: "0.000999", want: "0.001"},
+		{name: "clamped to the maximum", info: ethBtc, action: ACTION_SELL, price: "1.5", want: "1"},
+		{name: "no limits", info: &PairInfo{PricePrecision: 2}, action: ACTION_SELL, price: "123456.781", want: "123456.79"},
+		{name: "unknown pair", action: ACTION_BUY, price: "0.051239", want: "0.051239"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.info.RoundPrice(tt.action, d(tt.price)); !got.Equal(d(tt.want)) {
+				t.Errorf("RoundPrice(%s) = %s, want %s", tt.price, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPrice(t *testing.T) {
+	tests := []struct {
+		price string
+		ok    bool
+	}{
+		{price: "0.05123", ok: true},
+		{price: "0.001", ok: true},
+		{price: "1", ok: true},
+		{price: "0.051231"},
+		{price: "0.00099"},
+		{price: "1.00001"},
+		{price: "0"},
+		{price: "-0.05"},
+	}
+	for _, tt := range tests {
+		if err := ethBtc.CheckPrice(d(tt.price)); (err == nil) != tt.ok {
+			t.Errorf("CheckPrice(%s) = %v, want ok %t", tt.price, err, tt.ok)
+		}
+	}
+}
+
+func TestRoundAmountAndValue(t *testing.T) {
+	tests := []struct {
+		value, amount, rounded string
+	}{
+		{value: "1.23456789", amount: "1.2345", rounded: "1.234567"},
+		{value: "0.00009999", amount: "0", rounded: "0.000099"},
+		{value: "2", amount: "2", rounded: "2"},
+	}
+	for _, tt := range tests {
+		if got := ethBtc.RoundAmount(d(tt.value)); !got.Equal(d(tt.amount)) {
+			t.Errorf("RoundAmount(%s) = %s, want %s", tt.value, got, tt.amount)
+		}
+		if got := ethBtc.RoundValue(d(tt.value)); !got.Equal(d(tt.rounded)) {
+			t.Errorf("RoundValue(%s) = %s, want %s", tt.value, got, tt.rounded)
+		}
+	}
+}
+
+func TestCheckMinimum(t *testing.T) {
+	tests := []struct {
+		name   string
+		info   *PairInfo
+		amount string
+		price  string
+		want   ResponseErrorCode
+	}{
+		{name: "at the minimums", info: ethBtc, amount: "0.002", price: "0.05"},
+		{name: "at the minimum amount", info: ethBtc, amount: "0.001", price: "0.1"},
+		{name: "below the minimum amount", info: ethBtc, amount: "0.0009", price: "0.1", want: ERR_MIN_AMOUNT},
+		{name: "below the minimum value", info: ethBtc, amount: "0.001", price: "0.05", want: ERR_MIN_VALUE},
+		{name: "market order", info: ethBtc, amount: "0.001", price: "0"},
+		{name: "market order below the minimum amount", info: ethBtc, amount: "0.0009", price: "0", want: ERR_MIN_AMOUNT},
+		{name: "unknown pair", amount: "0", price: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.info.CheckMinimum(d(tt.amount), d(tt.price)); got != tt.want {
+				t.Errorf("CheckMinimum(%s, %s) = %q, want %q", tt.amount, tt.price, got, tt.want)
+			}
+		})
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"automata/signer"
 	"encoding/json"
 	"errors"
+	"time"
 )
 
 // Request Weight: 5 (10 for a market order)
-func (p *Client) PlaceOrder(req *PostOrderRequest) (*PostOrderResponse, error) {
+func (p *Client) PlaceOrder(req *PostOrderRequest) (_ *PostOrderResponse, err error) {
+	defer observe("order_create", time.Now(), &err)
 	req.Timestamp = getTimestamp()
 	body := mustMarshalJson(req)
 	sign := p.signBody("order_create", body)
@@ -32,7 +34,8 @@ func (p *Client) PlaceOrder(req *PostOrderRequest) (*PostOrderResponse, error) {
 }
 
 // Request Weight: 5
-func (p *Client) OrderStatus(req *OrderStatusRequest) (*OrderStatusResponse, error) {
+func (p *Client) OrderStatus(req *OrderStatusRequest) (_ *OrderStatusResponse, err error) {
+	defer observe("order_status", time.Now(), &err)
 	req.Timestamp = getTimestamp()
 	body := mustMarshalJson(req)
 	sign := p.signBody("order_status", body)
@@ -56,7 +59,8 @@ func (p *Client) OrderStatus(req *OrderStatusRequest) (*OrderStatusResponse, err
 }
 
 // Request Weight: 10
-func (p *Client) Balance() (*BalanceResponse, error) {
+func (p *Client) Balance() (_ *BalanceResponse, err error) {
+	defer observe("account", time.Now(), &err)
 	req := BalanceRequest{}
 	req.Timestamp = getTimestamp()
 	body := mustMarshalJson(req)
@@ -82,7 +86,8 @@ func (p *Client) Balance() (*BalanceResponse, error) {
 }
 
 // Request Weight: 10
-func (p *Client) CancelOrder(req *CancelOrderRequest) (*CancelOrderResponse, error) {
+func (p *Client) CancelOrder(req *CancelOrderRequest) (_ *CancelOrderResponse, err error) {
+	defer observe("order_cancel", time.Now(), &err)
 	req.Timestamp = getTimestamp()
 	body := mustMarshalJson(req)
 	sign := p.signBody("order_cancel", body)
@@ -106,7 +111,8 @@ func (p *Client) CancelOrder(req *CancelOrderRequest) (*CancelOrderResponse, err
 }
 
 // Request Weight: 60
-func (p *Client) MyOrders(req *MyOrdersRequest) (*MyOrdersResponse, error) {
+func (p *Client) MyOrders(req *MyOrdersRequest) (_ *MyOrdersResponse, err error) {
+	defer observe("my_orders", time.Now(), &err)
 	req.Timestamp = getTimestamp()
 	body := mustMarshalJson(req)
 	sign := p.signBody("my_orders", body)
@@ -2,9 +2,11 @@ package payeer
 
 import (
 	"errors"
+	"time"
 )
 
-func (p *Client) Info() (*InfoResponse, error) {
+func (p *Client) Info() (_ *InfoResponse, err error) {
+	defer observe("info", time.Now(), &err)
 	fastResp, err := p.httpClient.
 		GET("/info").
 		Send()
@@ -24,7 +26,8 @@ func (p *Client) Info() (*InfoResponse, error) {
 }
 
 // Request Weight: 1 * count of pairs
-func (p *Client) Orders(pairs []Pair) (*OrdersResponse, error) {
+func (p *Client) Orders(pairs []Pair) (_ *OrdersResponse, err error) {
+	defer observe("orders", time.Now(), &err)
 	req := &OrdersRequest{Pairs: joinPairs(pairs)}
 	fastResp, err := p.httpClient.GET("/orders").Body().AsJSON(req).Send()
 	if err != nil {
@@ -43,7 +46,8 @@ func (p *Client) Orders(pairs []Pair) (*OrdersResponse, error) {
 }
 
 // Request Weight: 1 * count of pairs
-func (p *Client) Trades(pairs []Pair) (*TradesResponse, error) {
+func (p *Client) Trades(pairs []Pair) (_ *TradesResponse, err error) {
+	defer observe("trades", time.Now(), &err)
 	req := &TradesRequest{Pairs: joinPairs(pairs)}
 	fastResp, err := p.httpClient.GET("/trades").Body().AsJSON(req).Send()
 	if err != nil {
@@ -62,7 +66,8 @@ func (p *Client) Trades(pairs []Pair) (*TradesResponse, error) {
 }
 
 // Request Weight: 1
-func (p *Client) Tickers(pairs []Pair) (*TickersResponse, error) {
+func (p *Client) Tickers(pairs []Pair) (_ *TickersResponse, err error) {
+	defer observe("ticker", time.Now(), &err)
 	req := &TickersRequest{Pairs: joinPairs(pairs)}
 	fastResp, err := p.httpClient.GET("/ticker").Body().AsJSON(req).Send()
 	if err != nil {
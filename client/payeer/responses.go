@@ -19,10 +19,14 @@ const (
 	ERR_INCORRECT_PRICE           ResponseErrorCode = "INCORRECT_PRICE"
 	ERR_MIN_AMOUNT                ResponseErrorCode = "MIN_AMOUNT"
 	ERR_MIN_VALUE                 ResponseErrorCode = "MIN_VALUE"
+	// Set locally when the risk manager refuses to send a request; Message
+	// carries the reason.
+	ERR_RISK_REJECTED ResponseErrorCode = "RISK_REJECTED"
 )
 
 type ResponseError struct {
-	Code ResponseErrorCode `json:"code"`
+	Code    ResponseErrorCode `json:"code"`
+	Message string            `json:"-"`
 }
 
 type BaseResponse struct {
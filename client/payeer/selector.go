@@ -2,154 +2,287 @@ package payeer
 
 import (
 	"automata/client/binance"
-	"automata/msync"
+	"cmp"
+	"fmt"
 	"log/slog"
 
 	"github.com/shopspring/decimal"
 )
 
-var cent = decimal.RequireFromString(".01")
+// Tickers looks up the reference book ticker of a symbol. A msync.MuMap of
+// Binance tickers is one; the market's reference prices are another.
+type Tickers interface {
+	Get(symbol binance.Symbol) (binance.OrderBookTickerStreamResult, bool)
+}
 
 type PayeerPriceSelectorContext struct {
 	info           *PairsOrderInfo
 	action         Action
-	binanceTickers *msync.MuMap[binance.Symbol, binance.OrderBookTickerStreamResult]
+	symbol         binance.Symbol
+	pairInfo       *PairInfo
+	edge           *Edge
+	binanceTickers Tickers
 }
 
 type PayeerPriceSelectorConfig struct {
-	PlacementValueOffset    decimal.Decimal
-	Symbol                  binance.Symbol
-	ElevationPriceFraction  decimal.Decimal
-	MaxWmaSurplus           decimal.Decimal
-	WmaTake                 int
-	WmaTakeAmount           decimal.Decimal
-	BidMaxBinancePriceRatio decimal.Decimal
-	AskMinBinancePriceRatio decimal.Decimal
+	PlacementValueOffset    decimal.Decimal `json:"placementValueOffset"`
+	Symbol                  binance.Symbol  `json:"symbol"`
+	ElevationPriceFraction  decimal.Decimal `json:"elevationPriceFraction"`
+	MaxWmaSurplus           decimal.Decimal `json:"maxWmaSurplus"`
+	WmaTake                 int             `json:"wmaTake"`
+	WmaTakeAmount           decimal.Decimal `json:"wmaTakeAmount"`
+	BidMaxBinancePriceRatio decimal.Decimal `json:"bidMaxBinancePriceRatio"`
+	AskMinBinancePriceRatio decimal.Decimal `json:"askMinBinancePriceRatio"`
+	// Edge tightens the Binance ratios of the stages to the target net edge
+	// after fees; the ratios apply as they are when it is nil.
+	Edge *Edge `json:"edge,omitempty"`
+	// Pipelines replace the default pipeline for a pair and side.
+	Pipelines map[Pair]map[Action]*PipelineConfig `json:"pipelines,omitempty"`
+}
+
+// DefaultPipeline is the pipeline of the fields of the config: the value
+// offset level elevated within the price fraction and filtered by the Binance
+// ratio, falling back to the Binance ratio price.
+func (c *PayeerPriceSelectorConfig) DefaultPipeline(action Action) *PipelineConfig {
+	ratio := c.BidMaxBinancePriceRatio
+	if action == ACTION_SELL {
+		ratio = c.AskMinBinancePriceRatio
+	}
+	return &PipelineConfig{
+		Stages: []StageConfig{
+			NewStageConfig(STAGE_SELECT_BY_VALUE_OFFSET, &ValueOffsetParams{ValueOffset: c.PlacementValueOffset}),
+			NewStageConfig(STAGE_SELECT_BY_ELEVATION, &ElevationParams{PriceFraction: c.ElevationPriceFraction}),
+			NewStageConfig(STAGE_FILTER_BY_BINANCE_PRICE, &BinanceRatioParams{Ratio: ratio}),
+		},
+		Fallbacks: [][]StageConfig{
+			{NewStageConfig(STAGE_SELECT_BY_BINANCE_RATIO, &BinanceRatioParams{Ratio: ratio})},
+		},
+	}
+}
+
+// PipelineConfig returns the configured pipeline of the pair and side, or the
+// default one.
+func (c *PayeerPriceSelectorConfig) PipelineConfig(pair Pair, action Action) *PipelineConfig {
+	if pipeline := c.Pipelines[pair][action]; pipeline != nil {
+		return pipeline
+	}
+	return c.DefaultPipeline(action)
 }
 
 type PayeerPriceSelector struct {
 	Config         *PayeerPriceSelectorConfig
-	binanceTickers *msync.MuMap[binance.Symbol, binance.OrderBookTickerStreamResult]
+	binanceTickers Tickers
+	info           *InfoResponse
+	defaults       map[Action]*Pipeline
+	pipelines      map[Pair]map[Action]*Pipeline
 }
 
-func NewPayeerPriceSelector(
+// NewPipelineSelector compiles the default and the configured pipelines.
+func NewPipelineSelector(
 	config *PayeerPriceSelectorConfig,
-	binanceTickers *msync.MuMap[binance.Symbol, binance.OrderBookTickerStreamResult],
-) *PayeerPriceSelector {
-	return &PayeerPriceSelector{
+	binanceTickers Tickers,
+) (*PayeerPriceSelector, error) {
+	ps := &PayeerPriceSelector{
 		Config:         config,
 		binanceTickers: binanceTickers,
+		defaults:       map[Action]*Pipeline{},
+		pipelines:      map[Pair]map[Action]*Pipeline{},
 	}
+	for _, action := range []Action{ACTION_BUY, ACTION_SELL} {
+		pipeline, err := NewPipeline(config.DefaultPipeline(action))
+		if err != nil {
+			return nil, fmt.Errorf("default %s pipeline: %w", action, err)
+		}
+		ps.defaults[action] = pipeline
+	}
+	for pair, sides := range config.Pipelines {
+		ps.pipelines[pair] = map[Action]*Pipeline{}
+		for action, pipelineConfig := range sides {
+			pipeline, err := NewPipeline(pipelineConfig)
+			if err != nil {
+				return nil, fmt.Errorf("pipelines.%s.%s: %w", pair, action, err)
+			}
+			ps.pipelines[pair][action] = pipeline
+		}
+	}
+	return ps, nil
 }
 
-func (ps *PayeerPriceSelector) SelectPrice(action Action, info *PairsOrderInfo) (bool, decimal.Decimal) {
+// NewPayeerPriceSelector is NewPipelineSelector for configs that are known to
+// be valid; it panics otherwise.
+func NewPayeerPriceSelector(
+	config *PayeerPriceSelectorConfig,
+	binanceTickers Tickers,
+) *PayeerPriceSelector {
+	ps, err := NewPipelineSelector(config, binanceTickers)
+	if err != nil {
+		panic(err)
+	}
+	return ps
+}
+
+// Stage is the output of one selector stage.
+type Stage struct {
+	Name string `json:"name"`
+	// Chain is 0 for the stages of the pipeline and n for its n-th fallback.
+	Chain int             `json:"chain,omitempty"`
+	Ok    bool            `json:"ok"`
+	Price decimal.Decimal `json:"price"`
+}
+
+// Selection is the price the selector picked and the output of every stage
+// that ran, in order.
+type Selection struct {
+	Ok     bool            `json:"ok"`
+	Price  decimal.Decimal `json:"price"`
+	Stages []Stage         `json:"stages"`
+}
+
+// SetInfo gives the selector the tick sizes and price limits of the pairs.
+// Until it is called prices move by the tick of the default precision and are
+// not rounded.
+func (ps *PayeerPriceSelector) SetInfo(info *InfoResponse) {
+	ps.info = info
+}
+
+// SelectPair runs the pipeline configured for the pair and side, or the
+// default one.
+func (ps *PayeerPriceSelector) SelectPair(pair Pair, action Action, info *PairsOrderInfo) *Selection {
+	return ps.run(ps.pipeline(pair, action), ps.info.PairInfo(pair), action, info)
+}
+
+// SymbolFor returns the Binance symbol the pipeline of the pair and side refers to.
+func (ps *PayeerPriceSelector) SymbolFor(pair Pair, action Action) binance.Symbol {
+	if symbol := ps.pipeline(pair, action).Symbol; symbol != "" {
+		return symbol
+	}
+	return ps.Config.Symbol
+}
+
+func (ps *PayeerPriceSelector) pipeline(pair Pair, action Action) *Pipeline {
+	if pipeline := ps.pipelines[pair][action]; pipeline != nil {
+		return pipeline
+	}
+	return ps.defaults[action]
+}
+
+// run runs the pipeline and rounds the price it arrives at to the tick and
+// price limits of the pair.
+func (ps *PayeerPriceSelector) run(pipeline *Pipeline, pairInfo *PairInfo, action Action, info *PairsOrderInfo) *Selection {
 	pctx := &PayeerPriceSelectorContext{
 		info:           info,
 		action:         action,
+		symbol:         cmp.Or(pipeline.Symbol, ps.Config.Symbol),
+		pairInfo:       pairInfo,
+		edge:           ps.Config.Edge,
 		binanceTickers: ps.binanceTickers,
 	}
+	selection := &Selection{}
+	selection.Ok, selection.Price = pipeline.run(pctx, selection)
+	if selection.Ok {
+		selection.Price = pairInfo.RoundPrice(action, selection.Price)
+	}
+	return selection
+}
 
-	ok, price := ps.pipe(
-		pctx,
-		ps.selectByValueOffset,
-		ps.selectByElevation,
-		ps.filterByBinancePrice,
-	)
+func selectByBinanceRatio(pctx *PayeerPriceSelectorContext, prevPrice decimal.Decimal, ratio decimal.Decimal) (bool, decimal.Decimal) {
+	binanceTickersData, ok := pctx.binanceTickers.Get(pctx.symbol)
+	if !ok {
+		slog.Error("[PayeerPriceSelector] binance price not found", "symbol", pctx.symbol)
+		return false, prevPrice
+	}
+	ratio = pctx.edge.Limit(pctx.action, pctx.pairInfo.MakerFee(), ratio)
 
-	if ok {
-		return ok, price
+	var binancePrice decimal.Decimal
+	if pctx.action == ACTION_BUY {
+		binancePrice = decimal.RequireFromString(binanceTickersData.BidPrice)
 	} else {
-		binanceTickersData, ok := pctx.binanceTickers.Get(ps.Config.Symbol)
-		if !ok {
-			slog.Error("[PayeerPriceSelector] binance price not found", "symbol", ps.Config.Symbol)
-			return false, price
-		}
-
-		var binancePrice decimal.Decimal
-		var ratio decimal.Decimal
-		if pctx.action == ACTION_BUY {
-			binancePrice = decimal.RequireFromString(binanceTickersData.BidPrice)
-			ratio = ps.Config.BidMaxBinancePriceRatio
-		} else {
-			binancePrice = decimal.RequireFromString(binanceTickersData.AskPrice)
-			ratio = ps.Config.AskMinBinancePriceRatio
-		}
+		binancePrice = decimal.RequireFromString(binanceTickersData.AskPrice)
+	}
 
-		price = binancePrice.Mul(ratio)
+	price := binancePrice.Mul(ratio)
 
-		slog.Info("[PayeerPriceSelector] binance price multiplied", "action", action, "original", binancePrice.String(), "ratio", ratio.String(), "multiplied", price.String())
+	slog.Info("[PayeerPriceSelector] binance price multiplied", "action", pctx.action, "original", binancePrice.String(), "ratio", ratio.String(), "multiplied", price.String())
 
-		orders := ps.resolveOrders(pctx)
+	orders := resolveOrders(pctx)
 
-		var priceFound func(orderPrice decimal.Decimal) bool
-		var elevate func(orderPrice decimal.Decimal) decimal.Decimal
+	var priceFound func(orderPrice decimal.Decimal) bool
+	var elevate func(orderPrice decimal.Decimal) decimal.Decimal
 
-		if pctx.action == ACTION_BUY {
-			priceFound = func(orderPrice decimal.Decimal) bool { return orderPrice.LessThan(price) }
-			elevate = func(orderPrice decimal.Decimal) decimal.Decimal { return orderPrice.Add(cent) }
-		} else {
-			priceFound = func(orderPrice decimal.Decimal) bool { return orderPrice.GreaterThan(price) }
-			elevate = func(orderPrice decimal.Decimal) decimal.Decimal { return orderPrice.Sub(cent) }
-		}
+	tick := pctx.pairInfo.Tick()
+	if pctx.action == ACTION_BUY {
+		priceFound = func(orderPrice decimal.Decimal) bool { return orderPrice.LessThan(price) }
+		elevate = func(orderPrice decimal.Decimal) decimal.Decimal { return orderPrice.Add(tick) }
+	} else {
+		priceFound = func(orderPrice decimal.Decimal) bool { return orderPrice.GreaterThan(price) }
+		elevate = func(orderPrice decimal.Decimal) decimal.Decimal { return orderPrice.Sub(tick) }
+	}
 
-		for i, order := range orders {
-			orderPrice := decimal.RequireFromString(order.Price)
-			if priceFound(orderPrice) {
-				price = elevate(orderPrice)
-				for j := i - 1; j >= 0; j-- {
-					topPrice := decimal.RequireFromString(orders[j].Price)
-					if topPrice.Equal(price) {
-						price = elevate(price)
-					} else {
-						break
-					}
+	for i, order := range orders {
+		orderPrice := decimal.RequireFromString(order.Price)
+		if priceFound(orderPrice) {
+			price = elevate(orderPrice)
+			for j := i - 1; j >= 0; j-- {
+				topPrice := decimal.RequireFromString(orders[j].Price)
+				if topPrice.Equal(price) {
+					price = elevate(price)
+				} else {
+					break
 				}
-				break
 			}
+			break
 		}
-		return true, price
 	}
+	return true, price
 }
 
-func (ps *PayeerPriceSelector) filterByBinancePrice(pctx *PayeerPriceSelectorContext, prevPrice decimal.Decimal) (bool, decimal.Decimal) {
-	binanceTickersData, ok := pctx.binanceTickers.Get(ps.Config.Symbol)
+// filterByBinancePrice accepts a buy below ratio times the Binance bid and a
+// sell above ratio times the Binance ask, with the ratio tightened to the edge.
+func filterByBinancePrice(pctx *PayeerPriceSelectorContext, prevPrice decimal.Decimal, ratio decimal.Decimal) (bool, decimal.Decimal) {
+	binanceTickersData, ok := pctx.binanceTickers.Get(pctx.symbol)
 	if !ok {
-		slog.Error("[PayeerPriceSelector] binance price not found", "symbol", ps.Config.Symbol)
+		slog.Error("[PayeerPriceSelector] binance price not found", "symbol", pctx.symbol)
 		return false, prevPrice
 	}
+	ratio = pctx.edge.Limit(pctx.action, pctx.pairInfo.MakerFee(), ratio)
 
 	var binancePrice decimal.Decimal
 	if pctx.action == ACTION_BUY {
 		binancePrice = decimal.RequireFromString(binanceTickersData.BidPrice)
-		ok = prevPrice.Div(binancePrice).LessThan(ps.Config.BidMaxBinancePriceRatio)
+		ok = prevPrice.Div(binancePrice).LessThan(ratio)
 	} else {
 		binancePrice = decimal.RequireFromString(binanceTickersData.AskPrice)
-		ok = prevPrice.Div(binancePrice).GreaterThan(ps.Config.AskMinBinancePriceRatio)
+		ok = prevPrice.Div(binancePrice).GreaterThan(ratio)
 	}
 
 	slog.Info("[PayeerPriceSelector] filter by binance price", "action", pctx.action, "ok", ok, "binance price", binancePrice.String(), "price", prevPrice.String())
 	return ok, prevPrice
 }
 
-func (ps *PayeerPriceSelector) filterByWmaRatio(pctx *PayeerPriceSelectorContext, prevPrice decimal.Decimal) (bool, decimal.Decimal) {
-	orders := ps.resolveOrders(pctx)
-	wma := ps.getWeightedMeanAverage(orders)
+func filterByWmaRatio(pctx *PayeerPriceSelectorContext, prevPrice decimal.Decimal, params *WmaRatioParams) (bool, decimal.Decimal) {
+	orders := resolveOrders(pctx)
+	if len(orders) == 0 {
+		slog.Warn("[PayeerPriceSelector] no orders for wma", "action", pctx.action)
+		return false, prevPrice
+	}
+	wma := getWeightedMeanAverage(orders, params.Take, params.TakeAmount)
 	isOk := false
 	var wmaVal decimal.Decimal
 	if pctx.action == ACTION_SELL {
-		wmaVal = decimal.NewFromInt(1).Sub(ps.Config.MaxWmaSurplus).Mul(wma)
+		wmaVal = decimal.NewFromInt(1).Sub(params.MaxSurplus).Mul(wma)
 		isOk = prevPrice.GreaterThan(wmaVal)
 	} else {
-		wmaVal = decimal.NewFromInt(1).Add(ps.Config.MaxWmaSurplus).Mul(wma)
+		wmaVal = decimal.NewFromInt(1).Add(params.MaxSurplus).Mul(wma)
 		isOk = prevPrice.LessThan(wmaVal)
 	}
-	slog.Info("[PayeerPriceSelector] filtered by wma ratio", "ok", isOk, "action", pctx.action, "price", prevPrice.String(), "wma", wma.String(), "wma surplus", ps.Config.MaxWmaSurplus.StringFixed(6), "wma adjusted", wmaVal.String())
+	slog.Info("[PayeerPriceSelector] filtered by wma ratio", "ok", isOk, "action", pctx.action, "price", prevPrice.String(), "wma", wma.String(), "wma surplus", params.MaxSurplus.StringFixed(6), "wma adjusted", wmaVal.String())
 	return isOk, prevPrice
 }
 
-func (ps *PayeerPriceSelector) selectByElevation(pctx *PayeerPriceSelectorContext, prevPrice decimal.Decimal) (bool, decimal.Decimal) {
-	fractionAbs := ps.Config.ElevationPriceFraction.Mul(prevPrice)
-	orders := ps.resolveOrders(pctx)
+func selectByElevation(pctx *PayeerPriceSelectorContext, prevPrice decimal.Decimal, priceFraction decimal.Decimal) (bool, decimal.Decimal) {
+	fractionAbs := priceFraction.Mul(prevPrice)
+	tick := pctx.pairInfo.Tick()
+	orders := resolveOrders(pctx)
 	prevPriceIndex := 0
 	for i, order := range orders {
 		orderPrice := decimal.RequireFromString(order.Price)
@@ -163,15 +296,15 @@ func (ps *PayeerPriceSelector) selectByElevation(pctx *PayeerPriceSelectorContex
 		}
 	}
 	afterPrice := prevPrice.Copy()
-	for i := prevPriceIndex; i >= 0; i-- {
+	for i := min(prevPriceIndex, len(orders)-1); i >= 0; i-- {
 		price := decimal.RequireFromString(orders[i].Price)
 		diff := price.Sub(afterPrice)
 		if pctx.action == ACTION_SELL {
 			diff = afterPrice.Sub(price)
 		}
 		if diff.LessThanOrEqual(fractionAbs) && diff.GreaterThanOrEqual(decimal.Zero) {
-			afterPrice = ps.elevatePrice(pctx, afterPrice, diff.Add(cent))
-			fractionAbs = fractionAbs.Sub(diff.Add(cent))
+			afterPrice = elevatePrice(pctx, afterPrice, diff.Add(tick))
+			fractionAbs = fractionAbs.Sub(diff.Add(tick))
 			if fractionAbs.LessThanOrEqual(decimal.Zero) {
 				break
 			}
@@ -181,19 +314,19 @@ func (ps *PayeerPriceSelector) selectByElevation(pctx *PayeerPriceSelectorContex
 	return true, afterPrice
 }
 
-func (ps *PayeerPriceSelector) selectByValueOffset(pctx *PayeerPriceSelectorContext, prevPrice decimal.Decimal) (bool, decimal.Decimal) {
+func selectByValueOffset(pctx *PayeerPriceSelectorContext, prevPrice decimal.Decimal, valueOffset decimal.Decimal) (bool, decimal.Decimal) {
 	acc := decimal.NewFromInt(0)
 	var selectedPrice decimal.Decimal
-	orders := ps.resolveOrders(pctx)
+	orders := resolveOrders(pctx)
 	for _, order := range orders {
 		value, _ := decimal.NewFromString(order.Value)
 		acc = acc.Add(value)
-		if acc.GreaterThanOrEqual(ps.Config.PlacementValueOffset) {
+		if acc.GreaterThanOrEqual(valueOffset) {
 			price, err := decimal.NewFromString(order.Price)
 			if err != nil {
 				panic(err)
 			}
-			selectedPrice = ps.elevatePrice(pctx, price, cent)
+			selectedPrice = elevatePrice(pctx, price, pctx.pairInfo.Tick())
 			break
 		}
 	}
@@ -201,7 +334,7 @@ func (ps *PayeerPriceSelector) selectByValueOffset(pctx *PayeerPriceSelectorCont
 	return true, selectedPrice
 }
 
-func (ps *PayeerPriceSelector) elevatePrice(pctx *PayeerPriceSelectorContext, price decimal.Decimal, diff decimal.Decimal) decimal.Decimal {
+func elevatePrice(pctx *PayeerPriceSelectorContext, price decimal.Decimal, diff decimal.Decimal) decimal.Decimal {
 	if pctx.action == ACTION_SELL {
 		return price.Sub(diff)
 	} else {
@@ -209,7 +342,7 @@ func (ps *PayeerPriceSelector) elevatePrice(pctx *PayeerPriceSelectorContext, pr
 	}
 }
 
-func (ps *PayeerPriceSelector) resolveOrders(pctx *PayeerPriceSelectorContext) []OrdersOrder {
+func resolveOrders(pctx *PayeerPriceSelectorContext) []OrdersOrder {
 	if pctx.action == ACTION_SELL {
 		return pctx.info.Asks
 	} else {
@@ -219,13 +352,23 @@ func (ps *PayeerPriceSelector) resolveOrders(pctx *PayeerPriceSelectorContext) [
 
 type PayeerPipeFn = func(pctx *PayeerPriceSelectorContext, prevPrice decimal.Decimal) (bool, decimal.Decimal)
 
-func (ps *PayeerPriceSelector) pipe(
+type namedStage struct {
+	name string
+	fn   PayeerPipeFn
+}
+
+// pipe runs the stages of a chain in order, recording each output in
+// selection, and stops at the first one that rejects the price.
+func pipe(
 	pctx *PayeerPriceSelectorContext,
-	fns ...PayeerPipeFn,
+	selection *Selection,
+	chain int,
+	stages ...namedStage,
 ) (bool, decimal.Decimal) {
 	prevPrice := decimal.Zero
-	for _, fn := range fns {
-		ok, price := fn(pctx, prevPrice)
+	for _, stage := range stages {
+		ok, price := stage.fn(pctx, prevPrice)
+		selection.Stages = append(selection.Stages, Stage{Name: stage.name, Chain: chain, Ok: ok, Price: price})
 		if !ok {
 			return false, price
 		}
@@ -234,7 +377,7 @@ func (ps *PayeerPriceSelector) pipe(
 	return true, prevPrice
 }
 
-func (ps *PayeerPriceSelector) getWeightedMeanAverage(orders []OrdersOrder) decimal.Decimal {
+func getWeightedMeanAverage(orders []OrdersOrder, take int, takeAmount decimal.Decimal) decimal.Decimal {
 	totalValue := decimal.NewFromInt(0)
 	totalAmount := decimal.NewFromInt(0)
 	for i, order := range orders {
@@ -242,10 +385,39 @@ func (ps *PayeerPriceSelector) getWeightedMeanAverage(orders []OrdersOrder) deci
 		amount, _ := decimal.NewFromString(order.Amount)
 		totalValue = totalValue.Add(value)
 		totalAmount = totalAmount.Add(amount)
-		if (ps.Config.WmaTake > 0 && i == ps.Config.WmaTake) ||
-			(ps.Config.WmaTakeAmount.IsPositive() && totalAmount.GreaterThan(ps.Config.WmaTakeAmount)) {
+		if (take > 0 && i == take) ||
+			(takeAmount.IsPositive() && totalAmount.GreaterThan(takeAmount)) {
 			break
 		}
 	}
 	return totalValue.Div(totalAmount)
 }
+
+// TopValueOffset sums the value of the book levels standing ahead of the price.
+func TopValueOffset(price decimal.Decimal, orders *PairsOrderInfo, action Action) decimal.Decimal {
+	acc := decimal.NewFromInt(0)
+	prices := orders.Bids
+	if action == ACTION_SELL {
+		prices = orders.Asks
+	}
+	for _, order := range prices {
+		orderPrice, err := decimal.NewFromString(order.Price)
+		if err != nil {
+			panic(err)
+		}
+		shouldInclude := orderPrice.GreaterThan(price)
+		if action == ACTION_SELL {
+			shouldInclude = orderPrice.LessThan(price)
+		}
+		if shouldInclude {
+			orderValue, err := decimal.NewFromString(order.Value)
+			if err != nil {
+				panic(err)
+			}
+			acc = acc.Add(orderValue)
+		} else {
+			break
+		}
+	}
+	return acc
+}
@@ -1,6 +1,9 @@
 package payeer
 
-import "strings"
+import (
+	"slices"
+	"strings"
+)
 
 // Trade sides
 type Action string
@@ -86,6 +89,25 @@ const (
 	// "USDC_USDT"
 )
 
+// KnownPairs are the pairs the strategies may be configured with.
+var KnownPairs = []Pair{
+	PAIR_BTCUSD,
+	PAIR_BTCRUB,
+	PAIR_BTCEUR,
+	PAIR_BTCUSDT,
+	PAIR_ETHUSD,
+	PAIR_ETHRUB,
+	PAIR_ETHEUR,
+	PAIR_ETHBTC,
+	PAIR_ETHUSDT,
+	PAIR_USDRUB,
+	PAIR_USDTRUB,
+}
+
+func (p Pair) IsKnown() bool {
+	return slices.Contains(KnownPairs, p)
+}
+
 func (p Pair) String() string {
 	return string(p)
 }
@@ -49,6 +49,7 @@ type OrderBookTicker struct {
 	BidQuantity float64
 	AskPrice    float64
 	AskQuantity float64
+	Timestamp   time.Time
 }
 
 const (
@@ -88,6 +89,28 @@ type OrderUpdate struct {
 	Status             int
 }
 
+// OrderStatus is the execution state of an order.
+type OrderStatus struct {
+	Symbol              Symbol
+	Id                  string
+	Status              string
+	ExecutedQty         float64
+	CummulativeQuoteQty float64
+}
+
+// SymbolRules are the trading rules of a symbol that an order has to meet.
+type SymbolRules struct {
+	Symbol     Symbol
+	BaseAsset  string
+	QuoteAsset string
+	// PriceTick and AmountStep are the price and quantity steps.
+	PriceTick  float64
+	AmountStep float64
+	MinAmount  float64
+	// MinNotional is the smallest quote value of an order.
+	MinNotional float64
+}
+
 type PartialDepthPair struct {
 	Price    float64
 	Quantity float64
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"automata/backtest"
+	"automata/client/binance"
+	"automata/client/payeer"
+	"automata/client/payeer/paper"
+	"automata/recorder"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func main() {
+	dataFlag := flag.String("data", "", "recorder directory to replay")
+	fromFlag := flag.String("from", "", "replay records received at or after this RFC3339 time")
+	toFlag := flag.String("to", "", "replay records received at or before this RFC3339 time")
+	eventsFlag := flag.String("events", "", "comma separated JSONL event files (optionally .gz) to replay")
+	infoFlag := flag.String("info", "", "saved /info response; fetched from Payeer when empty")
+	strategyFlag := flag.String("strategy", "shares", "strategy to replay: shares or value-offset")
+	balancesFlag := flag.String("balances", "USDT=1000,ETH=0.5", "starting virtual balances")
+	horizonFlag := flag.Duration("horizon", time.Minute, "markout horizon for adverse selection")
+	jsonFlag := flag.Bool("json", false, "print the report as JSON")
+	inventoryFlag := flag.String("inventory", "", "write the inventory path as CSV to this file")
+	flag.Parse()
+
+	slog.SetLogLoggerLevel(slog.LevelWarn)
+
+	balances, err := paper.ParseBalances(*balancesFlag)
+	if err != nil {
+		fatal("Invalid balances", err)
+	}
+
+	info, err := loadInfo(*infoFlag)
+	if err != nil {
+		fatal("Failed to load pair info", err)
+	}
+
+	sources := []backtest.Source{}
+	if *dataFlag != "" {
+		readerOptions := &recorder.ReaderOptions{
+			Venues: []recorder.Venue{recorder.VENUE_PAYEER, recorder.VENUE_BINANCE},
+		}
+		if readerOptions.From, err = parseTime(*fromFlag); err != nil {
+			fatal("Invalid -from", err)
+		}
+		if readerOptions.To, err = parseTime(*toFlag); err != nil {
+			fatal("Invalid -to", err)
+		}
+		reader, err := recorder.Open(*dataFlag, readerOptions)
+		if err != nil {
+			fatal("Failed to open recorder data", err)
+		}
+		defer reader.Close()
+		sources = append(sources, backtest.NewRecordedSource(reader))
+	}
+	for _, name := range strings.Split(*eventsFlag, ",") {
+		if name == "" {
+			continue
+		}
+		r, err := openEvents(name)
+		if err != nil {
+			fatal("Failed to open events", err)
+		}
+		defer r.Close()
+		sources = append(sources, backtest.NewJsonlSource(r))
+	}
+	if len(sources) == 0 {
+		fatal("No events specified", nil)
+	}
+
+	var strategy backtest.Strategy
+	switch *strategyFlag {
+	case "shares":
+		strategy = backtest.NewSharesStrategy(sharesOptions())
+	case "value-offset":
+		strategy = backtest.NewValueOffsetStrategy(valueOffsetOptions())
+	default:
+		fatal("Unknown strategy "+*strategyFlag, nil)
+	}
+
+	engine := backtest.NewEngine(&backtest.Options{
+		Info:           info,
+		Balances:       balances,
+		Strategies:     []backtest.Strategy{strategy},
+		MarkoutHorizon: *horizonFlag,
+	})
+	report, err := engine.Run(backtest.Merge(sources...))
+	if err != nil {
+		fatal("Backtest failed", err)
+	}
+
+	if *jsonFlag {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		report.WriteText(os.Stdout)
+	}
+
+	if *inventoryFlag != "" {
+		f, err := os.Create(*inventoryFlag)
+		if err != nil {
+			fatal("Failed to create inventory file", err)
+		}
+		defer f.Close()
+		if err := report.WriteInventoryCsv(f); err != nil {
+			fatal("Failed to write inventory file", err)
+		}
+	}
+}
+
+func sharesOptions() []backtest.SharesShare {
+	return []backtest.SharesShare{
+		{
+			ID:                "BUYER-1",
+			Action:            payeer.ACTION_BUY,
+			Pair:              payeer.PAIR_ETHUSDT,
+			BinanceSymbol:     binance.SYMBOL_ETHUSDT,
+			Share:             decimal.RequireFromString(".35"),
+			BinancePriceRatio: decimal.RequireFromString(".98"),
+			LoopInterval:      time.Millisecond * 500,
+		},
+		{
+			ID:                "BUYER-2",
+			Action:            payeer.ACTION_BUY,
+			Pair:              payeer.PAIR_ETHUSDT,
+			BinanceSymbol:     binance.SYMBOL_ETHUSDT,
+			Share:             decimal.RequireFromString(".30"),
+			BinancePriceRatio: decimal.RequireFromString(".99"),
+			LoopInterval:      time.Millisecond * 500,
+		},
+		{
+			ID:                "SELLER-1",
+			Action:            payeer.ACTION_SELL,
+			Pair:              payeer.PAIR_ETHUSDT,
+			BinanceSymbol:     binance.SYMBOL_ETHUSDT,
+			Share:             decimal.RequireFromString(".30"),
+			BinancePriceRatio: decimal.RequireFromString("1.01"),
+			LoopInterval:      time.Millisecond * 500,
+		},
+		{
+			ID:                "SELLER-2",
+			Action:            payeer.ACTION_SELL,
+			Pair:              payeer.PAIR_ETHUSDT,
+			BinanceSymbol:     binance.SYMBOL_ETHUSDT,
+			Share:             decimal.RequireFromString(".35"),
+			BinancePriceRatio: decimal.RequireFromString("1.02"),
+			LoopInterval:      time.Millisecond * 500,
+		},
+	}
+}
+
+func valueOffsetOptions() *backtest.ValueOffsetOptions {
+	return &backtest.ValueOffsetOptions{
+		Pair:                   payeer.PAIR_ETHUSDT,
+		ReplacementValueOffset: decimal.NewFromInt(50),
+		SelectorConfig: &payeer.PayeerPriceSelectorConfig{
+			PlacementValueOffset:    decimal.NewFromInt(15),
+			ElevationPriceFraction:  decimal.RequireFromString(".00005"),
+			MaxWmaSurplus:           decimal.RequireFromString(".003"),
+			WmaTakeAmount:           decimal.RequireFromString(".025"),
+			Symbol:                  binance.SYMBOL_ETHUSDT,
+			BidMaxBinancePriceRatio: decimal.RequireFromString(".999"),
+			AskMinBinancePriceRatio: decimal.RequireFromString("1.08"),
+		},
+		BuyEnabled:  true,
+		SellEnabled: true,
+		Amount:      decimal.RequireFromString("0.001"),
+	}
+}
+
+func loadInfo(name string) (*payeer.InfoResponse, error) {
+	if name == "" {
+		return payeer.NewClient(&payeer.Config{}).Info()
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var info payeer.InfoResponse
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func openEvents(name string) (io.ReadCloser, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(name, ".gz") {
+		return f, nil
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &gzipFile{Reader: gz, file: f}, nil
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipFile) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
+
+func parseTime(str string) (time.Time, error) {
+	if str == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, str)
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
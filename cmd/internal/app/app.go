@@ -0,0 +1,262 @@
+// Package app wires what every trading command shares around its strategies:
+// the flags, config loading, the market with its state, journal, risk limits
+// and references, the kill switch, alerts, the hedger, metrics, the admin
+// server and the shutdown.
+//
+// A command embeds Config in its own config type, loads it with Load, builds
+// an App with New, adds its strategies to the Runner, watches the config file
+// and hands over to Run:
+//
+//	flags := app.ParseFlags("strategy config file (.yaml, .yml or .json)")
+//	cfg := &Config{}
+//	app.Load(flags, cfg)
+//	a := app.New(flags, &cfg.Config, config.Version(cfg))
+//	a.Runner.Add("shares", shares.NewStrategy(a.Market, cfg.Strategy.Options()))
+//	...
+//	os.Exit(a.Run())
+package app
+
+import (
+	"automata/admin"
+	"automata/client/binance"
+	"automata/client/payeer"
+	"automata/client/payeer/paper"
+	"automata/config"
+	"automata/hedge"
+	"automata/journal"
+	"automata/logging"
+	"automata/metrics"
+	"automata/pnl"
+	"automata/risk"
+	"automata/statestore"
+	"automata/strategy"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Flags holds the command line of a trading command.
+type Flags struct {
+	Config          string
+	ReloadInterval  time.Duration
+	ShutdownTimeout time.Duration
+	SummaryPath     string
+	StatePath       string
+	JournalPath     string
+	PnlPath         string
+	printSchema     bool
+}
+
+// ParseFlags defines the flags of a trading command and parses them;
+// configUsage describes the -config file.
+func ParseFlags(configUsage string) *Flags {
+	flags := &Flags{}
+	flag.StringVar(&flags.Config, "config", "", configUsage)
+	flag.BoolVar(&flags.printSchema, "print-schema", false, "print the JSON schema of the config file and exit")
+	flag.DurationVar(&flags.ReloadInterval, "reload-interval", 2*time.Second, "how often to check the config file for changes, 0 reloads on SIGHUP only")
+	flag.DurationVar(&flags.ShutdownTimeout, "shutdown-timeout", 3*time.Minute, "how long to wait for open orders to be cancelled on SIGINT or SIGTERM; a second signal exits at once")
+	flag.StringVar(&flags.SummaryPath, "shutdown-summary", "", "write the shutdown summary as JSON to this file")
+	flag.StringVar(&flags.StatePath, "state", "", "state file recording placed orders, fills and balances so a restart adopts open orders; empty keeps state in memory")
+	flag.StringVar(&flags.JournalPath, "journal", "", "append every placement and cancel decision with its inputs to this file, see cmd/journal")
+	flag.StringVar(&flags.PnlPath, "pnl-csv", "", "write the PnL by strategy, share and pair as CSV to this file on shutdown")
+	flag.Parse()
+	return flags
+}
+
+// Load reads the config file into cfg. With -print-schema it prints the
+// schema of cfg instead and exits; a broken file exits with status 2.
+func Load(flags *Flags, cfg any) {
+	if flags.printSchema {
+		config.WriteSchema(os.Stdout, cfg)
+		os.Exit(0)
+	}
+	if err := config.Load(flags.Config, cfg); err != nil {
+		Exit(err)
+	}
+}
+
+// App holds the shared services of a running command.
+type App struct {
+	Market *strategy.Market
+	// Runner runs the strategies the command adds.
+	Runner *strategy.Runner
+
+	flags      *Flags
+	logHandler *logging.Handler
+	killSwitch *strategy.KillSwitch
+	alerts     *strategy.Alerts
+	hedger     *hedge.Hedger
+	server     *admin.Server
+	state      *statestore.File
+	journal    *journal.Journal
+}
+
+// New sets up logging and builds the market and the shared services of cfg;
+// version names the whole config in journal entries. Failures exit with
+// status 2.
+func New(flags *Flags, cfg *Config, version string) *App {
+	a := &App{flags: flags}
+	a.logHandler = logging.Setup(&logging.Options{
+		Format: cfg.Log.Format,
+		Level:  cfg.LogLevel.Level(),
+		Levels: cfg.Log.ComponentLevels(),
+	})
+
+	payeerClient := payeer.NewClient(&payeer.Config{
+		ApiId:  cfg.Payeer.ApiId,
+		Secret: cfg.Payeer.Secret,
+	})
+	var payeerApi payeer.Api = payeerClient
+	if cfg.Paper.Enabled {
+		slog.Info("Paper trading mode", "balances", cfg.Paper.Balances)
+		payeerApi = paper.NewExchange(payeerClient, &paper.Options{Balances: cfg.Paper.Balances})
+	}
+
+	market := strategy.NewMarket(payeerApi, binance.NewClient())
+	market.HedgeFeePercent = cfg.Hedge.FeePercent
+	if flags.StatePath != "" {
+		state, err := statestore.Open(flags.StatePath)
+		if err != nil {
+			Exit(err)
+		}
+		a.state = state
+		market.SetState(state)
+	}
+	if flags.JournalPath != "" {
+		j, err := journal.Open(flags.JournalPath)
+		if err != nil {
+			Exit(err)
+		}
+		j.SetVersion(version)
+		a.journal = j
+		market.SetJournal(j)
+	}
+	market.SetRisk(risk.NewManager(cfg.Risk.Limits()))
+	if len(cfg.References.Symbols) > 0 {
+		market.SetReferences(cfg.References.Options())
+	}
+	a.Market = market
+
+	a.killSwitch = strategy.NewKillSwitch(market, &strategy.KillSwitchOptions{
+		FlagFile:          cfg.KillSwitch.FlagFile,
+		LossLimit:         cfg.KillSwitch.LossLimit,
+		FeedMaxAge:        cfg.KillSwitch.FeedMaxAge.D(),
+		MaxExchangeErrors: cfg.KillSwitch.MaxExchangeErrors,
+		ErrorWindow:       cfg.KillSwitch.ErrorWindow.D(),
+	})
+	dispatcher := cfg.Notify.Dispatcher()
+	a.alerts = strategy.NewAlerts(market, dispatcher, a.killSwitch, &strategy.AlertOptions{
+		Fills:             cfg.Notify.Fills,
+		InsufficientFunds: cfg.Notify.InsufficientFunds,
+		StaleFeed:         cfg.Notify.StaleFeed.D(),
+	})
+	if cfg.Hedge.Enabled() {
+		venue, err := hedge.NewVenue(cfg.Hedge.Venue, cfg.Hedge.ApiKey, cfg.Hedge.Secret, cfg.Hedge.FeePercent, market.Risk)
+		if err != nil {
+			Exit(err)
+		}
+		a.hedger = hedge.NewHedger(cfg.Hedge.Options(), venue, market.Ledger, market.Risk, dispatcher)
+		market.OnFill(a.hedger.Fill)
+	}
+	reconciler := strategy.NewReconciler(market, &strategy.ReconcilerOptions{
+		Interval:  cfg.Reconcile.Interval.D(),
+		Tolerance: cfg.Reconcile.Tolerance,
+		HaltDrift: cfg.Reconcile.HaltDrift,
+	})
+	if cfg.Metrics.Addr != "" {
+		metrics.Serve(cfg.Metrics.Addr)
+	}
+	if cfg.Admin.Addr != "" {
+		server, err := admin.NewServer(cfg.Admin.Addr, cfg.Admin.Token)
+		if err != nil {
+			Exit(err)
+		}
+		server.Handle("/killswitch", a.killSwitch)
+		server.Handle("/reconcile", reconciler)
+		server.Handle("/pnl", market.Ledger)
+		if a.hedger != nil {
+			server.Handle("/hedge", a.hedger)
+		}
+		a.server = server
+	}
+
+	a.Runner = strategy.NewRunner(&strategy.RunnerOptions{
+		ShutdownTimeout: flags.ShutdownTimeout,
+		Market:          market,
+		KillSwitch:      a.killSwitch,
+		Reconciler:      reconciler,
+	})
+	return a
+}
+
+// Reconfigure applies the shared settings a reload may change; version names
+// the new config.
+func (a *App) Reconfigure(cfg *Config, version string) {
+	a.Market.Risk.SetLimits(cfg.Risk.Limits())
+	a.Market.Journal.SetVersion(version)
+	a.logHandler.SetLevels(cfg.LogLevel.Level(), cfg.Log.ComponentLevels())
+}
+
+// Serve starts the admin server, if one is configured, with params applying
+// parameter patches to the named strategy.
+func (a *App) Serve(params func(name string, patch json.RawMessage) ([]config.Change, error)) {
+	if a.server == nil {
+		return
+	}
+	api := &admin.API{
+		Runner: a.Runner,
+		Market: a.Market,
+		Params: params,
+	}
+	api.Register(a.server)
+	a.server.Start()
+}
+
+// Run runs the strategies until SIGINT or SIGTERM, then writes the shutdown
+// summary and the PnL and returns the exit status: 1 when the runner failed
+// or the shutdown left orders behind.
+func (a *App) Run() int {
+	ctx, stop := strategy.NotifyShutdown(context.Background())
+	defer stop()
+	go a.killSwitch.Watch(ctx)
+	go a.alerts.Watch(ctx)
+	go a.Market.References.Run(ctx)
+	if a.hedger != nil {
+		go a.hedger.Run(ctx)
+	}
+	err := a.Runner.Run(ctx)
+	a.hedger.Close()
+	summary := a.Runner.Summary()
+	if summary != nil && a.flags.SummaryPath != "" {
+		if err := summary.WriteFile(a.flags.SummaryPath); err != nil {
+			slog.Error("[Runner] Writing shutdown summary failed", "path", a.flags.SummaryPath, "error", err)
+		}
+	}
+	if a.flags.PnlPath != "" {
+		if err := a.Market.Ledger.WriteFile(a.flags.PnlPath, pnl.GROUP_SHARE); err != nil {
+			slog.Error("[Runner] Writing PnL failed", "path", a.flags.PnlPath, "error", err)
+		}
+	}
+	a.journal.Close()
+	if a.state != nil {
+		a.state.Close()
+	}
+	if err != nil {
+		slog.Error("[Runner] Exited with errors", "error", err)
+		return 1
+	}
+	if summary != nil && !summary.Clean() {
+		return 1
+	}
+	return 0
+}
+
+// Exit reports a startup error and exits with status 2.
+func Exit(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(2)
+}
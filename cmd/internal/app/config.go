@@ -0,0 +1,78 @@
+package app
+
+import (
+	"automata/config"
+	"errors"
+)
+
+// Config holds the sections every trading command shares. Commands embed it
+// next to their strategy sections; encoding/json lifts its fields, so the file
+// layout stays flat.
+type Config struct {
+	Payeer     config.Payeer     `json:"payeer" desc:"API credentials, not needed in paper mode"`
+	Paper      config.Paper      `json:"paper,omitempty"`
+	LogLevel   config.LogLevel   `json:"logLevel,omitempty"`
+	Log        config.Log        `json:"log,omitempty"`
+	Reconcile  config.Reconcile  `json:"reconcile,omitempty"`
+	Risk       config.Risk       `json:"risk,omitempty"`
+	References config.References `json:"references,omitempty"`
+	Hedge      config.Hedge      `json:"hedge,omitempty"`
+	KillSwitch config.KillSwitch `json:"killSwitch,omitempty"`
+	Admin      config.Admin      `json:"admin,omitempty"`
+	Metrics    config.Metrics    `json:"metrics,omitempty"`
+	Notify     config.Notify     `json:"notify,omitempty"`
+}
+
+// Validate collects the problems of the shared sections; the command's
+// Validate adds those of its strategies.
+func (c *Config) Validate(problems *config.Problems) {
+	if !c.Paper.Enabled {
+		c.Payeer.Validate(problems, "payeer")
+	}
+	c.Paper.Validate(problems, "paper")
+	c.LogLevel.Validate(problems, "logLevel")
+	c.Log.Validate(problems, "log")
+	c.Reconcile.Validate(problems, "reconcile")
+	c.Risk.Validate(problems, "risk")
+	c.References.Validate(problems, "references")
+	c.Hedge.Validate(problems, "hedge")
+	if c.Paper.Enabled && c.Hedge.Enabled() {
+		problems.Add("hedge.venue", "hedging places real orders, it is not available in paper mode")
+	}
+	c.KillSwitch.Validate(problems, "killSwitch")
+	c.Admin.Validate(problems, "admin")
+	c.Notify.Validate(problems, "notify")
+}
+
+// RestartRequired rejects reloads touching shared settings that are only read
+// at startup.
+func (c *Config) RestartRequired(new *Config) error {
+	if c.Payeer != new.Payeer {
+		return errors.New("payeer credentials changed, restart required")
+	}
+	if len(config.Diff(c.Paper, new.Paper)) > 0 {
+		return errors.New("paper settings changed, restart required")
+	}
+	if len(config.Diff(c.Reconcile, new.Reconcile)) > 0 {
+		return errors.New("reconcile settings changed, restart required")
+	}
+	if len(config.Diff(c.References, new.References)) > 0 {
+		return errors.New("reference settings changed, restart required")
+	}
+	if len(config.Diff(c.Hedge, new.Hedge)) > 0 {
+		return errors.New("hedge settings changed, restart required")
+	}
+	if len(config.Diff(c.KillSwitch, new.KillSwitch)) > 0 || c.Admin != new.Admin {
+		return errors.New("kill switch or admin settings changed, restart required")
+	}
+	if c.Metrics != new.Metrics {
+		return errors.New("metrics settings changed, restart required")
+	}
+	if c.Log.Format != new.Log.Format {
+		return errors.New("log format changed, restart required")
+	}
+	if len(config.Diff(c.Notify, new.Notify)) > 0 {
+		return errors.New("notify settings changed, restart required")
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+// Command journal queries the decision journal written with -journal and
+// replays the price selection of the decisions it finds.
+//
+//	journal -file decisions.jsonl -pair ETH_USDT -decision cancel -from 2024-05-01T00:00:00Z
+//	journal -file decisions.jsonl -seq 1234 -replay
+package main
+
+import (
+	"automata/journal"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+func main() {
+	fileFlag := flag.String("file", "", "journal file to read")
+	strategyFlag := flag.String("strategy", "", "only decisions of this strategy")
+	shareFlag := flag.String("share", "", "only decisions of this share")
+	pairFlag := flag.String("pair", "", "only decisions on this pair")
+	actionFlag := flag.String("action", "", "only buy or sell decisions")
+	decisionFlag := flag.String("decision", "", "only place or cancel decisions")
+	traceFlag := flag.String("trace", "", "only decisions with this trace ID")
+	orderFlag := flag.Int("order", 0, "only decisions placing or cancelling this order")
+	seqFlag := flag.Int64("seq", 0, "only the decision with this sequence number")
+	fromFlag := flag.String("from", "", "only decisions at or after this RFC3339 time")
+	toFlag := flag.String("to", "", "only decisions at or before this RFC3339 time")
+	rejectedFlag := flag.Bool("rejected", false, "only decisions the exchange or the risk manager rejected")
+	replayFlag := flag.Bool("replay", false, "run the price selection of every decision again and compare it with the recorded one")
+	jsonFlag := flag.Bool("json", false, "print the matching entries as JSON lines")
+	flag.Parse()
+
+	slog.SetLogLoggerLevel(slog.LevelWarn)
+
+	if *fileFlag == "" {
+		fatal("No journal specified, use -file", nil)
+	}
+	from, err := parseTime(*fromFlag)
+	if err != nil {
+		fatal("Invalid -from", err)
+	}
+	to, err := parseTime(*toFlag)
+	if err != nil {
+		fatal("Invalid -to", err)
+	}
+	match := func(e *journal.Entry) bool {
+		return (*strategyFlag == "" || e.Strategy == *strategyFlag) &&
+			(*shareFlag == "" || e.Share == *shareFlag) &&
+			(*pairFlag == "" || string(e.Pair) == *pairFlag) &&
+			(*actionFlag == "" || string(e.Action) == *actionFlag) &&
+			(*decisionFlag == "" || string(e.Decision) == *decisionFlag) &&
+			(*traceFlag == "" || e.Trace == *traceFlag) &&
+			(*orderFlag == 0 || e.OrderId == *orderFlag || e.Result.OrderId == *orderFlag) &&
+			(*seqFlag == 0 || e.Seq == *seqFlag) &&
+			(from.IsZero() || !e.Time.Before(from)) &&
+			(to.IsZero() || !e.Time.After(to)) &&
+			(!*rejectedFlag || !e.Result.Accepted)
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	encoder := json.NewEncoder(os.Stdout)
+	if !*jsonFlag && !*replayFlag {
+		fmt.Fprintln(out, "SEQ\tTIME\tSTRATEGY\tSHARE\tPAIR\tACTION\tDECISION\tPRICE\tAMOUNT\tACCEPTED\tORDER\tTICKER AGE\tCONFIG\tREASON/ERROR")
+	}
+	matched, mismatched := 0, 0
+	err = journal.Scan(*fileFlag, func(e *journal.Entry) bool {
+		if !match(e) {
+			return true
+		}
+		matched++
+		switch {
+		case *replayFlag:
+			if !replay(e) {
+				mismatched++
+			}
+		case *jsonFlag:
+			encoder.Encode(e)
+		default:
+			orderId := e.Result.OrderId
+			if orderId == 0 {
+				orderId = e.OrderId
+			}
+			age := "-"
+			if e.Ticker != nil {
+				age = e.Ticker.Age.String()
+			}
+			note := e.Reason
+			if e.Result.Error != "" {
+				note = e.Result.Error
+			}
+			fmt.Fprintf(out, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%t\t%d\t%s\t%s\t%s\n",
+				e.Seq, e.Time.Format(time.RFC3339), e.Strategy, e.Share, e.Pair, e.Action, e.Decision,
+				e.Price, e.Amount, e.Result.Accepted, orderId, age, e.ConfigVersion, note)
+		}
+		return true
+	})
+	out.Flush()
+	if err != nil {
+		fatal("Failed to read the journal", err)
+	}
+	if *replayFlag {
+		fmt.Printf("%d decisions replayed, %d differ\n", matched, mismatched)
+		if mismatched > 0 {
+			os.Exit(1)
+		}
+	}
+}
+
+// replay prints the recorded and the replayed stages of e side by side and
+// reports whether the replay arrives at the recorded stage outputs.
+func replay(e *journal.Entry) bool {
+	fmt.Printf("#%d %s %s %s %s %s trace=%s config=%s\n", e.Seq, e.Time.Format(time.RFC3339), e.Strategy, e.Decision, e.Pair, e.Action, e.Trace, e.ConfigVersion)
+	if e.Ticker != nil {
+		fmt.Printf("  ticker %s bid=%s ask=%s age=%s, %d book levels\n", e.Ticker.Symbol, e.Ticker.Bid, e.Ticker.Ask, e.Ticker.Age, len(e.Book))
+	}
+	selection, err := journal.Replay(e)
+	if err != nil {
+		fmt.Printf("  not replayable: %v\n", err)
+		return true
+	}
+	same := len(selection.Stages) == len(e.Stages)
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "  STAGE\tRECORDED\tREPLAYED\t")
+	for i := 0; i < max(len(selection.Stages), len(e.Stages)); i++ {
+		recorded, replayed, name := "-", "-", ""
+		if i < len(e.Stages) {
+			name = e.Stages[i].Name
+			recorded = fmt.Sprintf("%s ok=%t", e.Stages[i].Price, e.Stages[i].Ok)
+		}
+		if i < len(selection.Stages) {
+			name = selection.Stages[i].Name
+			replayed = fmt.Sprintf("%s ok=%t", selection.Stages[i].Price, selection.Stages[i].Ok)
+		}
+		mark := ""
+		if recorded != replayed {
+			same = false
+			mark = "DIFFERS"
+		}
+		fmt.Fprintf(out, "  %s\t%s\t%s\t%s\n", name, recorded, replayed, mark)
+	}
+	out.Flush()
+	fmt.Printf("  result accepted=%t orderId=%d %s\n", e.Result.Accepted, e.Result.OrderId, e.Result.Error)
+	return same
+}
+
+func parseTime(str string) (time.Time, error) {
+	if str == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, str)
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
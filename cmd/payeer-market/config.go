@@ -0,0 +1,19 @@
+package main
+
+import (
+	"automata/cmd/internal/app"
+	"automata/config"
+	"automata/strategy/markettrader"
+)
+
+type Config struct {
+	app.Config
+	Trader markettrader.Config `json:"trader"`
+}
+
+func (c *Config) Validate() error {
+	problems := &config.Problems{}
+	c.Config.Validate(problems)
+	c.Trader.Validate(problems, "trader")
+	return problems.Err()
+}
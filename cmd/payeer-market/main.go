@@ -1,37 +1,42 @@
 package main
 
 import (
-	"automata/client/binance"
-	"automata/client/payeer"
-	"log/slog"
+	"automata/cmd/internal/app"
+	"automata/config"
+	"automata/strategy/markettrader"
+	"encoding/json"
+	"fmt"
 	"os"
-	"time"
-
-	"github.com/shopspring/decimal"
 )
 
 func main() {
-	slog.SetLogLoggerLevel(slog.LevelInfo)
-	apiId := os.Getenv("API_ID")
-	secret := os.Getenv("SECRET")
+	flags := app.ParseFlags("trader config file (.yaml, .yml or .json)")
+	cfg := &Config{}
+	app.Load(flags, cfg)
 
-	payeerClient := payeer.NewClient(&payeer.Config{
-		ApiId:  apiId,
-		Secret: secret,
-	})
-	binanceClient := binance.NewClient()
+	a := app.New(flags, &cfg.Config, config.Version(cfg))
+	trader := markettrader.NewTrader(a.Market, cfg.Trader.Options())
+	a.Runner.Add("market-trader", trader)
 
-	trader := NewPayeerMarketTrader(payeerClient, binanceClient, &PayeerMarketTraderOptions{
-		Pairs: map[payeer.Pair]binance.Symbol{
-			payeer.PAIR_ETHUSDT: binance.SYMBOL_ETHUSDT,
-		},
-		BinanceTickerInterval: time.Millisecond * 20,
-		TradeLoopInterval:     time.Second * 10,
-		BidMinRatio:           decimal.RequireFromString("0"),
-		AskMaxRatio:           decimal.RequireFromString("999"),
-		MaxBuyAmount:          decimal.Zero,                     // 0.0 = no effect
-		QuoteMult:             decimal.RequireFromString("1.0"), // 1.0 = no effect
+	watcher := config.NewWatcher(flags.Config, cfg, func(old, new *Config) error {
+		if err := old.RestartRequired(&new.Config); err != nil {
+			return err
+		}
+		if err := trader.Reconfigure(new.Trader.Options()); err != nil {
+			return err
+		}
+		a.Reconfigure(&new.Config, config.Version(new))
+		return nil
 	})
+	watcher.Start(flags.ReloadInterval)
 
-	trader.Start()
+	a.Serve(func(name string, patch json.RawMessage) ([]config.Change, error) {
+		return watcher.Update("admin", func(next *Config) error {
+			if name != "market-trader" {
+				return fmt.Errorf("no strategy %q", name)
+			}
+			return config.Merge(patch, &next.Trader)
+		})
+	})
+	os.Exit(a.Run())
 }
@@ -1,48 +1,45 @@
 package main
 
 import (
-	"automata/client/binance"
-	"automata/client/payeer"
-	"time"
-
-	"log/slog"
+	"automata/cmd/internal/app"
+	"automata/config"
+	"automata/strategy/valueoffset"
+	"encoding/json"
+	"fmt"
 	"os"
-
-	"github.com/shopspring/decimal"
 )
 
 func main() {
-	slog.SetLogLoggerLevel(slog.LevelInfo)
-	apiId := os.Getenv("API_ID")
-	secret := os.Getenv("SECRET")
-	payeerClient := payeer.NewClient(&payeer.Config{
-		ApiId:  apiId,
-		Secret: secret,
-	})
-	binanceClient := binance.NewClient()
+	flags := app.ParseFlags("strategy config file (.yaml, .yml or .json)")
+	cfg := &Config{}
+	app.Load(flags, cfg)
 
-	strategy := NewVolumeOffsetStrategy(payeerClient, binanceClient, &ValueOffsetStrategyOptions{
-		Pairs: map[payeer.Pair]binance.Symbol{
-			payeer.PAIR_ETHUSDT: binance.SYMBOL_ETHUSDT,
-		},
-		BinanceTickerInterval: time.Millisecond * 100,
-		MaxPriceRatio:         "1.001",
-		// PlacementValueOffset:   "1000",
-		ReplacementValueOffset: "50",
-		SelectorConfig: &payeer.PayeerPriceSelectorConfig{
-			PlacementValueOffset:   decimal.NewFromInt(15),
-			ElevationPriceFraction: decimal.RequireFromString(".00005"),
-			MaxWmaSurplus:          decimal.RequireFromString(".003"),
-			WmaTakeAmount:          decimal.RequireFromString(".025"),
-			WmaTake:                0,
+	a := app.New(flags, &cfg.Config, config.Version(cfg))
+	valueOffset, err := valueoffset.NewStrategy(a.Market, cfg.Strategy.Options())
+	if err != nil {
+		app.Exit(err)
+	}
+	a.Runner.Add("value-offset", valueOffset)
+
+	watcher := config.NewWatcher(flags.Config, cfg, func(old, new *Config) error {
+		if err := old.RestartRequired(&new.Config); err != nil {
+			return err
+		}
+		if err := valueOffset.Reconfigure(new.Strategy.Options()); err != nil {
+			return err
+		}
+		a.Reconfigure(&new.Config, config.Version(new))
+		return nil
+	})
+	watcher.Start(flags.ReloadInterval)
 
-			Symbol:                  binance.SYMBOL_ETHUSDT,
-			BidMaxBinancePriceRatio: decimal.RequireFromString(".999"),
-			AskMinBinancePriceRatio: decimal.RequireFromString("1.08"),
-		},
-		BuyEnabled:  false,
-		SellEnabled: true,
-		Amount:      decimal.RequireFromString("0.001"),
+	a.Serve(func(name string, patch json.RawMessage) ([]config.Change, error) {
+		return watcher.Update("admin", func(next *Config) error {
+			if name != "value-offset" {
+				return fmt.Errorf("no strategy %q", name)
+			}
+			return config.Merge(patch, &next.Strategy)
+		})
 	})
-	strategy.Run()
+	os.Exit(a.Run())
 }
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"automata/cmd/internal/app"
+	"automata/config"
+	"automata/strategy/shares"
+)
+
+type Config struct {
+	app.Config
+	Strategy shares.Config `json:"strategy"`
+}
+
+func (c *Config) Validate() error {
+	problems := &config.Problems{}
+	c.Config.Validate(problems)
+	c.Strategy.Validate(problems, "strategy")
+	return problems.Err()
+}
@@ -1,76 +1,42 @@
 package main
 
 import (
-	"automata/client/binance"
-	"automata/client/payeer"
-	"log/slog"
+	"automata/cmd/internal/app"
+	"automata/config"
+	"automata/strategy/shares"
+	"encoding/json"
+	"fmt"
 	"os"
-	"time"
-
-	"github.com/shopspring/decimal"
 )
 
 func main() {
-	slog.SetLogLoggerLevel(slog.LevelInfo)
-	apiId := os.Getenv("API_ID")
-	secret := os.Getenv("SECRET")
+	flags := app.ParseFlags("strategy config file (.yaml, .yml or .json)")
+	cfg := &Config{}
+	app.Load(flags, cfg)
 
-	payeerClient := payeer.NewClient(&payeer.Config{
-		ApiId:  apiId,
-		Secret: secret,
-	})
-	binanceClient := binance.NewClient()
+	a := app.New(flags, &cfg.Config, config.Version(cfg))
+	sharesStrategy := shares.NewStrategy(a.Market, cfg.Strategy.Options())
+	a.Runner.Add("shares", sharesStrategy)
 
-	strategy := NewPayeerSharesStrategy(
-		payeerClient,
-		binanceClient,
-		&PayeerSharesStrategyOptions{
-			RefetchBalanceDelay: time.Millisecond * 200,
-			OrdersFetchInterval: time.Millisecond * 5,
-			Shares: []PayeerSharesStrategyShare{
-				{
-					ID:                    "BUYER-1",
-					Action:                payeer.ACTION_BUY,
-					Pair:                  payeer.PAIR_ETHUSDT,
-					BinanceSymbol:         binance.SYMBOL_ETHUSDT,
-					Share:                 decimal.RequireFromString(".35"),
-					BinancePriceRatio:     decimal.RequireFromString(".98"),
-					BinanceTickerInterval: time.Millisecond * 100,
-					LoopInterval:          time.Millisecond * 500,
-				},
-				{
-					ID:                    "BUYER-2",
-					Action:                payeer.ACTION_BUY,
-					Pair:                  payeer.PAIR_ETHUSDT,
-					BinanceSymbol:         binance.SYMBOL_ETHUSDT,
-					Share:                 decimal.RequireFromString(".30"),
-					BinancePriceRatio:     decimal.RequireFromString(".99"),
-					BinanceTickerInterval: time.Millisecond * 100,
-					LoopInterval:          time.Millisecond * 500,
-				},
-				{
-					ID:                    "SELLER-1",
-					Action:                payeer.ACTION_SELL,
-					Pair:                  payeer.PAIR_ETHUSDT,
-					BinanceSymbol:         binance.SYMBOL_ETHUSDT,
-					Share:                 decimal.RequireFromString(".30"),
-					BinancePriceRatio:     decimal.RequireFromString("1.01"),
-					BinanceTickerInterval: time.Millisecond * 100,
-					LoopInterval:          time.Millisecond * 500,
-				},
-				{
-					ID:                    "SELLER-2",
-					Action:                payeer.ACTION_SELL,
-					Pair:                  payeer.PAIR_ETHUSDT,
-					BinanceSymbol:         binance.SYMBOL_ETHUSDT,
-					Share:                 decimal.RequireFromString(".35"),
-					BinancePriceRatio:     decimal.RequireFromString("1.02"),
-					BinanceTickerInterval: time.Millisecond * 100,
-					LoopInterval:          time.Millisecond * 500,
-				},
-			},
-		},
-	)
+	watcher := config.NewWatcher(flags.Config, cfg, func(old, new *Config) error {
+		if err := old.RestartRequired(&new.Config); err != nil {
+			return err
+		}
+		if err := sharesStrategy.Reconfigure(new.Strategy.Options()); err != nil {
+			return err
+		}
+		a.Reconfigure(&new.Config, config.Version(new))
+		return nil
+	})
+	watcher.Start(flags.ReloadInterval)
 
-	strategy.Run()
+	a.Serve(func(name string, patch json.RawMessage) ([]config.Change, error) {
+		return watcher.Update("admin", func(next *Config) error {
+			if name != "shares" {
+				return fmt.Errorf("no strategy %q", name)
+			}
+			return config.Merge(patch, &next.Strategy)
+		})
+	})
+	os.Exit(a.Run())
 }
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"automata/client"
+	"automata/client/binance"
+	"automata/client/mexc"
+	"automata/client/payeer"
+	"automata/recorder"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func main() {
+	dir := flag.String("dir", "data", "output directory")
+	payeerPairs := flag.String("payeer", "ETH_USDT", "comma separated Payeer pairs")
+	binanceSymbols := flag.String("binance", "ETHUSDT", "comma separated Binance symbols")
+	binanceDepth := flag.Int("binance-depth", 20, "Binance partial depth levels (5, 10 or 20), 0 disables")
+	mexcSymbols := flag.String("mexc", "", "comma separated MEXC symbols")
+	mexcDepth := flag.Int("mexc-depth", 5, "MEXC partial depth levels")
+	bookInterval := flag.Duration("book-interval", time.Millisecond*500, "Payeer order book polling interval")
+	tradesInterval := flag.Duration("trades-interval", time.Second*2, "Payeer trades polling interval")
+	compression := flag.String("compression", string(recorder.COMPRESSION_GZIP), "gzip or none")
+	flushInterval := flag.Duration("flush-interval", time.Second*5, "how often buffered records are flushed to disk")
+	flag.Parse()
+
+	slog.SetLogLoggerLevel(slog.LevelInfo)
+
+	writer, err := recorder.NewWriter(&recorder.WriterOptions{
+		Dir:         *dir,
+		Compression: recorder.Compression(*compression),
+	})
+	if err != nil {
+		slog.Error("Failed to create writer", "error", err)
+		os.Exit(1)
+	}
+
+	r := &Recorder{writer: writer}
+
+	if pairs := splitList(*payeerPairs); len(pairs) > 0 {
+		payeerClient := payeer.NewClient(&payeer.Config{})
+		ps := make([]payeer.Pair, 0, len(pairs))
+		for _, pair := range pairs {
+			ps = append(ps, payeer.Pair(pair))
+		}
+		go r.recordPayeerBooks(payeerClient, ps, *bookInterval)
+		go r.recordPayeerTrades(payeerClient, ps, *tradesInterval)
+	}
+
+	if symbols := splitList(*binanceSymbols); len(symbols) > 0 {
+		binanceClient := binance.NewClient()
+		for _, symbol := range symbols {
+			r.recordBinance(binanceClient, binance.Symbol(symbol), *binanceDepth)
+		}
+	}
+
+	if symbols := splitList(*mexcSymbols); len(symbols) > 0 {
+		ss := make([]client.Symbol, 0, len(symbols))
+		for _, symbol := range symbols {
+			ss = append(ss, client.Symbol(symbol))
+		}
+		stream := mexc.NewPublicStream(ss, *mexcDepth)
+		stream.Start()
+		r.recordMexc(stream)
+	}
+
+	go func() {
+		for range time.Tick(*flushInterval) {
+			if err := writer.Flush(); err != nil {
+				slog.Error("Failed to flush records", "error", err)
+			}
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	<-signals
+	slog.Info("Stopping recorder...")
+	if err := writer.Close(); err != nil {
+		slog.Error("Failed to close writer", "error", err)
+		os.Exit(1)
+	}
+}
+
+type Recorder struct {
+	writer *recorder.Writer
+}
+
+func (r *Recorder) write(venue recorder.Venue, kind recorder.Kind, symbol string, exchTime time.Time, payload any) {
+	record, err := recorder.NewRecord(venue, kind, symbol, exchTime, payload)
+	if err != nil {
+		slog.Error("Failed to encode record", "venue", venue, "kind", kind, "error", err)
+		return
+	}
+	if err := r.writer.Write(record); err != nil {
+		slog.Error("Failed to write record", "venue", venue, "kind", kind, "error", err)
+	}
+}
+
+func (r *Recorder) recordPayeerBooks(c *payeer.Client, pairs []payeer.Pair, interval time.Duration) {
+	for range time.Tick(interval) {
+		rsp, err := c.Orders(pairs)
+		if err != nil {
+			slog.Error("Failed to fetch Payeer orders", "error", err)
+			continue
+		}
+		if !rsp.Success {
+			slog.Error("Payeer orders response error", "error", rsp.Error)
+			continue
+		}
+		for pair, book := range rsp.Pairs {
+			r.write(recorder.VENUE_PAYEER, recorder.KIND_BOOK, string(pair), time.Time{}, book)
+		}
+	}
+}
+
+func (r *Recorder) recordPayeerTrades(c *payeer.Client, pairs []payeer.Pair, interval time.Duration) {
+	seen := make(map[payeer.Pair]map[string]struct{})
+	for range time.Tick(interval) {
+		rsp, err := c.Trades(pairs)
+		if err != nil {
+			slog.Error("Failed to fetch Payeer trades", "error", err)
+			continue
+		}
+		if !rsp.Success {
+			slog.Error("Payeer trades response error", "error", rsp.Error)
+			continue
+		}
+		for pair, trades := range rsp.Trades {
+			previous := seen[pair]
+			current := make(map[string]struct{}, len(trades))
+			fresh := []payeer.TradesTrade{}
+			var lastDate int64
+			for _, trade := range trades {
+				current[trade.Id] = struct{}{}
+				if _, ok := previous[trade.Id]; ok {
+					continue
+				}
+				fresh = append(fresh, trade)
+				lastDate = max(lastDate, trade.Date)
+			}
+			seen[pair] = current
+			// The first poll only establishes what has already been traded
+			if previous == nil || len(fresh) == 0 {
+				continue
+			}
+			r.write(recorder.VENUE_PAYEER, recorder.KIND_TRADES, string(pair), time.Unix(lastDate, 0), fresh)
+		}
+	}
+}
+
+func (r *Recorder) recordBinance(c *binance.Client, symbol binance.Symbol, depth int) {
+	tickers := c.SubscribeTicker(symbol, 0)
+	go func() {
+		for ticker := range tickers {
+			r.write(recorder.VENUE_BINANCE, recorder.KIND_TICKER, string(symbol), time.Time{}, ticker)
+		}
+	}()
+	trades := c.SubscribeTrades(symbol)
+	go func() {
+		for trade := range trades {
+			r.write(recorder.VENUE_BINANCE, recorder.KIND_TRADE, string(symbol), time.UnixMilli(trade.TradeTime), trade)
+		}
+	}()
+	if depth > 0 {
+		depths := c.SubscribeDepth(symbol, depth)
+		go func() {
+			for d := range depths {
+				r.write(recorder.VENUE_BINANCE, recorder.KIND_DEPTH, string(symbol), time.Time{}, d)
+			}
+		}()
+	}
+}
+
+func (r *Recorder) recordMexc(stream *mexc.PublicStream) {
+	go func() {
+		for ticker := range stream.TickerStream {
+			r.write(recorder.VENUE_MEXC, recorder.KIND_TICKER, string(ticker.Symbol), ticker.Timestamp, ticker)
+		}
+	}()
+	go func() {
+		for deal := range stream.DealStream {
+			r.write(recorder.VENUE_MEXC, recorder.KIND_TRADE, string(deal.Symbol), deal.TradeTime, deal)
+		}
+	}()
+	go func() {
+		for depth := range stream.DepthStream {
+			r.write(recorder.VENUE_MEXC, recorder.KIND_DEPTH, string(depth.Symbol), depth.Timestamp, depth)
+		}
+	}()
+}
+
+func splitList(str string) []string {
+	items := []string{}
+	for _, item := range strings.Split(str, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"automata/cmd/internal/app"
+	"automata/config"
+	"automata/strategy/markettrader"
+	"automata/strategy/shares"
+	"automata/strategy/valueoffset"
+	"errors"
+	"fmt"
+)
+
+type Config struct {
+	app.Config
+	Strategies []StrategyConfig `json:"strategies"`
+}
+
+// StrategyConfig names one strategy instance; exactly one of the strategy
+// sections is set.
+type StrategyConfig struct {
+	Name         string               `json:"name" desc:"unique name used in logs and status"`
+	Shares       *shares.Config       `json:"shares,omitempty"`
+	ValueOffset  *valueoffset.Config  `json:"valueOffset,omitempty"`
+	MarketTrader *markettrader.Config `json:"marketTrader,omitempty"`
+}
+
+func (c *StrategyConfig) kind() string {
+	switch {
+	case c.Shares != nil:
+		return "shares"
+	case c.ValueOffset != nil:
+		return "valueOffset"
+	case c.MarketTrader != nil:
+		return "marketTrader"
+	}
+	return ""
+}
+
+func (c *Config) Validate() error {
+	problems := &config.Problems{}
+	c.Config.Validate(problems)
+	if len(c.Strategies) == 0 {
+		problems.Add("strategies", "at least one strategy is required")
+	}
+	names := map[string]bool{}
+	for i, s := range c.Strategies {
+		path := fmt.Sprintf("strategies.%d", i)
+		if s.Name == "" {
+			problems.Add(path+".name", "is required")
+		} else if names[s.Name] {
+			problems.Addf(path+".name", "duplicate name %q", s.Name)
+		}
+		names[s.Name] = true
+
+		sections := 0
+		if s.Shares != nil {
+			sections++
+			s.Shares.Validate(problems, path+".shares")
+		}
+		if s.ValueOffset != nil {
+			sections++
+			s.ValueOffset.Validate(problems, path+".valueOffset")
+		}
+		if s.MarketTrader != nil {
+			sections++
+			s.MarketTrader.Validate(problems, path+".marketTrader")
+		}
+		if sections != 1 {
+			problems.Add(path, "exactly one of shares, valueOffset or marketTrader must be set")
+		}
+	}
+	return problems.Err()
+}
+
+// restartRequired rejects reloads touching settings that are only read at
+// startup or adding, removing, renaming or changing the kind of strategies.
+func restartRequired(old, new *Config) error {
+	if err := old.RestartRequired(&new.Config); err != nil {
+		return err
+	}
+	if len(old.Strategies) != len(new.Strategies) {
+		return errors.New("strategies added or removed, restart required")
+	}
+	for i := range old.Strategies {
+		if old.Strategies[i].Name != new.Strategies[i].Name || old.Strategies[i].kind() != new.Strategies[i].kind() {
+			return fmt.Errorf("strategy %s renamed or changed kind, restart required", old.Strategies[i].Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"automata/cmd/internal/app"
+	"automata/config"
+	"automata/strategy/markettrader"
+	"automata/strategy/shares"
+	"automata/strategy/valueoffset"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+func main() {
+	flags := app.ParseFlags("config file listing the strategies to run (.yaml, .yml or .json)")
+	cfg := &Config{}
+	app.Load(flags, cfg)
+
+	a := app.New(flags, &cfg.Config, config.Version(cfg))
+	for _, s := range cfg.Strategies {
+		switch {
+		case s.Shares != nil:
+			options := s.Shares.Options()
+			options.Name = s.Name
+			a.Runner.Add(s.Name, shares.NewStrategy(a.Market, options))
+		case s.ValueOffset != nil:
+			options := s.ValueOffset.Options()
+			options.Name = s.Name
+			valueOffset, err := valueoffset.NewStrategy(a.Market, options)
+			if err != nil {
+				app.Exit(fmt.Errorf("%s: %w", s.Name, err))
+			}
+			a.Runner.Add(s.Name, valueOffset)
+		case s.MarketTrader != nil:
+			options := s.MarketTrader.Options()
+			options.Name = s.Name
+			a.Runner.Add(s.Name, markettrader.NewTrader(a.Market, options))
+		}
+	}
+
+	watcher := config.NewWatcher(flags.Config, cfg, func(old, new *Config) error {
+		if err := restartRequired(old, new); err != nil {
+			return err
+		}
+		var errs []error
+		for _, s := range new.Strategies {
+			instance, _ := a.Runner.Strategy(s.Name)
+			var err error
+			switch instance := instance.(type) {
+			case *shares.Strategy:
+				err = instance.Reconfigure(s.Shares.Options())
+			case *valueoffset.Strategy:
+				err = instance.Reconfigure(s.ValueOffset.Options())
+			case *markettrader.Trader:
+				err = instance.Reconfigure(s.MarketTrader.Options())
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.Name, err))
+			}
+		}
+		a.Reconfigure(&new.Config, config.Version(new))
+		return errors.Join(errs...)
+	})
+	watcher.Start(flags.ReloadInterval)
+
+	a.Serve(func(name string, patch json.RawMessage) ([]config.Change, error) {
+		return watcher.Update("admin", func(next *Config) error {
+			for _, s := range next.Strategies {
+				if s.Name != name {
+					continue
+				}
+				switch {
+				case s.Shares != nil:
+					return config.Merge(patch, s.Shares)
+				case s.ValueOffset != nil:
+					return config.Merge(patch, s.ValueOffset)
+				case s.MarketTrader != nil:
+					return config.Merge(patch, s.MarketTrader)
+				}
+			}
+			return fmt.Errorf("no strategy %q", name)
+		})
+	})
+	os.Exit(a.Run())
+}
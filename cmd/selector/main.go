@@ -0,0 +1,246 @@
+// Command selector runs the price selector pipelines of a pair on a live or
+// recorded book without placing anything and prints the output of every stage.
+// The config has the shape of the selector section of a value offset strategy.
+//
+//	selector -config selector.yaml -pair BTC_USDT
+//	selector -config selector.yaml -pair BTC_USDT -action sell -data ./records -at 2024-05-01T12:00:00Z
+//	selector -stages
+package main
+
+import (
+	"automata/client/binance"
+	"automata/client/payeer"
+	"automata/config"
+	"automata/msync"
+	"automata/recorder"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+func main() {
+	configFlag := flag.String("config", "", "selector config file (.yaml, .yml or .json)")
+	pairFlag := flag.String("pair", "", "Payeer pair to select prices for")
+	actionFlag := flag.String("action", "", "buy or sell, both when empty")
+	dataFlag := flag.String("data", "", "recorder directory to take the book and ticker from instead of the live exchanges")
+	atFlag := flag.String("at", "", "use the last book and ticker recorded at or before this RFC3339 time; the end of the recording when empty")
+	infoFlag := flag.String("info", "", "saved /info response with the tick sizes and price limits; fetched from Payeer when empty")
+	timeoutFlag := flag.Duration("timeout", 10*time.Second, "how long to wait for a live Binance ticker")
+	stagesFlag := flag.Bool("stages", false, "list the registered stages and exit")
+	jsonFlag := flag.Bool("json", false, "print the selections as JSON lines")
+	flag.Parse()
+
+	slog.SetLogLoggerLevel(slog.LevelWarn)
+
+	if *stagesFlag {
+		for _, name := range payeer.StageNames() {
+			fmt.Println(name)
+		}
+		return
+	}
+
+	selectorConfig := &payeer.PayeerPriceSelectorConfig{}
+	if err := config.Load(*configFlag, selectorConfig); err != nil {
+		fatal("Failed to load the selector config", err)
+	}
+	pair := payeer.Pair(*pairFlag)
+	if pair == "" {
+		fatal("No pair specified, use -pair", nil)
+	}
+	actions := []payeer.Action{payeer.ACTION_BUY, payeer.ACTION_SELL}
+	if *actionFlag != "" {
+		actions = []payeer.Action{payeer.Action(*actionFlag)}
+	}
+
+	tickers := msync.NewMuMap[binance.Symbol, binance.OrderBookTickerStreamResult]()
+	selector, err := payeer.NewPipelineSelector(selectorConfig, tickers)
+	if err != nil {
+		fatal("Invalid selector config", err)
+	}
+	info, err := loadInfo(*infoFlag)
+	if err != nil {
+		fatal("Failed to load pair info", err)
+	}
+	selector.SetInfo(info)
+	symbols := map[binance.Symbol]bool{}
+	for _, action := range actions {
+		symbols[selector.SymbolFor(pair, action)] = true
+	}
+
+	var book *payeer.PairsOrderInfo
+	if *dataFlag != "" {
+		at, err := parseTime(*atFlag)
+		if err != nil {
+			fatal("Invalid -at", err)
+		}
+		book, err = recordedMarket(*dataFlag, at, pair, symbols, tickers)
+		if err != nil {
+			fatal("Failed to read the recording", err)
+		}
+	} else {
+		book, err = liveMarket(pair, symbols, tickers, *timeoutFlag)
+		if err != nil {
+			fatal("Failed to fetch the market", err)
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	for _, action := range actions {
+		selection := selector.SelectPair(pair, action, book)
+		if *jsonFlag {
+			encoder.Encode(struct {
+				Pair   payeer.Pair   `json:"pair"`
+				Action payeer.Action `json:"action"`
+				*payeer.Selection
+			}{pair, action, selection})
+			continue
+		}
+		printSelection(pair, action, selector.SymbolFor(pair, action), tickers, book, selection)
+	}
+}
+
+func printSelection(
+	pair payeer.Pair,
+	action payeer.Action,
+	symbol binance.Symbol,
+	tickers *msync.MuMap[binance.Symbol, binance.OrderBookTickerStreamResult],
+	book *payeer.PairsOrderInfo,
+	selection *payeer.Selection,
+) {
+	levels := book.Bids
+	if action == payeer.ACTION_SELL {
+		levels = book.Asks
+	}
+	fmt.Printf("%s %s: %d book levels", pair, action, len(levels))
+	if len(levels) > 0 {
+		fmt.Printf(", best %s", levels[0].Price)
+	}
+	if ticker, ok := tickers.Get(symbol); ok {
+		fmt.Printf(", %s bid=%s ask=%s", symbol, ticker.BidPrice, ticker.AskPrice)
+	}
+	fmt.Println()
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "  CHAIN\tSTAGE\tOK\tPRICE")
+	for _, stage := range selection.Stages {
+		chain := "main"
+		if stage.Chain > 0 {
+			chain = fmt.Sprintf("fallback %d", stage.Chain)
+		}
+		fmt.Fprintf(out, "  %s\t%s\t%t\t%s\n", chain, stage.Name, stage.Ok, stage.Price)
+	}
+	out.Flush()
+	if selection.Ok {
+		fmt.Printf("  selected %s\n\n", selection.Price)
+	} else {
+		fmt.Printf("  no price selected\n\n")
+	}
+}
+
+// recordedMarket returns the last book of pair recorded at or before at and
+// stores the last tickers of symbols.
+func recordedMarket(
+	dir string,
+	at time.Time,
+	pair payeer.Pair,
+	symbols map[binance.Symbol]bool,
+	tickers *msync.MuMap[binance.Symbol, binance.OrderBookTickerStreamResult],
+) (*payeer.PairsOrderInfo, error) {
+	reader, err := recorder.Open(dir, &recorder.ReaderOptions{
+		Venues: []recorder.Venue{recorder.VENUE_PAYEER, recorder.VENUE_BINANCE},
+		To:     at,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var book *payeer.PairsOrderInfo
+	var bookTime time.Time
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case record.Venue == recorder.VENUE_PAYEER && record.Kind == recorder.KIND_BOOK && payeer.Pair(record.Symbol) == pair:
+			book = &payeer.PairsOrderInfo{}
+			if err := record.Decode(book); err != nil {
+				return nil, err
+			}
+			bookTime = record.Received()
+		case record.Venue == recorder.VENUE_BINANCE && record.Kind == recorder.KIND_TICKER && symbols[binance.Symbol(record.Symbol)]:
+			var ticker binance.OrderBookTickerStreamResult
+			if err := record.Decode(&ticker); err != nil {
+				return nil, err
+			}
+			tickers.Set(binance.Symbol(record.Symbol), ticker)
+		}
+	}
+	if book == nil {
+		return nil, fmt.Errorf("no %s book recorded", pair)
+	}
+	fmt.Printf("book recorded at %s\n", bookTime.Format(time.RFC3339Nano))
+	return book, nil
+}
+
+// liveMarket fetches the book of pair from Payeer and waits for the first
+// ticker of every symbol from Binance.
+func liveMarket(
+	pair payeer.Pair,
+	symbols map[binance.Symbol]bool,
+	tickers *msync.MuMap[binance.Symbol, binance.OrderBookTickerStreamResult],
+	timeout time.Duration,
+) (*payeer.PairsOrderInfo, error) {
+	orders, err := payeer.NewClient(&payeer.Config{}).Orders([]payeer.Pair{pair})
+	if err != nil {
+		return nil, err
+	}
+	book, ok := orders.Pairs[pair]
+	if !ok {
+		return nil, fmt.Errorf("no %s book returned", pair)
+	}
+	binanceClient := binance.NewClient()
+	for symbol := range symbols {
+		select {
+		case ticker := <-binanceClient.SubscribeTicker(symbol, 0):
+			tickers.Set(symbol, ticker)
+		case <-time.After(timeout):
+			return nil, fmt.Errorf("no %s ticker within %s", symbol, timeout)
+		}
+	}
+	return &book, nil
+}
+
+func loadInfo(name string) (*payeer.InfoResponse, error) {
+	if name == "" {
+		return payeer.NewClient(&payeer.Config{}).Info()
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var info payeer.InfoResponse
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func parseTime(str string) (time.Time, error) {
+	if str == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, str)
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
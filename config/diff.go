@@ -0,0 +1,142 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+type Change struct {
+	Path string `json:"path"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Path, c.Old, c.New)
+}
+
+const absent = "<none>"
+
+// Diff lists the leaf values that differ between two configs of the same type,
+// addressed by their json paths. Fields tagged secret:"true" are reported as
+// changed without their values.
+func Diff(old, new any) []Change {
+	changes := []Change{}
+	diffValues(&changes, "", reflect.ValueOf(old), reflect.ValueOf(new), false)
+	return changes
+}
+
+func diffValues(changes *[]Change, path string, a, b reflect.Value, secret bool) {
+	for a.Kind() == reflect.Pointer || a.Kind() == reflect.Interface {
+		if a.IsNil() {
+			a = reflect.Value{}
+			break
+		}
+		a = a.Elem()
+	}
+	for b.Kind() == reflect.Pointer || b.Kind() == reflect.Interface {
+		if b.IsNil() {
+			b = reflect.Value{}
+			break
+		}
+		b = b.Elem()
+	}
+	if !a.IsValid() || !b.IsValid() {
+		if a.IsValid() != b.IsValid() {
+			addChange(changes, path, a, b, secret)
+		}
+		return
+	}
+
+	switch {
+	case a.Type() == decimalType:
+		if !a.Interface().(decimal.Decimal).Equal(b.Interface().(decimal.Decimal)) {
+			addChange(changes, path, a, b, secret)
+		}
+	case a.Kind() == reflect.Struct:
+		t := a.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" && embedded(field) {
+				// encoding/json lifts the fields of an embedded struct.
+				diffValues(changes, path, a.Field(i), b.Field(i), secret)
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			diffValues(changes, joinPath(path, name), a.Field(i), b.Field(i), secret || field.Tag.Get("secret") == "true")
+		}
+	case a.Kind() == reflect.Map:
+		keys := map[string]reflect.Value{}
+		for _, key := range append(a.MapKeys(), b.MapKeys()...) {
+			keys[fmt.Sprint(key.Interface())] = key
+		}
+		names := make([]string, 0, len(keys))
+		for name := range keys {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			diffValues(changes, joinPath(path, name), a.MapIndex(keys[name]), b.MapIndex(keys[name]), secret)
+		}
+	case a.Kind() == reflect.Slice || a.Kind() == reflect.Array:
+		n := max(a.Len(), b.Len())
+		for i := 0; i < n; i++ {
+			var x, y reflect.Value
+			if i < a.Len() {
+				x = a.Index(i)
+			}
+			if i < b.Len() {
+				y = b.Index(i)
+			}
+			diffValues(changes, joinPath(path, fmt.Sprint(i)), x, y, secret)
+		}
+	default:
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			addChange(changes, path, a, b, secret)
+		}
+	}
+}
+
+// embedded reports whether field is an embedded struct whose fields
+// encoding/json lifts into the outer object.
+func embedded(field reflect.StructField) bool {
+	t := field.Type
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return field.Anonymous && t.Kind() == reflect.Struct
+}
+
+func addChange(changes *[]Change, path string, a, b reflect.Value, secret bool) {
+	change := Change{Path: path, Old: formatValue(a), New: formatValue(b)}
+	if secret {
+		change.Old, change.New = "<redacted>", "<redacted>"
+	}
+	*changes = append(*changes, change)
+}
+
+func formatValue(v reflect.Value) string {
+	if !v.IsValid() {
+		return absent
+	}
+	if stringer, ok := v.Interface().(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	if v.Kind() == reflect.Struct || v.Kind() == reflect.Map || v.Kind() == reflect.Slice {
+		return fmt.Sprintf("%+v", v.Interface())
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,45 @@
+// Package config loads strategy configuration from YAML or JSON files.
+//
+// # Files
+//
+// The format is chosen by extension: .yaml and .yml are read as YAML, anything
+// else as JSON. Both are decoded through the same JSON mapping, so field names
+// are the json tags of the target struct in either format. Unknown fields are
+// rejected, which catches typos such as "binancePriceRadio" at startup.
+//
+// Decimals may be written as numbers or strings ("0.35"); YAML numbers keep
+// their literal text so no precision is lost on the way. Durations are Go
+// duration strings such as "500ms" or "1m30s".
+//
+// # Secrets
+//
+// String values may reference environment variables so that API keys do not
+// have to live in the file:
+//
+//	payeer:
+//	  apiId: ${API_ID}
+//	  secret: ${SECRET}
+//	logLevel: ${LOG_LEVEL:-info}
+//
+// ${NAME} fails the load when NAME is unset; ${NAME:-default} falls back to
+// default. Use $$ for a literal dollar sign. Substitution happens after parsing,
+// on string values only, so secrets never need escaping.
+//
+// # Validation
+//
+// After decoding, Load calls Validate on the target when it implements
+// [Validator]. Validators collect every problem with [Problems] instead of
+// stopping at the first one, so a broken file is reported in one go.
+//
+// # Reloading
+//
+// [Watcher] polls the file and listens for SIGHUP. Each valid new version is
+// diffed against the running one, handed to the command's apply function and
+// logged field by field with secrets redacted. Strategies swap their
+// parameters as one snapshot that loops pick up at their next iteration, so
+// resting orders stay in place. Settings that are only read at startup, like
+// credentials or the set of pairs, make the apply function reject the reload.
+//
+// [Schema] derives a JSON Schema document from the same structs; the commands
+// print it with -print-schema for editor completion and review.
+package config
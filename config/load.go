@@ -0,0 +1,264 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Validator interface {
+	Validate() error
+}
+
+// Load reads the file at path into v, substitutes environment variables in
+// string values and validates the result.
+func Load(path string, v any) error {
+	if path == "" {
+		return errors.New("no config file given, pass -config <file>")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	if err := Decode(data, filepath.Ext(path), v); err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	return nil
+}
+
+// Decode is Load on an in-memory document; ext selects the format like the
+// file extension does for Load.
+func Decode(data []byte, ext string, v any) error {
+	var tree any
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+		converted, err := fromYaml(&doc)
+		if err != nil {
+			return err
+		}
+		tree = converted
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&tree); err != nil {
+			return jsonSyntaxError(data, err)
+		}
+	}
+
+	tree, err := expandTree(tree, "")
+	if err != nil {
+		return err
+	}
+	normalized, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(normalized))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return fmt.Errorf("%s: cannot use %s as %s", typeErr.Field, typeErr.Value, typeErr.Type)
+		}
+		return err
+	}
+
+	if validator, ok := v.(Validator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+func jsonSyntaxError(data []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return err
+	}
+	line, col := 1, 1
+	for _, c := range data[:syntaxErr.Offset] {
+		if c == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return fmt.Errorf("line %d, column %d: %w", line, col, err)
+}
+
+// fromYaml converts a YAML document to the same shape encoding/json produces
+// with UseNumber, keeping numeric literals as written.
+func fromYaml(node *yaml.Node) (any, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return map[string]any{}, nil
+		}
+		return fromYaml(node.Content[0])
+	case yaml.AliasNode:
+		return fromYaml(node.Alias)
+	case yaml.MappingNode:
+		m := make(map[string]any, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if key.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d: mapping keys must be scalars", key.Line)
+			}
+			if key.Tag == "!!merge" {
+				merged, err := fromYaml(node.Content[i+1])
+				if err != nil {
+					return nil, err
+				}
+				if mm, ok := merged.(map[string]any); ok {
+					for k, v := range mm {
+						if _, exists := m[k]; !exists {
+							m[k] = v
+						}
+					}
+				}
+				continue
+			}
+			value, err := fromYaml(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			m[key.Value] = value
+		}
+		return m, nil
+	case yaml.SequenceNode:
+		s := make([]any, 0, len(node.Content))
+		for _, item := range node.Content {
+			value, err := fromYaml(item)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, value)
+		}
+		return s, nil
+	case yaml.ScalarNode:
+		switch node.ShortTag() {
+		case "!!null":
+			return nil, nil
+		case "!!bool":
+			var b bool
+			if err := node.Decode(&b); err != nil {
+				return nil, fmt.Errorf("line %d: %w", node.Line, err)
+			}
+			return b, nil
+		case "!!int", "!!float":
+			if json.Valid([]byte(node.Value)) {
+				return json.Number(node.Value), nil
+			}
+			var f float64
+			if err := node.Decode(&f); err != nil {
+				return nil, fmt.Errorf("line %d: %w", node.Line, err)
+			}
+			return f, nil
+		default:
+			return node.Value, nil
+		}
+	}
+	return nil, fmt.Errorf("line %d: unsupported YAML node", node.Line)
+}
+
+func expandTree(tree any, path string) (any, error) {
+	switch t := tree.(type) {
+	case map[string]any:
+		for k, v := range t {
+			expanded, err := expandTree(v, joinPath(path, k))
+			if err != nil {
+				return nil, err
+			}
+			t[k] = expanded
+		}
+	case []any:
+		for i, v := range t {
+			expanded, err := expandTree(v, joinPath(path, fmt.Sprint(i)))
+			if err != nil {
+				return nil, err
+			}
+			t[i] = expanded
+		}
+	case string:
+		expanded, err := ExpandEnv(t)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return expanded, nil
+	}
+	return tree, nil
+}
+
+// ExpandEnv replaces ${NAME} and ${NAME:-default} references in s.
+func ExpandEnv(s string) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == '$' {
+			b.WriteByte('$')
+			i++
+			continue
+		}
+		if i+1 >= len(s) || s[i+1] != '{' {
+			b.WriteByte('$')
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated variable reference in %q", s)
+		}
+		ref := s[i+2 : i+end]
+		name, fallback, hasFallback := strings.Cut(ref, ":-")
+		if name == "" {
+			return "", fmt.Errorf("empty variable reference in %q", s)
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok || (value == "" && hasFallback) {
+			if !hasFallback {
+				return "", fmt.Errorf("environment variable %s is not set", name)
+			}
+			value = fallback
+		}
+		b.WriteString(value)
+		i += end
+	}
+	return b.String(), nil
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// Merge decodes the JSON object patch onto v: fields present in patch replace
+// the ones of v, lists as a whole, and unknown fields are rejected.
+func Merge(patch []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(patch))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return fmt.Errorf("%s: cannot use %s as %s", typeErr.Field, typeErr.Value, typeErr.Type)
+		}
+		return err
+	}
+	return nil
+}
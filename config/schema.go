@@ -0,0 +1,136 @@
+package config
+
+import (
+	"automata/client/binance"
+	"automata/client/payeer"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Schema returns a JSON Schema (draft 2020-12) describing v, built from its
+// json and desc struct tags.
+func Schema(v any) map[string]any {
+	schema := schemaOf(reflect.TypeOf(v))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	return schema
+}
+
+// WriteSchema writes the indented schema of v to w.
+func WriteSchema(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(Schema(v))
+}
+
+var (
+	decimalType  = reflect.TypeOf(decimal.Decimal{})
+	durationType = reflect.TypeOf(Duration(0))
+	pairType     = reflect.TypeOf(payeer.Pair(""))
+	actionType   = reflect.TypeOf(payeer.Action(""))
+	symbolType   = reflect.TypeOf(binance.Symbol(""))
+	logLevelType = reflect.TypeOf(LogLevel(""))
+	rawType      = reflect.TypeOf(json.RawMessage(nil))
+)
+
+func schemaOf(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case decimalType:
+		return map[string]any{
+			"type":    []string{"string", "number"},
+			"pattern": `^-?[0-9]*\.?[0-9]+$`,
+		}
+	case durationType:
+		return map[string]any{
+			"type":    "string",
+			"pattern": `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`,
+		}
+	case pairType:
+		pairs := make([]string, 0, len(payeer.KnownPairs))
+		for _, pair := range payeer.KnownPairs {
+			pairs = append(pairs, string(pair))
+		}
+		return map[string]any{"type": "string", "enum": pairs}
+	case actionType:
+		return map[string]any{"type": "string", "enum": []payeer.Action{payeer.ACTION_BUY, payeer.ACTION_SELL}}
+	case symbolType:
+		return map[string]any{"type": "string", "pattern": symbolPattern.String()}
+	case logLevelType:
+		return map[string]any{"type": "string", "enum": []string{"debug", "info", "warn", "error"}}
+	case rawType:
+		return map[string]any{"type": "object"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Map:
+		schema := map[string]any{
+			"type":                 "object",
+			"additionalProperties": schemaOf(t.Elem()),
+		}
+		if t.Key() == pairType {
+			schema["propertyNames"] = schemaOf(pairType)
+		}
+		return schema
+	case reflect.Struct:
+		properties := map[string]any{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" && embedded(field) {
+				lifted := schemaOf(field.Type)
+				for name, property := range lifted["properties"].(map[string]any) {
+					properties[name] = property
+				}
+				if names, ok := lifted["required"].([]string); ok {
+					required = append(required, names...)
+				}
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			property := schemaOf(field.Type)
+			if desc := field.Tag.Get("desc"); desc != "" {
+				property["description"] = desc
+			}
+			properties[name] = property
+			if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Pointer {
+				required = append(required, name)
+			}
+		}
+		schema := map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]any{}
+}
@@ -0,0 +1,505 @@
+package config
+
+import (
+	"automata/client/binance"
+	"automata/client/payeer"
+	"automata/hedge"
+	"automata/logging"
+	"automata/notify"
+	"automata/reference"
+	"automata/risk"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Duration is a time.Duration written as a Go duration string ("500ms").
+type Duration time.Duration
+
+func (d Duration) D() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"500ms\", got %s", data)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Payeer holds the API credentials, usually as ${API_ID} and ${SECRET}.
+type Payeer struct {
+	ApiId  string `json:"apiId" desc:"Payeer API id" secret:"true"`
+	Secret string `json:"secret" desc:"Payeer API secret" secret:"true"`
+}
+
+func (p *Payeer) Validate(problems *Problems, path string) {
+	if p.ApiId == "" {
+		problems.Add(path+".apiId", "is required")
+	}
+	if p.Secret == "" {
+		problems.Add(path+".secret", "is required")
+	}
+}
+
+// Paper switches the Payeer client to the simulated exchange.
+type Paper struct {
+	Enabled  bool                       `json:"enabled" desc:"simulate order execution against live books instead of trading"`
+	Balances map[string]decimal.Decimal `json:"balances,omitempty" desc:"virtual starting balances by asset"`
+}
+
+func (p *Paper) Validate(problems *Problems, path string) {
+	for asset, amount := range p.Balances {
+		problems.NotNegative(path+".balances."+asset, amount)
+	}
+}
+
+type LogLevel string
+
+func (l LogLevel) Level() slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(l)); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+func (l LogLevel) Validate(problems *Problems, path string) {
+	if l == "" {
+		return
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(l)); err != nil {
+		problems.Add(path, "must be one of debug, info, warn, error")
+	}
+}
+
+// Log selects the log format and overrides the level per component, the name
+// in brackets at the start of a message such as PayeerFetcher.
+type Log struct {
+	Format logging.Format      `json:"format,omitempty" desc:"text or json; default text"`
+	Levels map[string]LogLevel `json:"levels,omitempty" desc:"level by component, e.g. PayeerFetcher: warn"`
+}
+
+func (l *Log) Validate(problems *Problems, path string) {
+	switch l.Format {
+	case "", logging.FORMAT_TEXT, logging.FORMAT_JSON:
+	default:
+		problems.Add(path+".format", "must be text or json")
+	}
+	for component, level := range l.Levels {
+		level.Validate(problems, path+".levels."+component)
+	}
+}
+
+func (l *Log) ComponentLevels() map[string]slog.Level {
+	levels := make(map[string]slog.Level, len(l.Levels))
+	for component, level := range l.Levels {
+		levels[component] = level.Level()
+	}
+	return levels
+}
+
+// Reconcile tunes the periodic check of local order and balance state against
+// the exchange. Zero values fall back to the defaults in the descriptions.
+type Reconcile struct {
+	Interval  Duration        `json:"interval,omitempty" desc:"time between checks, a fill triggers one early; default 1m"`
+	Tolerance decimal.Decimal `json:"tolerance,omitempty" desc:"balance drift relative to the asset total that is ignored; default 0.001"`
+	HaltDrift decimal.Decimal `json:"haltDrift,omitempty" desc:"balance drift relative to the asset total that halts trading; default 0.05"`
+}
+
+func (r *Reconcile) Validate(problems *Problems, path string) {
+	if r.Interval != 0 {
+		problems.Interval(path+".interval", r.Interval, 10*time.Second)
+	}
+	problems.DecimalRange(path+".tolerance", r.Tolerance, "0", "1")
+	problems.DecimalRange(path+".haltDrift", r.HaltDrift, "0", "1")
+	if !r.HaltDrift.IsZero() && r.HaltDrift.LessThan(r.Tolerance) {
+		problems.Addf(path+".haltDrift", "%s is below the tolerance %s", r.HaltDrift, r.Tolerance)
+	}
+}
+
+// Risk holds the pre-trade limits every order passes; omitted limits are off.
+type Risk struct {
+	MaxOrderNotional    map[string]decimal.Decimal `json:"maxOrderNotional,omitempty" desc:"largest amount × price of one order, by quote asset"`
+	MaxInventory        map[string]decimal.Decimal `json:"maxInventory,omitempty" desc:"largest net amount bought or sold since the process started, by asset"`
+	MaxOrdersPerMinute  int                        `json:"maxOrdersPerMinute,omitempty"`
+	MaxCancelsPerMinute int                        `json:"maxCancelsPerMinute,omitempty" desc:"cancels above the rate wait instead of failing"`
+	PriceCollar         decimal.Decimal            `json:"priceCollar,omitempty" desc:"largest relative distance of an order price from the Binance mid, e.g. 0.02; orders on pairs without a mid are rejected"`
+	ReferenceMaxAge     Duration                   `json:"referenceMaxAge,omitempty" desc:"age of the Binance mid above which the collar rejects orders; default 10s"`
+	DailyLossLimit      map[string]decimal.Decimal `json:"dailyLossLimit,omitempty" desc:"largest loss of the UTC day, by quote asset"`
+}
+
+func (r *Risk) Validate(problems *Problems, path string) {
+	for asset, limit := range r.MaxOrderNotional {
+		problems.Positive(path+".maxOrderNotional."+asset, limit)
+	}
+	for asset, limit := range r.MaxInventory {
+		problems.Positive(path+".maxInventory."+asset, limit)
+	}
+	for asset, limit := range r.DailyLossLimit {
+		problems.Positive(path+".dailyLossLimit."+asset, limit)
+	}
+	if r.MaxOrdersPerMinute < 0 {
+		problems.Add(path+".maxOrdersPerMinute", "must not be negative")
+	}
+	if r.MaxCancelsPerMinute < 0 {
+		problems.Add(path+".maxCancelsPerMinute", "must not be negative")
+	}
+	problems.DecimalRange(path+".priceCollar", r.PriceCollar, "0", "0.5")
+	if r.ReferenceMaxAge != 0 {
+		problems.Interval(path+".referenceMaxAge", r.ReferenceMaxAge, 100*time.Millisecond)
+	}
+}
+
+func (r *Risk) Limits() *risk.Limits {
+	return &risk.Limits{
+		MaxOrderNotional:    r.MaxOrderNotional,
+		MaxInventory:        r.MaxInventory,
+		MaxOrdersPerMinute:  r.MaxOrdersPerMinute,
+		MaxCancelsPerMinute: r.MaxCancelsPerMinute,
+		PriceCollar:         r.PriceCollar,
+		ReferenceMaxAge:     r.ReferenceMaxAge.D(),
+		DailyLossLimit:      r.DailyLossLimit,
+	}
+}
+
+// References configures the reference prices that combine several venues; a
+// reference named like a Binance symbol replaces its book ticker for every
+// strategy. Omitted, strategies read the Binance book ticker alone.
+type References struct {
+	MaxAge         Duration                              `json:"maxAge,omitempty" desc:"drop a quote older than this; default 5s"`
+	MaxDeviation   decimal.Decimal                       `json:"maxDeviation,omitempty" desc:"drop a quote whose mid is further than this fraction from the weighted median; default 0.005"`
+	MinConfidence  decimal.Decimal                       `json:"minConfidence,omitempty" desc:"share of the source weight below which the reference is unavailable"`
+	DepthLevels    int                                   `json:"depthLevels,omitempty" desc:"levels of the Binance depth streams, 5, 10 or 20; default 20"`
+	PayeerInterval Duration                              `json:"payeerInterval,omitempty" desc:"time between polls of the Payeer tickers and books; default 2s"`
+	Symbols        map[binance.Symbol][]reference.Source `json:"symbols,omitempty" desc:"sources of each reference"`
+}
+
+func (r *References) Validate(problems *Problems, path string) {
+	if r.MaxAge != 0 {
+		problems.Interval(path+".maxAge", r.MaxAge, 100*time.Millisecond)
+	}
+	problems.DecimalRange(path+".maxDeviation", r.MaxDeviation, "0", "0.5")
+	problems.DecimalRange(path+".minConfidence", r.MinConfidence, "0", "1")
+	if r.PayeerInterval != 0 {
+		problems.Interval(path+".payeerInterval", r.PayeerInterval, time.Second)
+	}
+	switch r.DepthLevels {
+	case 0, 5, 10, 20:
+	default:
+		problems.Addf(path+".depthLevels", "must be 5, 10 or 20, got %d", r.DepthLevels)
+	}
+	for name, sources := range r.Symbols {
+		namePath := path + ".symbols." + string(name)
+		problems.Symbol(namePath, name)
+		if len(sources) == 0 {
+			problems.Add(namePath, "at least one source is required")
+		}
+		for i, source := range sources {
+			sourcePath := fmt.Sprintf("%s.%d", namePath, i)
+			if !slices.Contains(reference.SourceKinds, source.Kind) {
+				problems.Addf(sourcePath+".kind", "must be one of %v, got %q", reference.SourceKinds, source.Kind)
+			}
+			problems.NotNegative(sourcePath+".weight", source.Weight)
+			if source.Kind != reference.SOURCE_SYNTHETIC {
+				r.validateQuote(problems, sourcePath, source.Kind, source.Symbol, source.Amount)
+			} else if len(source.Legs) == 0 {
+				problems.Add(sourcePath+".legs", "at least one leg is required")
+			}
+			if len(source.Legs) > 0 && source.Kind != reference.SOURCE_SYNTHETIC {
+				problems.Addf(sourcePath+".legs", "only apply to %s sources", reference.SOURCE_SYNTHETIC)
+			}
+			for j, leg := range source.Legs {
+				legPath := fmt.Sprintf("%s.legs.%d", sourcePath, j)
+				if !slices.Contains(reference.LegKinds, leg.Kind) {
+					problems.Addf(legPath+".kind", "must be one of %v, got %q", reference.LegKinds, leg.Kind)
+				}
+				r.validateQuote(problems, legPath, leg.Kind, leg.Symbol, leg.Amount)
+			}
+			if source.Convert != "" {
+				if _, ok := r.Symbols[source.Convert]; !ok {
+					problems.Addf(sourcePath+".convert", "no reference %q", source.Convert)
+				}
+			}
+		}
+		if r.converts(name, name, map[binance.Symbol]bool{}) {
+			problems.Add(namePath, "converts through itself")
+		}
+	}
+}
+
+// validateQuote checks the symbol and amount of a source or leg by its kind.
+func (r *References) validateQuote(problems *Problems, path string, kind reference.SourceKind, symbol string, amount decimal.Decimal) {
+	switch kind {
+	case reference.SOURCE_PAYEER_TICKER, reference.SOURCE_PAYEER_BOOK:
+		problems.Pair(path+".symbol", payeer.Pair(symbol))
+	case reference.SOURCE_REFERENCE:
+		if _, ok := r.Symbols[binance.Symbol(symbol)]; !ok {
+			problems.Addf(path+".symbol", "no reference %q", symbol)
+		}
+	default:
+		problems.Symbol(path+".symbol", binance.Symbol(symbol))
+	}
+	problems.NotNegative(path+".amount", amount)
+	if !amount.IsZero() && !kind.Sweeps() {
+		problems.Addf(path+".amount", "only applies to %s and %s", reference.SOURCE_BINANCE_DEPTH, reference.SOURCE_PAYEER_BOOK)
+	}
+}
+
+// converts reports whether the cross rates and reference legs of name lead
+// back to target.
+func (r *References) converts(name, target binance.Symbol, seen map[binance.Symbol]bool) bool {
+	if seen[name] {
+		return false
+	}
+	seen[name] = true
+	for _, source := range r.Symbols[name] {
+		rates := []binance.Symbol{source.Convert}
+		for _, leg := range source.Legs {
+			if leg.Kind == reference.SOURCE_REFERENCE {
+				rates = append(rates, binance.Symbol(leg.Symbol))
+			}
+		}
+		for _, rate := range rates {
+			if rate == target || rate != "" && r.converts(rate, target, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *References) Options() *reference.Options {
+	return &reference.Options{
+		MaxAge:         r.MaxAge.D(),
+		MaxDeviation:   r.MaxDeviation,
+		MinConfidence:  r.MinConfidence,
+		DepthLevels:    r.DepthLevels,
+		PayeerInterval: r.PayeerInterval.D(),
+		References:     r.Symbols,
+	}
+}
+
+// Hedge offsets the Payeer fills of the listed pairs on Binance or MEXC when
+// Venue is set. Omitted, fills stay unhedged.
+type Hedge struct {
+	Venue          hedge.VenueName            `json:"venue,omitempty" desc:"binance or mexc; empty disables hedging"`
+	ApiKey         string                     `json:"apiKey,omitempty" desc:"API key of the venue, usually ${HEDGE_API_KEY}" secret:"true"`
+	Secret         string                     `json:"secret,omitempty" desc:"API secret of the venue, usually ${HEDGE_SECRET}" secret:"true"`
+	Symbols        map[payeer.Pair]string     `json:"symbols,omitempty" desc:"venue symbol each pair is hedged on, trading the same base and quote, e.g. BTCUSDT"`
+	MaxSlippageBps decimal.Decimal            `json:"maxSlippageBps,omitempty" desc:"furthest a hedge price may be from the best bid or ask of the venue; default 10"`
+	Interval       Duration                   `json:"interval,omitempty" desc:"time between hedge attempts; default 1s"`
+	FeePercent     decimal.Decimal            `json:"feePercent,omitempty" desc:"taker fee of the hedge venue in percent; booked for MEXC hedges, which report none, and priced into the target edge of the strategies"`
+	AlertNotional  map[string]decimal.Decimal `json:"alertNotional,omitempty" desc:"alert when the unhedged exposure of a pair is worth more, by quote asset"`
+	AlertAfter     Duration                   `json:"alertAfter,omitempty" desc:"alert when a fill stays unhedged longer; default 5m"`
+}
+
+func (h *Hedge) Enabled() bool {
+	return h.Venue != ""
+}
+
+func (h *Hedge) Validate(problems *Problems, path string) {
+	problems.DecimalRange(path+".feePercent", h.FeePercent, "0", "1")
+	if !h.Enabled() {
+		return
+	}
+	if !slices.Contains(hedge.Venues, h.Venue) {
+		problems.Addf(path+".venue", "must be one of %v, got %q", hedge.Venues, h.Venue)
+	}
+	if h.ApiKey == "" {
+		problems.Add(path+".apiKey", "is required")
+	}
+	if h.Secret == "" {
+		problems.Add(path+".secret", "is required")
+	}
+	if len(h.Symbols) == 0 {
+		problems.Add(path+".symbols", "at least one pair is required")
+	}
+	for pair, symbol := range h.Symbols {
+		problems.Pair(path+".symbols", pair)
+		base, quote, ok := risk.SplitSymbol(symbol)
+		if !ok {
+			problems.Addf(path+".symbols."+string(pair), "unknown quote asset in %q", symbol)
+		} else if base != pair.Base() || quote != pair.Quote() {
+			problems.Addf(path+".symbols."+string(pair), "%s does not trade %s against %s", symbol, pair.Base(), pair.Quote())
+		}
+	}
+	problems.DecimalRange(path+".maxSlippageBps", h.MaxSlippageBps, "0", "500")
+	if h.Interval != 0 {
+		problems.Interval(path+".interval", h.Interval, 100*time.Millisecond)
+	}
+	for asset, limit := range h.AlertNotional {
+		problems.Positive(path+".alertNotional."+asset, limit)
+	}
+	if h.AlertAfter != 0 {
+		problems.Interval(path+".alertAfter", h.AlertAfter, time.Second)
+	}
+}
+
+func (h *Hedge) Options() *hedge.Options {
+	return &hedge.Options{
+		Symbols:        h.Symbols,
+		MaxSlippageBps: h.MaxSlippageBps,
+		Interval:       h.Interval.D(),
+		AlertNotional:  h.AlertNotional,
+		AlertAfter:     h.AlertAfter.D(),
+	}
+}
+
+// Admin enables the operator HTTP API when Addr is set.
+type Admin struct {
+	Addr  string `json:"addr,omitempty" desc:"listen address such as 127.0.0.1:8081; empty disables the API"`
+	Token string `json:"token,omitempty" desc:"bearer token every request must carry, usually ${ADMIN_TOKEN}" secret:"true"`
+}
+
+func (a *Admin) Validate(problems *Problems, path string) {
+	if a.Addr != "" && len(a.Token) < 16 {
+		problems.Add(path+".token", "must be at least 16 characters when the API is enabled")
+	}
+}
+
+// KillSwitch configures what trips the global circuit breaker; omitted
+// triggers are off. It can always be tripped through the admin API.
+type KillSwitch struct {
+	FlagFile          string                     `json:"flagFile,omitempty" desc:"trip while this file exists"`
+	LossLimit         map[string]decimal.Decimal `json:"lossLimit,omitempty" desc:"trip when the daily loss reaches this, by quote asset"`
+	FeedMaxAge        Duration                   `json:"feedMaxAge,omitempty" desc:"trip when a Binance ticker is older"`
+	MaxExchangeErrors int                        `json:"maxExchangeErrors,omitempty" desc:"trip after this many failed Payeer requests within errorWindow"`
+	ErrorWindow       Duration                   `json:"errorWindow,omitempty" desc:"default 1m"`
+}
+
+func (k *KillSwitch) Validate(problems *Problems, path string) {
+	for asset, limit := range k.LossLimit {
+		problems.Positive(path+".lossLimit."+asset, limit)
+	}
+	if k.FeedMaxAge != 0 {
+		problems.Interval(path+".feedMaxAge", k.FeedMaxAge, time.Second)
+	}
+	if k.MaxExchangeErrors < 0 {
+		problems.Add(path+".maxExchangeErrors", "must not be negative")
+	}
+	if k.ErrorWindow != 0 {
+		problems.Interval(path+".errorWindow", k.ErrorWindow, time.Second)
+	}
+}
+
+// Metrics enables the Prometheus endpoint when Addr is set.
+type Metrics struct {
+	Addr string `json:"addr,omitempty" desc:"listen address of /metrics such as 127.0.0.1:9100; empty disables it"`
+}
+
+// Notify sends alerts to every configured channel; without one alerts are off.
+type Notify struct {
+	Webhook           *Webhook  `json:"webhook,omitempty"`
+	Telegram          *Telegram `json:"telegram,omitempty"`
+	Smtp              *Smtp     `json:"smtp,omitempty"`
+	DedupWindow       Duration  `json:"dedupWindow,omitempty" desc:"repeats of an alert within this are dropped; default 10m"`
+	MaxPerMinute      int       `json:"maxPerMinute,omitempty" desc:"alerts over this per minute are dropped; default 20"`
+	Fills             bool      `json:"fills,omitempty" desc:"alert on every fill"`
+	InsufficientFunds int       `json:"insufficientFunds,omitempty" desc:"alert after this many INSUFFICIENT_FUNDS responses within 10m; default 3"`
+	StaleFeed         Duration  `json:"staleFeed,omitempty" desc:"alert when a Binance ticker is older; default 30s"`
+}
+
+type Webhook struct {
+	Url     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty" desc:"extra request headers, e.g. an Authorization with ${WEBHOOK_TOKEN}" secret:"true"`
+}
+
+type Telegram struct {
+	BaseUrl string `json:"baseUrl,omitempty" desc:"Bot API server; default https://api.telegram.org"`
+	Token   string `json:"token" desc:"bot token, usually ${TELEGRAM_TOKEN}" secret:"true"`
+	ChatId  string `json:"chatId"`
+}
+
+type Smtp struct {
+	Addr     string   `json:"addr" desc:"host:port of the mail server"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty" secret:"true"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+func (n *Notify) Validate(problems *Problems, path string) {
+	if n.Webhook != nil && n.Webhook.Url == "" {
+		problems.Add(path+".webhook.url", "is required")
+	}
+	if n.Telegram != nil {
+		if n.Telegram.Token == "" {
+			problems.Add(path+".telegram.token", "is required")
+		}
+		if n.Telegram.ChatId == "" {
+			problems.Add(path+".telegram.chatId", "is required")
+		}
+	}
+	if n.Smtp != nil {
+		if _, _, err := net.SplitHostPort(n.Smtp.Addr); err != nil {
+			problems.Add(path+".smtp.addr", "must be host:port")
+		}
+		if n.Smtp.From == "" {
+			problems.Add(path+".smtp.from", "is required")
+		}
+		if len(n.Smtp.To) == 0 {
+			problems.Add(path+".smtp.to", "at least one recipient is required")
+		}
+	}
+	if n.DedupWindow != 0 {
+		problems.Interval(path+".dedupWindow", n.DedupWindow, time.Second)
+	}
+	if n.MaxPerMinute < 0 {
+		problems.Add(path+".maxPerMinute", "must not be negative")
+	}
+	if n.InsufficientFunds < 0 {
+		problems.Add(path+".insufficientFunds", "must not be negative")
+	}
+	if n.StaleFeed != 0 {
+		problems.Interval(path+".staleFeed", n.StaleFeed, time.Second)
+	}
+}
+
+// Dispatcher returns a dispatcher to the configured channels, or nil when none
+// is configured.
+func (n *Notify) Dispatcher() *notify.Dispatcher {
+	notifiers := []notify.Notifier{}
+	if n.Webhook != nil {
+		notifiers = append(notifiers, &notify.Webhook{URL: n.Webhook.Url, Headers: n.Webhook.Headers})
+	}
+	if n.Telegram != nil {
+		notifiers = append(notifiers, &notify.Telegram{BaseUrl: n.Telegram.BaseUrl, Token: n.Telegram.Token, ChatId: n.Telegram.ChatId})
+	}
+	if n.Smtp != nil {
+		notifiers = append(notifiers, &notify.SMTP{
+			Addr:     n.Smtp.Addr,
+			Username: n.Smtp.Username,
+			Password: n.Smtp.Password,
+			From:     n.Smtp.From,
+			To:       n.Smtp.To,
+		})
+	}
+	if len(notifiers) == 0 {
+		return nil
+	}
+	return notify.NewDispatcher(&notify.DispatcherOptions{
+		DedupWindow:  n.DedupWindow.D(),
+		MaxPerMinute: n.MaxPerMinute,
+	}, notifiers...)
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"automata/client/binance"
+	"automata/client/payeer"
+	"automata/inventory"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Problems collects validation failures keyed by field path.
+type Problems struct {
+	list []string
+}
+
+func (p *Problems) Add(path, message string) {
+	p.list = append(p.list, path+": "+message)
+}
+
+func (p *Problems) Addf(path, format string, args ...any) {
+	p.Add(path, fmt.Sprintf(format, args...))
+}
+
+func (p *Problems) Err() error {
+	if len(p.list) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: p.list}
+}
+
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// DecimalRange checks min <= v <= max; an empty bound is not checked.
+func (p *Problems) DecimalRange(path string, v decimal.Decimal, min, max string) {
+	if min != "" && v.LessThan(decimal.RequireFromString(min)) {
+		p.Addf(path, "%s is below the minimum %s", v, min)
+	}
+	if max != "" && v.GreaterThan(decimal.RequireFromString(max)) {
+		p.Addf(path, "%s is above the maximum %s", v, max)
+	}
+}
+
+func (p *Problems) Positive(path string, v decimal.Decimal) {
+	if !v.IsPositive() {
+		p.Addf(path, "must be positive, got %s", v)
+	}
+}
+
+func (p *Problems) NotNegative(path string, v decimal.Decimal) {
+	if v.IsNegative() {
+		p.Addf(path, "must not be negative, got %s", v)
+	}
+}
+
+func (p *Problems) Interval(path string, d Duration, min time.Duration) {
+	if d.D() < min {
+		p.Addf(path, "%s is shorter than the minimum %s", d, min)
+	}
+}
+
+func (p *Problems) Pair(path string, pair payeer.Pair) {
+	if !pair.IsKnown() {
+		p.Addf(path, "unknown pair %q", pair)
+	}
+}
+
+var symbolPattern = regexp.MustCompile(`^[A-Z0-9]{5,20}$`)
+
+func (p *Problems) Symbol(path string, symbol binance.Symbol) {
+	if !symbolPattern.MatchString(string(symbol)) {
+		p.Addf(path, "invalid Binance symbol %q", symbol)
+	}
+}
+
+func (p *Problems) Action(path string, action payeer.Action) {
+	if action != payeer.ACTION_BUY && action != payeer.ACTION_SELL {
+		p.Addf(path, "must be %q or %q, got %q", payeer.ACTION_BUY, payeer.ACTION_SELL, action)
+	}
+}
+
+// ShareSums checks that fractions drawing on the same asset add up to at most 1.
+func (p *Problems) ShareSums(path string, sums map[string]decimal.Decimal) {
+	for asset, sum := range sums {
+		if sum.GreaterThan(decimal.NewFromInt(1)) {
+			p.Addf(path, "shares spending %s add up to %s, more than 1", asset, sum)
+		}
+	}
+}
+
+// Edge checks a target edge; a nil edge is not checked.
+func (p *Problems) Edge(path string, edge *payeer.Edge) {
+	if edge == nil {
+		return
+	}
+	p.DecimalRange(path+".targetBps", edge.TargetBps, "-100", "1000")
+	if !edge.HedgeFeePercent.IsZero() {
+		p.Add(path+".hedgeFeePercent", "is taken from hedge.feePercent, remove it here")
+	}
+}
+
+// Skew checks an inventory skew: maxShort < targetBase < maxLong within [0, 1].
+// A nil skew is not checked.
+func (p *Problems) Skew(path string, skew *inventory.Skew) {
+	if skew == nil {
+		return
+	}
+	p.DecimalRange(path+".targetBase", skew.TargetBase, "0.01", "0.99")
+	p.DecimalRange(path+".maxLong", skew.MaxLong, "0", "1")
+	p.DecimalRange(path+".maxShort", skew.MaxShort, "0", "1")
+	if !skew.MaxLong.GreaterThan(skew.TargetBase) {
+		p.Addf(path+".maxLong", "%s must be above targetBase %s", skew.MaxLong, skew.TargetBase)
+	}
+	if !skew.MaxShort.LessThan(skew.TargetBase) {
+		p.Addf(path+".maxShort", "%s must be below targetBase %s", skew.MaxShort, skew.TargetBase)
+	}
+	p.DecimalRange(path+".priceBps", skew.PriceBps, "0", "100")
+	p.DecimalRange(path+".sizeReduction", skew.SizeReduction, "0", "1")
+	if !skew.Exponent.IsZero() {
+		p.DecimalRange(path+".exponent", skew.Exponent, "0.5", "4")
+	}
+}
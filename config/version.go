@@ -0,0 +1,18 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Version identifies a config by the first 12 hex digits of the SHA-256 of its
+// JSON form, so records can name the config they were made under.
+func Version(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Watcher reloads a config file when its contents change, on SIGHUP or when
+// Reload is called, and hands every valid new version to an apply function.
+// A file that fails to load or apply is logged and the running config is kept.
+type Watcher[T any] struct {
+	path    string
+	apply   func(old, new *T) error
+	mu      sync.Mutex
+	current *T
+	data    []byte
+}
+
+func NewWatcher[T any](path string, current *T, apply func(old, new *T) error) *Watcher[T] {
+	data, _ := os.ReadFile(path)
+	return &Watcher[T]{
+		path:    path,
+		apply:   apply,
+		current: current,
+		data:    data,
+	}
+}
+
+// Start polls the file every interval and listens for SIGHUP. A zero interval
+// disables polling.
+func (w *Watcher[T]) Start(interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		var tick <-chan time.Time
+		if interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			select {
+			case <-tick:
+				data, err := os.ReadFile(w.path)
+				if err != nil || w.unchanged(data) {
+					continue
+				}
+			case <-hup:
+				slog.Info("[Config] SIGHUP received, reloading", "path", w.path)
+			}
+			w.Reload()
+		}
+	}()
+}
+
+func (w *Watcher[T]) unchanged(data []byte) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return bytes.Equal(data, w.data)
+}
+
+// Current returns the config that was applied last.
+func (w *Watcher[T]) Current() *T {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+// Reload loads the file and applies it if anything changed. The returned
+// changes are empty when the file matches the running config.
+func (w *Watcher[T]) Reload() ([]Change, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		slog.Error("[Config] Reload failed", "path", w.path, "error", err)
+		return nil, err
+	}
+	w.data = data
+
+	next := new(T)
+	if err := Decode(data, filepath.Ext(w.path), next); err != nil {
+		err = fmt.Errorf("config %s: %w", w.path, err)
+		slog.Error("[Config] Reload rejected, keeping the running config", "error", err)
+		return nil, err
+	}
+	return w.commit(next, w.path)
+}
+
+// Update applies edit to a copy of the running config and applies the result
+// like a reload, e.g. for a parameter change through the admin API. The file is
+// not written, so the next change to it replaces the update.
+func (w *Watcher[T]) Update(source string, edit func(next *T) error) ([]Change, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	data, err := json.Marshal(w.current)
+	if err != nil {
+		return nil, err
+	}
+	next := new(T)
+	if err := json.Unmarshal(data, next); err != nil {
+		return nil, err
+	}
+	if err := edit(next); err != nil {
+		return nil, err
+	}
+	if validator, ok := any(next).(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			slog.Error("[Config] Update rejected, keeping the running config", "source", source, "error", err)
+			return nil, err
+		}
+	}
+	return w.commit(next, source)
+}
+
+// commit applies next if it differs from the running config; w.mu is held.
+func (w *Watcher[T]) commit(next *T, source string) ([]Change, error) {
+	changes := Diff(w.current, next)
+	if len(changes) == 0 {
+		slog.Info("[Config] Reloaded, nothing changed", "source", source)
+		return changes, nil
+	}
+	if err := w.apply(w.current, next); err != nil {
+		slog.Error("[Config] Reload rejected, keeping the running config", "source", source, "error", err)
+		return nil, err
+	}
+	for _, change := range changes {
+		slog.Info("[Config] Changed", "path", change.Path, "old", change.Old, "new", change.New)
+	}
+	slog.Info("[Config] Applied", "source", source, "changes", len(changes))
+	w.current = next
+	return changes, nil
+}
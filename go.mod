@@ -2,14 +2,17 @@ module automata
 
 go 1.22.4
 
-require github.com/gorilla/websocket v1.5.3
+require (
+	github.com/fatih/color v1.17.0
+	github.com/gorilla/websocket v1.5.3
+	github.com/opus-domini/fast-shot v1.1.2
+	github.com/shopspring/decimal v1.4.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
-	github.com/fatih/color v1.17.0 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	github.com/opus-domini/fast-shot v1.1.2 // indirect
-	github.com/shopspring/decimal v1.4.0 // indirect
 	golang.org/x/sys v0.18.0 // indirect
 )
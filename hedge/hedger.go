@@ -0,0 +1,555 @@
+// Package hedge offsets Payeer fills on a second venue.
+//
+// A Hedger adds the fills of the configured pairs to a signed exposure per
+// pair and sends immediate-or-cancel orders against it on the hedge venue.
+// Exposure below the minimum amount or notional of the venue waits for more
+// fills to batch with. Orders are limited to MaxSlippageBps from the best bid
+// or ask of the venue; what they leave unfilled stays exposed and is retried.
+// A fill against the exposure offsets the oldest fills instead of being
+// hedged.
+//
+// Every execution is split first-in first-out over the fills it offsets and
+// booked into the PnL ledger under their strategy and share, so the position
+// of a share nets its Payeer fills against their hedges. Exposure and the
+// hedge trades are kept in memory; fills of a previous process are not hedged
+// after a restart.
+package hedge
+
+import (
+	"automata/client/payeer"
+	"automata/metrics"
+	"automata/msync"
+	"automata/notify"
+	"automata/pnl"
+	"automata/risk"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// STRATEGY owns the part of an execution that outgrew the exposure, which
+// happens when opposite fills arrive while the order is out. It is hedged
+// back like a fill.
+const STRATEGY = "hedge"
+
+type Options struct {
+	// Symbols maps each hedged Payeer pair to its venue symbol, which trades
+	// the same base and quote.
+	Symbols map[payeer.Pair]string
+	// MaxSlippageBps is the furthest a hedge price may be from the best bid or
+	// ask of the venue. Default 10.
+	MaxSlippageBps decimal.Decimal
+	// Interval between hedge attempts; a fill triggers one right away.
+	// Default 1s.
+	Interval time.Duration
+	// AlertNotional alerts once the unhedged exposure of a pair is worth more,
+	// by quote asset, valued at the prices of its fills.
+	AlertNotional map[string]decimal.Decimal
+	// AlertAfter alerts once a fill stays unhedged longer. Default 5m.
+	AlertAfter time.Duration
+}
+
+// fill is the unhedged part of a Payeer fill, negative when it sold.
+type fill struct {
+	strategy string
+	share    string
+	time     time.Time
+	amount   decimal.Decimal
+	price    decimal.Decimal
+}
+
+// exposure is the unhedged base amount of a pair, positive when the fills
+// bought more than they sold, and the fills it is made of, oldest first. All
+// fills have the sign of the amount.
+type exposure struct {
+	amount    decimal.Decimal
+	fills     []fill
+	failures  int
+	lastError string
+	alerted   bool
+}
+
+// add books f, offsetting the oldest fills of the opposite side first.
+func (e *exposure) add(f fill) {
+	e.amount = e.amount.Add(f.amount)
+	for len(e.fills) > 0 && !f.amount.IsZero() && e.fills[0].amount.Sign() != f.amount.Sign() {
+		first := &e.fills[0]
+		if first.amount.Abs().GreaterThan(f.amount.Abs()) {
+			first.amount = first.amount.Add(f.amount)
+			return
+		}
+		f.amount = f.amount.Add(first.amount)
+		e.fills = e.fills[1:]
+	}
+	if !f.amount.IsZero() {
+		e.fills = append(e.fills, f)
+	}
+}
+
+// notional values the exposure at the prices of its fills.
+func (e *exposure) notional() decimal.Decimal {
+	notional := decimal.Zero
+	for _, f := range e.fills {
+		notional = notional.Add(f.amount.Abs().Mul(f.price))
+	}
+	return notional
+}
+
+func (e *exposure) since() time.Time {
+	if len(e.fills) == 0 {
+		return time.Time{}
+	}
+	return e.fills[0].time
+}
+
+// Result is the hedging of the fills of one share and pair.
+type Result struct {
+	pnl.Key
+	Trades int `json:"trades"`
+	// Hedged is the base amount hedged.
+	Hedged decimal.Decimal `json:"hedged"`
+	// Spread is what the hedges locked in against the prices of the Payeer
+	// fills, in quote; PnL is the spread less the hedge fees. Payeer fees are
+	// booked with the fills.
+	Spread decimal.Decimal `json:"spread"`
+	Fees   decimal.Decimal `json:"fees"`
+	PnL    decimal.Decimal `json:"pnl"`
+}
+
+// Exposure is the unhedged state of one pair.
+type Exposure struct {
+	Pair   payeer.Pair     `json:"pair"`
+	Symbol string          `json:"symbol"`
+	Amount decimal.Decimal `json:"amount"`
+	// Notional is valued at the prices of the unhedged fills.
+	Notional  decimal.Decimal `json:"notional"`
+	Since     time.Time       `json:"since,omitempty"`
+	Failures  int             `json:"failures,omitempty"`
+	LastError string          `json:"lastError,omitempty"`
+}
+
+type Status struct {
+	Venue     VenueName  `json:"venue"`
+	Exposures []Exposure `json:"exposures"`
+	Results   []Result   `json:"results"`
+}
+
+type Hedger struct {
+	options    *Options
+	venue      Venue
+	ledger     *pnl.Ledger
+	risk       *risk.Manager
+	dispatcher *notify.Dispatcher
+	rules      *msync.MuMap[string, *Rules]
+	wake       chan struct{}
+	// hedging serialises the hedge attempts of Run and Close.
+	hedging   sync.Mutex
+	mu        sync.Mutex
+	exposures map[payeer.Pair]*exposure
+	results   map[pnl.Key]*Result
+}
+
+// NewHedger books hedge trades into ledger and risk, which may be nil, and
+// sends its alerts to dispatcher. Pass Fill to Market.OnFill and start Run.
+func NewHedger(options *Options, venue Venue, ledger *pnl.Ledger, manager *risk.Manager, dispatcher *notify.Dispatcher) *Hedger {
+	if options.MaxSlippageBps.IsZero() {
+		options.MaxSlippageBps = decimal.NewFromInt(10)
+	}
+	if options.Interval == 0 {
+		options.Interval = time.Second
+	}
+	if options.AlertAfter == 0 {
+		options.AlertAfter = 5 * time.Minute
+	}
+	h := &Hedger{
+		options:    options,
+		venue:      venue,
+		ledger:     ledger,
+		risk:       manager,
+		dispatcher: dispatcher,
+		rules:      msync.NewMuMap[string, *Rules](),
+		wake:       make(chan struct{}, 1),
+		exposures:  make(map[payeer.Pair]*exposure),
+		results:    make(map[pnl.Key]*Result),
+	}
+	metrics.Register(h)
+	return h
+}
+
+// Fill adds the Payeer trades of the hedged pairs to their exposure.
+func (h *Hedger) Fill(trades []pnl.Trade) {
+	added := false
+	h.mu.Lock()
+	for _, trade := range trades {
+		pair := payeer.Pair(trade.Pair)
+		if _, ok := h.options.Symbols[pair]; !ok || trade.Venue != "payeer" {
+			continue
+		}
+		amount := trade.Amount
+		if trade.Side == risk.SIDE_SELL {
+			amount = amount.Neg()
+		}
+		h.exposure(pair).add(fill{
+			strategy: trade.Strategy,
+			share:    trade.Share,
+			time:     trade.Time,
+			amount:   amount,
+			price:    trade.Price,
+		})
+		added = true
+	}
+	h.mu.Unlock()
+	if added {
+		select {
+		case h.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// exposure returns the exposure of pair; h.mu must be held.
+func (h *Hedger) exposure(pair payeer.Pair) *exposure {
+	e, ok := h.exposures[pair]
+	if !ok {
+		e = &exposure{}
+		h.exposures[pair] = e
+	}
+	return e
+}
+
+// Run hedges the exposure every Interval and after fills until ctx is done.
+func (h *Hedger) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-h.wake:
+		}
+		h.Flush()
+		h.check()
+	}
+}
+
+// Flush hedges what it can of the exposure of every pair now.
+func (h *Hedger) Flush() {
+	h.hedging.Lock()
+	defer h.hedging.Unlock()
+	for pair := range h.options.Symbols {
+		h.hedge(pair)
+	}
+}
+
+// Close hedges once more, for the fills of orders cancelled on shutdown, and
+// logs the exposure left unhedged. It accepts a nil *Hedger.
+func (h *Hedger) Close() {
+	if h == nil {
+		return
+	}
+	h.Flush()
+	for _, e := range h.Status().Exposures {
+		if !e.Amount.IsZero() {
+			slog.Warn("[Hedger] Stopped with unhedged exposure", "pair", e.Pair, "amount", e.Amount, "since", e.Since)
+		}
+	}
+}
+
+// hedge sends an order against the exposure of pair once it meets the
+// minimums of the venue.
+func (h *Hedger) hedge(pair payeer.Pair) {
+	h.mu.Lock()
+	amount := decimal.Zero
+	if e, ok := h.exposures[pair]; ok {
+		amount = e.amount
+	}
+	h.mu.Unlock()
+	if amount.IsZero() {
+		return
+	}
+	symbol := h.options.Symbols[pair]
+	rules, err := h.rulesFor(symbol)
+	if err != nil {
+		h.failed(pair, err)
+		return
+	}
+	side := risk.SIDE_SELL
+	if amount.IsNegative() {
+		side = risk.SIDE_BUY
+	}
+	size := rules.RoundAmount(amount.Abs())
+	bid, ask, err := h.venue.BookTicker(symbol)
+	if err != nil {
+		h.failed(pair, err)
+		return
+	}
+	slippage := h.options.MaxSlippageBps.Div(decimal.NewFromInt(10000))
+	touch, limit := bid, bid.Mul(decimal.NewFromInt(1).Sub(slippage))
+	if side == risk.SIDE_BUY {
+		touch, limit = ask, ask.Mul(decimal.NewFromInt(1).Add(slippage))
+	}
+	if !touch.IsPositive() {
+		h.failed(pair, fmt.Errorf("%s has no %s price", symbol, side))
+		return
+	}
+	limit = rules.RoundPrice(side, limit)
+	if !size.IsPositive() || size.LessThan(rules.MinAmount) || size.Mul(limit).LessThan(rules.MinNotional) {
+		slog.Debug("[Hedger] Exposure below the venue minimum, batching", "pair", pair, "amount", amount, "minAmount", rules.MinAmount, "minNotional", rules.MinNotional)
+		return
+	}
+	clientOrderId := fmt.Sprintf("hedge_%d", time.Now().UnixNano())
+	execution, err := h.venue.PlaceIOC(symbol, side, size, limit, clientOrderId)
+	if err != nil {
+		h.failed(pair, err)
+		return
+	}
+	if !execution.Amount.IsPositive() {
+		slog.Info("[Hedger] Nothing filled within the slippage limit", "pair", pair, "side", side, "amount", size, "limit", limit, "touch", touch)
+		h.succeeded(pair)
+		return
+	}
+	trades := h.settle(pair, rules, side, execution)
+	h.succeeded(pair)
+	h.ledger.Add(trades...)
+	if h.risk != nil {
+		h.risk.RecordFill(risk.Fill{
+			Base:   rules.Base,
+			Quote:  rules.Quote,
+			Side:   side,
+			Amount: execution.Amount,
+			Price:  execution.Value.Div(execution.Amount),
+			Fee:    execution.Fee,
+		})
+	}
+	slog.Info("[Hedger] Hedged", "pair", pair, "venue", h.venue.Name(), "symbol", symbol, "side", side, "amount", execution.Amount, "value", execution.Value, "fee", execution.Fee, "limit", limit, "touch", touch)
+}
+
+// settle takes execution off the exposure of pair and returns a trade per
+// fill it offsets.
+func (h *Hedger) settle(pair payeer.Pair, rules *Rules, side risk.Side, execution *Execution) []pnl.Trade {
+	price := execution.Value.Div(execution.Amount)
+	// reduce is the sign of the fills the execution offsets.
+	reduce := 1
+	if side == risk.SIDE_BUY {
+		reduce = -1
+	}
+	trade := func(strategy, share string, amount decimal.Decimal, i int) pnl.Trade {
+		return pnl.Trade{
+			Venue:    string(h.venue.Name()),
+			Id:       fmt.Sprintf("%s/%d", execution.OrderId, i),
+			Time:     execution.Time,
+			Strategy: strategy,
+			Share:    share,
+			Pair:     string(pair),
+			Base:     rules.Base,
+			Quote:    rules.Quote,
+			Side:     side,
+			Amount:   amount,
+			Price:    price,
+			Fee:      execution.Fee.Mul(amount).Div(execution.Amount),
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	e := h.exposure(pair)
+	trades := []pnl.Trade{}
+	remaining := execution.Amount
+	for remaining.IsPositive() && len(e.fills) > 0 && e.fills[0].amount.Sign() == reduce {
+		f := &e.fills[0]
+		amount := decimal.Min(f.amount.Abs(), remaining)
+		t := trade(f.strategy, f.share, amount, len(trades))
+		trades = append(trades, t)
+		h.attribute(t, f.price)
+		remaining = remaining.Sub(amount)
+		if amount.Equal(f.amount.Abs()) {
+			e.fills = e.fills[1:]
+		} else {
+			f.amount = f.amount.Sub(amount.Mul(decimal.NewFromInt(int64(reduce))))
+		}
+	}
+	signed := execution.Amount.Mul(decimal.NewFromInt(int64(reduce)))
+	e.amount = e.amount.Sub(signed)
+	if remaining.IsPositive() {
+		slog.Warn("[Hedger] Execution outgrew the exposure, hedging it back", "pair", pair, "amount", remaining)
+		t := trade(STRATEGY, "", remaining, len(trades))
+		trades = append(trades, t)
+		e.fills = append(e.fills, fill{
+			strategy: STRATEGY,
+			time:     execution.Time,
+			amount:   remaining.Mul(decimal.NewFromInt(int64(-reduce))),
+			price:    price,
+		})
+	}
+	return trades
+}
+
+// attribute adds the spread of t against the price of the fill it offsets to
+// the result of its share; h.mu must be held.
+func (h *Hedger) attribute(t pnl.Trade, fillPrice decimal.Decimal) {
+	key := pnl.Key{Strategy: t.Strategy, Share: t.Share, Pair: t.Pair}
+	result, ok := h.results[key]
+	if !ok {
+		result = &Result{Key: key}
+		h.results[key] = result
+	}
+	spread := t.Price.Sub(fillPrice).Mul(t.Amount)
+	if t.Side == risk.SIDE_BUY {
+		spread = spread.Neg()
+	}
+	result.Trades++
+	result.Hedged = result.Hedged.Add(t.Amount)
+	result.Spread = result.Spread.Add(spread)
+	result.Fees = result.Fees.Add(t.Fee)
+	result.PnL = result.Spread.Sub(result.Fees)
+}
+
+func (h *Hedger) rulesFor(symbol string) (*Rules, error) {
+	if rules, ok := h.rules.Get(symbol); ok {
+		return rules, nil
+	}
+	rules, err := h.venue.Rules(symbol)
+	if err != nil {
+		return nil, err
+	}
+	h.rules.Set(symbol, rules)
+	return rules, nil
+}
+
+// failed alerts on the first of consecutive hedge failures of pair.
+func (h *Hedger) failed(pair payeer.Pair, err error) {
+	slog.Error("[Hedger] Hedging failed", "pair", pair, "venue", h.venue.Name(), "error", err)
+	h.mu.Lock()
+	e := h.exposure(pair)
+	e.failures++
+	e.lastError = err.Error()
+	failures := e.failures
+	amount := e.amount
+	h.mu.Unlock()
+	if failures > 1 {
+		return
+	}
+	h.dispatcher.Send(notify.Alert{
+		Kind:     notify.KIND_UNHEDGED,
+		Severity: notify.SEVERITY_WARNING,
+		Title:    fmt.Sprintf("Hedging %s on %s failed", pair, h.venue.Name()),
+		Text:     err.Error(),
+		Key:      string(pair) + "/failed",
+		Fields:   map[string]string{"unhedged": amount.String() + " " + pair.Base()},
+	})
+}
+
+func (h *Hedger) succeeded(pair payeer.Pair) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	e := h.exposure(pair)
+	e.failures = 0
+	e.lastError = ""
+}
+
+// check alerts once when the exposure of a pair grows over AlertNotional or
+// stays unhedged for AlertAfter, and once when it is hedged again.
+func (h *Hedger) check() {
+	now := time.Now()
+	for _, status := range h.Status().Exposures {
+		limit, ok := h.options.AlertNotional[status.Pair.Quote()]
+		overLimit := ok && status.Notional.GreaterThanOrEqual(limit)
+		overdue := !status.Since.IsZero() && now.Sub(status.Since) >= h.options.AlertAfter
+		alert := overLimit || overdue
+		h.mu.Lock()
+		e := h.exposure(status.Pair)
+		changed := e.alerted != alert
+		e.alerted = alert
+		h.mu.Unlock()
+		fields := map[string]string{
+			"amount":   status.Amount.String() + " " + status.Pair.Base(),
+			"notional": status.Notional.StringFixed(2) + " " + status.Pair.Quote(),
+		}
+		switch {
+		case changed && alert:
+			fields["since"] = status.Since.Format(time.RFC3339)
+			if status.LastError != "" {
+				fields["error"] = status.LastError
+			}
+			h.dispatcher.Send(notify.Alert{
+				Kind:     notify.KIND_UNHEDGED,
+				Severity: notify.SEVERITY_WARNING,
+				Title:    fmt.Sprintf("%s %s unhedged for %s", status.Amount, status.Pair, now.Sub(status.Since).Round(time.Second)),
+				Key:      string(status.Pair),
+				Fields:   fields,
+			})
+		case changed:
+			h.dispatcher.Send(notify.Alert{
+				Kind:     notify.KIND_UNHEDGED,
+				Severity: notify.SEVERITY_INFO,
+				Title:    fmt.Sprintf("%s exposure is back within limits", status.Pair),
+				Key:      string(status.Pair) + "/recovered",
+				Fields:   fields,
+			})
+		}
+	}
+}
+
+// Status returns the exposure of every hedged pair and the results of every
+// share, sorted.
+func (h *Hedger) Status() *Status {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	status := &Status{Venue: h.venue.Name(), Exposures: []Exposure{}, Results: []Result{}}
+	for pair, symbol := range h.options.Symbols {
+		exposure := Exposure{Pair: pair, Symbol: symbol}
+		if e, ok := h.exposures[pair]; ok {
+			exposure.Amount = e.amount
+			exposure.Notional = e.notional()
+			exposure.Since = e.since()
+			exposure.Failures = e.failures
+			exposure.LastError = e.lastError
+		}
+		status.Exposures = append(status.Exposures, exposure)
+	}
+	slices.SortFunc(status.Exposures, func(a, b Exposure) int {
+		return cmp.Compare(a.Pair, b.Pair)
+	})
+	for _, result := range h.results {
+		status.Results = append(status.Results, *result)
+	}
+	slices.SortFunc(status.Results, func(a, b Result) int {
+		return cmp.Or(cmp.Compare(a.Strategy, b.Strategy), cmp.Compare(a.Share, b.Share), cmp.Compare(a.Pair, b.Pair))
+	})
+	return status
+}
+
+// ServeHTTP returns the Status as JSON.
+func (h *Hedger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Status())
+}
+
+// Collect exports the unhedged exposure of every pair and the hedge results of
+// every share.
+func (h *Hedger) Collect(sink *metrics.Sink) {
+	status := h.Status()
+	for _, e := range status.Exposures {
+		sink.Gauge("hedge_unhedged_amount", "Unhedged base amount of a pair, negative when short.", e.Amount.InexactFloat64(), "pair", string(e.Pair))
+		sink.Gauge("hedge_unhedged_notional", "Unhedged exposure of a pair valued at its fill prices.", e.Notional.InexactFloat64(), "pair", string(e.Pair))
+		age := 0.0
+		if !e.Since.IsZero() {
+			age = time.Since(e.Since).Seconds()
+		}
+		sink.Gauge("hedge_unhedged_age_seconds", "Age of the oldest unhedged fill of a pair.", age, "pair", string(e.Pair))
+		sink.Gauge("hedge_failures", "Consecutive failed hedge attempts of a pair.", float64(e.Failures), "pair", string(e.Pair))
+	}
+	for _, r := range status.Results {
+		sink.Gauge("hedge_hedged_amount", "Base amount hedged of the fills of a share.", r.Hedged.InexactFloat64(), "strategy", r.Strategy, "share", r.Share, "pair", r.Pair)
+		sink.Gauge("hedge_pnl", "Spread the hedges locked in against the fills of a share, less hedge fees.", r.PnL.InexactFloat64(), "strategy", r.Strategy, "share", r.Share, "pair", r.Pair)
+	}
+}
@@ -0,0 +1,196 @@
+package hedge
+
+import (
+	"automata/client/payeer"
+	"automata/internal/dectest"
+	"automata/pnl"
+	"automata/risk"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var d = dectest.D
+
+// order is an IOC order sent to the fake venue.
+type order struct {
+	side          risk.Side
+	amount, price decimal.Decimal
+}
+
+// fakeVenue quotes BTCUSDT at 101/102 and fills IOC orders at the touch, up
+// to fill when it is set.
+type fakeVenue struct {
+	fill   decimal.Decimal
+	err    error
+	orders []order
+}
+
+func (v *fakeVenue) Name() VenueName {
+	return VENUE_BINANCE
+}
+
+func (v *fakeVenue) Rules(symbol string) (*Rules, error) {
+	return &Rules{Base: "BTC", Quote: "USDT", PriceTick: d("0.01"), AmountStep: d("0.0001"), MinAmount: d("0.001"), MinNotional: d("5")}, nil
+}
+
+func (v *fakeVenue) BookTicker(symbol string) (decimal.Decimal, decimal.Decimal, error) {
+	return d("101"), d("102"), nil
+}
+
+func (v *fakeVenue) PlaceIOC(symbol string, side risk.Side, amount, price decimal.Decimal, clientOrderId string) (*Execution, error) {
+	v.orders = append(v.orders, order{side, amount, price})
+	if v.err != nil {
+		return nil, v.err
+	}
+	if v.fill.IsPositive() {
+		amount = decimal.Min(amount, v.fill)
+	}
+	touch := d("101")
+	if side == risk.SIDE_BUY {
+		touch = d("102")
+	}
+	value := amount.Mul(touch)
+	return &Execution{OrderId: "1", Amount: amount, Value: value, Fee: value.Mul(d("0.001")), Time: time.Now()}, nil
+}
+
+// payeerFill is a Payeer trade of BTC_USDT by share.
+func payeerFill(id, share string, side risk.Side, amount, price string) pnl.Trade {
+	return pnl.Trade{Venue: "payeer", Id: id, Time: time.Now(), Strategy: "test", Share: share, Pair: "BTC_USDT", Base: "BTC", Quote: "USDT", Side: side, Amount: d(amount), Price: d(price)}
+}
+
+func TestExposureAdd(t *testing.T) {
+	tests := []struct {
+		name      string
+		amounts   []string
+		want      string
+		wantFills []string
+	}{
+		{name: "fills of one side add up", amounts: []string{"1", "2"}, want: "3", wantFills: []string{"1", "2"}},
+		{name: "opposite fill offsets the oldest first", amounts: []string{"1", "2", "-1.5"}, want: "1.5", wantFills: []string{"1.5"}},
+		{name: "crossing zero", amounts: []string{"1", "-3"}, want: "-2", wantFills: []string{"-2"}},
+		{name: "offset exactly", amounts: []string{"-1", "1"}, want: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &exposure{}
+			for _, amount := range tt.amounts {
+				e.add(fill{amount: d(amount)})
+			}
+			if !e.amount.Equal(d(tt.want)) {
+				t.Errorf("amount = %s, want %s", e.amount, tt.want)
+			}
+			if len(e.fills) != len(tt.wantFills) {
+				t.Fatalf("fills = %v, want %v", e.fills, tt.wantFills)
+			}
+			for i, want := range tt.wantFills {
+				if !e.fills[i].amount.Equal(d(want)) {
+					t.Errorf("fill %d = %s, want %s", i, e.fills[i].amount, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHedge(t *testing.T) {
+	tests := []struct {
+		name  string
+		fills []pnl.Trade
+		venue *fakeVenue
+		// wantOrder is the IOC order sent, nil for none.
+		wantOrder   *order
+		wantExposed string
+		// wantSpread is the spread locked in by share.
+		wantSpread map[string]string
+	}{
+		{
+			name:        "below the venue minimum",
+			fills:       []pnl.Trade{payeerFill("1", "a", risk.SIDE_BUY, "0.0005", "100")},
+			venue:       &fakeVenue{},
+			wantExposed: "0.0005",
+		},
+		{
+			name:        "bought on Payeer, sold within the slippage of the bid",
+			fills:       []pnl.Trade{payeerFill("1", "a", risk.SIDE_BUY, "1", "100")},
+			venue:       &fakeVenue{},
+			wantOrder:   &order{risk.SIDE_SELL, d("1"), d("100.9")},
+			wantExposed: "0",
+			wantSpread:  map[string]string{"a": "1"},
+		},
+		{
+			name:        "sold on Payeer, bought within the slippage of the ask",
+			fills:       []pnl.Trade{payeerFill("1", "a", risk.SIDE_SELL, "1", "103")},
+			venue:       &fakeVenue{},
+			wantOrder:   &order{risk.SIDE_BUY, d("1"), d("102.1")},
+			wantExposed: "0",
+			wantSpread:  map[string]string{"a": "1"},
+		},
+		{
+			name:        "partial fill stays exposed",
+			fills:       []pnl.Trade{payeerFill("1", "a", risk.SIDE_BUY, "1", "100")},
+			venue:       &fakeVenue{fill: d("0.4")},
+			wantOrder:   &order{risk.SIDE_SELL, d("1"), d("100.9")},
+			wantExposed: "0.6",
+			wantSpread:  map[string]string{"a": "0.4"},
+		},
+		{
+			name: "split over the fills it offsets",
+			fills: []pnl.Trade{
+				payeerFill("1", "a", risk.SIDE_BUY, "0.5", "100"),
+				payeerFill("2", "b", risk.SIDE_BUY, "0.5", "99"),
+			},
+			venue:       &fakeVenue{},
+			wantOrder:   &order{risk.SIDE_SELL, d("1"), d("100.9")},
+			wantExposed: "0",
+			wantSpread:  map[string]string{"a": "0.5", "b": "1"},
+		},
+		{
+			name:        "venue error stays exposed",
+			fills:       []pnl.Trade{payeerFill("1", "a", risk.SIDE_BUY, "1", "100")},
+			venue:       &fakeVenue{err: errors.New("rejected")},
+			wantOrder:   &order{risk.SIDE_SELL, d("1"), d("100.9")},
+			wantExposed: "1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := pnl.NewLedger()
+			h := NewHedger(&Options{Symbols: map[payeer.Pair]string{"BTC_USDT": "BTCUSDT"}}, tt.venue, ledger, nil, nil)
+			h.Fill(tt.fills)
+			h.Flush()
+
+			switch {
+			case tt.wantOrder == nil && len(tt.venue.orders) > 0:
+				t.Errorf("orders = %v, want none", tt.venue.orders)
+			case tt.wantOrder != nil && len(tt.venue.orders) != 1:
+				t.Errorf("orders = %v, want %v", tt.venue.orders, *tt.wantOrder)
+			case tt.wantOrder != nil:
+				got := tt.venue.orders[0]
+				if got.side != tt.wantOrder.side || !got.amount.Equal(tt.wantOrder.amount) || !got.price.Equal(tt.wantOrder.price) {
+					t.Errorf("order = %v, want %v", got, *tt.wantOrder)
+				}
+			}
+			status := h.Status()
+			exposed := status.Exposures[0]
+			if !exposed.Amount.Equal(d(tt.wantExposed)) {
+				t.Errorf("exposure = %s, want %s", exposed.Amount, tt.wantExposed)
+			}
+			if tt.venue.err != nil && (exposed.Failures != 1 || exposed.LastError != "rejected") {
+				t.Errorf("exposure = %+v, want one failure", exposed)
+			}
+			if len(status.Results) != len(tt.wantSpread) {
+				t.Fatalf("results = %+v, want spreads %v", status.Results, tt.wantSpread)
+			}
+			for _, result := range status.Results {
+				if want := tt.wantSpread[result.Share]; !result.Spread.Equal(d(want)) {
+					t.Errorf("spread of %s = %s, want %s", result.Share, result.Spread, want)
+				}
+				if !result.PnL.Equal(result.Spread.Sub(result.Fees)) {
+					t.Errorf("PnL of %s = %s, want the spread less %s fees", result.Share, result.PnL, result.Fees)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package hedge
+
+import (
+	"automata/client"
+	"automata/client/binance"
+	"automata/client/mexc"
+	"automata/risk"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type VenueName string
+
+const (
+	VENUE_BINANCE VenueName = "binance"
+	VENUE_MEXC    VenueName = "mexc"
+)
+
+var Venues = []VenueName{VENUE_BINANCE, VENUE_MEXC}
+
+// Venue places the offsetting orders. Symbols are venue symbols such as
+// BTCUSDT.
+type Venue interface {
+	Name() VenueName
+	Rules(symbol string) (*Rules, error)
+	BookTicker(symbol string) (bid, ask decimal.Decimal, err error)
+	// PlaceIOC sends a limit order that fills what it can at price or better
+	// and cancels the rest.
+	PlaceIOC(symbol string, side risk.Side, amount, price decimal.Decimal, clientOrderId string) (*Execution, error)
+}
+
+// Rules are the trading rules of a symbol an order has to meet.
+type Rules struct {
+	Base        string
+	Quote       string
+	PriceTick   decimal.Decimal
+	AmountStep  decimal.Decimal
+	MinAmount   decimal.Decimal
+	MinNotional decimal.Decimal
+}
+
+// RoundAmount rounds amount down to the step.
+func (r *Rules) RoundAmount(amount decimal.Decimal) decimal.Decimal {
+	if !r.AmountStep.IsPositive() {
+		return amount
+	}
+	return amount.Div(r.AmountStep).Floor().Mul(r.AmountStep)
+}
+
+// RoundPrice rounds price to the tick, up for a sell and down for a buy so
+// that the limit never allows more slippage than asked.
+func (r *Rules) RoundPrice(side risk.Side, price decimal.Decimal) decimal.Decimal {
+	if !r.PriceTick.IsPositive() {
+		return price
+	}
+	ticks := price.Div(r.PriceTick)
+	if side == risk.SIDE_SELL {
+		return ticks.Ceil().Mul(r.PriceTick)
+	}
+	return ticks.Floor().Mul(r.PriceTick)
+}
+
+// Execution is what an IOC order filled; Fee is in the quote asset.
+type Execution struct {
+	OrderId string
+	Amount  decimal.Decimal
+	Value   decimal.Decimal
+	Fee     decimal.Decimal
+	Time    time.Time
+}
+
+// NewVenue connects to the named venue. feePercent is the taker fee booked
+// for venues that do not report it.
+func NewVenue(name VenueName, apiKey, secret string, feePercent decimal.Decimal, manager *risk.Manager) (Venue, error) {
+	switch name {
+	case VENUE_BINANCE:
+		return &binanceVenue{client: binance.NewRestClient(apiKey, secret), risk: manager}, nil
+	case VENUE_MEXC:
+		mexcClient := mexc.NewClient(apiKey, secret)
+		mexcClient.Risk = manager
+		return &mexcVenue{client: mexcClient, feePercent: feePercent}, nil
+	}
+	return nil, fmt.Errorf("unknown hedge venue %q", name)
+}
+
+/*
+** Binance
+ */
+
+type binanceVenue struct {
+	client *binance.RestClient
+	risk   *risk.Manager
+}
+
+func (v *binanceVenue) Name() VenueName {
+	return VENUE_BINANCE
+}
+
+func (v *binanceVenue) Rules(symbol string) (*Rules, error) {
+	filters, err := v.client.Filters(binance.Symbol(symbol))
+	if err != nil {
+		return nil, err
+	}
+	return &Rules{
+		Base:        filters.BaseAsset,
+		Quote:       filters.QuoteAsset,
+		PriceTick:   filters.TickSize,
+		AmountStep:  filters.StepSize,
+		MinAmount:   filters.MinQty,
+		MinNotional: filters.MinNotional,
+	}, nil
+}
+
+func (v *binanceVenue) BookTicker(symbol string) (decimal.Decimal, decimal.Decimal, error) {
+	ticker, err := v.client.BookTicker(binance.Symbol(symbol))
+	if err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+	bid, err := decimal.NewFromString(ticker.BidPrice)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+	ask, err := decimal.NewFromString(ticker.AskPrice)
+	return bid, ask, err
+}
+
+// PlaceIOC checks the order against the risk limits itself, the Binance
+// client cannot.
+func (v *binanceVenue) PlaceIOC(symbol string, side risk.Side, amount, price decimal.Decimal, clientOrderId string) (*Execution, error) {
+	base, quote, _ := risk.SplitSymbol(symbol)
+	if err := v.risk.CheckOrder(risk.Order{Venue: string(VENUE_BINANCE), Base: base, Quote: quote, Side: side, Amount: amount, Price: price}); err != nil {
+		return nil, err
+	}
+	orderSide := binance.SIDE_BUY
+	if side == risk.SIDE_SELL {
+		orderSide = binance.SIDE_SELL
+	}
+	rsp, err := v.client.PlaceOrder(&binance.OrderRequest{
+		Symbol:        binance.Symbol(symbol),
+		Side:          orderSide,
+		TimeInForce:   binance.TIME_IN_FORCE_IOC,
+		Quantity:      amount,
+		Price:         price,
+		ClientOrderId: clientOrderId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	execution := &Execution{
+		OrderId: strconv.FormatInt(rsp.OrderId, 10),
+		Amount:  parse(rsp.ExecutedQty),
+		Value:   parse(rsp.CummulativeQuoteQty),
+		Fee:     decimal.Zero,
+		Time:    time.UnixMilli(rsp.TransactTime),
+	}
+	for _, fill := range rsp.Fills {
+		commission := parse(fill.Commission)
+		switch fill.CommissionAsset {
+		case quote:
+			execution.Fee = execution.Fee.Add(commission)
+		case base:
+			execution.Fee = execution.Fee.Add(commission.Mul(parse(fill.Price)))
+		default:
+			slog.Debug("[Hedger] Commission in a third asset is not booked", "asset", fill.CommissionAsset, "commission", fill.Commission)
+		}
+	}
+	return execution, nil
+}
+
+/*
+** MEXC
+ */
+
+type mexcVenue struct {
+	client     *mexc.Client
+	feePercent decimal.Decimal
+}
+
+func (v *mexcVenue) Name() VenueName {
+	return VENUE_MEXC
+}
+
+func (v *mexcVenue) Rules(symbol string) (*Rules, error) {
+	rules, err := v.client.SymbolRules(client.Symbol(symbol))
+	if err != nil {
+		return nil, err
+	}
+	return &Rules{
+		Base:        rules.BaseAsset,
+		Quote:       rules.QuoteAsset,
+		PriceTick:   decimal.NewFromFloat(rules.PriceTick),
+		AmountStep:  decimal.NewFromFloat(rules.AmountStep),
+		MinAmount:   decimal.NewFromFloat(rules.MinAmount),
+		MinNotional: decimal.NewFromFloat(rules.MinNotional),
+	}, nil
+}
+
+func (v *mexcVenue) BookTicker(symbol string) (decimal.Decimal, decimal.Decimal, error) {
+	ticker, err := v.client.OrderBookTicker(client.Symbol(symbol))
+	if err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+	return decimal.NewFromFloat(ticker.BidPrice), decimal.NewFromFloat(ticker.AskPrice), nil
+}
+
+// PlaceIOC reads the execution back with a query, a new MEXC order reports
+// none. The fee is the configured taker fee of the value.
+func (v *mexcVenue) PlaceIOC(symbol string, side risk.Side, amount, price decimal.Decimal, _ string) (*Execution, error) {
+	orderSide := client.BuyOrderSide
+	if side == risk.SIDE_SELL {
+		orderSide = client.SellOrderSide
+	}
+	order := &client.Order{
+		Symbol:  client.Symbol(symbol),
+		Side:    orderSide,
+		Type:    client.ImmediateOrCancelOrderType,
+		OrigQty: amount.InexactFloat64(),
+		Price:   price.InexactFloat64(),
+	}
+	if err := v.client.PlaceOrder(order); err != nil {
+		return nil, err
+	}
+	status, err := v.client.QueryOrder(order.Symbol, order.Id)
+	if err != nil {
+		return nil, fmt.Errorf("order %s placed, querying its execution: %w", order.Id, err)
+	}
+	value := decimal.NewFromFloat(status.CummulativeQuoteQty)
+	return &Execution{
+		OrderId: order.Id,
+		Amount:  decimal.NewFromFloat(status.ExecutedQty),
+		Value:   value,
+		Fee:     value.Mul(v.feePercent).Div(decimal.NewFromInt(100)),
+		Time:    time.Now(),
+	}, nil
+}
+
+func parse(s string) decimal.Decimal {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return decimal.Zero
+	}
+	return d
+}
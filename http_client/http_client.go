@@ -4,12 +4,18 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"os"
+	"strings"
+	"time"
 )
 
 type HttpClient struct {
+	// Observe, when set, is called after every request with the path
+	// without its query.
+	Observe func(method, path string, started time.Time, err error)
 	client  *http.Client
 	baseUrl string
 	headers http.Header
@@ -39,10 +45,16 @@ func (c *HttpClient) Delete(url string, data any) error {
 	return c.do("DELETE", url, data)
 }
 
-func (c *HttpClient) do(method string, path string, data any) error {
+func (c *HttpClient) do(method string, path string, data any) (err error) {
+	if c.Observe != nil {
+		defer func(started time.Time) {
+			endpoint, _, _ := strings.Cut(path, "?")
+			c.Observe(method, endpoint, started, err)
+		}(time.Now())
+	}
 	curl, err := url.Parse(c.baseUrl + path)
 	if err != nil {
-		log.Println("[HttpClient] Failed to parse url", err)
+		slog.Error("[HttpClient] Failed to parse url", "error", err)
 		return err
 	}
 	req := &http.Request{
@@ -50,17 +62,18 @@ func (c *HttpClient) do(method string, path string, data any) error {
 		URL:    curl,
 		Header: c.headers,
 	}
-	// log.Printf("[HttpClient] Making %s request to %s", method, curl.String())
+	slog.Debug("[HttpClient] Making request", "method", method, "path", curl.Path)
 	resp, err := c.client.Do(req)
 	if err != nil {
-		log.Println("[HttpClient] Failed to send request", err)
+		slog.Error("[HttpClient] Failed to send request", "error", err)
 		return err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 400 {
 		body, _ := io.ReadAll(resp.Body)
 		if resp.StatusCode == 429 || resp.StatusCode == 403 {
-			log.Fatal("[HttpClient] FATAL: ", string(body))
+			slog.Error("[HttpClient] Rate limited or banned, exiting", "status", resp.StatusCode, "body", string(body))
+			os.Exit(1)
 		}
 		return errors.New(string(body))
 	}
@@ -76,9 +89,7 @@ func (c *HttpClient) readJson(r io.Reader, data any) error {
 	if err != nil {
 		return err
 	}
-	// log.Println("[HttpClient] Received response: " + string(body))
-	// decoder := json.NewDecoder(r)
-	// err := decoder.Decode(data)
+	slog.Debug("[HttpClient] Received response", "body", string(body))
 	err = json.Unmarshal(body, data)
 	if err != nil {
 		return err
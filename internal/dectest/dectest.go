@@ -0,0 +1,9 @@
+// Package dectest holds the decimal helpers shared by the tests.
+package dectest
+
+import "github.com/shopspring/decimal"
+
+// D parses a decimal literal of a test and panics on a malformed one.
+func D(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
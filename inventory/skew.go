@@ -0,0 +1,155 @@
+// Package inventory skews the quotes of a pair towards a target allocation of
+// its value between the base and the quote asset. As the base share moves
+// away from the target both prices shift, down when long and up when short,
+// and the side growing the position shrinks; at MaxLong buying stops and at
+// MaxShort selling stops.
+package inventory
+
+import (
+	"automata/client/payeer"
+	"automata/metrics"
+	"math"
+
+	"github.com/shopspring/decimal"
+)
+
+// STAGE_SKEW names the skew in a Selection, after the selector stages.
+const STAGE_SKEW = "inventorySkew"
+
+var (
+	one = decimal.NewFromInt(1)
+	bps = decimal.NewFromInt(10000)
+)
+
+type Skew struct {
+	TargetBase    decimal.Decimal `json:"targetBase" desc:"share of the pair's value to hold in the base asset, e.g. 0.5"`
+	MaxLong       decimal.Decimal `json:"maxLong" desc:"base share at which buying stops, e.g. 0.8"`
+	MaxShort      decimal.Decimal `json:"maxShort" desc:"base share at which selling stops, e.g. 0.2"`
+	PriceBps      decimal.Decimal `json:"priceBps" desc:"shift of both prices in basis points at maxLong or maxShort, down when long and up when short"`
+	SizeReduction decimal.Decimal `json:"sizeReduction,omitempty" desc:"fraction the side growing the position shrinks by at maxLong or maxShort"`
+	Exponent      decimal.Decimal `json:"exponent,omitempty" desc:"curve from deviation to shift, 1 is linear and 2 quadratic; default 1"`
+}
+
+// Adjustment is the skew of one side at an inventory.
+type Adjustment struct {
+	// BaseShare is the share of the value held in the base asset.
+	BaseShare decimal.Decimal `json:"baseShare"`
+	// Deviation is the curved distance from the target, -1 at MaxShort and
+	// 1 at MaxLong.
+	Deviation   decimal.Decimal `json:"deviation"`
+	PriceFactor decimal.Decimal `json:"priceFactor"`
+	SizeFactor  decimal.Decimal `json:"sizeFactor"`
+	// Stopped is set when the side would grow a position at its limit.
+	Stopped bool `json:"stopped,omitempty"`
+}
+
+// Adjust returns the skew of action for base and quote balances valued at
+// mid. A nil s, or an empty inventory, returns nil, which leaves quotes
+// unchanged.
+func (s *Skew) Adjust(action payeer.Action, base, quote, mid decimal.Decimal) *Adjustment {
+	if s == nil {
+		return nil
+	}
+	baseValue := base.Mul(mid)
+	total := baseValue.Add(quote)
+	if !total.IsPositive() {
+		return nil
+	}
+	share := baseValue.Div(total)
+	var deviation decimal.Decimal
+	if share.GreaterThanOrEqual(s.TargetBase) {
+		deviation = share.Sub(s.TargetBase).Div(s.MaxLong.Sub(s.TargetBase))
+	} else {
+		deviation = share.Sub(s.TargetBase).Div(s.TargetBase.Sub(s.MaxShort))
+	}
+	deviation = decimal.Min(decimal.Max(deviation, one.Neg()), one)
+	if exponent := s.Exponent.InexactFloat64(); exponent > 0 && exponent != 1 {
+		d := deviation.InexactFloat64()
+		deviation = decimal.NewFromFloat(math.Copysign(math.Pow(math.Abs(d), exponent), d))
+	}
+
+	adjustment := &Adjustment{
+		BaseShare:   share,
+		Deviation:   deviation,
+		PriceFactor: one.Sub(s.PriceBps.Div(bps).Mul(deviation)),
+		SizeFactor:  one,
+	}
+	growing := deviation
+	if action == payeer.ACTION_SELL {
+		growing = deviation.Neg()
+		adjustment.Stopped = share.LessThanOrEqual(s.MaxShort)
+	} else {
+		adjustment.Stopped = share.GreaterThanOrEqual(s.MaxLong)
+	}
+	if growing.IsPositive() {
+		adjustment.SizeFactor = one.Sub(s.SizeReduction.Mul(growing))
+	}
+	return adjustment
+}
+
+// IsStopped reports whether the side is at its inventory limit. A nil a is
+// never stopped.
+func (a *Adjustment) IsStopped() bool {
+	return a != nil && a.Stopped
+}
+
+// Limit names the limit a stopped side of action is at.
+func Limit(action payeer.Action) string {
+	if action == payeer.ACTION_SELL {
+		return "maxShort"
+	}
+	return "maxLong"
+}
+
+// Ratio shifts a price ratio to a reference by the skew. A nil a returns
+// ratio.
+func (a *Adjustment) Ratio(ratio decimal.Decimal) decimal.Decimal {
+	if a == nil {
+		return ratio
+	}
+	return ratio.Mul(a.PriceFactor)
+}
+
+// Size shrinks an amount or budget by the skew. A nil a returns amount.
+func (a *Adjustment) Size(amount decimal.Decimal) decimal.Decimal {
+	if a == nil {
+		return amount
+	}
+	return amount.Mul(a.SizeFactor)
+}
+
+// Price shifts a selected price by the skew, keeps it within the target edge
+// of the reference bid (buy) or ask (sell) and rounds it to the tick. A nil a
+// returns price.
+func (a *Adjustment) Price(action payeer.Action, price, reference decimal.Decimal, edge *payeer.Edge, pairInfo *payeer.PairInfo) decimal.Decimal {
+	if a == nil {
+		return price
+	}
+	price = price.Mul(a.PriceFactor)
+	if edge != nil {
+		limit := reference.Mul(edge.Ratio(action, pairInfo.MakerFee()))
+		if action == payeer.ACTION_SELL {
+			price = decimal.Max(price, limit)
+		} else {
+			price = decimal.Min(price, limit)
+		}
+	}
+	return pairInfo.RoundPrice(action, price)
+}
+
+// Collect exports the adjustment under labels such as "strategy", "pair" and
+// "action". A nil a exports nothing.
+func (a *Adjustment) Collect(sink *metrics.Sink, labels ...string) {
+	if a == nil {
+		return
+	}
+	sink.Gauge("inventory_base_share", "Share of the pair's value held in the base asset.", a.BaseShare.InexactFloat64(), labels...)
+	sink.Gauge("inventory_deviation", "Curved deviation of the inventory from its target, -1 at maxShort and 1 at maxLong.", a.Deviation.InexactFloat64(), labels...)
+	sink.Gauge("inventory_price_factor", "Factor the inventory skew applies to a price.", a.PriceFactor.InexactFloat64(), labels...)
+	sink.Gauge("inventory_size_factor", "Factor the inventory skew applies to a size.", a.SizeFactor.InexactFloat64(), labels...)
+	stopped := 0.0
+	if a.Stopped {
+		stopped = 1
+	}
+	sink.Gauge("inventory_stopped", "1 while a side is stopped at its inventory limit.", stopped, labels...)
+}
@@ -0,0 +1,104 @@
+package inventory
+
+import (
+	"automata/client/payeer"
+	"automata/internal/dectest"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+var d = dectest.D
+
+func skew(exponent string) *Skew {
+	return &Skew{
+		TargetBase:    d("0.5"),
+		MaxLong:       d("0.75"),
+		MaxShort:      d("0.25"),
+		PriceBps:      d("20"),
+		SizeReduction: d("0.5"),
+		Exponent:      d(exponent),
+	}
+}
+
+func TestAdjust(t *testing.T) {
+	tests := []struct {
+		name     string
+		exponent string
+		action   payeer.Action
+		// base and quote are valued at a mid of 100.
+		base, quote string
+		share       string
+		deviation   string
+		price       string
+		size        string
+		stopped     bool
+	}{
+		{name: "at the target", exponent: "0", action: payeer.ACTION_BUY, base: "1", quote: "100", share: "0.5", deviation: "0", price: "1", size: "1"},
+		{name: "long, buy", exponent: "0", action: payeer.ACTION_BUY, base: "5", quote: "300", share: "0.625", deviation: "0.5", price: "0.999", size: "0.75"},
+		{name: "long, sell", exponent: "0", action: payeer.ACTION_SELL, base: "5", quote: "300", share: "0.625", deviation: "0.5", price: "0.999", size: "1"},
+		{name: "short, sell", exponent: "1", action: payeer.ACTION_SELL, base: "3", quote: "500", share: "0.375", deviation: "-0.5", price: "1.001", size: "0.75"},
+		{name: "short, buy", exponent: "1", action: payeer.ACTION_BUY, base: "3", quote: "500", share: "0.375", deviation: "-0.5", price: "1.001", size: "1"},
+		{name: "quadratic long", exponent: "2", action: payeer.ACTION_BUY, base: "5", quote: "300", share: "0.625", deviation: "0.25", price: "0.9995", size: "0.875"},
+		{name: "quadratic short", exponent: "2", action: payeer.ACTION_SELL, base: "3", quote: "500", share: "0.375", deviation: "-0.25", price: "1.0005", size: "0.875"},
+		{name: "cubic long", exponent: "3", action: payeer.ACTION_BUY, base: "5", quote: "300", share: "0.625", deviation: "0.125", price: "0.99975", size: "0.9375"},
+		{name: "at maxLong, buy", exponent: "2", action: payeer.ACTION_BUY, base: "3", quote: "100", share: "0.75", deviation: "1", price: "0.998", size: "0.5", stopped: true},
+		{name: "at maxLong, sell", exponent: "2", action: payeer.ACTION_SELL, base: "3", quote: "100", share: "0.75", deviation: "1", price: "0.998", size: "1"},
+		{name: "past maxLong", exponent: "0", action: payeer.ACTION_BUY, base: "9", quote: "100", share: "0.9", deviation: "1", price: "0.998", size: "0.5", stopped: true},
+		{name: "at maxShort, sell", exponent: "0", action: payeer.ACTION_SELL, base: "1", quote: "300", share: "0.25", deviation: "-1", price: "1.002", size: "0.5", stopped: true},
+		{name: "at maxShort, buy", exponent: "0", action: payeer.ACTION_BUY, base: "1", quote: "300", share: "0.25", deviation: "-1", price: "1.002", size: "1"},
+		{name: "quote only", exponent: "0", action: payeer.ACTION_SELL, base: "0", quote: "100", share: "0", deviation: "-1", price: "1.002", size: "0.5", stopped: true},
+		{name: "base only", exponent: "0", action: payeer.ACTION_BUY, base: "1", quote: "0", share: "1", deviation: "1", price: "0.998", size: "0.5", stopped: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := skew(tt.exponent).Adjust(tt.action, d(tt.base), d(tt.quote), d("100"))
+			if a == nil {
+				t.Fatal("no adjustment")
+			}
+			for _, field := range []struct {
+				name      string
+				got, want decimal.Decimal
+			}{
+				{"share", a.BaseShare, d(tt.share)},
+				{"deviation", a.Deviation, d(tt.deviation)},
+				{"price factor", a.PriceFactor, d(tt.price)},
+				{"size factor", a.SizeFactor, d(tt.size)},
+			} {
+				if !field.got.Equal(field.want) {
+					t.Errorf("%s = %s, want %s", field.name, field.got, field.want)
+				}
+			}
+			if a.Stopped != tt.stopped || a.IsStopped() != tt.stopped {
+				t.Errorf("stopped = %t, want %t", a.Stopped, tt.stopped)
+			}
+		})
+	}
+}
+
+func TestAdjustEmpty(t *testing.T) {
+	if a := skew("1").Adjust(payeer.ACTION_BUY, decimal.Zero, decimal.Zero, d("100")); a != nil {
+		t.Errorf("empty inventory adjusted: %+v", a)
+	}
+	var off *Skew
+	a := off.Adjust(payeer.ACTION_BUY, d("1"), d("100"), d("100"))
+	if a != nil {
+		t.Errorf("nil skew adjusted: %+v", a)
+	}
+	if a.IsStopped() || !a.Ratio(d("0.99")).Equal(d("0.99")) || !a.Size(d("2")).Equal(d("2")) {
+		t.Error("nil adjustment changes quotes")
+	}
+}
+
+func TestPrice(t *testing.T) {
+	info := &payeer.PairInfo{PricePrecision: 2, FeeMakerPercent: 0}
+	long := skew("1").Adjust(payeer.ACTION_BUY, d("5"), d("300"), d("100"))
+	if price := long.Price(payeer.ACTION_BUY, d("100"), d("100"), nil, info); !price.Equal(d("99.9")) {
+		t.Errorf("skewed buy price = %s, want 99.9", price)
+	}
+	// A 20 bps edge keeps the buy at or below 99.8.
+	edge := &payeer.Edge{TargetBps: d("20")}
+	if price := long.Price(payeer.ACTION_BUY, d("100"), d("100"), edge, info); !price.Equal(d("99.8")) {
+		t.Errorf("buy price within the edge = %s, want 99.8", price)
+	}
+}
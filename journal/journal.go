@@ -0,0 +1,201 @@
+// Package journal keeps an append-only audit trail of order decisions.
+//
+// Every placement and cancel decision is written as one JSON line with the
+// inputs it was made from: the Payeer book levels, the Binance ticker and its
+// age, the output of every price selector stage, the parameters needed to run
+// the selection again, the config version and the result. Lines are never
+// rewritten; cmd/journal queries them and replays a decision against its
+// recorded inputs.
+package journal
+
+import (
+	"automata/client/binance"
+	"automata/client/payeer"
+	"automata/config"
+	"automata/inventory"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type Decision string
+
+const (
+	DECISION_PLACE  Decision = "place"
+	DECISION_CANCEL Decision = "cancel"
+)
+
+// Ticker is the Binance book ticker a decision was made against.
+type Ticker struct {
+	Symbol binance.Symbol  `json:"symbol"`
+	Bid    decimal.Decimal `json:"bid"`
+	Ask    decimal.Decimal `json:"ask"`
+	Age    config.Duration `json:"age"`
+}
+
+// Elevation holds the inputs of payeer.ResolvePriceWithElevation.
+type Elevation struct {
+	Ratio    decimal.Decimal      `json:"ratio"`
+	MyOrders []payeer.PriceAmount `json:"myOrders,omitempty"`
+}
+
+// Result is what became of the decision.
+type Result struct {
+	Accepted bool   `json:"accepted"`
+	OrderId  int    `json:"orderId,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type Entry struct {
+	Seq      int64         `json:"seq"`
+	Time     time.Time     `json:"time"`
+	Strategy string        `json:"strategy"`
+	Share    string        `json:"share,omitempty"`
+	Pair     payeer.Pair   `json:"pair"`
+	Action   payeer.Action `json:"action"`
+	Decision Decision      `json:"decision"`
+	// Reason says why an order is cancelled.
+	Reason string `json:"reason,omitempty"`
+	Trace  string `json:"trace,omitempty"`
+	// OrderId is the order a cancel decision is about.
+	OrderId int `json:"orderId,omitempty"`
+	// Book holds the levels of the side the decision looked at, best first.
+	Book   []payeer.OrdersOrder `json:"book"`
+	Ticker *Ticker              `json:"ticker,omitempty"`
+	// Stages is the output of every selector stage that ran.
+	Stages []payeer.Stage `json:"stages,omitempty"`
+	// Selector or Elevation hold the parameters to replay the selection.
+	Selector  *payeer.PayeerPriceSelectorConfig `json:"selector,omitempty"`
+	Elevation *Elevation                        `json:"elevation,omitempty"`
+	// Skew is the inventory skew applied to the price and amount.
+	Skew *inventory.Adjustment `json:"skew,omitempty"`
+	// PairInfo holds the tick size and price limits the price was rounded to.
+	PairInfo      *payeer.PairInfo `json:"pairInfo,omitempty"`
+	ConfigVersion string           `json:"configVersion,omitempty"`
+	Price         decimal.Decimal  `json:"price"`
+	Amount        decimal.Decimal  `json:"amount"`
+	Result        Result           `json:"result"`
+}
+
+// Journal appends entries to a file. All methods accept a nil *Journal, which
+// records nothing.
+type Journal struct {
+	path    string
+	mu      sync.Mutex
+	file    *os.File
+	seq     int64
+	version string
+}
+
+// Open opens the journal at path for appending, creating it if needed, and
+// continues the sequence numbers of the entries in it.
+func Open(path string) (*Journal, error) {
+	j := &Journal{path: path}
+	err := Scan(path, func(entry *Entry) bool {
+		j.seq = entry.Seq
+		return true
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("journal %s: %w", path, err)
+	}
+	if j.file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+		return nil, fmt.Errorf("journal %s: %w", path, err)
+	}
+	slog.Info("[Journal] Opened", "path", path, "entries", j.seq)
+	return j, nil
+}
+
+// SetVersion sets the config version recorded with every following entry.
+func (j *Journal) SetVersion(version string) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.version = version
+}
+
+// Append numbers entry, stamps it with the time and config version unless
+// set, and writes it. Failures are logged; trading goes on without the entry.
+func (j *Journal) Append(entry *Entry) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.seq++
+	entry.Seq = j.seq
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if entry.ConfigVersion == "" {
+		entry.ConfigVersion = j.version
+	}
+	data, err := json.Marshal(entry)
+	if err == nil {
+		_, err = j.file.Write(append(data, '\n'))
+	}
+	if err != nil {
+		slog.Error("[Journal] Appending entry failed", "path", j.path, "seq", entry.Seq, "error", err)
+	}
+}
+
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
+
+// Scan calls fn with every entry of the journal at path in order until fn
+// returns false. A torn last line left by a crash is skipped.
+func Scan(path string, fn func(entry *Entry) bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return ScanReader(file, fn)
+}
+
+func ScanReader(r io.Reader, fn func(entry *Entry) bool) error {
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		entry := &Entry{}
+		if err := json.Unmarshal(data, entry); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if !fn(entry) {
+			return nil
+		}
+	}
+}
+
+// BookSide returns the levels a decision on action looks at: the bids for a
+// buy, the asks for a sell.
+func BookSide(info *payeer.PairsOrderInfo, action payeer.Action) []payeer.OrdersOrder {
+	if info == nil {
+		return nil
+	}
+	if action == payeer.ACTION_SELL {
+		return info.Asks
+	}
+	return info.Bids
+}
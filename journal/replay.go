@@ -0,0 +1,59 @@
+package journal
+
+import (
+	"automata/client/binance"
+	"automata/client/payeer"
+	"automata/inventory"
+	"automata/msync"
+	"errors"
+)
+
+// Replay runs the price selection of entry again on its recorded book and
+// ticker, shifted by its inventory skew, and returns the stages and the price
+// it arrives at. The elevation ratio of an entry already holds its skew.
+func Replay(entry *Entry) (*payeer.Selection, error) {
+	if entry.Ticker == nil {
+		return nil, errors.New("entry has no ticker")
+	}
+	ticker := binance.OrderBookTickerStreamResult{
+		Symbol:   entry.Ticker.Symbol,
+		BidPrice: entry.Ticker.Bid.String(),
+		AskPrice: entry.Ticker.Ask.String(),
+	}
+	info := &payeer.PairsOrderInfo{}
+	if entry.Action == payeer.ACTION_SELL {
+		info.Asks = entry.Book
+	} else {
+		info.Bids = entry.Book
+	}
+	switch {
+	case entry.Selector != nil:
+		tickers := msync.NewMuMap[binance.Symbol, binance.OrderBookTickerStreamResult]()
+		selector, err := payeer.NewPipelineSelector(entry.Selector, tickers)
+		if err != nil {
+			return nil, err
+		}
+		if entry.PairInfo != nil {
+			selector.SetInfo(&payeer.InfoResponse{Pairs: map[payeer.Pair]payeer.PairInfo{entry.Pair: *entry.PairInfo}})
+		}
+		tickers.Set(selector.SymbolFor(entry.Pair, entry.Action), ticker)
+		selection := selector.SelectPair(entry.Pair, entry.Action, info)
+		if selection.Ok && entry.Skew != nil {
+			reference := entry.Ticker.Bid
+			if entry.Action == payeer.ACTION_SELL {
+				reference = entry.Ticker.Ask
+			}
+			selection.Price = entry.Skew.Price(entry.Action, selection.Price, reference, entry.Selector.Edge, entry.PairInfo)
+			selection.Stages = append(selection.Stages, payeer.Stage{Name: inventory.STAGE_SKEW, Ok: true, Price: selection.Price})
+		}
+		return selection, nil
+	case entry.Elevation != nil:
+		price := payeer.ResolvePriceWithElevation(entry.PairInfo, entry.Action, entry.Elevation.Ratio, &ticker, info, entry.Elevation.MyOrders)
+		return &payeer.Selection{
+			Ok:     true,
+			Price:  price,
+			Stages: []payeer.Stage{{Name: payeer.STAGE_ELEVATION, Ok: true, Price: price}},
+		}, nil
+	}
+	return nil, errors.New("entry records no selector parameters")
+}
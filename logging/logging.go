@@ -0,0 +1,212 @@
+// Package logging sets up the process-wide slog handler.
+//
+// Log messages start with the component in brackets, "[PayeerFetcher] Order
+// placed", or carry a "component" attribute. The handler filters every record
+// by the level of its component, falling back to the default level, and writes
+// text or JSON. In JSON the bracketed prefix moves into the component field so
+// that log pipelines can filter on it. Loggers attach strategy, share, pair,
+// order and trace IDs as attributes, see the Attr helpers and NewTraceId.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+type Format string
+
+const (
+	FORMAT_TEXT Format = "text"
+	FORMAT_JSON Format = "json"
+)
+
+// Attribute keys shared by every component.
+const (
+	KEY_COMPONENT = "component"
+	KEY_STRATEGY  = "strategy"
+	KEY_SHARE     = "share"
+	KEY_PAIR      = "pair"
+	KEY_ORDER_ID  = "orderId"
+	KEY_TRACE     = "trace"
+)
+
+type Options struct {
+	// Format defaults to text.
+	Format Format
+	// Level applies to components without an entry in Levels.
+	Level  slog.Level
+	Levels map[string]slog.Level
+	// Output defaults to stderr.
+	Output io.Writer
+}
+
+// levels is shared by a handler and every handler derived from it.
+type levels struct {
+	mu         sync.RWMutex
+	level      slog.Level
+	components map[string]slog.Level
+	min        slog.LevelVar
+}
+
+func (l *levels) set(level slog.Level, components map[string]slog.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = level
+	l.components = components
+	min := level
+	for _, level := range components {
+		min = minLevel(min, level)
+	}
+	l.min.Set(min)
+}
+
+func (l *levels) enabled(component string, level slog.Level) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if componentLevel, ok := l.components[component]; ok {
+		return level >= componentLevel
+	}
+	return level >= l.level
+}
+
+// Handler filters by component level and strips the component prefix in JSON.
+type Handler struct {
+	inner     slog.Handler
+	levels    *levels
+	json      bool
+	component string
+}
+
+// Setup installs a Handler as the slog and log default and returns it for
+// SetLevels on config reloads.
+func Setup(options *Options) *Handler {
+	h := NewHandler(options)
+	slog.SetDefault(slog.New(h))
+	return h
+}
+
+func NewHandler(options *Options) *Handler {
+	output := options.Output
+	if output == nil {
+		output = os.Stderr
+	}
+	h := &Handler{levels: &levels{}, json: options.Format == FORMAT_JSON}
+	h.levels.set(options.Level, options.Levels)
+	handlerOptions := &slog.HandlerOptions{Level: &h.levels.min}
+	if h.json {
+		h.inner = slog.NewJSONHandler(output, handlerOptions)
+	} else {
+		h.inner = slog.NewTextHandler(output, handlerOptions)
+	}
+	return h
+}
+
+// SetLevels replaces the default and component levels.
+func (h *Handler) SetLevels(level slog.Level, components map[string]slog.Level) {
+	h.levels.set(level, components)
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	component, message := h.component, record.Message
+	if prefix, rest, ok := splitComponent(record.Message); ok {
+		if component == "" {
+			component = prefix
+		}
+		if h.json {
+			message = rest
+		}
+	}
+	if !h.levels.enabled(component, record.Level) {
+		return nil
+	}
+	if h.json && component != "" && h.component == "" {
+		next := slog.NewRecord(record.Time, record.Level, message, record.PC)
+		next.AddAttrs(slog.String(KEY_COMPONENT, component))
+		record.Attrs(func(attr slog.Attr) bool {
+			next.AddAttrs(attr)
+			return true
+		})
+		record = next
+	} else if message != record.Message {
+		record.Message = message
+	}
+	return h.inner.Handle(ctx, record)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := *h
+	for _, attr := range attrs {
+		if attr.Key == KEY_COMPONENT {
+			next.component = attr.Value.String()
+		}
+	}
+	next.inner = h.inner.WithAttrs(attrs)
+	return &next
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	next := *h
+	next.inner = h.inner.WithGroup(name)
+	return &next
+}
+
+// splitComponent splits "[Component] message" into its parts.
+func splitComponent(message string) (component, rest string, ok bool) {
+	if !strings.HasPrefix(message, "[") {
+		return "", message, false
+	}
+	end := strings.Index(message, "]")
+	if end < 0 {
+		return "", message, false
+	}
+	return message[1:end], strings.TrimLeft(message[end+1:], " "), true
+}
+
+func minLevel(a, b slog.Level) slog.Level {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// NewTraceId returns a random ID that follows one order from price selection
+// through placement to its fill or cancel.
+func NewTraceId() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+func Component(name string) slog.Attr {
+	return slog.String(KEY_COMPONENT, name)
+}
+
+func Strategy(name string) slog.Attr {
+	return slog.String(KEY_STRATEGY, name)
+}
+
+func Share(id string) slog.Attr {
+	return slog.String(KEY_SHARE, id)
+}
+
+func Pair[T ~string](pair T) slog.Attr {
+	return slog.String(KEY_PAIR, string(pair))
+}
+
+func OrderId(id int) slog.Attr {
+	return slog.Int(KEY_ORDER_ID, id)
+}
+
+func Trace(id string) slog.Attr {
+	return slog.String(KEY_TRACE, id)
+}
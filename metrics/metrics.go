@@ -0,0 +1,84 @@
+// Package metrics exposes counters, gauges and histograms in the Prometheus
+// text format.
+//
+// Packages keep their metric vectors in package variables and register them
+// with Register, usually from init. Values that are cheaper to read when
+// scraped, like open orders or ticker ages, are registered as a Func. Several
+// collectors may write samples of the same metric as long as their labels
+// differ, so every strategy instance can register its own Func.
+package metrics
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Collector writes its samples to the sink on every scrape.
+type Collector interface {
+	Collect(sink *Sink)
+}
+
+// Func adapts a function to a Collector.
+type Func func(sink *Sink)
+
+func (f Func) Collect(sink *Sink) {
+	f(sink)
+}
+
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Default is the registry the package level functions use.
+var Default = NewRegistry()
+
+func (r *Registry) Register(collector Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, collector)
+}
+
+// Gather collects every registered collector.
+func (r *Registry) Gather() *Sink {
+	r.mu.Lock()
+	collectors := append([]Collector{}, r.collectors...)
+	r.mu.Unlock()
+	sink := newSink()
+	for _, collector := range collectors {
+		collector.Collect(sink)
+	}
+	return sink
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.Gather().WriteTo(w)
+}
+
+func Register(collector Collector) {
+	Default.Register(collector)
+}
+
+// Serve exposes the default registry on addr at /metrics in the background.
+func Serve(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Default)
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		slog.Info("[Metrics] Listening", "addr", addr)
+		if err := server.ListenAndServe(); err != nil {
+			slog.Error("[Metrics] Server stopped", "error", err)
+		}
+	}()
+}
@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Type string
+
+const (
+	TYPE_COUNTER   Type = "counter"
+	TYPE_GAUGE     Type = "gauge"
+	TYPE_HISTOGRAM Type = "histogram"
+)
+
+// Sink collects the samples of one scrape, grouped by metric.
+type Sink struct {
+	families map[string]*family
+}
+
+type family struct {
+	help    string
+	typ     Type
+	samples map[string]float64
+	order   []string
+}
+
+func newSink() *Sink {
+	return &Sink{families: make(map[string]*family)}
+}
+
+// Gauge adds a gauge sample; labels are name, value pairs.
+func (s *Sink) Gauge(name, help string, value float64, labels ...string) {
+	s.add(name, help, TYPE_GAUGE, name, value, labels)
+}
+
+// Counter adds a counter sample; labels are name, value pairs.
+func (s *Sink) Counter(name, help string, value float64, labels ...string) {
+	s.add(name, help, TYPE_COUNTER, name, value, labels)
+}
+
+func (s *Sink) add(name, help string, typ Type, sample string, value float64, labels []string) {
+	f, ok := s.families[name]
+	if !ok {
+		f = &family{help: help, typ: typ, samples: make(map[string]float64)}
+		s.families[name] = f
+	}
+	sample += formatLabels(labels)
+	if _, ok := f.samples[sample]; !ok {
+		f.order = append(f.order, sample)
+	}
+	f.samples[sample] = value
+}
+
+// WriteTo writes the samples in the Prometheus text format, sorted by metric
+// name and in the order they were added within a metric.
+func (s *Sink) WriteTo(w io.Writer) (int64, error) {
+	names := make([]string, 0, len(s.families))
+	for name := range s.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		f := s.families[name]
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, strings.ReplaceAll(f.help, "\n", " "), name, f.typ)
+		for _, sample := range f.order {
+			fmt.Fprintf(&b, "%s %s\n", sample, formatValue(f.samples[sample]))
+		}
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(labels[i])
+		b.WriteString(`="`)
+		b.WriteString(escaper.Replace(labels[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
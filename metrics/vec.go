@@ -0,0 +1,161 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// vec holds one value per combination of label values.
+type vec[T any] struct {
+	name   string
+	help   string
+	labels []string
+	mu     sync.Mutex
+	values map[string]*T
+	keys   map[string][]string
+}
+
+func newVec[T any](name, help string, labels []string) vec[T] {
+	return vec[T]{name: name, help: help, labels: labels, values: make(map[string]*T), keys: make(map[string][]string)}
+}
+
+// with returns the value for labelValues, creating it with init; it must be
+// called with mu held.
+func (v *vec[T]) with(labelValues []string, init func() *T) *T {
+	if len(labelValues) != len(v.labels) {
+		panic("metrics: " + v.name + " takes labels " + strings.Join(v.labels, ", "))
+	}
+	key := strings.Join(labelValues, "\xff")
+	value, ok := v.values[key]
+	if !ok {
+		value = init()
+		v.values[key] = value
+		v.keys[key] = append([]string{}, labelValues...)
+	}
+	return value
+}
+
+// sorted returns the keys in a stable order; it must be called with mu held.
+func (v *vec[T]) sorted() []string {
+	keys := make([]string, 0, len(v.values))
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (v *vec[T]) pairs(key string, extra ...string) []string {
+	pairs := make([]string, 0, 2*len(v.labels)+len(extra))
+	for i, value := range v.keys[key] {
+		pairs = append(pairs, v.labels[i], value)
+	}
+	return append(pairs, extra...)
+}
+
+type CounterVec struct {
+	vec[float64]
+}
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{newVec[float64](name, help, labels)}
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.with(labelValues, func() *float64 { return new(float64) }) += delta
+}
+
+func (c *CounterVec) Collect(sink *Sink) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range c.sorted() {
+		sink.Counter(c.name, c.help, *c.values[key], c.pairs(key)...)
+	}
+}
+
+type GaugeVec struct {
+	vec[float64]
+}
+
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{newVec[float64](name, help, labels)}
+}
+
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	*g.with(labelValues, func() *float64 { return new(float64) }) = value
+}
+
+func (g *GaugeVec) Add(delta float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	*g.with(labelValues, func() *float64 { return new(float64) }) += delta
+}
+
+func (g *GaugeVec) Collect(sink *Sink) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, key := range g.sorted() {
+		sink.Gauge(g.name, g.help, *g.values[key], g.pairs(key)...)
+	}
+}
+
+// LatencyBuckets are upper bounds in seconds suited for exchange requests.
+var LatencyBuckets = []float64{0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+type HistogramVec struct {
+	vec[histogram]
+	buckets []float64
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return &HistogramVec{vec: newVec[histogram](name, help, labels), buckets: buckets}
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hist := h.with(labelValues, func() *histogram { return &histogram{counts: make([]uint64, len(h.buckets))} })
+	for i, bound := range h.buckets {
+		if value <= bound {
+			hist.counts[i]++
+		}
+	}
+	hist.count++
+	hist.sum += value
+}
+
+// Since observes the seconds elapsed since start.
+func (h *HistogramVec) Since(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (h *HistogramVec) Collect(sink *Sink) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range h.sorted() {
+		hist := h.values[key]
+		for i, bound := range h.buckets {
+			sink.add(h.name, h.help, TYPE_HISTOGRAM, h.name+"_bucket", float64(hist.counts[i]), h.pairs(key, "le", formatValue(bound)))
+		}
+		sink.add(h.name, h.help, TYPE_HISTOGRAM, h.name+"_bucket", float64(hist.count), h.pairs(key, "le", formatValue(math.Inf(1))))
+		sink.add(h.name, h.help, TYPE_HISTOGRAM, h.name+"_sum", hist.sum, h.pairs(key))
+		sink.add(h.name, h.help, TYPE_HISTOGRAM, h.name+"_count", float64(hist.count), h.pairs(key))
+	}
+}
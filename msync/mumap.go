@@ -1,6 +1,9 @@
 package msync
 
-import "sync"
+import (
+	"maps"
+	"sync"
+)
 
 type MuMap[K comparable, T any] struct {
 	mu   sync.Mutex
@@ -61,3 +64,11 @@ func (mm *MuMap[K, T]) Keys() []K {
 	}
 	return keys
 }
+
+// Clone returns a copy of the map taken under the lock.
+func (mm *MuMap[K, T]) Clone() map[K]T {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	return maps.Clone(mm.data)
+}
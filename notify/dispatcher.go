@@ -0,0 +1,151 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type DispatcherOptions struct {
+	// DedupWindow drops repeats of an alert with the same kind and key within
+	// it; defaults to 10m.
+	DedupWindow time.Duration
+	// MaxPerMinute caps the alerts sent per minute across all kinds; defaults
+	// to 20. Alerts over the cap are dropped and counted.
+	MaxPerMinute int
+	// Timeout bounds one delivery attempt of an alert to a notifier; defaults
+	// to 10s.
+	Timeout time.Duration
+	// Retries is how often a failed delivery is tried again; defaults to 2.
+	// Rejected requests, see Permanent, are not retried.
+	Retries int
+	// RetryDelay is the wait before the first retry, doubling with every
+	// further one; defaults to 1s.
+	RetryDelay time.Duration
+}
+
+// Dispatcher delivers alerts to every notifier. All methods accept a nil
+// *Dispatcher, which drops everything.
+type Dispatcher struct {
+	notifiers []Notifier
+	options   *DispatcherOptions
+	queue     chan *Alert
+	mu        sync.Mutex
+	sent      map[string]time.Time
+	repeats   map[string]int
+	recent    []time.Time
+	dropped   int
+}
+
+func NewDispatcher(options *DispatcherOptions, notifiers ...Notifier) *Dispatcher {
+	if options.DedupWindow == 0 {
+		options.DedupWindow = 10 * time.Minute
+	}
+	if options.MaxPerMinute == 0 {
+		options.MaxPerMinute = 20
+	}
+	if options.Timeout == 0 {
+		options.Timeout = 10 * time.Second
+	}
+	if options.Retries == 0 {
+		options.Retries = 2
+	}
+	if options.RetryDelay == 0 {
+		options.RetryDelay = time.Second
+	}
+	d := &Dispatcher{
+		notifiers: notifiers,
+		options:   options,
+		queue:     make(chan *Alert, 100),
+		sent:      make(map[string]time.Time),
+		repeats:   make(map[string]int),
+	}
+	go d.deliver()
+	return d
+}
+
+// Send queues alert unless it repeats one sent within the dedup window or the
+// rate limit is reached. It never blocks.
+func (d *Dispatcher) Send(alert Alert) {
+	if d == nil || len(d.notifiers) == 0 {
+		return
+	}
+	if alert.Time.IsZero() {
+		alert.Time = time.Now()
+	}
+	if alert.Severity == "" {
+		alert.Severity = SEVERITY_INFO
+	}
+	key := string(alert.Kind) + "/" + alert.Key
+	d.mu.Lock()
+	now := time.Now()
+	if last, ok := d.sent[key]; ok && now.Sub(last) < d.options.DedupWindow {
+		d.repeats[key]++
+		d.mu.Unlock()
+		return
+	}
+	for len(d.recent) > 0 && now.Sub(d.recent[0]) >= time.Minute {
+		d.recent = d.recent[1:]
+	}
+	if len(d.recent) >= d.options.MaxPerMinute {
+		d.dropped++
+		if d.dropped == 1 {
+			slog.Warn("[Notify] Alert rate limit reached, dropping alerts", "maxPerMinute", d.options.MaxPerMinute)
+		}
+		d.mu.Unlock()
+		return
+	}
+	if d.dropped > 0 {
+		alert.Fields = withField(alert.Fields, "dropped", fmt.Sprint(d.dropped))
+		d.dropped = 0
+	}
+	d.recent = append(d.recent, now)
+	d.sent[key] = now
+	alert.Suppressed = d.repeats[key]
+	delete(d.repeats, key)
+	d.mu.Unlock()
+
+	select {
+	case d.queue <- &alert:
+	default:
+		slog.Error("[Notify] Alert queue full, dropping alert", "kind", alert.Kind, "title", alert.Title)
+	}
+}
+
+func (d *Dispatcher) deliver() {
+	for alert := range d.queue {
+		for _, notifier := range d.notifiers {
+			d.notify(notifier, alert)
+		}
+	}
+}
+
+// notify delivers alert to notifier, retrying failures that may pass later.
+func (d *Dispatcher) notify(notifier Notifier, alert *Alert) {
+	delay := d.options.RetryDelay
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), d.options.Timeout)
+		err := notifier.Notify(ctx, alert)
+		cancel()
+		if err == nil {
+			return
+		}
+		if attempt == d.options.Retries || Permanent(err) {
+			slog.Error("[Notify] Delivering alert failed", "notifier", fmt.Sprintf("%T", notifier), "kind", alert.Kind, "attempts", attempt+1, "error", err)
+			return
+		}
+		slog.Warn("[Notify] Delivering alert failed, retrying", "notifier", fmt.Sprintf("%T", notifier), "kind", alert.Kind, "retryIn", delay, "error", err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func withField(fields map[string]string, key, value string) map[string]string {
+	copied := map[string]string{key: value}
+	for k, v := range fields {
+		copied[k] = v
+	}
+	return copied
+}
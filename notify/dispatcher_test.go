@@ -0,0 +1,104 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder fails the first failures deliveries with err.
+type recorder struct {
+	mu       sync.Mutex
+	failures int
+	err      error
+	attempts int
+	alerts   []*Alert
+	done     chan struct{}
+}
+
+func (r *recorder) Notify(ctx context.Context, alert *Alert) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts++
+	if r.attempts <= r.failures {
+		if r.attempts == r.failures && Permanent(r.err) {
+			close(r.done)
+		}
+		return r.err
+	}
+	r.alerts = append(r.alerts, alert)
+	close(r.done)
+	return nil
+}
+
+func (r *recorder) wait(t *testing.T) {
+	t.Helper()
+	select {
+	case <-r.done:
+	case <-time.After(time.Second):
+		t.Fatal("no delivery")
+	}
+	// Give a wrong extra attempt the chance to show.
+	time.Sleep(20 * time.Millisecond)
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		err       error
+		attempts  int
+		delivered bool
+	}{
+		{name: "first try", attempts: 1, delivered: true},
+		{name: "after a server error", failures: 2, err: &StatusError{StatusCode: 503}, attempts: 3, delivered: true},
+		{name: "after a network error", failures: 1, err: errors.New("connection refused"), attempts: 2, delivered: true},
+		{name: "rejected", failures: 1, err: &StatusError{StatusCode: 400}, attempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier := &recorder{failures: tt.failures, err: tt.err, done: make(chan struct{})}
+			d := NewDispatcher(&DispatcherOptions{RetryDelay: time.Millisecond}, notifier)
+			d.Send(Alert{Kind: KIND_FILL, Title: "fill"})
+			notifier.wait(t)
+
+			notifier.mu.Lock()
+			defer notifier.mu.Unlock()
+			if notifier.attempts != tt.attempts {
+				t.Errorf("attempts = %d, want %d", notifier.attempts, tt.attempts)
+			}
+			if delivered := len(notifier.alerts) == 1; delivered != tt.delivered {
+				t.Errorf("delivered = %t, want %t", delivered, tt.delivered)
+			}
+		})
+	}
+}
+
+func TestDispatcherGivesUp(t *testing.T) {
+	notifier := &recorder{failures: 10, err: &StatusError{StatusCode: 500}, done: make(chan struct{})}
+	d := NewDispatcher(&DispatcherOptions{Retries: 3, RetryDelay: time.Millisecond}, notifier)
+	d.Send(Alert{Kind: KIND_FILL, Title: "fill"})
+	time.Sleep(100 * time.Millisecond)
+
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+	if notifier.attempts != 4 {
+		t.Errorf("attempts = %d, want 4", notifier.attempts)
+	}
+}
+
+func TestDispatcherDedup(t *testing.T) {
+	notifier := &recorder{done: make(chan struct{})}
+	d := NewDispatcher(&DispatcherOptions{}, notifier)
+	d.Send(Alert{Kind: KIND_STALE_FEED, Key: "ETHUSDT", Title: "stale"})
+	d.Send(Alert{Kind: KIND_STALE_FEED, Key: "ETHUSDT", Title: "stale"})
+	notifier.wait(t)
+
+	notifier.mu.Lock()
+	defer notifier.mu.Unlock()
+	if notifier.attempts != 1 {
+		t.Errorf("attempts = %d, want the repeat dropped", notifier.attempts)
+	}
+}
@@ -0,0 +1,74 @@
+// Package notify sends alerts about fills, errors and halts to the team.
+//
+// A Notifier delivers one alert to one channel: a generic webhook, a bot in
+// the Telegram Bot API format or SMTP mail. Every notifier takes the address of
+// its server, so a local stand-in can receive the alerts. A Dispatcher sits in
+// front of the notifiers: it drops repeats of an alert within the dedup window,
+// caps the alerts per minute and delivers in the background so that trading
+// never waits for a slow channel.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Severity string
+
+const (
+	SEVERITY_INFO     Severity = "info"
+	SEVERITY_WARNING  Severity = "warning"
+	SEVERITY_CRITICAL Severity = "critical"
+)
+
+type Kind string
+
+const (
+	KIND_FILL               Kind = "fill"
+	KIND_INSUFFICIENT_FUNDS Kind = "insufficient_funds"
+	KIND_STALE_FEED         Kind = "stale_feed"
+	KIND_KILL_SWITCH        Kind = "kill_switch"
+	KIND_UNHEDGED           Kind = "unhedged"
+)
+
+type Alert struct {
+	Kind     Kind      `json:"kind"`
+	Severity Severity  `json:"severity"`
+	Title    string    `json:"title"`
+	Text     string    `json:"text,omitempty"`
+	Time     time.Time `json:"time"`
+	// Key identifies repeats of the same alert together with Kind, e.g. the
+	// symbol of a stale feed.
+	Key    string            `json:"key,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+	// Suppressed counts the repeats dropped since this alert was last sent.
+	Suppressed int `json:"suppressed,omitempty"`
+}
+
+// String renders the alert as plain text for chat and mail.
+func (a *Alert) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %s", strings.ToUpper(string(a.Severity)), a.Title)
+	if a.Text != "" {
+		b.WriteString("\n" + a.Text)
+	}
+	keys := make([]string, 0, len(a.Fields))
+	for key := range a.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, "\n%s: %s", key, a.Fields[key])
+	}
+	if a.Suppressed > 0 {
+		fmt.Fprintf(&b, "\n(%d similar alerts suppressed)", a.Suppressed)
+	}
+	return b.String()
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, alert *Alert) error
+}
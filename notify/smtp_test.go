@@ -0,0 +1,136 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpServer is a mail server speaking just enough SMTP for net/smtp. It
+// answers RCPT with rcptReply and sends the session to the returned channel.
+func smtpServer(t *testing.T, rcptReply string) (string, <-chan []string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	session := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var lines []string
+		defer func() { session <- lines }()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 localhost ESMTP")
+		data := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			lines = append(lines, line)
+			switch {
+			case data:
+				if line == "." {
+					data = false
+					reply("250 queued")
+				}
+			case strings.HasPrefix(line, "EHLO"):
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			case strings.HasPrefix(line, "AUTH PLAIN"):
+				reply("235 authenticated")
+			case strings.HasPrefix(line, "MAIL FROM"):
+				reply("250 ok")
+			case strings.HasPrefix(line, "RCPT TO"):
+				reply(rcptReply)
+			case line == "DATA":
+				data = true
+				reply("354 go ahead")
+			case line == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return listener.Addr().String(), session
+}
+
+func TestSMTP(t *testing.T) {
+	addr, session := smtpServer(t, "250 ok")
+	mailer := &SMTP{Addr: addr, Username: "bot", Password: "pw", From: "bot@example.com", To: []string{"a@example.com", "b@example.com"}}
+	if err := mailer.Notify(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Join(<-session, "\n")
+	auth := "AUTH PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00bot\x00pw"))
+	for _, want := range []string{
+		auth,
+		"MAIL FROM:<bot@example.com>",
+		"RCPT TO:<a@example.com>",
+		"RCPT TO:<b@example.com>",
+		"To: a@example.com, b@example.com",
+		"Subject: [WARNING] Buy filled",
+		"Date: Wed, 01 May 2024 12:00:00 +0000",
+		"[WARNING] Buy filled\n0.5 ETH at 2000\namount: 0.5\npair: ETH_USDT\n.",
+	} {
+		if !strings.Contains(lines, want) {
+			t.Errorf("session lacks %q:\n%s", want, lines)
+		}
+	}
+}
+
+func TestSMTPSubjectHeaderInjection(t *testing.T) {
+	addr, session := smtpServer(t, "250 ok")
+	injected := *alert
+	injected.Title = "Buy filled\r\nBcc: x@example.com"
+	mailer := &SMTP{Addr: addr, From: "bot@example.com", To: []string{"a@example.com"}}
+	if err := mailer.Notify(context.Background(), &injected); err != nil {
+		t.Fatal(err)
+	}
+	lines := <-session
+	headers := lines[slices.Index(lines, "DATA")+1:]
+	headers = headers[:slices.Index(headers, "")]
+	for _, line := range headers {
+		if strings.HasPrefix(line, "Bcc:") {
+			t.Errorf("title injected a header: %q", line)
+		}
+	}
+}
+
+func TestSMTPRejected(t *testing.T) {
+	addr, _ := smtpServer(t, "550 no such user")
+	err := (&SMTP{Addr: addr, From: "bot@example.com", To: []string{"a@example.com"}}).Notify(context.Background(), alert)
+	if err == nil || !strings.Contains(err.Error(), "550") {
+		t.Errorf("error = %v, want the 550 reply", err)
+	}
+}
+
+func TestSMTPTimeout(t *testing.T) {
+	// A server that accepts but never greets.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = (&SMTP{Addr: listener.Addr().String(), From: "bot@example.com", To: []string{"a@example.com"}}).Notify(ctx, alert)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want the deadline", err)
+	}
+}
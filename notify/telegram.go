@@ -0,0 +1,43 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const telegramUrl = "https://api.telegram.org"
+
+// Telegram sends every alert as a message through the Telegram Bot API or a
+// server speaking the same protocol at BaseUrl.
+type Telegram struct {
+	// BaseUrl defaults to the Telegram Bot API.
+	BaseUrl string
+	Token   string
+	ChatId  string
+	Client  *http.Client
+}
+
+func (t *Telegram) Notify(ctx context.Context, alert *Alert) error {
+	body, err := json.Marshal(map[string]any{
+		"chat_id":                  t.ChatId,
+		"text":                     alert.String(),
+		"disable_web_page_preview": true,
+	})
+	if err != nil {
+		return err
+	}
+	baseUrl := t.BaseUrl
+	if baseUrl == "" {
+		baseUrl = telegramUrl
+	}
+	url := strings.TrimSuffix(baseUrl, "/") + "/bot" + t.Token + "/sendMessage"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return send(t.Client, req)
+}
@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTelegram(t *testing.T) {
+	var got struct {
+		ChatId  string `json:"chat_id"`
+		Text    string `json:"text"`
+		Preview bool   `json:"disable_web_page_preview"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bot123:abc/sendMessage" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	telegram := &Telegram{BaseUrl: server.URL + "/", Token: "123:abc", ChatId: "-100"}
+	if err := telegram.Notify(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+	want := "[WARNING] Buy filled\n0.5 ETH at 2000\namount: 0.5\npair: ETH_USDT"
+	if got.ChatId != "-100" || got.Text != want || !got.Preview {
+		t.Errorf("payload = %+v, want text %q", got, want)
+	}
+}
+
+func TestTelegramError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"ok":false,"description":"Bad Request: chat not found"}`))
+	}))
+	defer server.Close()
+
+	err := (&Telegram{BaseUrl: server.URL, Token: "t", ChatId: "1"}).Notify(context.Background(), alert)
+	if err == nil || !Permanent(err) {
+		t.Errorf("error = %v, want a permanent rejection", err)
+	}
+}
@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var alert = &Alert{
+	Kind:     KIND_FILL,
+	Severity: SEVERITY_WARNING,
+	Title:    "Buy filled",
+	Text:     "0.5 ETH at 2000",
+	Time:     time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	Key:      "ETH_USDT",
+	Fields:   map[string]string{"pair": "ETH_USDT", "amount": "0.5"},
+}
+
+func TestWebhook(t *testing.T) {
+	var got Alert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("Authorization = %q", auth)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	webhook := &Webhook{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer secret"}}
+	if err := webhook.Notify(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+	if got.Kind != alert.Kind || got.Severity != alert.Severity || got.Title != alert.Title || got.Text != alert.Text ||
+		!got.Time.Equal(alert.Time) || got.Key != alert.Key || got.Fields["amount"] != "0.5" {
+		t.Errorf("payload = %+v, want %+v", got, alert)
+	}
+}
+
+func TestWebhookErrors(t *testing.T) {
+	tests := []struct {
+		status    int
+		permanent bool
+	}{
+		{status: http.StatusBadRequest, permanent: true},
+		{status: http.StatusUnauthorized, permanent: true},
+		{status: http.StatusTooManyRequests},
+		{status: http.StatusInternalServerError},
+		{status: http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "nope", tt.status)
+			}))
+			defer server.Close()
+
+			err := (&Webhook{URL: server.URL}).Notify(context.Background(), alert)
+			var status *StatusError
+			if !errors.As(err, &status) || status.StatusCode != tt.status || status.Body != "nope\n" {
+				t.Fatalf("error = %v, want a %d reply", err, tt.status)
+			}
+			if Permanent(err) != tt.permanent {
+				t.Errorf("Permanent = %t, want %t", !tt.permanent, tt.permanent)
+			}
+		})
+	}
+}
+
+func TestWebhookUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	err := (&Webhook{URL: server.URL}).Notify(context.Background(), alert)
+	if err == nil || Permanent(err) {
+		t.Errorf("error = %v, want a retryable one", err)
+	}
+}
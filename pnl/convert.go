@@ -0,0 +1,80 @@
+package pnl
+
+import (
+	"automata/client"
+	"automata/client/payeer"
+	"automata/risk"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PayeerTrades converts the trades of an order. Payeer charges the fee in the
+// asset received, so the fee of a buy is converted to quote at the trade price.
+// Trade IDs are prefixed with the order ID because a trade between two orders
+// of the same account shows up in both.
+func PayeerTrades(strategy, share string, order *payeer.OrderDetails) []Trade {
+	side := risk.SIDE_BUY
+	if order.Action == payeer.ACTION_SELL {
+		side = risk.SIDE_SELL
+	}
+	trades := []Trade{}
+	for id, t := range order.Trades {
+		trade := Trade{
+			Venue:    "payeer",
+			Id:       order.Id + "/" + id,
+			Time:     time.Unix(t.Date, 0),
+			Strategy: strategy,
+			Share:    share,
+			Pair:     string(order.Pair),
+			Base:     order.Pair.Base(),
+			Quote:    order.Pair.Quote(),
+			Side:     side,
+			Amount:   parse(t.Amount),
+			Price:    parse(t.Price),
+		}
+		fee := parse(t.MakerCommission).Add(parse(t.TakerCommission))
+		if side == risk.SIDE_BUY {
+			fee = fee.Mul(trade.Price)
+		}
+		trade.Fee = fee
+		trades = append(trades, trade)
+	}
+	return trades
+}
+
+// DealTrade converts a trade of a MEXC order; deals carry no fee, so it is
+// passed in the quote asset. Symbols with an unknown quote keep the symbol as
+// base.
+func DealTrade(strategy, share string, deal *client.Deal, fee decimal.Decimal) Trade {
+	base, quote, ok := risk.SplitSymbol(string(deal.Symbol))
+	if !ok {
+		base = string(deal.Symbol)
+	}
+	side := risk.SIDE_BUY
+	if deal.TradeType == client.TradeTypeSell {
+		side = risk.SIDE_SELL
+	}
+	return Trade{
+		Venue:    "mexc",
+		Id:       deal.OrderId + "/" + deal.TradeId,
+		Time:     deal.TradeTime,
+		Strategy: strategy,
+		Share:    share,
+		Pair:     string(deal.Symbol),
+		Base:     base,
+		Quote:    quote,
+		Side:     side,
+		Amount:   decimal.NewFromFloat(deal.Quantity),
+		Price:    decimal.NewFromFloat(deal.Price),
+		Fee:      fee,
+	}
+}
+
+func parse(s string) decimal.Decimal {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return decimal.Zero
+	}
+	return d
+}
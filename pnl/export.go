@@ -0,0 +1,73 @@
+package pnl
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+var csvHeader = []string{
+	"strategy", "share", "pair", "base", "quote", "trades", "bought", "sold", "fees", "position",
+	"fifo_cost", "avg_cost", "realised_fifo", "realised_avg", "mark", "mark_age", "unrealised_fifo", "unrealised_avg",
+}
+
+// WriteCSV writes rows with a header line. Mark and unrealised columns are
+// empty for positions without a mark.
+func WriteCSV(w io.Writer, rows []Row) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := []string{
+			row.Strategy, row.Share, row.Pair, row.Base, row.Quote, strconv.Itoa(row.Trades),
+			row.Bought.String(), row.Sold.String(), row.Fees.String(), row.Position.String(),
+			row.FifoCost.String(), row.AvgCost.String(), row.RealisedFifo.String(), row.RealisedAvg.String(),
+			"", "", "", "",
+		}
+		if row.Marked {
+			record[14] = row.Mark.String()
+			record[15] = row.MarkAge.String()
+			record[16] = row.UnrealisedFifo.String()
+			record[17] = row.UnrealisedAvg.String()
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// WriteFile exports the report of grouping as CSV to path.
+func (l *Ledger) WriteFile(path string, grouping Grouping) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteCSV(f, l.Report(grouping)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ServeHTTP returns the report as JSON, or as CSV with format=csv; group=pair
+// sums the positions of every pair.
+func (l *Ledger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	grouping := GROUP_SHARE
+	if r.URL.Query().Get("group") == string(GROUP_PAIR) {
+		grouping = GROUP_PAIR
+	}
+	rows := l.Report(grouping)
+	if r.URL.Query().Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		WriteCSV(w, rows)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rows)
+}
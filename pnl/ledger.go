@@ -0,0 +1,255 @@
+// Package pnl keeps the realised and unrealised profit and loss of the fills
+// of a process.
+//
+// A Ledger books every trade into a position per strategy, share and pair and
+// tracks its cost basis twice, first-in first-out and average cost. Fees are
+// booked as realised loss when they are paid. Open positions are marked to the
+// reference mid set with UpdateMark; positions without a mark report no
+// unrealised PnL. Positions may go short, a later buy then closes them.
+package pnl
+
+import (
+	"automata/risk"
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Trade is one execution of an order.
+type Trade struct {
+	Venue string
+	// Id identifies the trade within Venue; a trade already booked is ignored.
+	Id       string
+	Time     time.Time
+	Strategy string
+	Share    string
+	Pair     string
+	Base     string
+	Quote    string
+	Side     risk.Side
+	Amount   decimal.Decimal
+	Price    decimal.Decimal
+	// Fee in the quote asset.
+	Fee decimal.Decimal
+}
+
+type Grouping string
+
+const (
+	// GROUP_SHARE reports a row per strategy, share and pair.
+	GROUP_SHARE Grouping = "share"
+	// GROUP_PAIR sums the rows of every pair across strategies and shares.
+	GROUP_PAIR Grouping = "pair"
+)
+
+// Key identifies a position; fields not used by a Grouping are empty.
+type Key struct {
+	Strategy string `json:"strategy,omitempty"`
+	Share    string `json:"share,omitempty"`
+	Pair     string `json:"pair"`
+}
+
+// Row is the state of one position in a report.
+type Row struct {
+	Key
+	Base   string `json:"base"`
+	Quote  string `json:"quote"`
+	Trades int    `json:"trades"`
+	// Bought and Sold are base amounts, Fees are in quote.
+	Bought   decimal.Decimal `json:"bought"`
+	Sold     decimal.Decimal `json:"sold"`
+	Fees     decimal.Decimal `json:"fees"`
+	Position decimal.Decimal `json:"position"`
+	// FifoCost and AvgCost are the cost basis of Position in quote.
+	FifoCost     decimal.Decimal `json:"fifoCost"`
+	AvgCost      decimal.Decimal `json:"avgCost"`
+	RealisedFifo decimal.Decimal `json:"realisedFifo"`
+	RealisedAvg  decimal.Decimal `json:"realisedAvg"`
+	// Marked reports whether a reference mid was known; the unrealised PnL is
+	// zero otherwise.
+	Marked         bool            `json:"marked"`
+	Mark           decimal.Decimal `json:"mark"`
+	MarkAge        time.Duration   `json:"markAge"`
+	UnrealisedFifo decimal.Decimal `json:"unrealisedFifo"`
+	UnrealisedAvg  decimal.Decimal `json:"unrealisedAvg"`
+}
+
+// lot is an open FIFO lot, negative amounts are short.
+type lot struct {
+	amount decimal.Decimal
+	price  decimal.Decimal
+}
+
+type position struct {
+	base, quote  string
+	trades       int
+	bought, sold decimal.Decimal
+	fees         decimal.Decimal
+	lots         []lot
+	realisedFifo decimal.Decimal
+	// amount is the signed position, avgPrice its average cost.
+	amount      decimal.Decimal
+	avgPrice    decimal.Decimal
+	realisedAvg decimal.Decimal
+}
+
+type mark struct {
+	mid  decimal.Decimal
+	time time.Time
+}
+
+type Ledger struct {
+	mu        sync.Mutex
+	positions map[Key]*position
+	seen      map[string]bool
+	marks     map[[2]string]mark
+}
+
+func NewLedger() *Ledger {
+	return &Ledger{
+		positions: make(map[Key]*position),
+		seen:      make(map[string]bool),
+		marks:     make(map[[2]string]mark),
+	}
+}
+
+// Add books trades in the order given; callers replaying history should sort
+// them by time first.
+func (l *Ledger) Add(trades ...Trade) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, trade := range trades {
+		id := trade.Venue + "/" + trade.Id
+		if l.seen[id] || !trade.Amount.IsPositive() {
+			continue
+		}
+		l.seen[id] = true
+		key := Key{Strategy: trade.Strategy, Share: trade.Share, Pair: trade.Pair}
+		p, ok := l.positions[key]
+		if !ok {
+			p = &position{base: trade.Base, quote: trade.Quote}
+			l.positions[key] = p
+		}
+		p.add(trade)
+	}
+}
+
+func (p *position) add(trade Trade) {
+	p.trades++
+	p.fees = p.fees.Add(trade.Fee)
+	signed := trade.Amount
+	if trade.Side == risk.SIDE_BUY {
+		p.bought = p.bought.Add(trade.Amount)
+	} else {
+		p.sold = p.sold.Add(trade.Amount)
+		signed = signed.Neg()
+	}
+	p.realisedFifo = p.realisedFifo.Add(p.addFifo(signed, trade.Price)).Sub(trade.Fee)
+	p.realisedAvg = p.realisedAvg.Add(p.addAvg(signed, trade.Price)).Sub(trade.Fee)
+}
+
+// addFifo closes the oldest opposite lots first and returns the realised PnL.
+func (p *position) addFifo(amount, price decimal.Decimal) decimal.Decimal {
+	realised := decimal.Zero
+	for !amount.IsZero() && len(p.lots) > 0 && p.lots[0].amount.Sign() != amount.Sign() {
+		open := &p.lots[0]
+		closing := decimal.Min(amount.Abs(), open.amount.Abs())
+		if open.amount.IsPositive() {
+			realised = realised.Add(closing.Mul(price.Sub(open.price)))
+			open.amount = open.amount.Sub(closing)
+			amount = amount.Add(closing)
+		} else {
+			realised = realised.Add(closing.Mul(open.price.Sub(price)))
+			open.amount = open.amount.Add(closing)
+			amount = amount.Sub(closing)
+		}
+		if open.amount.IsZero() {
+			p.lots = p.lots[1:]
+		}
+	}
+	if !amount.IsZero() {
+		p.lots = append(p.lots, lot{amount: amount, price: price})
+	}
+	return realised
+}
+
+// addAvg closes against the average cost and returns the realised PnL.
+func (p *position) addAvg(amount, price decimal.Decimal) decimal.Decimal {
+	if p.amount.IsZero() || p.amount.Sign() == amount.Sign() {
+		total := p.amount.Add(amount)
+		p.avgPrice = p.amount.Abs().Mul(p.avgPrice).Add(amount.Abs().Mul(price)).Div(total.Abs())
+		p.amount = total
+		return decimal.Zero
+	}
+	closing := decimal.Min(amount.Abs(), p.amount.Abs())
+	realised := closing.Mul(price.Sub(p.avgPrice))
+	if p.amount.IsNegative() {
+		realised = realised.Neg()
+	}
+	p.amount = p.amount.Add(amount)
+	switch {
+	case p.amount.IsZero():
+		p.avgPrice = decimal.Zero
+	case p.amount.Sign() == amount.Sign():
+		// The trade flipped the position, the rest is opened at price.
+		p.avgPrice = price
+	}
+	return realised
+}
+
+// UpdateMark sets the reference mid open positions of base/quote are marked to.
+func (l *Ledger) UpdateMark(base, quote string, bid, ask decimal.Decimal) {
+	if !bid.IsPositive() || !ask.IsPositive() {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.marks[[2]string{base, quote}] = mark{mid: bid.Add(ask).Div(decimal.NewFromInt(2)), time: time.Now()}
+}
+
+// Report returns a row per position, sorted by key.
+func (l *Ledger) Report(grouping Grouping) []Row {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rows := map[Key]*Row{}
+	for key, p := range l.positions {
+		if grouping == GROUP_PAIR {
+			key = Key{Pair: key.Pair}
+		}
+		row, ok := rows[key]
+		if !ok {
+			row = &Row{Key: key, Base: p.base, Quote: p.quote}
+			rows[key] = row
+		}
+		row.Trades += p.trades
+		row.Bought = row.Bought.Add(p.bought)
+		row.Sold = row.Sold.Add(p.sold)
+		row.Fees = row.Fees.Add(p.fees)
+		row.Position = row.Position.Add(p.amount)
+		row.AvgCost = row.AvgCost.Add(p.amount.Mul(p.avgPrice))
+		row.RealisedFifo = row.RealisedFifo.Add(p.realisedFifo)
+		row.RealisedAvg = row.RealisedAvg.Add(p.realisedAvg)
+		for _, open := range p.lots {
+			row.FifoCost = row.FifoCost.Add(open.amount.Mul(open.price))
+		}
+	}
+	result := make([]Row, 0, len(rows))
+	for _, row := range rows {
+		if m, ok := l.marks[[2]string{row.Base, row.Quote}]; ok {
+			row.Marked = true
+			row.Mark = m.mid
+			row.MarkAge = time.Since(m.time).Round(time.Millisecond)
+			value := row.Position.Mul(m.mid)
+			row.UnrealisedFifo = value.Sub(row.FifoCost)
+			row.UnrealisedAvg = value.Sub(row.AvgCost)
+		}
+		result = append(result, *row)
+	}
+	slices.SortFunc(result, func(a, b Row) int {
+		return cmp.Or(cmp.Compare(a.Strategy, b.Strategy), cmp.Compare(a.Share, b.Share), cmp.Compare(a.Pair, b.Pair))
+	})
+	return result
+}
@@ -0,0 +1,168 @@
+package pnl
+
+import (
+	"automata/internal/dectest"
+	"automata/risk"
+	"fmt"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+var d = dectest.D
+
+// trade returns a BTC_USDT trade of the strategy "s"; fee may be empty.
+func trade(id string, side risk.Side, amount, price, fee string) Trade {
+	t := Trade{
+		Venue:    "payeer",
+		Id:       id,
+		Strategy: "s",
+		Pair:     "BTC_USDT",
+		Base:     "BTC",
+		Quote:    "USDT",
+		Side:     side,
+		Amount:   d(amount),
+		Price:    d(price),
+	}
+	if fee != "" {
+		t.Fee = d(fee)
+	}
+	return t
+}
+
+type want struct {
+	position, fifoCost, avgCost, realisedFifo, realisedAvg, fees string
+}
+
+func check(t *testing.T, row Row, w want) {
+	t.Helper()
+	for _, field := range []struct {
+		name      string
+		got, want decimal.Decimal
+	}{
+		{"position", row.Position, d(w.position)},
+		{"fifoCost", row.FifoCost, d(w.fifoCost)},
+		{"avgCost", row.AvgCost, d(w.avgCost)},
+		{"realisedFifo", row.RealisedFifo, d(w.realisedFifo)},
+		{"realisedAvg", row.RealisedAvg, d(w.realisedAvg)},
+		{"fees", row.Fees, d(w.fees)},
+	} {
+		if !field.got.Equal(field.want) {
+			t.Errorf("%s = %s, want %s", field.name, field.got, field.want)
+		}
+	}
+}
+
+func TestLedger(t *testing.T) {
+	tests := []struct {
+		name   string
+		trades []Trade
+		want   want
+	}{
+		{
+			name:   "open long",
+			trades: []Trade{trade("1", risk.SIDE_BUY, "1", "100", ""), trade("2", risk.SIDE_BUY, "1", "110", "")},
+			want:   want{position: "2", fifoCost: "210", avgCost: "210", realisedFifo: "0", realisedAvg: "0", fees: "0"},
+		},
+		{
+			// FIFO closes the lot at 100 and half the lot at 110, average cost
+			// closes 1.5 at 105.
+			name: "partial lot",
+			trades: []Trade{
+				trade("1", risk.SIDE_BUY, "1", "100", ""),
+				trade("2", risk.SIDE_BUY, "1", "110", ""),
+				trade("3", risk.SIDE_SELL, "1.5", "120", ""),
+			},
+			want: want{position: "0.5", fifoCost: "55", avgCost: "52.5", realisedFifo: "25", realisedAvg: "22.5", fees: "0"},
+		},
+		{
+			name:   "long to short",
+			trades: []Trade{trade("1", risk.SIDE_BUY, "1", "100", ""), trade("2", risk.SIDE_SELL, "3", "90", "")},
+			want:   want{position: "-2", fifoCost: "-180", avgCost: "-180", realisedFifo: "-10", realisedAvg: "-10", fees: "0"},
+		},
+		{
+			name: "partly covered short",
+			trades: []Trade{
+				trade("1", risk.SIDE_BUY, "1", "100", ""),
+				trade("2", risk.SIDE_SELL, "3", "90", ""),
+				trade("3", risk.SIDE_BUY, "1", "80", ""),
+			},
+			want: want{position: "-1", fifoCost: "-90", avgCost: "-90", realisedFifo: "0", realisedAvg: "0", fees: "0"},
+		},
+		{
+			name:   "short to long",
+			trades: []Trade{trade("1", risk.SIDE_SELL, "2", "100", ""), trade("2", risk.SIDE_BUY, "3", "110", "")},
+			want:   want{position: "1", fifoCost: "110", avgCost: "110", realisedFifo: "-20", realisedAvg: "-20", fees: "0"},
+		},
+		{
+			name:   "fees",
+			trades: []Trade{trade("1", risk.SIDE_BUY, "1", "100", "0.1"), trade("2", risk.SIDE_SELL, "1", "101", "0.2")},
+			want:   want{position: "0", fifoCost: "0", avgCost: "0", realisedFifo: "0.7", realisedAvg: "0.7", fees: "0.3"},
+		},
+		{
+			name:   "fees of an open position",
+			trades: []Trade{trade("1", risk.SIDE_BUY, "1", "100", "0.1")},
+			want:   want{position: "1", fifoCost: "100", avgCost: "100", realisedFifo: "-0.1", realisedAvg: "-0.1", fees: "0.1"},
+		},
+		{
+			name: "repeated and empty trades",
+			trades: []Trade{
+				trade("1", risk.SIDE_BUY, "1", "100", ""),
+				trade("1", risk.SIDE_BUY, "1", "100", ""),
+				trade("2", risk.SIDE_BUY, "0", "100", ""),
+			},
+			want: want{position: "1", fifoCost: "100", avgCost: "100", realisedFifo: "0", realisedAvg: "0", fees: "0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := NewLedger()
+			ledger.Add(tt.trades...)
+			rows := ledger.Report(GROUP_SHARE)
+			if len(rows) != 1 {
+				t.Fatalf("%d rows, want 1", len(rows))
+			}
+			check(t, rows[0], tt.want)
+		})
+	}
+}
+
+func TestLedgerMark(t *testing.T) {
+	ledger := NewLedger()
+	ledger.Add(
+		trade("1", risk.SIDE_BUY, "1", "100", ""),
+		trade("2", risk.SIDE_BUY, "1", "110", ""),
+		trade("3", risk.SIDE_SELL, "1.5", "120", ""),
+	)
+	if row := ledger.Report(GROUP_SHARE)[0]; row.Marked || !row.UnrealisedFifo.IsZero() {
+		t.Errorf("unmarked row reports %s unrealised", row.UnrealisedFifo)
+	}
+
+	ledger.UpdateMark("BTC", "USDT", d("119"), d("121"))
+	row := ledger.Report(GROUP_SHARE)[0]
+	if !row.Marked || !row.Mark.Equal(d("120")) {
+		t.Fatalf("mark = %s, want 120", row.Mark)
+	}
+	if !row.UnrealisedFifo.Equal(d("5")) || !row.UnrealisedAvg.Equal(d("7.5")) {
+		t.Errorf("unrealised = %s fifo, %s avg, want 5 and 7.5", row.UnrealisedFifo, row.UnrealisedAvg)
+	}
+}
+
+func TestLedgerGrouping(t *testing.T) {
+	ledger := NewLedger()
+	for i, strategy := range []string{"b", "a"} {
+		buy := trade(fmt.Sprint(i), risk.SIDE_BUY, "1", "100", "0.1")
+		buy.Strategy = strategy
+		ledger.Add(buy)
+	}
+
+	rows := ledger.Report(GROUP_SHARE)
+	if len(rows) != 2 || rows[0].Strategy != "a" || rows[1].Strategy != "b" {
+		t.Fatalf("rows = %+v, want a and b in order", rows)
+	}
+	rows = ledger.Report(GROUP_PAIR)
+	if len(rows) != 1 || rows[0].Strategy != "" || rows[0].Trades != 2 {
+		t.Fatalf("rows = %+v, want one BTC_USDT row of 2 trades", rows)
+	}
+	check(t, rows[0], want{position: "2", fifoCost: "200", avgCost: "200", realisedFifo: "-0.2", realisedAvg: "-0.2", fees: "0.2"})
+}
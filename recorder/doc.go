@@ -0,0 +1,48 @@
+// Package recorder stores and replays market data captured from Payeer, Binance and MEXC.
+//
+// # Layout
+//
+// Records are partitioned by venue and rotated every hour (UTC, by receive time):
+//
+//	<dir>/<venue>/<YYYY-MM-DD>/<HH>-<MMSS>.v<schema>.jsonl[.gz]
+//
+// MMSS is the minute and second the file was opened at, so a restart within the
+// hour starts a new file instead of overwriting the previous one. A file that is
+// still being written has the ".part" suffix appended; it is renamed once the
+// hour is over or the writer is closed, so readers only see complete files
+// unless they ask for partial ones.
+//
+// # Compression
+//
+// Files are gzip compressed by default, or written as plain JSONL. zstd is out
+// of scope: the standard library has no encoder, and gzip keeps the recorder
+// free of dependencies while still shrinking the JSON well.
+//
+// # Records
+//
+// Every line is one JSON object:
+//
+//	{"v":1,"venue":"binance","kind":"ticker","sym":"ETHUSDT","recv":1767225600123456789,"exch":1767225600120,"data":{...}}
+//
+//	v      schema version, currently 1
+//	venue  payeer | binance | mexc
+//	kind   book | trades | ticker | trade | depth
+//	sym    venue symbol: Payeer pair (ETH_USDT) or Binance/MEXC symbol (ETHUSDT)
+//	recv   local receive time, unix nanoseconds
+//	exch   exchange event time, unix milliseconds; 0 when the venue does not send one
+//	data   venue payload, see below
+//
+// Payloads by venue and kind:
+//
+//	payeer  book    payeer.PairsOrderInfo as returned by /orders
+//	payeer  trades  []payeer.TradesTrade, only trades not seen in the previous poll
+//	binance ticker  binance.OrderBookTickerStreamResult (<symbol>@bookTicker)
+//	binance trade   binance.TradeStreamResult (<symbol>@trade)
+//	binance depth   binance.PartialDepthStreamResult (<symbol>@depth<N>@100ms)
+//	mexc    ticker  client.OrderBookTicker
+//	mexc    trade   client.Deal
+//	mexc    depth   client.PartialDepth
+//
+// Within a file records are ordered by receive time. Reader merges the files of
+// all venues into a single receive-time ordered stream.
+package recorder
@@ -0,0 +1,250 @@
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+type ReaderOptions struct {
+	// Venues to read, all venues found in the directory when empty
+	Venues []Venue
+	// Receive time range; zero values leave the range open
+	From time.Time
+	To   time.Time
+	// IncludePartial also reads ".part" files of a running or crashed writer
+	IncludePartial bool
+}
+
+// Reader merges the recorded files of all venues into one stream ordered by receive time.
+type Reader struct {
+	options *ReaderOptions
+	chains  []*fileChain
+	heads   recordHeap
+	started bool
+}
+
+func Open(dir string, options *ReaderOptions) (*Reader, error) {
+	if options == nil {
+		options = &ReaderOptions{}
+	}
+	venues := options.Venues
+	if len(venues) == 0 {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				venues = append(venues, Venue(entry.Name()))
+			}
+		}
+	}
+	r := &Reader{options: options}
+	for _, venue := range venues {
+		paths, err := r.venueFiles(filepath.Join(dir, string(venue)))
+		if err != nil {
+			return nil, err
+		}
+		if len(paths) > 0 {
+			r.chains = append(r.chains, &fileChain{paths: paths})
+		}
+	}
+	return r, nil
+}
+
+// Next returns the next record, or io.EOF when all files are exhausted.
+func (r *Reader) Next() (*Record, error) {
+	if !r.started {
+		r.started = true
+		for i := range r.chains {
+			if err := r.advance(i); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if r.heads.Len() == 0 {
+		return nil, io.EOF
+	}
+	head := heap.Pop(&r.heads).(chainHead)
+	if err := r.advance(head.chain); err != nil {
+		return nil, err
+	}
+	return head.record, nil
+}
+
+func (r *Reader) Close() error {
+	for _, chain := range r.chains {
+		chain.closeCurrent()
+	}
+	return nil
+}
+
+func (r *Reader) advance(chain int) error {
+	for {
+		record, err := r.chains[chain].next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !r.options.From.IsZero() && record.RecvTime < r.options.From.UnixNano() {
+			continue
+		}
+		if !r.options.To.IsZero() && record.RecvTime > r.options.To.UnixNano() {
+			r.chains[chain].closeCurrent()
+			r.chains[chain].paths = nil
+			return nil
+		}
+		heap.Push(&r.heads, chainHead{record: record, chain: chain})
+		return nil
+	}
+}
+
+func (r *Reader) venueFiles(venueDir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(venueDir, "*", "*.jsonl*"))
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{}
+	for _, path := range matches {
+		if strings.HasSuffix(path, partSuffix) && !r.options.IncludePartial {
+			continue
+		}
+		hour, err := fileHour(path)
+		if err != nil {
+			continue
+		}
+		if !r.options.From.IsZero() && hour.Add(time.Hour).Before(r.options.From) {
+			continue
+		}
+		if !r.options.To.IsZero() && hour.After(r.options.To) {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	return paths, nil
+}
+
+// fileHour parses the hour of a file from its <YYYY-MM-DD>/<HH>-<MMSS> path.
+func fileHour(path string) (time.Time, error) {
+	day := filepath.Base(filepath.Dir(path))
+	name := filepath.Base(path)
+	if len(name) < 2 {
+		return time.Time{}, fmt.Errorf("unexpected file name %s", name)
+	}
+	return time.ParseInLocation("2006-01-02 15", day+" "+name[:2], time.UTC)
+}
+
+type fileChain struct {
+	paths   []string
+	file    *os.File
+	gz      *gzip.Reader
+	scanner *bufio.Scanner
+	path    string
+	line    int
+}
+
+func (c *fileChain) next() (*Record, error) {
+	for {
+		if c.scanner == nil {
+			if len(c.paths) == 0 {
+				return nil, io.EOF
+			}
+			if err := c.openNext(); err != nil {
+				return nil, err
+			}
+		}
+		if c.scanner.Scan() {
+			c.line++
+			if len(c.scanner.Bytes()) == 0 {
+				continue
+			}
+			var record Record
+			if err := json.Unmarshal(c.scanner.Bytes(), &record); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", c.path, c.line, err)
+			}
+			if record.Version > SchemaVersion {
+				return nil, fmt.Errorf("%s:%d: unsupported schema version %d", c.path, c.line, record.Version)
+			}
+			return &record, nil
+		}
+		err := c.scanner.Err()
+		partial := strings.HasSuffix(c.path, partSuffix)
+		c.closeCurrent()
+		// A partial file of a crashed writer ends with a truncated block
+		if err != nil && !partial {
+			return nil, fmt.Errorf("%s: %w", c.path, err)
+		}
+	}
+}
+
+func (c *fileChain) openNext() error {
+	c.path = c.paths[0]
+	c.paths = c.paths[1:]
+	c.line = 0
+	file, err := os.Open(c.path)
+	if err != nil {
+		return err
+	}
+	c.file = file
+	var in io.Reader = file
+	if strings.HasSuffix(strings.TrimSuffix(c.path, partSuffix), ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("%s: %w", c.path, err)
+		}
+		c.gz = gz
+		in = gz
+	}
+	c.scanner = bufio.NewScanner(in)
+	c.scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	return nil
+}
+
+func (c *fileChain) closeCurrent() {
+	if c.gz != nil {
+		c.gz.Close()
+		c.gz = nil
+	}
+	if c.file != nil {
+		c.file.Close()
+		c.file = nil
+	}
+	c.scanner = nil
+}
+
+type chainHead struct {
+	record *Record
+	chain  int
+}
+
+type recordHeap []chainHead
+
+func (h recordHeap) Len() int { return len(h) }
+func (h recordHeap) Less(i, j int) bool {
+	if h[i].record.RecvTime == h[j].record.RecvTime {
+		return h[i].chain < h[j].chain
+	}
+	return h[i].record.RecvTime < h[j].record.RecvTime
+}
+func (h recordHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *recordHeap) Push(x any)   { *h = append(*h, x.(chainHead)) }
+func (h *recordHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
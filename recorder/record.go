@@ -0,0 +1,73 @@
+package recorder
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const SchemaVersion = 1
+
+type Venue string
+
+const (
+	VENUE_PAYEER  Venue = "payeer"
+	VENUE_BINANCE Venue = "binance"
+	VENUE_MEXC    Venue = "mexc"
+)
+
+type Kind string
+
+const (
+	KIND_BOOK   Kind = "book"
+	KIND_TRADES Kind = "trades"
+	KIND_TICKER Kind = "ticker"
+	KIND_TRADE  Kind = "trade"
+	KIND_DEPTH  Kind = "depth"
+)
+
+type Record struct {
+	Version  int             `json:"v"`
+	Venue    Venue           `json:"venue"`
+	Kind     Kind            `json:"kind"`
+	Symbol   string          `json:"sym"`
+	RecvTime int64           `json:"recv"`
+	ExchTime int64           `json:"exch,omitempty"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// NewRecord marshals the payload into a record received now.
+func NewRecord(venue Venue, kind Kind, symbol string, exchTime time.Time, payload any) (*Record, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	record := &Record{
+		Version:  SchemaVersion,
+		Venue:    venue,
+		Kind:     kind,
+		Symbol:   symbol,
+		RecvTime: time.Now().UnixNano(),
+		Data:     data,
+	}
+	if !exchTime.IsZero() {
+		record.ExchTime = exchTime.UnixMilli()
+	}
+	return record, nil
+}
+
+func (r *Record) Received() time.Time {
+	return time.Unix(0, r.RecvTime)
+}
+
+// Exchanged returns the exchange event time, or the zero time when it is unknown.
+func (r *Record) Exchanged() time.Time {
+	if r.ExchTime == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(r.ExchTime)
+}
+
+// Decode unmarshals the payload into v, see the package documentation for the payload types.
+func (r *Record) Decode(v any) error {
+	return json.Unmarshal(r.Data, v)
+}
@@ -0,0 +1,174 @@
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Compression of the recorded files; zstd is not supported, see the package
+// documentation.
+type Compression string
+
+const (
+	COMPRESSION_GZIP Compression = "gzip"
+	COMPRESSION_NONE Compression = "none"
+)
+
+const partSuffix = ".part"
+
+type WriterOptions struct {
+	Dir         string
+	Compression Compression
+}
+
+// Writer appends records to hourly rotated files, one file per venue and hour.
+// It is safe for concurrent use.
+type Writer struct {
+	options *WriterOptions
+	mu      sync.Mutex
+	files   map[Venue]*hourFile
+}
+
+type hourFile struct {
+	hour   time.Time
+	path   string
+	file   *os.File
+	gz     *gzip.Writer
+	buf    *bufio.Writer
+	writes int
+}
+
+func NewWriter(options *WriterOptions) (*Writer, error) {
+	if options.Compression == "" {
+		options.Compression = COMPRESSION_GZIP
+	}
+	if options.Compression != COMPRESSION_GZIP && options.Compression != COMPRESSION_NONE {
+		return nil, fmt.Errorf("unsupported compression %q", options.Compression)
+	}
+	if err := os.MkdirAll(options.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Writer{
+		options: options,
+		files:   make(map[Venue]*hourFile),
+	}, nil
+}
+
+func (w *Writer) Write(record *Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	hour := record.Received().UTC().Truncate(time.Hour)
+	f, ok := w.files[record.Venue]
+	if !ok || !f.hour.Equal(hour) {
+		if ok {
+			if err := f.close(); err != nil {
+				slog.Error("[Recorder] Failed to close file", "path", f.path, "error", err)
+			}
+		}
+		f, err = w.open(record.Venue, record.Received().UTC())
+		if err != nil {
+			delete(w.files, record.Venue)
+			return err
+		}
+		w.files[record.Venue] = f
+	}
+	f.writes++
+	if _, err := f.buf.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Flush pushes buffered records to disk so a crash loses as little as possible.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, f := range w.files {
+		if err := f.flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var firstErr error
+	for venue, f := range w.files {
+		if err := f.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(w.files, venue)
+	}
+	return firstErr
+}
+
+func (w *Writer) open(venue Venue, t time.Time) (*hourFile, error) {
+	dir := filepath.Join(w.options.Dir, string(venue), t.Format("2006-01-02"))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("%s.v%d.jsonl", t.Format("15-0405"), SchemaVersion)
+	if w.options.Compression == COMPRESSION_GZIP {
+		name += ".gz"
+	}
+	path := filepath.Join(dir, name)
+	file, err := os.OpenFile(path+partSuffix, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	f := &hourFile{
+		hour: t.Truncate(time.Hour),
+		path: path,
+		file: file,
+	}
+	var out io.Writer = file
+	if w.options.Compression == COMPRESSION_GZIP {
+		f.gz = gzip.NewWriter(file)
+		out = f.gz
+	}
+	f.buf = bufio.NewWriterSize(out, 64*1024)
+	slog.Info("[Recorder] File opened", "path", path+partSuffix)
+	return f, nil
+}
+
+func (f *hourFile) flush() error {
+	if err := f.buf.Flush(); err != nil {
+		return err
+	}
+	if f.gz != nil {
+		if err := f.gz.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *hourFile) close() error {
+	if err := f.buf.Flush(); err != nil {
+		return err
+	}
+	if f.gz != nil {
+		if err := f.gz.Close(); err != nil {
+			return err
+		}
+	}
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	slog.Info("[Recorder] File closed", "path", f.path, "records", f.writes)
+	return os.Rename(f.path+partSuffix, f.path)
+}
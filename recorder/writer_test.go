@@ -0,0 +1,133 @@
+package recorder
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 5, 1, 10, 59, 30, 0, time.UTC)
+
+func record(venue Venue, recv time.Time, price string) *Record {
+	return &Record{
+		Version:  SchemaVersion,
+		Venue:    venue,
+		Kind:     KIND_TICKER,
+		Symbol:   "ETHUSDT",
+		RecvTime: recv.UnixNano(),
+		Data:     json.RawMessage(`{"b":"` + price + `"}`),
+	}
+}
+
+func readAll(t *testing.T, dir string, options *ReaderOptions) []*Record {
+	t.Helper()
+	reader, err := Open(dir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	var records []*Record
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, compression := range []Compression{COMPRESSION_GZIP, COMPRESSION_NONE} {
+		t.Run(string(compression), func(t *testing.T) {
+			dir := t.TempDir()
+			writer, err := NewWriter(&WriterOptions{Dir: dir, Compression: compression})
+			if err != nil {
+				t.Fatal(err)
+			}
+			written := []*Record{
+				record(VENUE_BINANCE, start, "1"),
+				record(VENUE_MEXC, start.Add(time.Second), "2"),
+				record(VENUE_BINANCE, start.Add(20*time.Second), "3"),
+				// The next hour rotates both venues into new files.
+				record(VENUE_BINANCE, start.Add(40*time.Second), "4"),
+				record(VENUE_MEXC, start.Add(50*time.Second), "5"),
+			}
+			for _, r := range written[:3] {
+				if err := writer.Write(r); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			suffix := ".v1.jsonl"
+			if compression == COMPRESSION_GZIP {
+				suffix += ".gz"
+			}
+			firstHour := filepath.Join(dir, "binance", "2024-05-01", "10-5930"+suffix)
+			if !exists(firstHour+partSuffix) || exists(firstHour) {
+				t.Fatalf("%s is not open as a .part file", firstHour)
+			}
+			if err := writer.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if got := readAll(t, dir, nil); len(got) != 0 {
+				t.Errorf("reader sees %d records of .part files", len(got))
+			}
+			if got := readAll(t, dir, &ReaderOptions{IncludePartial: true}); len(got) != 3 {
+				t.Errorf("reader sees %d records with partial files, want 3", len(got))
+			}
+
+			for _, r := range written[3:] {
+				if err := writer.Write(r); err != nil {
+					t.Fatal(err)
+				}
+			}
+			secondHour := filepath.Join(dir, "binance", "2024-05-01", "11-0010"+suffix)
+			if exists(firstHour+partSuffix) || !exists(firstHour) {
+				t.Errorf("%s not renamed on rotation", firstHour)
+			}
+			if !exists(secondHour + partSuffix) {
+				t.Errorf("%s not opened on rotation", secondHour)
+			}
+
+			if err := writer.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if exists(secondHour+partSuffix) || !exists(secondHour) {
+				t.Errorf("%s not renamed on close", secondHour)
+			}
+
+			got := readAll(t, dir, nil)
+			if len(got) != len(written) {
+				t.Fatalf("read %d records, want %d", len(got), len(written))
+			}
+			for i, r := range got {
+				if r.Venue != written[i].Venue || r.RecvTime != written[i].RecvTime || string(r.Data) != string(written[i].Data) {
+					t.Errorf("record %d = %+v, want %+v", i, r, written[i])
+				}
+			}
+
+			from := start.Add(30 * time.Second)
+			if got := readAll(t, dir, &ReaderOptions{Venues: []Venue{VENUE_BINANCE}, From: from}); len(got) != 1 || string(got[0].Data) != `{"b":"4"}` {
+				t.Errorf("binance records from %s = %v, want the fourth", from, got)
+			}
+		})
+	}
+}
+
+func TestUnsupportedCompression(t *testing.T) {
+	if _, err := NewWriter(&WriterOptions{Dir: t.TempDir(), Compression: "zstd"}); err == nil {
+		t.Error("zstd accepted")
+	}
+}
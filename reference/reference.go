@@ -0,0 +1,328 @@
+// Package reference combines the book tickers and depth of several venues into
+// one reference bid and ask per symbol. Quotes older than MaxAge or too far
+// from the weighted median are dropped, the rest are averaged by weight, and
+// the share of the configured weight that made it in is the confidence of the
+// price. A source quoted in another currency is converted through a cross rate
+// which is itself a reference, e.g. a USDT quote times USDTRUB gives RUB, and a
+// synthetic source chains legs for pairs no venue quotes directly, e.g.
+// BTCUSDT × the Payeer USDT_RUB book for BTC_RUB.
+package reference
+
+import (
+	"automata/client/binance"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type SourceKind string
+
+const (
+	SOURCE_BINANCE_TICKER SourceKind = "binanceTicker"
+	SOURCE_MEXC_TICKER    SourceKind = "mexcTicker"
+	SOURCE_BINANCE_DEPTH  SourceKind = "binanceDepth"
+	SOURCE_PAYEER_TICKER  SourceKind = "payeerTicker"
+	SOURCE_PAYEER_BOOK    SourceKind = "payeerBook"
+	// SOURCE_SYNTHETIC multiplies the quotes of its legs.
+	SOURCE_SYNTHETIC SourceKind = "synthetic"
+	// SOURCE_REFERENCE reads another reference by name; it is only valid as
+	// a leg.
+	SOURCE_REFERENCE SourceKind = "reference"
+)
+
+// SourceKinds are the kinds of a source, LegKinds those of a leg.
+var (
+	SourceKinds = []SourceKind{SOURCE_BINANCE_TICKER, SOURCE_MEXC_TICKER, SOURCE_BINANCE_DEPTH, SOURCE_PAYEER_TICKER, SOURCE_PAYEER_BOOK, SOURCE_SYNTHETIC}
+	LegKinds    = []SourceKind{SOURCE_BINANCE_TICKER, SOURCE_MEXC_TICKER, SOURCE_BINANCE_DEPTH, SOURCE_PAYEER_TICKER, SOURCE_PAYEER_BOOK, SOURCE_REFERENCE}
+)
+
+// Sweeps reports whether sources of kind take an Amount.
+func (k SourceKind) Sweeps() bool {
+	return k == SOURCE_BINANCE_DEPTH || k == SOURCE_PAYEER_BOOK
+}
+
+// maxConversions bounds how deep cross rates may chain, so that a cycle in the
+// config cannot recurse forever.
+const maxConversions = 4
+
+type Source struct {
+	Kind SourceKind `json:"kind"`
+	// Symbol is the venue symbol, e.g. BTCUSDT on Binance and MEXC or
+	// USDT_RUB on Payeer. A synthetic source has none.
+	Symbol string `json:"symbol,omitempty"`
+	// Weight of the source in the average, 1 when zero.
+	Weight decimal.Decimal `json:"weight,omitempty"`
+	// Amount is the base amount a depth or book source sweeps for its VWAP,
+	// the whole depth when zero.
+	Amount decimal.Decimal `json:"amount,omitempty"`
+	// Convert names the reference the quote is multiplied by, e.g. USDTRUB to
+	// turn a BTCUSDT quote into BTCRUB.
+	Convert binance.Symbol `json:"convert,omitempty"`
+	// Legs of a synthetic source, multiplied in order.
+	Legs []Leg `json:"legs,omitempty"`
+}
+
+func (s *Source) String() string {
+	if s.Kind != SOURCE_SYNTHETIC {
+		return string(s.Kind) + ":" + s.Symbol
+	}
+	legs := make([]string, len(s.Legs))
+	for i, leg := range s.Legs {
+		legs[i] = leg.String()
+	}
+	return string(s.Kind) + ":" + strings.Join(legs, "×")
+}
+
+// Leg is one factor of a synthetic source: a venue quote read like a source,
+// or another reference with kind reference. A bid of the product sells
+// through every leg at its bid and an ask buys through every leg at its ask,
+// so the product of BTCUSDT and USDT_RUB is bid BTC for RUB and ask BTC for
+// RUB.
+type Leg struct {
+	Kind   SourceKind      `json:"kind"`
+	Symbol string          `json:"symbol"`
+	Amount decimal.Decimal `json:"amount,omitempty"`
+	// Invert takes the leg the other way round, 1/ask as the bid and 1/bid as
+	// the ask, e.g. EURUSDT to go from USDT to EUR.
+	Invert bool `json:"invert,omitempty"`
+}
+
+func (l *Leg) String() string {
+	if l.Invert {
+		return "1/" + l.Symbol
+	}
+	return l.Symbol
+}
+
+func (s *Source) weight() decimal.Decimal {
+	if s.Weight.IsPositive() {
+		return s.Weight
+	}
+	return one
+}
+
+type Options struct {
+	// MaxAge drops a quote received longer ago than this. Default 5s.
+	MaxAge time.Duration
+	// MaxDeviation drops a quote whose mid is further than this fraction from
+	// the weighted median mid of the reference. Default 0.005.
+	MaxDeviation decimal.Decimal
+	// MinConfidence makes a reference unavailable while less than this share
+	// of its weight is usable.
+	MinConfidence decimal.Decimal
+	// DepthLevels of the Binance depth streams, 5, 10 or 20. Default 20.
+	DepthLevels int
+	// PayeerInterval between polls of the Payeer tickers and books. Default 2s.
+	PayeerInterval time.Duration
+	// References by name. A name of a Binance symbol replaces its book ticker
+	// wherever a strategy looks it up.
+	References map[binance.Symbol][]Source
+}
+
+var (
+	one = decimal.NewFromInt(1)
+	two = decimal.NewFromInt(2)
+)
+
+// Quote is the bid and ask of one source as it went into a price.
+type Quote struct {
+	Source   string          `json:"source"`
+	Bid      decimal.Decimal `json:"bid"`
+	Ask      decimal.Decimal `json:"ask"`
+	Weight   decimal.Decimal `json:"weight"`
+	Received time.Time       `json:"received"`
+	// Dropped says why the quote is not part of the price, e.g. "stale".
+	Dropped string `json:"dropped,omitempty"`
+
+	confidence decimal.Decimal
+}
+
+func (q *Quote) mid() decimal.Decimal {
+	return q.Bid.Add(q.Ask).Div(two)
+}
+
+// Price is the combined reference of a symbol.
+type Price struct {
+	Symbol binance.Symbol  `json:"symbol"`
+	Bid    decimal.Decimal `json:"bid"`
+	Ask    decimal.Decimal `json:"ask"`
+	// Confidence is the share of the configured weight, within [0, 1], that
+	// the price is made of; a converted quote counts with the confidence of
+	// its cross rate.
+	Confidence decimal.Decimal `json:"confidence"`
+	// Received is when the oldest quote of the price arrived.
+	Received time.Time `json:"received"`
+	Quotes   []Quote   `json:"quotes"`
+}
+
+func (p *Price) quote() Quote {
+	return Quote{Bid: p.Bid, Ask: p.Ask, Received: p.Received, confidence: p.Confidence}
+}
+
+// Age returns how long ago the oldest quote of the price arrived.
+func (p *Price) Age() time.Duration {
+	return time.Since(p.Received)
+}
+
+// Ticker returns the price as a Binance book ticker, for code written against
+// the Binance stream.
+func (p *Price) Ticker() binance.OrderBookTickerStreamResult {
+	return binance.OrderBookTickerStreamResult{
+		Symbol:   p.Symbol,
+		BidPrice: p.Bid.String(),
+		AskPrice: p.Ask.String(),
+	}
+}
+
+var (
+	ErrUnknown = errors.New("unknown reference")
+	errNoQuote = errors.New("no quote")
+)
+
+// combine builds the price of name from the latest quotes, looking up cross
+// rates through convert.
+func combine(
+	options *Options,
+	name binance.Symbol,
+	quote func(source *Source) (Quote, error),
+	convert func(name binance.Symbol) (*Price, error),
+	now time.Time,
+) (*Price, error) {
+	sources, ok := options.References[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknown, name)
+	}
+	price := &Price{Symbol: name, Confidence: decimal.Zero}
+	total := decimal.Zero
+	var usable []int
+	for i := range sources {
+		source := &sources[i]
+		total = total.Add(source.weight())
+		q, err := quote(source)
+		q.Source = source.String()
+		q.Weight = source.weight()
+		switch {
+		case err != nil:
+			q.Dropped = err.Error()
+		case now.Sub(q.Received) > options.MaxAge:
+			q.Dropped = "stale"
+		case !q.Bid.IsPositive() || !q.Ask.IsPositive():
+			q.Dropped = "empty"
+		case source.Convert != "":
+			rate, err := convert(source.Convert)
+			if err != nil {
+				q.Dropped = "no rate: " + err.Error()
+				break
+			}
+			if q, err = multiply(q, rate.quote(), false); err != nil {
+				q.Dropped = "no rate: " + err.Error()
+			}
+		}
+		if q.Dropped == "" {
+			usable = append(usable, len(price.Quotes))
+		}
+		price.Quotes = append(price.Quotes, q)
+	}
+
+	median := weightedMedian(price.Quotes, usable)
+	weight := decimal.Zero
+	for _, i := range usable {
+		q := &price.Quotes[i]
+		if q.mid().Div(median).Sub(one).Abs().GreaterThan(options.MaxDeviation) {
+			q.Dropped = "outlier"
+			continue
+		}
+		price.Bid = price.Bid.Add(q.Bid.Mul(q.Weight))
+		price.Ask = price.Ask.Add(q.Ask.Mul(q.Weight))
+		price.Confidence = price.Confidence.Add(q.Weight.Mul(q.confidence))
+		weight = weight.Add(q.Weight)
+		if price.Received.IsZero() || q.Received.Before(price.Received) {
+			price.Received = q.Received
+		}
+	}
+	if weight.IsZero() {
+		return price, fmt.Errorf("%s: no usable quote", name)
+	}
+	price.Bid = price.Bid.Div(weight)
+	price.Ask = price.Ask.Div(weight)
+	price.Confidence = price.Confidence.Div(total)
+	if price.Confidence.LessThan(options.MinConfidence) {
+		return price, fmt.Errorf("%s: confidence %s below %s", name, price.Confidence.StringFixed(2), options.MinConfidence)
+	}
+	return price, nil
+}
+
+// multiply chains quote by leg, inverted when the leg says so. The product is
+// as old as its oldest leg.
+func multiply(quote, leg Quote, invert bool) (Quote, error) {
+	if !leg.Bid.IsPositive() || !leg.Ask.IsPositive() {
+		return quote, errors.New("empty leg")
+	}
+	if invert {
+		leg.Bid, leg.Ask = one.Div(leg.Ask), one.Div(leg.Bid)
+	}
+	quote.Bid = quote.Bid.Mul(leg.Bid)
+	quote.Ask = quote.Ask.Mul(leg.Ask)
+	quote.confidence = quote.confidence.Mul(leg.confidence)
+	if quote.Received.IsZero() || leg.Received.Before(quote.Received) {
+		quote.Received = leg.Received
+	}
+	return quote, nil
+}
+
+// weightedMedian returns the mid of the usable quote at which half of their
+// weight is reached going up.
+func weightedMedian(quotes []Quote, usable []int) decimal.Decimal {
+	if len(usable) == 0 {
+		return decimal.Zero
+	}
+	sorted := slices.Clone(usable)
+	slices.SortFunc(sorted, func(a, b int) int {
+		return quotes[a].mid().Cmp(quotes[b].mid())
+	})
+	half := decimal.Zero
+	for _, i := range sorted {
+		half = half.Add(quotes[i].Weight)
+	}
+	half = half.Div(two)
+	cumulative := decimal.Zero
+	for _, i := range sorted {
+		cumulative = cumulative.Add(quotes[i].Weight)
+		if cumulative.GreaterThanOrEqual(half) {
+			return quotes[i].mid()
+		}
+	}
+	return quotes[sorted[len(sorted)-1]].mid()
+}
+
+// vwap returns the volume weighted price of the levels up to amount, of all
+// of them when amount is zero.
+func vwap(levels [][2]string, amount decimal.Decimal) (decimal.Decimal, bool) {
+	value, filled := decimal.Zero, decimal.Zero
+	for _, level := range levels {
+		price, err := decimal.NewFromString(level[0])
+		if err != nil {
+			continue
+		}
+		size, err := decimal.NewFromString(level[1])
+		if err != nil {
+			continue
+		}
+		if amount.IsPositive() {
+			size = decimal.Min(size, amount.Sub(filled))
+		}
+		value = value.Add(price.Mul(size))
+		filled = filled.Add(size)
+		if amount.IsPositive() && filled.GreaterThanOrEqual(amount) {
+			break
+		}
+	}
+	if !filled.IsPositive() {
+		return decimal.Zero, false
+	}
+	return value.Div(filled), true
+}
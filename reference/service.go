@@ -0,0 +1,364 @@
+package reference
+
+import (
+	"automata/client"
+	"automata/client/binance"
+	"automata/client/mexc"
+	"automata/client/payeer"
+	"automata/client/payeer/fetcher"
+	"automata/metrics"
+	"automata/msync"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// BinanceTicker looks up the latest Binance book ticker of symbol and when it
+// was received. The service shares the ticker streams of its owner instead of
+// opening its own.
+type BinanceTicker func(symbol binance.Symbol) (binance.OrderBookTickerStreamResult, time.Time, bool)
+
+type book struct {
+	bids, asks [][2]string
+	received   time.Time
+}
+
+type depthQuote struct {
+	depth    binance.PartialDepthStreamResult
+	received time.Time
+}
+
+// Service keeps the latest quote of every source and combines them on demand.
+type Service struct {
+	options       *Options
+	binance       *binance.Client
+	payeer        *fetcher.Fetcher
+	binanceTicker BinanceTicker
+	depths        *msync.MuMap[binance.Symbol, depthQuote]
+	mexcTickers   *msync.MuMap[string, Quote]
+	payeerTickers *msync.MuMap[string, Quote]
+	payeerBooks   *msync.MuMap[string, book]
+	// available is when each reference was last usable, or when the service
+	// started.
+	available *msync.MuMap[binance.Symbol, time.Time]
+}
+
+// NewService creates the service of options. The Payeer sources are fetched
+// through payeerFetcher so they count against the request weight of the
+// strategies.
+func NewService(options *Options, binanceClient *binance.Client, payeerFetcher *fetcher.Fetcher, binanceTicker BinanceTicker) *Service {
+	if options.MaxAge == 0 {
+		options.MaxAge = 5 * time.Second
+	}
+	if options.MaxDeviation.IsZero() {
+		options.MaxDeviation = decimal.RequireFromString("0.005")
+	}
+	if options.DepthLevels == 0 {
+		options.DepthLevels = 20
+	}
+	if options.PayeerInterval == 0 {
+		options.PayeerInterval = 2 * time.Second
+	}
+	s := &Service{
+		options:       options,
+		binance:       binanceClient,
+		payeer:        payeerFetcher,
+		binanceTicker: binanceTicker,
+		depths:        msync.NewMuMap[binance.Symbol, depthQuote](),
+		mexcTickers:   msync.NewMuMap[string, Quote](),
+		payeerTickers: msync.NewMuMap[string, Quote](),
+		payeerBooks:   msync.NewMuMap[string, book](),
+		available:     msync.NewMuMap[binance.Symbol, time.Time](),
+	}
+	for name := range options.References {
+		s.available.Set(name, time.Now())
+	}
+	metrics.Register(s)
+	return s
+}
+
+// Symbols returns the venue symbols the sources and legs of kind read, in
+// order.
+func (s *Service) Symbols(kind SourceKind) []string {
+	if s == nil {
+		return nil
+	}
+	symbols := []string{}
+	add := func(k SourceKind, symbol string) {
+		if k == kind && !slices.Contains(symbols, symbol) {
+			symbols = append(symbols, symbol)
+		}
+	}
+	for _, sources := range s.options.References {
+		for _, source := range sources {
+			add(source.Kind, source.Symbol)
+			for _, leg := range source.Legs {
+				add(leg.Kind, leg.Symbol)
+			}
+		}
+	}
+	slices.Sort(symbols)
+	return symbols
+}
+
+// Start subscribes to the Binance depth and MEXC ticker streams the sources
+// read. Binance tickers come from the BinanceTicker lookup, the Payeer sources
+// are polled by Run.
+func (s *Service) Start() {
+	for _, symbol := range s.Symbols(SOURCE_BINANCE_DEPTH) {
+		symbol := binance.Symbol(symbol)
+		ch := s.binance.SubscribeDepth(symbol, s.options.DepthLevels)
+		go func() {
+			for depth := range ch {
+				s.depths.Set(symbol, depthQuote{depth, time.Now()})
+			}
+		}()
+	}
+	if symbols := s.Symbols(SOURCE_MEXC_TICKER); len(symbols) > 0 {
+		mexcSymbols := make([]client.Symbol, len(symbols))
+		for i, symbol := range symbols {
+			mexcSymbols[i] = client.Symbol(symbol)
+		}
+		stream := mexc.NewPublicStream(mexcSymbols, 5)
+		stream.Start()
+		go func() {
+			for ticker := range stream.TickerStream {
+				s.mexcTickers.Set(string(ticker.Symbol), Quote{
+					Bid:      decimal.NewFromFloat(ticker.BidPrice),
+					Ask:      decimal.NewFromFloat(ticker.AskPrice),
+					Received: time.Now(),
+				})
+			}
+		}()
+		go func() {
+			for range stream.DealStream {
+			}
+		}()
+		go func() {
+			for range stream.DepthStream {
+			}
+		}()
+	}
+	slog.Info("[Reference] Started", "references", len(s.options.References), "binanceDepth", s.Symbols(SOURCE_BINANCE_DEPTH), "mexc", s.Symbols(SOURCE_MEXC_TICKER),
+		"payeerTicker", s.Symbols(SOURCE_PAYEER_TICKER), "payeerBook", s.Symbols(SOURCE_PAYEER_BOOK))
+}
+
+// Run fetches the Payeer tickers and books the sources read every
+// PayeerInterval, one request for all pairs of each, until ctx is done. A nil
+// s or one without Payeer sources returns at once.
+func (s *Service) Run(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	tickerPairs := payeerPairs(s.Symbols(SOURCE_PAYEER_TICKER))
+	bookPairs := payeerPairs(s.Symbols(SOURCE_PAYEER_BOOK))
+	if len(tickerPairs) == 0 && len(bookPairs) == 0 {
+		return
+	}
+	ticker := time.NewTicker(s.options.PayeerInterval)
+	defer ticker.Stop()
+	for {
+		s.pollPayeer(tickerPairs, bookPairs)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) pollPayeer(tickerPairs, bookPairs []payeer.Pair) {
+	if len(tickerPairs) > 0 {
+		tickers, err := s.payeer.TryTickers(tickerPairs)
+		if err != nil {
+			slog.Error("[Reference] Payeer tickers error", "error", err)
+		}
+		for pair, ticker := range tickers {
+			bid, _ := decimal.NewFromString(ticker.Bid)
+			ask, _ := decimal.NewFromString(ticker.Ask)
+			s.payeerTickers.Set(string(pair), Quote{Bid: bid, Ask: ask, Received: time.Now()})
+		}
+	}
+	if len(bookPairs) > 0 {
+		orders, err := s.payeer.TryOrders(bookPairs)
+		if err != nil {
+			slog.Error("[Reference] Payeer orders error", "error", err)
+		}
+		for pair, info := range orders {
+			s.payeerBooks.Set(string(pair), book{bids: levels(info.Bids), asks: levels(info.Asks), received: time.Now()})
+		}
+	}
+}
+
+func payeerPairs(symbols []string) []payeer.Pair {
+	pairs := make([]payeer.Pair, len(symbols))
+	for i, symbol := range symbols {
+		pairs[i] = payeer.Pair(symbol)
+	}
+	return pairs
+}
+
+func levels(orders []payeer.OrdersOrder) [][2]string {
+	levels := make([][2]string, len(orders))
+	for i, order := range orders {
+		levels[i] = [2]string{order.Price, order.Amount}
+	}
+	return levels
+}
+
+// Has reports whether name is a configured reference. A nil s has none.
+func (s *Service) Has(name binance.Symbol) bool {
+	if s == nil {
+		return false
+	}
+	_, ok := s.options.References[name]
+	return ok
+}
+
+// Names returns the configured references in order.
+func (s *Service) Names() []binance.Symbol {
+	if s == nil {
+		return nil
+	}
+	names := make([]binance.Symbol, 0, len(s.options.References))
+	for name := range s.options.References {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Price combines the latest quotes of the sources of name. The price comes
+// with an error when no quote is usable or the confidence is too low, so the
+// caller can still see which quotes were dropped and why.
+func (s *Service) Price(name binance.Symbol) (*Price, error) {
+	price, err := s.price(name, 0)
+	if err == nil {
+		s.available.Set(name, time.Now())
+	}
+	return price, err
+}
+
+func (s *Service) price(name binance.Symbol, conversions int) (*Price, error) {
+	convert := func(rate binance.Symbol) (*Price, error) {
+		if conversions >= maxConversions {
+			return nil, fmt.Errorf("%s: more than %d conversions", rate, maxConversions)
+		}
+		return s.price(rate, conversions+1)
+	}
+	quote := func(source *Source) (Quote, error) {
+		if source.Kind != SOURCE_SYNTHETIC {
+			return s.quote(source.Kind, source.Symbol, source.Amount)
+		}
+		product := Quote{Bid: one, Ask: one, confidence: one}
+		for _, leg := range source.Legs {
+			var q Quote
+			var err error
+			if leg.Kind == SOURCE_REFERENCE {
+				var rate *Price
+				if rate, err = convert(binance.Symbol(leg.Symbol)); err == nil {
+					q = rate.quote()
+				}
+			} else {
+				q, err = s.quote(leg.Kind, leg.Symbol, leg.Amount)
+			}
+			if err == nil {
+				product, err = multiply(product, q, leg.Invert)
+			}
+			if err != nil {
+				return product, fmt.Errorf("leg %s: %w", leg.String(), err)
+			}
+		}
+		return product, nil
+	}
+	return combine(s.options, name, quote, convert, time.Now())
+}
+
+// Get returns the reference of name as a book ticker, false while it is
+// unavailable.
+func (s *Service) Get(name binance.Symbol) (binance.OrderBookTickerStreamResult, bool) {
+	price, err := s.Price(name)
+	if err != nil {
+		return binance.OrderBookTickerStreamResult{}, false
+	}
+	return price.Ticker(), true
+}
+
+// quote returns the latest venue quote of a source or leg kind.
+func (s *Service) quote(kind SourceKind, symbol string, amount decimal.Decimal) (Quote, error) {
+	var quote Quote
+	var ok bool
+	switch kind {
+	case SOURCE_BINANCE_TICKER:
+		var ticker binance.OrderBookTickerStreamResult
+		ticker, quote.Received, ok = s.binanceTicker(binance.Symbol(symbol))
+		quote.Bid, _ = decimal.NewFromString(ticker.BidPrice)
+		quote.Ask, _ = decimal.NewFromString(ticker.AskPrice)
+	case SOURCE_MEXC_TICKER:
+		quote, ok = s.mexcTickers.Get(symbol)
+	case SOURCE_PAYEER_TICKER:
+		quote, ok = s.payeerTickers.Get(symbol)
+	case SOURCE_BINANCE_DEPTH:
+		var depth depthQuote
+		if depth, ok = s.depths.Get(binance.Symbol(symbol)); ok {
+			quote, ok = sweep(depth.depth.Bids, depth.depth.Asks, amount, depth.received)
+		}
+	case SOURCE_PAYEER_BOOK:
+		var b book
+		if b, ok = s.payeerBooks.Get(symbol); ok {
+			quote, ok = sweep(b.bids, b.asks, amount, b.received)
+		}
+	}
+	if !ok {
+		return Quote{}, errNoQuote
+	}
+	quote.confidence = one
+	return quote, nil
+}
+
+func sweep(bids, asks [][2]string, amount decimal.Decimal, received time.Time) (Quote, bool) {
+	bid, bidOk := vwap(bids, amount)
+	ask, askOk := vwap(asks, amount)
+	return Quote{Bid: bid, Ask: ask, Received: received}, bidOk && askOk
+}
+
+// Ages returns the age of every reference: of its oldest quote while it is
+// usable, since it was last usable otherwise.
+func (s *Service) Ages() map[binance.Symbol]time.Duration {
+	ages := map[binance.Symbol]time.Duration{}
+	for _, name := range s.Names() {
+		if price, err := s.Price(name); err == nil {
+			ages[name] = price.Age()
+			continue
+		}
+		available, _ := s.available.Get(name)
+		ages[name] = time.Since(available)
+	}
+	return ages
+}
+
+// Collect exports the confidence and age of every reference.
+func (s *Service) Collect(sink *metrics.Sink) {
+	for _, name := range s.Names() {
+		price, err := s.Price(name)
+		if price == nil {
+			continue
+		}
+		sink.Gauge("reference_confidence", "Share of the source weight the reference price is made of.", price.Confidence.InexactFloat64(), "symbol", string(name))
+		sink.Gauge("reference_available", "1 while the reference price is usable.", boolValue(err == nil), "symbol", string(name))
+		if err == nil {
+			sink.Gauge("reference_age_seconds", "Age of the oldest quote in the reference price.", price.Age().Seconds(), "symbol", string(name))
+		}
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
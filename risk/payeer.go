@@ -0,0 +1,57 @@
+package risk
+
+import (
+	"automata/client/payeer"
+
+	"github.com/shopspring/decimal"
+)
+
+// PayeerOrder describes a Payeer limit or market order; invalid numbers count
+// as zero.
+func PayeerOrder(action payeer.Action, pair payeer.Pair, amount, price string) Order {
+	return Order{
+		Venue:  "payeer",
+		Base:   pair.Base(),
+		Quote:  pair.Quote(),
+		Side:   payeerSide(action),
+		Amount: parse(amount),
+		Price:  parse(price),
+	}
+}
+
+// PayeerFills converts the trades of an order. Payeer charges the fee in the
+// asset received, so the fee of a buy is converted to quote at the trade price.
+func PayeerFills(order *payeer.OrderDetails) []Fill {
+	fills := []Fill{}
+	for _, trade := range order.Trades {
+		fill := Fill{
+			Base:   order.Pair.Base(),
+			Quote:  order.Pair.Quote(),
+			Side:   payeerSide(order.Action),
+			Amount: parse(trade.Amount),
+			Price:  parse(trade.Price),
+		}
+		fee := parse(trade.MakerCommission).Add(parse(trade.TakerCommission))
+		if fill.Side == SIDE_BUY {
+			fee = fee.Mul(fill.Price)
+		}
+		fill.Fee = fee
+		fills = append(fills, fill)
+	}
+	return fills
+}
+
+func payeerSide(action payeer.Action) Side {
+	if action == payeer.ACTION_SELL {
+		return SIDE_SELL
+	}
+	return SIDE_BUY
+}
+
+func parse(s string) decimal.Decimal {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return decimal.Zero
+	}
+	return d
+}
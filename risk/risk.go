@@ -0,0 +1,372 @@
+// Package risk checks orders before they are sent to an exchange.
+//
+// A Manager enforces per-order notional limits, net inventory limits per
+// asset, order and cancel rates, a price collar around the reference mid and a
+// daily loss limit. Order paths call CheckOrder right before the request and
+// give up on a *Rejection, cancel paths call WaitCancel, which delays but never
+// rejects; fills are fed back with RecordFill.
+// All methods accept a nil *Manager, which allows everything.
+package risk
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type Side string
+
+const (
+	SIDE_BUY  Side = "buy"
+	SIDE_SELL Side = "sell"
+)
+
+type Rule string
+
+const (
+	RULE_NOTIONAL   Rule = "max_order_notional"
+	RULE_INVENTORY  Rule = "max_inventory"
+	RULE_ORDER_RATE Rule = "max_orders_per_minute"
+	RULE_COLLAR     Rule = "price_collar"
+	RULE_DAILY_LOSS Rule = "daily_loss_limit"
+)
+
+// Limits are the thresholds a Manager enforces; zero values disable a rule.
+// Notional, inventory and loss limits are keyed by asset.
+type Limits struct {
+	// MaxOrderNotional caps amount × price of one order, by quote asset.
+	MaxOrderNotional map[string]decimal.Decimal
+	// MaxInventory caps the absolute net amount bought or sold of an asset
+	// since the process started. Orders reducing the inventory pass.
+	MaxInventory       map[string]decimal.Decimal
+	MaxOrdersPerMinute int
+	// MaxCancelsPerMinute delays cancels above the rate instead of rejecting
+	// them, a cancel only ever lowers the risk.
+	MaxCancelsPerMinute int
+	// PriceCollar is the largest allowed relative distance of a price from the
+	// reference mid, e.g. 0.02 for 2%. Orders on a pair without a reference are
+	// rejected while it is set.
+	PriceCollar decimal.Decimal
+	// ReferenceMaxAge is how old a reference mid may be before orders on its
+	// pair are rejected by the collar; defaults to 10s.
+	ReferenceMaxAge time.Duration
+	// DailyLossLimit caps the loss of the UTC day, realised and marked to the
+	// reference mid, by quote asset.
+	DailyLossLimit map[string]decimal.Decimal
+}
+
+type Order struct {
+	Venue  string
+	Base   string
+	Quote  string
+	Side   Side
+	Amount decimal.Decimal
+	// Price is the limit price, or the expected execution price of a market
+	// order.
+	Price decimal.Decimal
+}
+
+func (o Order) String() string {
+	return fmt.Sprintf("%s %s %s %s_%s @ %s", o.Venue, o.Side, o.Amount, o.Base, o.Quote, o.Price)
+}
+
+type Fill struct {
+	Base   string
+	Quote  string
+	Side   Side
+	Amount decimal.Decimal
+	Price  decimal.Decimal
+	// Fee in the quote asset. Both venues take it from the asset received, so
+	// the inventory of a buy loses its base equivalent at Price.
+	Fee decimal.Decimal
+}
+
+// Rejection is returned for an order that breaks a rule.
+type Rejection struct {
+	Rule   Rule
+	Reason string
+}
+
+func (r *Rejection) Error() string {
+	return "risk rejected (" + string(r.Rule) + "): " + r.Reason
+}
+
+type pair struct {
+	base  string
+	quote string
+}
+
+type reference struct {
+	mid  decimal.Decimal
+	time time.Time
+}
+
+// position is the trading of a pair in the UTC day.
+type position struct {
+	// base is the net amount bought, cash the net quote received incl. fees.
+	base      decimal.Decimal
+	cash      decimal.Decimal
+	lastPrice decimal.Decimal
+}
+
+type Manager struct {
+	mu     sync.Mutex
+	limits *Limits
+	clock  func() time.Time
+	sleep  func(time.Duration)
+	orders []time.Time
+	// cancels holds the times cancels were let through, incl. the ones still
+	// waiting for theirs.
+	cancels []time.Time
+	day     time.Time
+	// positions restart every UTC day for the daily loss, inventory holds the
+	// net amount of every asset since the start.
+	positions  map[pair]*position
+	inventory  map[string]decimal.Decimal
+	references map[pair]reference
+}
+
+func NewManager(limits *Limits) *Manager {
+	m := &Manager{
+		clock:      time.Now,
+		sleep:      time.Sleep,
+		inventory:  make(map[string]decimal.Decimal),
+		references: make(map[pair]reference),
+	}
+	m.SetLimits(limits)
+	m.resetDay(m.clock())
+	return m
+}
+
+// SetLimits replaces the limits, e.g. on a config reload. limits is copied,
+// the caller keeps its own.
+func (m *Manager) SetLimits(limits *Limits) {
+	copied := *limits
+	if copied.ReferenceMaxAge == 0 {
+		copied.ReferenceMaxAge = 10 * time.Second
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.limits = &copied
+}
+
+// UpdateReference sets the mid the collar and the daily loss are measured
+// against.
+func (m *Manager) UpdateReference(base, quote string, bid, ask decimal.Decimal) {
+	if m == nil || !bid.IsPositive() || !ask.IsPositive() {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.references[pair{base, quote}] = reference{
+		mid:  bid.Add(ask).Div(decimal.NewFromInt(2)),
+		time: m.clock(),
+	}
+}
+
+// CheckOrder applies every rule to order and counts it towards the order rate
+// when it passes.
+func (m *Manager) CheckOrder(order Order) error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.clock()
+	m.rollDay(now)
+	if err := m.checkOrder(order, now); err != nil {
+		slog.Warn("[Risk] Order rejected", "order", order.String(), "rule", err.Rule, "reason", err.Reason)
+		return err
+	}
+	m.orders = append(m.orders, now)
+	return nil
+}
+
+func (m *Manager) checkOrder(order Order, now time.Time) *Rejection {
+	limits := m.limits
+	notional := order.Amount.Mul(order.Price)
+
+	if max, ok := limits.MaxOrderNotional[order.Quote]; ok && max.IsPositive() && notional.GreaterThan(max) {
+		return &Rejection{RULE_NOTIONAL, fmt.Sprintf("notional %s %s above %s", notional, order.Quote, max)}
+	}
+
+	if limits.MaxOrdersPerMinute > 0 {
+		m.orders = trim(m.orders, now)
+		if len(m.orders) >= limits.MaxOrdersPerMinute {
+			return &Rejection{RULE_ORDER_RATE, fmt.Sprintf("%d orders in the last minute", len(m.orders))}
+		}
+	}
+
+	if limits.PriceCollar.IsPositive() {
+		ref, ok := m.references[pair{order.Base, order.Quote}]
+		if !ok {
+			return &Rejection{RULE_COLLAR, fmt.Sprintf("no reference mid for %s_%s", order.Base, order.Quote)}
+		}
+		if age := now.Sub(ref.time); age > limits.ReferenceMaxAge {
+			return &Rejection{RULE_COLLAR, fmt.Sprintf("reference mid is %s old", age.Round(time.Millisecond))}
+		}
+		distance := order.Price.Div(ref.mid).Sub(decimal.NewFromInt(1)).Abs()
+		if distance.GreaterThan(limits.PriceCollar) {
+			return &Rejection{RULE_COLLAR, fmt.Sprintf("price %s is %s%% from the reference mid %s", order.Price, distance.Mul(decimal.NewFromInt(100)).StringFixed(2), ref.mid)}
+		}
+	}
+
+	baseDelta, quoteDelta := order.Amount, notional.Neg()
+	if order.Side == SIDE_SELL {
+		baseDelta, quoteDelta = baseDelta.Neg(), quoteDelta.Neg()
+	}
+	for asset, delta := range map[string]decimal.Decimal{order.Base: baseDelta, order.Quote: quoteDelta} {
+		max, ok := limits.MaxInventory[asset]
+		if !ok || !max.IsPositive() {
+			continue
+		}
+		current := m.inventory[asset]
+		projected := current.Add(delta)
+		if projected.Abs().GreaterThan(max) && projected.Abs().GreaterThan(current.Abs()) {
+			return &Rejection{RULE_INVENTORY, fmt.Sprintf("net %s inventory would be %s, limit %s", asset, projected, max)}
+		}
+	}
+
+	if max, ok := limits.DailyLossLimit[order.Quote]; ok && max.IsPositive() {
+		if loss := m.pnl(order.Quote).Neg(); loss.GreaterThanOrEqual(max) {
+			return &Rejection{RULE_DAILY_LOSS, fmt.Sprintf("daily loss %s %s reached the limit %s", loss, order.Quote, max)}
+		}
+	}
+	return nil
+}
+
+// WaitCancel counts a cancel towards the cancel rate. Above the rate it waits
+// until the cancel fits in the minute again: a cancel only lowers the risk, so
+// it is never rejected. Cancels on shutdown bypass it so that they go out at
+// once.
+func (m *Manager) WaitCancel() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	now := m.clock()
+	at := now
+	if max := m.limits.MaxCancelsPerMinute; max > 0 {
+		m.cancels = trim(m.cancels, now)
+		if len(m.cancels) >= max {
+			at = m.cancels[len(m.cancels)-max].Add(time.Minute)
+		}
+	}
+	m.cancels = append(m.cancels, at)
+	slices.SortFunc(m.cancels, time.Time.Compare)
+	m.mu.Unlock()
+	if wait := at.Sub(now); wait > 0 {
+		slog.Warn("[Risk] Cancel rate reached, delaying the cancel", "rule", "max_cancels_per_minute", "wait", wait.Round(time.Millisecond))
+		m.sleep(wait)
+	}
+}
+
+// RecordFill updates inventory and daily PnL.
+func (m *Manager) RecordFill(fill Fill) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rollDay(m.clock())
+	key := pair{fill.Base, fill.Quote}
+	p, ok := m.positions[key]
+	if !ok {
+		p = &position{}
+		m.positions[key] = p
+	}
+	value := fill.Amount.Mul(fill.Price)
+	if fill.Side == SIDE_BUY {
+		p.base = p.base.Add(fill.Amount)
+		p.cash = p.cash.Sub(value)
+	} else {
+		p.base = p.base.Sub(fill.Amount)
+		p.cash = p.cash.Add(value)
+	}
+	p.cash = p.cash.Sub(fill.Fee)
+	p.lastPrice = fill.Price
+
+	baseDelta, quoteDelta := fill.Amount, value.Neg()
+	if fill.Side == SIDE_SELL {
+		baseDelta, quoteDelta = baseDelta.Neg(), quoteDelta.Neg()
+	}
+	if fill.Side == SIDE_BUY && fill.Price.IsPositive() {
+		baseDelta = baseDelta.Sub(fill.Fee.Div(fill.Price))
+	} else {
+		quoteDelta = quoteDelta.Sub(fill.Fee)
+	}
+	m.inventory[fill.Base] = m.inventory[fill.Base].Add(baseDelta)
+	m.inventory[fill.Quote] = m.inventory[fill.Quote].Add(quoteDelta)
+}
+
+// Inventory returns the net amount of every traded asset since the process
+// started.
+func (m *Manager) Inventory() map[string]decimal.Decimal {
+	result := map[string]decimal.Decimal{}
+	if m == nil {
+		return result
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for asset, amount := range m.inventory {
+		result[asset] = amount
+	}
+	return result
+}
+
+// DailyPnL returns the PnL of the UTC day by quote asset, with open inventory
+// marked to the reference mid or the last fill price.
+func (m *Manager) DailyPnL() map[string]decimal.Decimal {
+	result := map[string]decimal.Decimal{}
+	if m == nil {
+		return result
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key := range m.positions {
+		result[key.quote] = m.pnl(key.quote)
+	}
+	return result
+}
+
+func (m *Manager) pnl(quote string) decimal.Decimal {
+	total := decimal.Zero
+	for key, p := range m.positions {
+		if key.quote != quote {
+			continue
+		}
+		mark := p.lastPrice
+		if ref, ok := m.references[key]; ok {
+			mark = ref.mid
+		}
+		total = total.Add(p.cash).Add(p.base.Mul(mark))
+	}
+	return total
+}
+
+func (m *Manager) rollDay(now time.Time) {
+	if day := now.UTC().Truncate(24 * time.Hour); day.After(m.day) {
+		if len(m.positions) > 0 {
+			slog.Info("[Risk] New day, resetting the daily PnL", "day", day.Format(time.DateOnly))
+		}
+		m.resetDay(now)
+	}
+}
+
+func (m *Manager) resetDay(now time.Time) {
+	m.day = now.UTC().Truncate(24 * time.Hour)
+	m.positions = make(map[pair]*position)
+}
+
+// trim drops the entries older than a minute; entries in the future stay.
+func trim(times []time.Time, now time.Time) []time.Time {
+	i := 0
+	for i < len(times) && now.Sub(times[i]) >= time.Minute {
+		i++
+	}
+	return times[i:]
+}
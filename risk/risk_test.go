@@ -0,0 +1,238 @@
+package risk
+
+import (
+	"automata/internal/dectest"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var start = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// testManager returns a manager whose clock only moves when the test or a
+// waiting cancel moves it.
+func testManager(limits *Limits) (*Manager, *time.Time) {
+	now := start
+	m := NewManager(limits)
+	m.clock = func() time.Time { return now }
+	m.sleep = func(d time.Duration) { now = now.Add(d) }
+	m.resetDay(now)
+	return m, &now
+}
+
+var d = dectest.D
+
+func order(side Side, amount, price string) Order {
+	return Order{Venue: "payeer", Base: "BTC", Quote: "USDT", Side: side, Amount: d(amount), Price: d(price)}
+}
+
+func rule(err error) Rule {
+	var rejection *Rejection
+	if errors.As(err, &rejection) {
+		return rejection.Rule
+	}
+	return ""
+}
+
+func TestCollar(t *testing.T) {
+	tests := []struct {
+		name      string
+		reference bool
+		age       time.Duration
+		price     string
+		want      Rule
+	}{
+		{name: "inside", reference: true, price: "101"},
+		{name: "at the edge", reference: true, price: "102"},
+		{name: "above", reference: true, price: "102.01", want: RULE_COLLAR},
+		{name: "below", reference: true, price: "97.9", want: RULE_COLLAR},
+		{name: "stale reference", reference: true, age: 11 * time.Second, price: "100", want: RULE_COLLAR},
+		{name: "no reference", price: "100", want: RULE_COLLAR},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, now := testManager(&Limits{PriceCollar: d("0.02")})
+			if tt.reference {
+				m.UpdateReference("BTC", "USDT", d("99"), d("101"))
+			}
+			*now = now.Add(tt.age)
+			if got := rule(m.CheckOrder(order(SIDE_BUY, "1", tt.price))); got != tt.want {
+				t.Errorf("rule = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollarOff(t *testing.T) {
+	m, _ := testManager(&Limits{})
+	if err := m.CheckOrder(order(SIDE_BUY, "1", "100")); err != nil {
+		t.Errorf("order without a reference rejected with the collar off: %v", err)
+	}
+}
+
+func TestInventory(t *testing.T) {
+	tests := []struct {
+		name  string
+		fills []Fill
+		order Order
+		want  Rule
+	}{
+		{name: "empty", order: order(SIDE_BUY, "2", "100")},
+		{name: "above", order: order(SIDE_BUY, "2.1", "100"), want: RULE_INVENTORY},
+		{
+			name:  "adds to a long",
+			fills: []Fill{{Base: "BTC", Quote: "USDT", Side: SIDE_BUY, Amount: d("1.5"), Price: d("100")}},
+			order: order(SIDE_BUY, "0.6", "100"),
+			want:  RULE_INVENTORY,
+		},
+		{
+			name:  "reduces a long above the limit",
+			fills: []Fill{{Base: "BTC", Quote: "USDT", Side: SIDE_BUY, Amount: d("3"), Price: d("100")}},
+			order: order(SIDE_SELL, "0.5", "100"),
+		},
+		{
+			name:  "crosses to a short above the limit",
+			fills: []Fill{{Base: "BTC", Quote: "USDT", Side: SIDE_BUY, Amount: d("1"), Price: d("100")}},
+			order: order(SIDE_SELL, "3.5", "100"),
+			want:  RULE_INVENTORY,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := testManager(&Limits{MaxInventory: map[string]decimal.Decimal{"BTC": d("2")}})
+			for _, fill := range tt.fills {
+				m.RecordFill(fill)
+			}
+			if got := rule(m.CheckOrder(tt.order)); got != tt.want {
+				t.Errorf("rule = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInventoryAcrossDays(t *testing.T) {
+	m, now := testManager(&Limits{MaxInventory: map[string]decimal.Decimal{"BTC": d("2")}})
+	m.RecordFill(Fill{Base: "BTC", Quote: "USDT", Side: SIDE_BUY, Amount: d("1.5"), Price: d("100"), Fee: d("0.3")})
+	*now = now.Add(24 * time.Hour)
+
+	if got := rule(m.CheckOrder(order(SIDE_BUY, "1", "100"))); got != RULE_INVENTORY {
+		t.Errorf("rule on the next day = %q, want %q", got, RULE_INVENTORY)
+	}
+	inventory := m.Inventory()
+	if !inventory["BTC"].Equal(d("1.497")) || !inventory["USDT"].Equal(d("-150")) {
+		t.Errorf("inventory = %v, want BTC 1.497 and USDT -150", inventory)
+	}
+	if pnl := m.DailyPnL(); len(pnl) != 0 {
+		t.Errorf("daily PnL on the next day = %v, want none", pnl)
+	}
+}
+
+func TestInventoryFee(t *testing.T) {
+	tests := []struct {
+		name      string
+		fill      Fill
+		wantBase  string
+		wantQuote string
+	}{
+		{name: "buy pays in base", fill: Fill{Side: SIDE_BUY, Amount: d("2"), Price: d("100"), Fee: d("0.4")}, wantBase: "1.996", wantQuote: "-200"},
+		{name: "sell pays in quote", fill: Fill{Side: SIDE_SELL, Amount: d("2"), Price: d("100"), Fee: d("0.4")}, wantBase: "-2", wantQuote: "199.6"},
+		{name: "no fee", fill: Fill{Side: SIDE_BUY, Amount: d("2"), Price: d("100")}, wantBase: "2", wantQuote: "-200"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := testManager(&Limits{})
+			tt.fill.Base, tt.fill.Quote = "BTC", "USDT"
+			m.RecordFill(tt.fill)
+			inventory := m.Inventory()
+			if !inventory["BTC"].Equal(d(tt.wantBase)) || !inventory["USDT"].Equal(d(tt.wantQuote)) {
+				t.Errorf("inventory = %v, want BTC %s and USDT %s", inventory, tt.wantBase, tt.wantQuote)
+			}
+		})
+	}
+}
+
+func TestDailyLoss(t *testing.T) {
+	m, now := testManager(&Limits{DailyLossLimit: map[string]decimal.Decimal{"USDT": d("10")}})
+	m.UpdateReference("BTC", "USDT", d("100"), d("100"))
+	m.RecordFill(Fill{Base: "BTC", Quote: "USDT", Side: SIDE_BUY, Amount: d("1"), Price: d("100"), Fee: d("1")})
+
+	if err := m.CheckOrder(order(SIDE_BUY, "0.1", "100")); err != nil {
+		t.Fatalf("order below the loss limit rejected: %v", err)
+	}
+	// Marked to the new mid the position lost 9 and the fee 1.
+	m.UpdateReference("BTC", "USDT", d("91"), d("91"))
+	if got := rule(m.CheckOrder(order(SIDE_SELL, "0.1", "91"))); got != RULE_DAILY_LOSS {
+		t.Errorf("rule = %q, want %q", got, RULE_DAILY_LOSS)
+	}
+	if pnl := m.DailyPnL()["USDT"]; !pnl.Equal(d("-10")) {
+		t.Errorf("daily PnL = %s, want -10", pnl)
+	}
+
+	*now = now.Add(24 * time.Hour)
+	if err := m.CheckOrder(order(SIDE_SELL, "0.1", "91")); err != nil {
+		t.Errorf("order on the next day rejected: %v", err)
+	}
+}
+
+func TestOrderRate(t *testing.T) {
+	m, now := testManager(&Limits{MaxOrdersPerMinute: 2})
+	for i := 0; i < 2; i++ {
+		if err := m.CheckOrder(order(SIDE_BUY, "1", "100")); err != nil {
+			t.Fatalf("order %d rejected: %v", i, err)
+		}
+	}
+	if got := rule(m.CheckOrder(order(SIDE_BUY, "1", "100"))); got != RULE_ORDER_RATE {
+		t.Errorf("rule = %q, want %q", got, RULE_ORDER_RATE)
+	}
+	*now = now.Add(time.Minute)
+	if err := m.CheckOrder(order(SIDE_BUY, "1", "100")); err != nil {
+		t.Errorf("order a minute later rejected: %v", err)
+	}
+}
+
+func TestCancelRate(t *testing.T) {
+	m, now := testManager(&Limits{MaxCancelsPerMinute: 2})
+	m.WaitCancel()
+	*now = now.Add(10 * time.Second)
+	m.WaitCancel()
+	if waited := now.Sub(start); waited != 10*time.Second {
+		t.Fatalf("cancels within the rate waited %s", waited-10*time.Second)
+	}
+
+	// The third cancel waits for the first to leave the minute, the fourth
+	// for the second.
+	m.WaitCancel()
+	if got := now.Sub(start); got != time.Minute {
+		t.Errorf("third cancel went out after %s, want 1m0s", got)
+	}
+	m.WaitCancel()
+	if got := now.Sub(start); got != 70*time.Second {
+		t.Errorf("fourth cancel went out after %s, want 1m10s", got)
+	}
+}
+
+func TestSetLimitsCopies(t *testing.T) {
+	limits := &Limits{MaxOrdersPerMinute: 1}
+	m := NewManager(limits)
+	if limits.ReferenceMaxAge != 0 {
+		t.Errorf("caller's ReferenceMaxAge set to %s", limits.ReferenceMaxAge)
+	}
+	limits.MaxOrdersPerMinute = 0
+	if m.limits.MaxOrdersPerMinute != 1 {
+		t.Errorf("manager follows the caller's limits")
+	}
+}
+
+func TestNilManager(t *testing.T) {
+	var m *Manager
+	if err := m.CheckOrder(order(SIDE_BUY, "1", "100")); err != nil {
+		t.Errorf("nil manager rejected: %v", err)
+	}
+	m.WaitCancel()
+	m.RecordFill(Fill{})
+	if len(m.Inventory()) != 0 || len(m.DailyPnL()) != 0 {
+		t.Errorf("nil manager reports state")
+	}
+}
@@ -0,0 +1,17 @@
+package risk
+
+import "strings"
+
+// quotes are the quote assets SplitSymbol recognises, longest first.
+var quotes = []string{"FDUSD", "USDT", "USDC", "TUSD", "BUSD", "USD", "EUR", "RUB", "TRY", "BTC", "ETH", "BNB"}
+
+// SplitSymbol splits a Binance or MEXC symbol such as "ETHUSDT" into base and
+// quote. It reports false for symbols with an unknown quote.
+func SplitSymbol(symbol string) (base, quote string, ok bool) {
+	for _, quote := range quotes {
+		if base, found := strings.CutSuffix(symbol, quote); found && base != "" {
+			return base, quote, true
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,189 @@
+package statestore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// compactAfter is how many journal entries may pile up on top of the live
+// records before the journal is rewritten.
+const compactAfter = 1000
+
+// File is a Store backed by an append-only journal of JSON lines:
+//
+//	{"key":"orders/123","value":{...}}
+//	{"key":"orders/123","deleted":true}
+//
+// Every write is synced before it returns. On open the journal is replayed;
+// a torn last line left by a crash is dropped. The journal is compacted by
+// writing the live records to a temporary file and renaming it over the old
+// one, so a crash during compaction leaves either version intact.
+type File struct {
+	path    string
+	mu      sync.Mutex
+	file    *os.File
+	values  map[string]json.RawMessage
+	entries int
+}
+
+var _ Store = (*File)(nil)
+
+type journalEntry struct {
+	Key     string          `json:"key"`
+	Value   json.RawMessage `json:"value,omitempty"`
+	Deleted bool            `json:"deleted,omitempty"`
+}
+
+// Open replays the journal at path, creating it if needed, and compacts it.
+func Open(path string) (*File, error) {
+	f := &File{path: path, values: make(map[string]json.RawMessage)}
+	if err := f.replay(); err != nil {
+		return nil, fmt.Errorf("state %s: %w", path, err)
+	}
+	if err := f.compact(); err != nil {
+		return nil, fmt.Errorf("state %s: %w", path, err)
+	}
+	slog.Info("[StateStore] Opened", "path", path, "records", len(f.values))
+	return f, nil
+}
+
+func (f *File) replay() error {
+	file, err := os.Open(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(bytes.TrimSpace(data)) > 0 {
+				slog.Warn("[StateStore] Dropping incomplete last journal entry", "path", f.path, "line", line)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var entry journalEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if entry.Deleted {
+			delete(f.values, entry.Key)
+		} else {
+			f.values[entry.Key] = entry.Value
+		}
+	}
+}
+
+// compact writes the live records to a new journal and opens it for appending.
+func (f *File) compact() error {
+	tmp := f.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	for _, key := range keys(f.values, "") {
+		data, _ := json.Marshal(journalEntry{Key: key, Value: f.values[key]})
+		writer.Write(append(data, '\n'))
+	}
+	err = errors.Join(writer.Flush(), file.Sync(), file.Close())
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		return err
+	}
+	if dir, err := os.Open(filepath.Dir(f.path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	if f.file != nil {
+		f.file.Close()
+	}
+	f.file, err = os.OpenFile(f.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	f.entries = len(f.values)
+	return err
+}
+
+func (f *File) append(entry journalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := f.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err := f.file.Sync(); err != nil {
+		return err
+	}
+	f.entries++
+	if f.entries > len(f.values)+compactAfter {
+		return f.compact()
+	}
+	return nil
+}
+
+func (f *File) Get(key string, v any) (bool, error) {
+	f.mu.Lock()
+	data, ok := f.values[key]
+	f.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+func (f *File) Put(key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.append(journalEntry{Key: key, Value: data}); err != nil {
+		return err
+	}
+	f.values[key] = data
+	return nil
+}
+
+func (f *File) Delete(key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.values[key]; !ok {
+		return nil
+	}
+	if err := f.append(journalEntry{Key: key, Deleted: true}); err != nil {
+		return err
+	}
+	delete(f.values, key)
+	return nil
+}
+
+func (f *File) Keys(prefix string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return keys(f.values, prefix)
+}
+
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
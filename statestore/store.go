@@ -0,0 +1,133 @@
+// Package statestore keeps small pieces of strategy state, such as the orders
+// a strategy placed, across restarts.
+//
+// Values are stored as JSON under string keys. [File] appends every change to
+// a journal and syncs it before returning, so a crash loses at most the change
+// that was being written; [Memory] keeps everything in process for paper runs
+// and backtests.
+package statestore
+
+import (
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type Store interface {
+	// Get decodes the value stored under key into v and reports whether the
+	// key exists.
+	Get(key string, v any) (bool, error)
+	Put(key string, v any) error
+	Delete(key string) error
+	// Keys returns the sorted keys starting with prefix.
+	Keys(prefix string) []string
+	Close() error
+}
+
+type Memory struct {
+	mu     sync.Mutex
+	values map[string]json.RawMessage
+}
+
+var _ Store = (*Memory)(nil)
+
+func NewMemory() *Memory {
+	return &Memory{values: make(map[string]json.RawMessage)}
+}
+
+func (m *Memory) Get(key string, v any) (bool, error) {
+	m.mu.Lock()
+	data, ok := m.values[key]
+	m.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+func (m *Memory) Put(key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[key] = data
+	return nil
+}
+
+func (m *Memory) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.values, key)
+	return nil
+}
+
+func (m *Memory) Keys(prefix string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return keys(m.values, prefix)
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+func keys(values map[string]json.RawMessage, prefix string) []string {
+	result := []string{}
+	for key := range values {
+		if strings.HasPrefix(key, prefix) {
+			result = append(result, key)
+		}
+	}
+	slices.Sort(result)
+	return result
+}
+
+// Bucket is a typed view on the keys of a store below prefix. Write errors are
+// logged rather than returned: losing a record only costs adopting the order
+// after a restart, which must not stop trading.
+type Bucket[T any] struct {
+	store  Store
+	prefix string
+}
+
+func NewBucket[T any](store Store, prefix string) *Bucket[T] {
+	return &Bucket[T]{store: store, prefix: prefix + "/"}
+}
+
+func (b *Bucket[T]) Get(id string) (T, bool) {
+	var value T
+	ok, err := b.store.Get(b.prefix+id, &value)
+	if err != nil {
+		slog.Error("[StateStore] Decoding record failed", "key", b.prefix+id, "error", err)
+		return value, false
+	}
+	return value, ok
+}
+
+func (b *Bucket[T]) Set(id string, value T) {
+	if err := b.store.Put(b.prefix+id, value); err != nil {
+		slog.Error("[StateStore] Writing record failed", "key", b.prefix+id, "error", err)
+	}
+}
+
+func (b *Bucket[T]) Delete(id string) {
+	if err := b.store.Delete(b.prefix + id); err != nil {
+		slog.Error("[StateStore] Deleting record failed", "key", b.prefix+id, "error", err)
+	}
+}
+
+// All returns every record of the bucket by ID.
+func (b *Bucket[T]) All() map[string]T {
+	result := make(map[string]T)
+	for _, key := range b.store.Keys(b.prefix) {
+		id := strings.TrimPrefix(key, b.prefix)
+		if value, ok := b.Get(id); ok {
+			result[id] = value
+		}
+	}
+	return result
+}
@@ -0,0 +1,188 @@
+package strategy
+
+import (
+	"automata/client/payeer"
+	"automata/notify"
+	"automata/pnl"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type AlertOptions struct {
+	// Fills sends an alert for every fill.
+	Fills bool
+	// InsufficientFunds alerts once that many INSUFFICIENT_FUNDS responses
+	// arrived within InsufficientFundsWindow; defaults to 3 within 10m.
+	InsufficientFunds       int
+	InsufficientFundsWindow time.Duration
+	// StaleFeed alerts when a Binance ticker is older; defaults to 30s.
+	StaleFeed time.Duration
+	// CheckInterval between the feed and kill switch checks; defaults to 5s.
+	CheckInterval time.Duration
+}
+
+// Alerts turns market events into notifications: fills, repeated insufficient
+// funds, stale reference feeds and kill switch trips. The dispatcher de-duplicates
+// and rate-limits them.
+type Alerts struct {
+	market     *Market
+	dispatcher *notify.Dispatcher
+	killSwitch *KillSwitch
+	options    *AlertOptions
+	mu         sync.Mutex
+	rejections map[string][]time.Time
+	stale      map[string]bool
+}
+
+// NewAlerts hooks into the fills and error responses of market; killSwitch may
+// be nil.
+func NewAlerts(market *Market, dispatcher *notify.Dispatcher, killSwitch *KillSwitch, options *AlertOptions) *Alerts {
+	if options.InsufficientFunds == 0 {
+		options.InsufficientFunds = 3
+	}
+	if options.InsufficientFundsWindow == 0 {
+		options.InsufficientFundsWindow = 10 * time.Minute
+	}
+	if options.StaleFeed == 0 {
+		options.StaleFeed = 30 * time.Second
+	}
+	if options.CheckInterval == 0 {
+		options.CheckInterval = 5 * time.Second
+	}
+	a := &Alerts{
+		market:     market,
+		dispatcher: dispatcher,
+		killSwitch: killSwitch,
+		options:    options,
+		rejections: make(map[string][]time.Time),
+		stale:      make(map[string]bool),
+	}
+	if options.Fills {
+		market.OnFill(a.fill)
+	}
+	market.Fetcher.OnRejected = a.rejected
+	return a
+}
+
+// Watch checks the reference feeds and the kill switch until ctx is done.
+func (a *Alerts) Watch(ctx context.Context) {
+	if a.killSwitch != nil {
+		go a.watchKillSwitch(ctx)
+	}
+	for Sleep(ctx, a.options.CheckInterval) {
+		a.checkFeeds()
+	}
+}
+
+func (a *Alerts) fill(trades []pnl.Trade) {
+	first := trades[0]
+	amount, value, fee := decimal.Zero, decimal.Zero, decimal.Zero
+	for _, trade := range trades {
+		amount = amount.Add(trade.Amount)
+		value = value.Add(trade.Amount.Mul(trade.Price))
+		fee = fee.Add(trade.Fee)
+	}
+	fields := map[string]string{
+		"strategy": first.Strategy,
+		"amount":   amount.String() + " " + first.Base,
+		"price":    value.Div(amount).StringFixed(4) + " " + first.Quote,
+		"value":    value.StringFixed(2) + " " + first.Quote,
+		"fee":      fee.String() + " " + first.Quote,
+	}
+	if first.Share != "" {
+		fields["share"] = first.Share
+	}
+	a.dispatcher.Send(notify.Alert{
+		Kind:     notify.KIND_FILL,
+		Severity: notify.SEVERITY_INFO,
+		Title:    fmt.Sprintf("Filled %s %s %s", first.Side, amount, first.Pair),
+		Key:      first.Id,
+		Fields:   fields,
+	})
+}
+
+func (a *Alerts) rejected(method string, code payeer.ResponseErrorCode) {
+	if code != payeer.ERR_INSUFFICIENT_FUNDS {
+		return
+	}
+	a.mu.Lock()
+	now := time.Now()
+	times := append(a.rejections[method], now)
+	for len(times) > 0 && now.Sub(times[0]) > a.options.InsufficientFundsWindow {
+		times = times[1:]
+	}
+	a.rejections[method] = times
+	count := len(times)
+	a.mu.Unlock()
+	if count < a.options.InsufficientFunds {
+		return
+	}
+	a.dispatcher.Send(notify.Alert{
+		Kind:     notify.KIND_INSUFFICIENT_FUNDS,
+		Severity: notify.SEVERITY_WARNING,
+		Title:    fmt.Sprintf("Payeer answered %s %s %d times within %s", method, code, count, a.options.InsufficientFundsWindow),
+		Text:     "Balances may be out of sync or a deposit is missing.",
+		Key:      method,
+	})
+}
+
+// checkFeeds alerts once when a ticker goes stale and once when it recovers.
+func (a *Alerts) checkFeeds() {
+	for symbol, age := range a.market.TickerAges() {
+		stale := age > a.options.StaleFeed
+		a.mu.Lock()
+		changed := a.stale[string(symbol)] != stale
+		a.stale[string(symbol)] = stale
+		a.mu.Unlock()
+		switch {
+		case changed && stale:
+			a.dispatcher.Send(notify.Alert{
+				Kind:     notify.KIND_STALE_FEED,
+				Severity: notify.SEVERITY_WARNING,
+				Title:    fmt.Sprintf("Binance %s ticker is %s old", symbol, age.Round(time.Second)),
+				Key:      string(symbol),
+			})
+		case changed:
+			a.dispatcher.Send(notify.Alert{
+				Kind:     notify.KIND_STALE_FEED,
+				Severity: notify.SEVERITY_INFO,
+				Title:    fmt.Sprintf("Binance %s ticker recovered", symbol),
+				Key:      string(symbol) + "/recovered",
+			})
+		}
+	}
+}
+
+func (a *Alerts) watchKillSwitch(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-a.killSwitch.Tripped():
+		}
+		state := a.killSwitch.State()
+		a.dispatcher.Send(notify.Alert{
+			Kind:     notify.KIND_KILL_SWITCH,
+			Severity: notify.SEVERITY_CRITICAL,
+			Title:    "Kill switch tripped, all strategies stopped",
+			Text:     state.Reason,
+			Key:      state.Since.String(),
+			Fields:   map[string]string{"since": state.Since.Format(time.RFC3339)},
+		})
+		select {
+		case <-ctx.Done():
+			return
+		case <-a.killSwitch.Released():
+		}
+		a.dispatcher.Send(notify.Alert{
+			Kind:     notify.KIND_KILL_SWITCH,
+			Severity: notify.SEVERITY_WARNING,
+			Title:    "Kill switch reset, trading resumes",
+			Key:      "reset/" + state.Since.String(),
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package strategy
+
+import (
+	"automata/client/payeer"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// MinOrderLifetime is how long Payeer refuses to cancel a fresh order.
+const MinOrderLifetime = time.Minute
+
+// CancelOrder cancels orderId as soon as Payeer accepts it and retries until
+// the cancellation is acknowledged or ctx expires. An order that is filled or
+// cancelled already counts as acknowledged.
+func CancelOrder(ctx context.Context, api payeer.PrivateApi, orderId int, placed time.Time) error {
+	if wait := MinOrderLifetime - time.Since(placed); wait > 0 {
+		slog.Info("[Strategy] Waiting before the order can be cancelled", "orderId", orderId, "wait", wait)
+		if !Sleep(ctx, wait) {
+			return fmt.Errorf("order %d: %w", orderId, ctx.Err())
+		}
+	}
+	for {
+		rsp, err := api.CancelOrder(&payeer.CancelOrderRequest{OrderId: orderId})
+		switch {
+		case err != nil:
+			slog.Error("[Strategy] Cancel order HTTP error. Retrying...", "orderId", orderId, "error", err)
+		case rsp.Success:
+			slog.Info("[Strategy] Order canceled", "orderId", orderId)
+			return nil
+		case rsp.Error.Code == payeer.ERR_INVALID_STATUS_FOR_REFUND:
+			slog.Info("[Strategy] Order already closed", "orderId", orderId)
+			return nil
+		default:
+			slog.Error("[Strategy] Cancel order response error. Retrying...", "orderId", orderId, "error", rsp.Error)
+		}
+		if !Sleep(ctx, time.Second) {
+			return fmt.Errorf("order %d: %w", orderId, ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package strategy
+
+import (
+	"automata/client/payeer"
+	"automata/config"
+	"automata/reference"
+	"context"
+	"sync"
+	"time"
+)
+
+// Controllable is implemented by strategies the admin API can inspect and
+// steer while they run.
+type Controllable interface {
+	Strategy
+	// Quotes lists what the strategy quotes, with the orders it has open.
+	Quotes() []Quote
+	Balances() map[string]payeer.Balance
+	// Pauses holds the sides that must not place new orders.
+	Pauses() *Pauses
+	// CancelAll cancels every open order of the strategy. Its loops place new
+	// ones unless the sides are paused.
+	CancelAll(ctx context.Context) error
+	// RefreshBalances fetches the balances from the exchange again.
+	RefreshBalances()
+}
+
+// Quote is a share or pair side of a strategy; the order fields are empty
+// while it has no open order.
+type Quote struct {
+	Share   string        `json:"share,omitempty"`
+	Pair    payeer.Pair   `json:"pair"`
+	Action  payeer.Action `json:"action"`
+	OrderId int           `json:"orderId,omitempty"`
+	Price   string        `json:"price,omitempty"`
+	Amount  string        `json:"amount,omitempty"`
+	Placed  *time.Time    `json:"placed,omitempty"`
+}
+
+// Pauses switches the buy and sell side of a strategy off at runtime, on top of
+// the sides enabled in its config. Orders already open are left alone. The
+// zero value has nothing paused.
+type Pauses struct {
+	mu     sync.Mutex
+	paused map[payeer.Action]bool
+}
+
+func (p *Pauses) Paused(action payeer.Action) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused[action]
+}
+
+func (p *Pauses) Set(action payeer.Action, paused bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused == nil {
+		p.paused = make(map[payeer.Action]bool)
+	}
+	p.paused[action] = paused
+}
+
+// Snapshot returns the pause of both sides.
+func (p *Pauses) Snapshot() map[payeer.Action]bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return map[payeer.Action]bool{
+		payeer.ACTION_BUY:  p.paused[payeer.ACTION_BUY],
+		payeer.ACTION_SELL: p.paused[payeer.ACTION_SELL],
+	}
+}
+
+// Ticker is the latest Binance book ticker or reference price of a symbol.
+type Ticker struct {
+	Bid string          `json:"bid"`
+	Ask string          `json:"ask"`
+	Age config.Duration `json:"age"`
+	// Confidence and Quotes are set for a reference price.
+	Confidence string            `json:"confidence,omitempty"`
+	Quotes     []reference.Quote `json:"quotes,omitempty"`
+}
+
+// Tickers returns the latest Binance ticker of every subscribed symbol and
+// every reference price, which replaces the ticker of the same name.
+func (m *Market) Tickers() map[string]Ticker {
+	ages := m.TickerAges()
+	tickers := map[string]Ticker{}
+	for symbol, ticker := range m.binanceTickers.Clone() {
+		tickers[string(symbol)] = Ticker{Bid: ticker.BidPrice, Ask: ticker.AskPrice, Age: config.Duration(ages[symbol].Round(time.Millisecond))}
+	}
+	for _, name := range m.References.Names() {
+		price, _ := m.References.Price(name)
+		if price == nil {
+			continue
+		}
+		tickers[string(name)] = Ticker{
+			Bid:        price.Bid.String(),
+			Ask:        price.Ask.String(),
+			Age:        config.Duration(ages[name].Round(time.Millisecond)),
+			Confidence: price.Confidence.StringFixed(2),
+			Quotes:     price.Quotes,
+		}
+	}
+	return tickers
+}
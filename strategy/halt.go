@@ -0,0 +1,55 @@
+package strategy
+
+import (
+	"cmp"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Halt stops every strategy of the market from placing new orders until
+// Resume is called with the same reason. Resting orders stay in place and keep
+// being managed.
+func (m *Market) Halt(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.halts[reason]; ok {
+		return
+	}
+	if m.halts == nil {
+		m.halts = make(map[string]time.Time)
+	}
+	m.halts[reason] = time.Now()
+	slog.Error("[Market] Trading halted", "reason", reason)
+}
+
+// Resume lifts the halt of reason; trading stays off while other halts last.
+func (m *Market) Resume(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	since, ok := m.halts[reason]
+	if !ok {
+		return
+	}
+	delete(m.halts, reason)
+	if len(m.halts) > 0 {
+		slog.Info("[Market] Halt lifted, others remain", "reason", reason, "haltedFor", time.Since(since).Round(time.Second), "remaining", len(m.halts))
+		return
+	}
+	slog.Info("[Market] Trading resumed", "reason", reason, "haltedFor", time.Since(since).Round(time.Second))
+}
+
+// Halted reports whether placing orders is halted and why, oldest halt first.
+func (m *Market) Halted() (bool, string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	reasons := make([]string, 0, len(m.halts))
+	for reason := range m.halts {
+		reasons = append(reasons, reason)
+	}
+	slices.SortFunc(reasons, func(a, b string) int {
+		return cmp.Or(m.halts[a].Compare(m.halts[b]), cmp.Compare(a, b))
+	})
+	return len(reasons) > 0, strings.Join(reasons, "; ")
+}
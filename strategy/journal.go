@@ -0,0 +1,46 @@
+package strategy
+
+import (
+	"automata/client/binance"
+	"automata/config"
+	"automata/journal"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// SetJournal makes every strategy of the market record its order decisions in
+// j. Like SetState it must be called before any strategy is created.
+func (m *Market) SetJournal(j *journal.Journal) {
+	m.Journal = j
+}
+
+// JournalTicker returns the reference price or latest Binance ticker of symbol
+// and its age, or nil when none is available.
+func (m *Market) JournalTicker(symbol binance.Symbol) *journal.Ticker {
+	if m.References.Has(symbol) {
+		price, err := m.References.Price(symbol)
+		if err != nil {
+			return nil
+		}
+		return &journal.Ticker{
+			Symbol: symbol,
+			Bid:    price.Bid,
+			Ask:    price.Ask,
+			Age:    config.Duration(price.Age().Round(time.Millisecond)),
+		}
+	}
+	ticker, ok := m.binanceTickers.Get(symbol)
+	if !ok {
+		return nil
+	}
+	received, _ := m.tickerTimes.Get(symbol)
+	bid, _ := decimal.NewFromString(ticker.BidPrice)
+	ask, _ := decimal.NewFromString(ticker.AskPrice)
+	return &journal.Ticker{
+		Symbol: symbol,
+		Bid:    bid,
+		Ask:    ask,
+		Age:    config.Duration(time.Since(received).Round(time.Millisecond)),
+	}
+}
@@ -0,0 +1,243 @@
+package strategy
+
+import (
+	"automata/client/binance"
+	"automata/metrics"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type KillSwitchOptions struct {
+	// FlagFile trips the switch while it exists.
+	FlagFile string
+	// LossLimit trips the switch when the daily loss of the risk manager
+	// reaches it, by quote asset.
+	LossLimit map[string]decimal.Decimal
+	// FeedMaxAge trips the switch when a Binance ticker is older.
+	FeedMaxAge time.Duration
+	// MaxExchangeErrors trips the switch after that many failed exchange
+	// requests within ErrorWindow.
+	MaxExchangeErrors int
+	ErrorWindow       time.Duration
+	// CheckInterval between the flag, loss and feed checks; defaults to 1s.
+	CheckInterval time.Duration
+}
+
+// KillSwitchState is the latched state; it survives restarts through the
+// market's state store.
+type KillSwitchState struct {
+	Engaged bool      `json:"engaged"`
+	Reason  string    `json:"reason,omitempty"`
+	Since   time.Time `json:"since"`
+}
+
+// haltReason is the market halt the switch holds while engaged.
+func (s KillSwitchState) haltReason() string {
+	return "kill switch: " + s.Reason
+}
+
+// KillSwitch is a global circuit breaker. Once tripped, the Runner stops every
+// strategy and cancels their resting orders, and trading stays off until
+// Reset is called by hand.
+type KillSwitch struct {
+	market   *Market
+	options  *KillSwitchOptions
+	mu       sync.Mutex
+	state    KillSwitchState
+	tripped  chan struct{}
+	released chan struct{}
+	errors   []time.Time
+}
+
+const killSwitchKey = "killswitch"
+
+func NewKillSwitch(market *Market, options *KillSwitchOptions) *KillSwitch {
+	if options.CheckInterval == 0 {
+		options.CheckInterval = time.Second
+	}
+	if options.ErrorWindow == 0 {
+		options.ErrorWindow = time.Minute
+	}
+	k := &KillSwitch{
+		market:  market,
+		options: options,
+		tripped: make(chan struct{}),
+	}
+	if _, err := market.state.Get(killSwitchKey, &k.state); err != nil {
+		slog.Error("[KillSwitch] Loading the latched state failed", "error", err)
+	}
+	if k.state.Engaged {
+		slog.Warn("[KillSwitch] Engaged before the restart, trading stays off until reset", "reason", k.state.Reason, "since", k.state.Since)
+		close(k.tripped)
+		// Open until Reset, the Runner waits on it.
+		k.released = make(chan struct{})
+		market.Halt(k.state.haltReason())
+	} else {
+		k.state = KillSwitchState{}
+		k.released = make(chan struct{})
+		close(k.released)
+	}
+	market.Fetcher.OnError = k.ExchangeError
+	metrics.Register(k)
+	return k
+}
+
+// Trip engages the switch; it stays engaged until Reset.
+func (k *KillSwitch) Trip(reason string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.state.Engaged {
+		return
+	}
+	k.state = KillSwitchState{Engaged: true, Reason: reason, Since: time.Now()}
+	k.save()
+	close(k.tripped)
+	k.released = make(chan struct{})
+	slog.Error("[KillSwitch] Tripped, stopping all strategies and cancelling their orders", "reason", reason)
+	k.market.Halt(k.state.haltReason())
+}
+
+// Reset releases the switch. It refuses while the flag file still exists.
+func (k *KillSwitch) Reset() error {
+	if k.options.FlagFile != "" {
+		if _, err := os.Stat(k.options.FlagFile); err == nil {
+			return fmt.Errorf("flag file %s still exists, remove it first", k.options.FlagFile)
+		}
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !k.state.Engaged {
+		return nil
+	}
+	slog.Warn("[KillSwitch] Reset by hand, trading resumes", "reason", k.state.Reason, "engagedFor", time.Since(k.state.Since).Round(time.Second))
+	reason := k.state.haltReason()
+	k.state = KillSwitchState{}
+	k.errors = nil
+	k.save()
+	close(k.released)
+	k.tripped = make(chan struct{})
+	// Only the halt of the switch is lifted, a reconciler halt stays until
+	// the balances are back or it is resumed at /reconcile.
+	k.market.Resume(reason)
+	return nil
+}
+
+func (k *KillSwitch) save() {
+	if err := k.market.state.Put(killSwitchKey, k.state); err != nil {
+		slog.Error("[KillSwitch] Saving the latched state failed", "error", err)
+	}
+}
+
+func (k *KillSwitch) State() KillSwitchState {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.state
+}
+
+// Tripped is closed when the switch engages.
+func (k *KillSwitch) Tripped() <-chan struct{} {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.tripped
+}
+
+// Released is closed when the switch is reset.
+func (k *KillSwitch) Released() <-chan struct{} {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.released
+}
+
+// ExchangeError counts a failed exchange request.
+func (k *KillSwitch) ExchangeError(method string, err error) {
+	if k.options.MaxExchangeErrors <= 0 {
+		return
+	}
+	k.mu.Lock()
+	now := time.Now()
+	k.errors = append(k.errors, now)
+	i := 0
+	for i < len(k.errors) && now.Sub(k.errors[i]) > k.options.ErrorWindow {
+		i++
+	}
+	k.errors = k.errors[i:]
+	count := len(k.errors)
+	k.mu.Unlock()
+	if count >= k.options.MaxExchangeErrors {
+		k.Trip(fmt.Sprintf("%d exchange errors within %s, last %s: %v", count, k.options.ErrorWindow, method, err))
+	}
+}
+
+// Watch checks the flag file, the daily loss and the reference feed until ctx
+// is done.
+func (k *KillSwitch) Watch(ctx context.Context) {
+	for Sleep(ctx, k.options.CheckInterval) {
+		if reason := k.check(); reason != "" {
+			k.Trip(reason)
+		}
+	}
+}
+
+func (k *KillSwitch) check() string {
+	if k.options.FlagFile != "" {
+		if _, err := os.Stat(k.options.FlagFile); err == nil {
+			return "flag file " + k.options.FlagFile + " exists"
+		}
+	}
+	pnl := k.market.Risk.DailyPnL()
+	for quote, limit := range k.options.LossLimit {
+		if loss := pnl[quote].Neg(); limit.IsPositive() && loss.GreaterThanOrEqual(limit) {
+			return fmt.Sprintf("daily loss %s %s reached %s", loss, quote, limit)
+		}
+	}
+	if k.options.FeedMaxAge > 0 && !k.State().Engaged {
+		ages := k.market.TickerAges()
+		symbols := make([]string, 0, len(ages))
+		for symbol := range ages {
+			symbols = append(symbols, string(symbol))
+		}
+		sort.Strings(symbols)
+		for _, symbol := range symbols {
+			if age := ages[binance.Symbol(symbol)]; age > k.options.FeedMaxAge {
+				return fmt.Sprintf("Binance %s ticker is %s old", symbol, age.Round(time.Millisecond))
+			}
+		}
+	}
+	return ""
+}
+
+// ServeHTTP shows the state on GET and trips or resets the switch on POST with
+// action=trip (and an optional reason) or action=reset.
+func (k *KillSwitch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+	switch {
+	case r.Method == http.MethodGet:
+	case r.Method == http.MethodPost && r.URL.Query().Get("action") == "trip":
+		reason := r.URL.Query().Get("reason")
+		if reason == "" {
+			reason = "admin request"
+		}
+		k.Trip(reason)
+	case r.Method == http.MethodPost && r.URL.Query().Get("action") == "reset":
+		err = k.Reset()
+	default:
+		err = errors.New("use GET, or POST with action=trip or action=reset")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(k.State())
+}
@@ -0,0 +1,137 @@
+package strategy
+
+import (
+	"automata/risk"
+	"automata/statestore"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestKillSwitchCheck(t *testing.T) {
+	flagFile := filepath.Join(t.TempDir(), "stop")
+	tests := []struct {
+		name string
+		flag bool
+		// mark is the BTC_USDT mid after buying 1 BTC at 100.
+		mark  string
+		limit string
+		want  string
+	}{
+		{name: "nothing wrong", mark: "95", limit: "10"},
+		{name: "flag file", flag: true, mark: "100", limit: "10", want: "flag file " + flagFile + " exists"},
+		{name: "daily loss", mark: "90", limit: "10", want: "daily loss 10 USDT reached 10"},
+		{name: "no loss limit", mark: "50", limit: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.flag {
+				if err := os.WriteFile(flagFile, nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				defer os.Remove(flagFile)
+			}
+			market, _ := testMarket()
+			market.SetRisk(risk.NewManager(&risk.Limits{}))
+			market.Risk.RecordFill(risk.Fill{Base: "BTC", Quote: "USDT", Side: risk.SIDE_BUY, Amount: d("1"), Price: d("100")})
+			market.Risk.UpdateReference("BTC", "USDT", d(tt.mark), d(tt.mark))
+			k := NewKillSwitch(market, &KillSwitchOptions{
+				FlagFile:  flagFile,
+				LossLimit: map[string]decimal.Decimal{"USDT": d(tt.limit)},
+			})
+			if got := k.check(); got != tt.want {
+				t.Errorf("check = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKillSwitchExchangeErrors(t *testing.T) {
+	market, _ := testMarket()
+	k := NewKillSwitch(market, &KillSwitchOptions{MaxExchangeErrors: 2})
+
+	// An order the exchange does not know is an unexpected error response
+	if _, err := market.Fetcher.TryOrderDetails(42); err == nil {
+		t.Fatal("details of an unknown order returned no error")
+	}
+	if k.State().Engaged {
+		t.Fatal("engaged after one error")
+	}
+	market.Fetcher.TryOrderDetails(42)
+	state := k.State()
+	if !state.Engaged || !strings.HasPrefix(state.Reason, "2 exchange errors within 1m0s, last OrderStatus") {
+		t.Fatalf("state = %+v, want engaged by 2 errors", state)
+	}
+	select {
+	case <-k.Tripped():
+	default:
+		t.Error("Tripped not closed")
+	}
+	if halted, _ := market.Halted(); !halted {
+		t.Error("market not halted")
+	}
+}
+
+func TestKillSwitchLatchedAcrossRestarts(t *testing.T) {
+	state := statestore.NewMemory()
+	start := func() (*Market, *KillSwitch) {
+		market, _ := testMarket()
+		market.SetState(state)
+		return market, NewKillSwitch(market, &KillSwitchOptions{})
+	}
+
+	_, k := start()
+	k.Trip("test")
+
+	market, k := start()
+	if got := k.State(); !got.Engaged || got.Reason != "test" {
+		t.Fatalf("state after a restart = %+v, want engaged by test", got)
+	}
+	if halted, _ := market.Halted(); !halted {
+		t.Fatal("market not halted after a restart")
+	}
+	select {
+	case <-k.Released():
+		t.Fatal("released while engaged")
+	default:
+	}
+
+	if err := k.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if halted, reason := market.Halted(); halted {
+		t.Errorf("halted after the reset: %s", reason)
+	}
+	if _, k := start(); k.State().Engaged {
+		t.Error("engaged again after a reset and a restart")
+	}
+}
+
+func TestKillSwitchReset(t *testing.T) {
+	flagFile := filepath.Join(t.TempDir(), "stop")
+	market, _ := testMarket()
+	k := NewKillSwitch(market, &KillSwitchOptions{FlagFile: flagFile})
+	market.Halt("balance drift of USDT in test above threshold")
+
+	if err := os.WriteFile(flagFile, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	k.Trip("flag file")
+	if err := k.Reset(); err == nil {
+		t.Fatal("reset while the flag file exists")
+	}
+	os.Remove(flagFile)
+	if err := k.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if k.State().Engaged {
+		t.Error("engaged after the reset")
+	}
+	// The reconciler halt is not the switch's to lift
+	if _, reason := market.Halted(); reason != "balance drift of USDT in test above threshold" {
+		t.Errorf("halt reason = %q, want the drift left", reason)
+	}
+}
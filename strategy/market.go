@@ -0,0 +1,239 @@
+package strategy
+
+import (
+	"automata/client/binance"
+	"automata/client/payeer"
+	payeerFetcher "automata/client/payeer/fetcher"
+	"automata/journal"
+	"automata/metrics"
+	"automata/msync"
+	"automata/pnl"
+	"automata/reference"
+	"automata/risk"
+	"automata/statestore"
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Market holds the exchange clients and market data shared by all strategies
+// of a process, so that two strategies quoting the same pair do not open two
+// Binance streams or spend Payeer weight twice on the same data.
+type Market struct {
+	Payeer  payeer.Api
+	Fetcher *payeerFetcher.Fetcher
+	Binance *binance.Client
+	// Risk checks orders of every strategy, see SetRisk.
+	Risk *risk.Manager
+	// Ledger books the fills of every strategy.
+	Ledger *pnl.Ledger
+	// Journal records the order decisions of every strategy, see SetJournal.
+	Journal *journal.Journal
+	// References combines several venues into the reference prices
+	// strategies quote against, see SetReferences.
+	References *reference.Service
+	// HedgeFeePercent is the taker fee of the hedge venue the target edge of
+	// the strategies is priced with.
+	HedgeFeePercent decimal.Decimal
+
+	// state persists order ownership, fills and balances, see SetState.
+	state  statestore.Store
+	orders *statestore.Bucket[OrderRecord]
+	fills  *statestore.Bucket[FillRecord]
+
+	mu             sync.Mutex
+	info           *payeer.InfoResponse
+	binanceTickers *msync.MuMap[binance.Symbol, binance.OrderBookTickerStreamResult]
+	subscribed     map[binance.Symbol]time.Duration
+	tickerTimes    *msync.MuMap[binance.Symbol, time.Time]
+	fillRecorded   chan struct{}
+	fillHooks      []func(trades []pnl.Trade)
+	// halts holds when each halt reason was raised.
+	halts map[string]time.Time
+}
+
+func NewMarket(payeerClient payeer.Api, binanceClient *binance.Client) *Market {
+	m := &Market{
+		Payeer:         payeerClient,
+		Fetcher:        payeerFetcher.NewFetcher(payeerClient),
+		Binance:        binanceClient,
+		binanceTickers: msync.NewMuMap[binance.Symbol, binance.OrderBookTickerStreamResult](),
+		subscribed:     make(map[binance.Symbol]time.Duration),
+		tickerTimes:    msync.NewMuMap[binance.Symbol, time.Time](),
+		fillRecorded:   make(chan struct{}, 1),
+		Ledger:         pnl.NewLedger(),
+	}
+	m.SetState(statestore.NewMemory())
+	metrics.Register(m)
+	return m
+}
+
+// SetRisk makes every order path of the market go through manager. Like
+// SetState it must be called before any strategy is created.
+func (m *Market) SetRisk(manager *risk.Manager) {
+	m.Risk = manager
+	m.Fetcher.Risk = manager
+}
+
+// SetState replaces the in-memory state store, typically with a file store so
+// that strategies adopt their orders after a restart. It must be called before
+// any strategy is initialized.
+func (m *Market) SetState(state statestore.Store) {
+	m.state = state
+	m.orders = statestore.NewBucket[OrderRecord](state, "orders")
+	m.fills = statestore.NewBucket[FillRecord](state, "fills")
+	m.replayFills()
+}
+
+// Info returns the Payeer pair info, fetched once per process.
+func (m *Market) Info() *payeer.InfoResponse {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.info == nil {
+		m.info = m.Fetcher.Info()
+	}
+	return m.info
+}
+
+// BinanceTickers subscribes to the book ticker of symbol unless a subscription
+// exists already and returns the map all tickers are kept in. A later caller
+// asking for a shorter interval than the existing stream gets the existing one.
+func (m *Market) BinanceTickers(symbol binance.Symbol, interval time.Duration) *msync.MuMap[binance.Symbol, binance.OrderBookTickerStreamResult] {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if existing, ok := m.subscribed[symbol]; ok {
+		if interval < existing {
+			slog.Warn("[Market] Binance ticker already subscribed with a longer interval", "symbol", symbol, "interval", existing, "requested", interval)
+		}
+		return m.binanceTickers
+	}
+	m.subscribed[symbol] = interval
+	m.tickerTimes.Set(symbol, time.Now())
+	ch := m.Binance.SubscribeTicker(symbol, interval)
+	go func() {
+		base, quote, known := risk.SplitSymbol(string(symbol))
+		for ticker := range ch {
+			m.binanceTickers.Set(symbol, ticker)
+			m.tickerTimes.Set(symbol, time.Now())
+			if known {
+				bid, _ := decimal.NewFromString(ticker.BidPrice)
+				ask, _ := decimal.NewFromString(ticker.AskPrice)
+				m.Risk.UpdateReference(base, quote, bid, ask)
+				m.Ledger.UpdateMark(base, quote, bid, ask)
+			}
+		}
+	}()
+	return m.binanceTickers
+}
+
+// SetReferences makes the reference prices of options replace the Binance
+// ticker of their name wherever a strategy looks it up, and subscribes to
+// their streamed sources; the Payeer sources are polled while
+// References.Run runs. Like SetState it must be called before any strategy is
+// created.
+func (m *Market) SetReferences(options *reference.Options) {
+	m.References = reference.NewService(options, m.Binance, m.Fetcher, m.binanceTicker)
+	for _, symbol := range m.References.Symbols(reference.SOURCE_BINANCE_TICKER) {
+		m.BinanceTickers(binance.Symbol(symbol), 0)
+	}
+	m.References.Start()
+	go m.markReferences()
+}
+
+// markReferences feeds the reference prices to the risk collar and the PnL
+// marks like the Binance tickers, so that a pair without a Binance symbol,
+// such as BTC_RUB, is collared and marked by its synthetic reference.
+func (m *Market) markReferences() {
+	for range time.Tick(time.Second) {
+		for _, name := range m.References.Names() {
+			base, quote, known := risk.SplitSymbol(string(name))
+			if !known {
+				continue
+			}
+			if price, err := m.References.Price(name); err == nil {
+				m.Risk.UpdateReference(base, quote, price.Bid, price.Ask)
+				m.Ledger.UpdateMark(base, quote, price.Bid, price.Ask)
+			}
+		}
+	}
+}
+
+func (m *Market) binanceTicker(symbol binance.Symbol) (binance.OrderBookTickerStreamResult, time.Time, bool) {
+	ticker, ok := m.binanceTickers.Get(symbol)
+	received, _ := m.tickerTimes.Get(symbol)
+	return ticker, received, ok
+}
+
+// SubscribeReference subscribes to the Binance book ticker of symbol unless a
+// reference price of that name replaces it.
+func (m *Market) SubscribeReference(symbol binance.Symbol, interval time.Duration) {
+	if !m.References.Has(symbol) {
+		m.BinanceTickers(symbol, interval)
+	}
+}
+
+// ReferenceTickers returns the lookup every reference is read through: the
+// reference price when a symbol names one, the Binance book ticker otherwise.
+// Symbols are subscribed with SubscribeReference.
+func (m *Market) ReferenceTickers() payeer.Tickers {
+	return marketTickers{m}
+}
+
+type marketTickers struct {
+	market *Market
+}
+
+func (t marketTickers) Get(symbol binance.Symbol) (binance.OrderBookTickerStreamResult, bool) {
+	if t.market.References.Has(symbol) {
+		return t.market.References.Get(symbol)
+	}
+	return t.market.binanceTickers.Get(symbol)
+}
+
+// TickerAges returns how long ago each subscribed Binance ticker was
+// received, or since it was subscribed when none arrived yet, and the age of
+// every reference price.
+func (m *Market) TickerAges() map[binance.Symbol]time.Duration {
+	ages := map[binance.Symbol]time.Duration{}
+	for symbol, received := range m.tickerTimes.Clone() {
+		ages[symbol] = time.Since(received)
+	}
+	if m.References != nil {
+		for name, age := range m.References.Ages() {
+			ages[name] = age
+		}
+	}
+	return ages
+}
+
+// OpenOrders fetches every open order of the account by ID, retrying until
+// Payeer answers or ctx expires.
+func (m *Market) OpenOrders(ctx context.Context) (map[int]payeer.MyOrdersOrder, error) {
+	for {
+		rsp, err := m.Payeer.MyOrders(&payeer.MyOrdersRequest{})
+		switch {
+		case err != nil:
+			slog.Error("[Market] MyOrders HTTP error. Retrying...", "error", err)
+		case !rsp.Success:
+			slog.Error("[Market] MyOrders response error. Retrying...", "error", rsp.Error)
+		default:
+			orders := make(map[int]payeer.MyOrdersOrder, len(rsp.Orders))
+			for _, order := range rsp.Orders {
+				id, err := strconv.Atoi(order.Id)
+				if err != nil {
+					return nil, fmt.Errorf("order id %q: %w", order.Id, err)
+				}
+				orders[id] = order
+			}
+			return orders, nil
+		}
+		if !Sleep(ctx, time.Second) {
+			return nil, fmt.Errorf("my orders: %w", ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package markettrader
+
+import (
+	"automata/client/binance"
+	"automata/client/payeer"
+	"automata/config"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type Config struct {
+	Pairs                 map[payeer.Pair]binance.Symbol `json:"pairs" desc:"Payeer pairs to trade and their Binance symbols or reference names, e.g. BTCRUB"`
+	BinanceTickerInterval config.Duration                `json:"binanceTickerInterval"`
+	TradeLoopInterval     config.Duration                `json:"tradeLoopInterval"`
+	BidMinRatio           decimal.Decimal                `json:"bidMinRatio" desc:"sell into bids priced above this ratio to the Binance bid"`
+	AskMaxRatio           decimal.Decimal                `json:"askMaxRatio" desc:"buy asks priced below this ratio to the Binance ask"`
+	MaxBuyAmount          decimal.Decimal                `json:"maxBuyAmount" desc:"cap on a single buy in base asset, 0 disables the cap"`
+	QuoteMult             decimal.Decimal                `json:"quoteMult" desc:"divides the affordable buy amount, 1 has no effect"`
+	PlaceOrders           bool                           `json:"placeOrders,omitempty" desc:"send the market orders, to the paper exchange in paper mode; off, the trader only logs them"`
+}
+
+func (c *Config) Validate(problems *config.Problems, path string) {
+	if len(c.Pairs) == 0 {
+		problems.Add(path+".pairs", "at least one pair is required")
+	}
+	for pair, symbol := range c.Pairs {
+		problems.Pair(path+".pairs", pair)
+		problems.Symbol(path+".pairs."+string(pair), symbol)
+	}
+	problems.Interval(path+".binanceTickerInterval", c.BinanceTickerInterval, 10*time.Millisecond)
+	problems.Interval(path+".tradeLoopInterval", c.TradeLoopInterval, time.Second)
+	problems.NotNegative(path+".bidMinRatio", c.BidMinRatio)
+	problems.Positive(path+".askMaxRatio", c.AskMaxRatio)
+	problems.NotNegative(path+".maxBuyAmount", c.MaxBuyAmount)
+	problems.DecimalRange(path+".quoteMult", c.QuoteMult, "1", "1.1")
+}
+
+func (c *Config) Options() *Options {
+	return &Options{
+		Pairs:                 c.Pairs,
+		BinanceTickerInterval: c.BinanceTickerInterval.D(),
+		TradeLoopInterval:     c.TradeLoopInterval.D(),
+		BidMinRatio:           c.BidMinRatio,
+		AskMaxRatio:           c.AskMaxRatio,
+		MaxBuyAmount:          c.MaxBuyAmount,
+		QuoteMult:             c.QuoteMult,
+		PlaceOrders:           c.PlaceOrders,
+	}
+}
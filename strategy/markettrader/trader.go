@@ -0,0 +1,428 @@
+package markettrader
+
+import (
+	"automata/client/binance"
+	"automata/client/payeer"
+	"automata/journal"
+	"automata/metrics"
+	"automata/msync"
+	"automata/risk"
+	"automata/strategy"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type Options struct {
+	// Name attributes the fills of this trader; defaults to "market-trader".
+	Name                  string
+	Pairs                 map[payeer.Pair]binance.Symbol
+	BinanceTickerInterval time.Duration
+	TradeLoopInterval     time.Duration
+	BidMinRatio           decimal.Decimal
+	AskMaxRatio           decimal.Decimal
+	MaxBuyAmount          decimal.Decimal
+	QuoteMult             decimal.Decimal
+	// PlaceOrders sends the market orders; without it the trader only logs
+	// the orders it would place.
+	PlaceOrders bool
+}
+
+type Trader struct {
+	market           *strategy.Market
+	binanceClient    *binance.Client
+	minWeights       *msync.Mu[int]
+	info             *payeer.InfoResponse
+	balance          *msync.MuMap[string, payeer.Balance]
+	orders           *msync.MuMap[payeer.Pair, payeer.PairsOrderInfo]
+	weightsTimestamp *msync.Mu[time.Time]
+	binanceTickers   payeer.Tickers
+	options          *msync.Mu[*Options]
+	pauses           strategy.Pauses
+}
+
+var _ strategy.Strategy = (*Trader)(nil)
+
+func NewTrader(m *strategy.Market, o *Options) *Trader {
+	if o.Name == "" {
+		o.Name = "market-trader"
+	}
+	for _, symbol := range o.Pairs {
+		m.SubscribeReference(symbol, o.BinanceTickerInterval)
+	}
+	binanceTickers := m.ReferenceTickers()
+	s := &Trader{
+		market:           m,
+		binanceClient:    m.Binance,
+		options:          msync.NewMu(o),
+		minWeights:       msync.NewMu[int](600),
+		weightsTimestamp: msync.NewMu[time.Time](time.Now()),
+		binanceTickers:   binanceTickers,
+		orders:           msync.NewMuMap[payeer.Pair, payeer.PairsOrderInfo](),
+		balance:          msync.NewMuMap[string, payeer.Balance](),
+	}
+	metrics.Register(s)
+	return s
+}
+
+func (s *Trader) Init(ctx context.Context) error {
+	s.resetInfo()
+	return s.fetchAndUpdateBalance()
+}
+
+// Run returns the first failed exchange request of any loop; the other loops
+// stop with it.
+func (s *Trader) Run(ctx context.Context) error {
+	loops := []func(ctx context.Context) error{s.fetchOrdersLoop}
+	for _, pair := range s.getPairs() {
+		for _, action := range []payeer.Action{payeer.ACTION_BUY, payeer.ACTION_SELL} {
+			loops = append(loops, func(ctx context.Context) error { return s.tradeLoop(ctx, pair, action) })
+		}
+	}
+	return strategy.RunLoops(ctx, loops...)
+}
+
+// Stop has nothing to cancel, market orders never rest on the book.
+func (s *Trader) Stop(ctx context.Context) error {
+	return nil
+}
+
+func (s *Trader) Status() strategy.Status {
+	return strategy.Status{
+		OpenOrders: []int{},
+		Details:    map[string]any{"weightRemaining": s.minWeights.Get()},
+	}
+}
+
+var _ strategy.Reconcilable = (*Trader)(nil)
+var _ strategy.Controllable = (*Trader)(nil)
+
+func (s *Trader) Balances() map[string]payeer.Balance {
+	return s.balance.Clone()
+}
+
+// Quotes lists the pairs and sides the trader takes; market orders never
+// rest, so there are no open orders.
+func (s *Trader) Quotes() []strategy.Quote {
+	quotes := []strategy.Quote{}
+	for _, pair := range s.getPairs() {
+		for _, action := range []payeer.Action{payeer.ACTION_BUY, payeer.ACTION_SELL} {
+			quotes = append(quotes, strategy.Quote{Pair: pair, Action: action})
+		}
+	}
+	return quotes
+}
+
+func (s *Trader) Pauses() *strategy.Pauses {
+	return &s.pauses
+}
+
+// CancelAll has nothing to cancel: market orders never rest.
+func (s *Trader) CancelAll(ctx context.Context) error {
+	return nil
+}
+
+func (s *Trader) RefreshBalances() {
+	if err := s.fetchAndUpdateBalance(); err != nil {
+		slog.Error("[PayeerMarketTrader] Fetching balances failed", "error", err)
+	}
+}
+
+// Collect exports the balances.
+func (s *Trader) Collect(sink *metrics.Sink) {
+	strategy.CollectBalances(sink, s.options.Get().Name, s.Balances())
+}
+
+// ReconcileOrder has nothing to drop: market orders are never tracked.
+func (s *Trader) ReconcileOrder(order *payeer.OrderDetails) {}
+
+func (s *Trader) ReconcileBalances(balances map[string]payeer.Balance) {
+	for asset, balance := range balances {
+		// Assets already cached are overwritten even when emptied, so they
+		// do not keep drifting
+		if _, ok := s.balance.Get(asset); ok || balance.Available > 0 {
+			s.balance.Set(asset, balance)
+		}
+	}
+	slog.Info("[PayeerMarketTrader] Balances reconciled")
+}
+
+// Reconfigure swaps in new ratios, amounts and the loop interval; each trade
+// loop picks them up at its next iteration. Pairs and the Binance ticker
+// interval are wired up at startup and need a restart.
+func (s *Trader) Reconfigure(o *Options) error {
+	current := s.options.Get()
+	if !maps.Equal(current.Pairs, o.Pairs) || current.BinanceTickerInterval != o.BinanceTickerInterval {
+		return errors.New("pairs or Binance ticker interval changed, restart required")
+	}
+	o.Name = current.Name
+	s.options.Set(o)
+	slog.Info("[PayeerMarketTrader] Options reconfigured")
+	return nil
+}
+
+func (s *Trader) tradeLoop(ctx context.Context, pair payeer.Pair, action payeer.Action) error {
+	for {
+		if !strategy.Sleep(ctx, s.options.Get().TradeLoopInterval) {
+			return nil
+		}
+		options := s.options.Get()
+
+		// Getting cached binance tickers data for the symbol
+		binanceTickersData, ok := s.binanceTickers.Get(options.Pairs[pair])
+		if !ok {
+			slog.Error("[PayeerMarketTrader] No binance ticker cached for", "symbol", options.Pairs[pair])
+			continue
+		}
+
+		// Getting cached payeer orders data for the pair
+		ordersData, ok := s.orders.Get(pair)
+		if !ok {
+			slog.Error("[PayeerMarketTrader] No orders cached for", "pair", pair)
+			continue
+		}
+
+		// Resolving action-specific data based on the action
+		var orders []payeer.OrdersOrder
+		var doesPriceSatisfy func(price decimal.Decimal) bool
+		if action == payeer.ACTION_BUY {
+			orders = ordersData.Asks
+			binanceAskPrice := decimal.RequireFromString(binanceTickersData.AskPrice)
+			doesPriceSatisfy = func(price decimal.Decimal) bool {
+				return price.Div(binanceAskPrice).LessThan(options.AskMaxRatio)
+			}
+		} else {
+			orders = ordersData.Bids
+			binanceBidPrice := decimal.RequireFromString(binanceTickersData.BidPrice)
+			doesPriceSatisfy = func(price decimal.Decimal) bool {
+				return price.Div(binanceBidPrice).GreaterThan(options.BidMinRatio)
+			}
+		}
+
+		// Summing up the satisfying orders amounts to get the total amount
+		totalAmount := decimal.Zero
+		satisfyingOrders := []payeer.OrdersOrder{}
+		for _, order := range orders {
+			orderPrice := decimal.RequireFromString(order.Price)
+			if doesPriceSatisfy(orderPrice) {
+				orderAmount := decimal.RequireFromString(order.Amount)
+				totalAmount = totalAmount.Add(orderAmount)
+				satisfyingOrders = append(satisfyingOrders, order)
+			}
+		}
+
+		if totalAmount.IsZero() {
+			slog.Info("[PayeerMarketTrader] Total amount is zero", "pair", pair, "action", action)
+			continue
+		}
+
+		// Resolving the amount of an order to be placed based on the total amount, balance and configuration
+		var orderAmount decimal.Decimal
+		if action == payeer.ACTION_BUY {
+			// Getting cached balance for the quote
+			quoteBalance, ok := s.balance.Get(pair.Quote())
+			if !ok {
+				slog.Info("[PayeerMarketTrader] Zero quote balance for", "quote", pair.Quote())
+				continue
+			}
+
+			// Resolving orderAmount based on the quote balance
+			quoteBalanceAvailable := decimal.NewFromFloat(quoteBalance.Available)
+			orderAmount = decimal.Zero
+			for _, order := range satisfyingOrders {
+				value := decimal.RequireFromString(order.Value)
+				price := decimal.RequireFromString(order.Price)
+				orderQuote := decimal.Min(quoteBalanceAvailable, value)
+				// The amount from the order is adjusted by multiplying the order price by QuoteMult
+				orderAmount = orderAmount.Add(orderQuote.Div(price.Mul(options.QuoteMult)))
+				quoteBalanceAvailable = quoteBalanceAvailable.Sub(orderQuote)
+				if quoteBalanceAvailable.IsZero() {
+					break
+				}
+			}
+
+			// Fixing the precision
+			orderAmount = s.info.PairInfo(pair).RoundAmount(orderAmount)
+
+			slog.Info("[PayeerMarketTrader] Order amount calculated", "pair", pair, "action", action, "orderAmount", orderAmount.String(), "quoteBalance", quoteBalance.Available)
+
+			// Restricting the order amount if MaxBuyAmount is positivie
+			if options.MaxBuyAmount.IsPositive() {
+				orderAmount = decimal.Min(orderAmount, options.MaxBuyAmount)
+				slog.Info("[PayeerMarketTrader] Order amount restricted", "orderAmount", orderAmount, "maxBuyAmount", options.MaxBuyAmount.String())
+			}
+		} else {
+			// Getting cached balance for the base
+			baseBalance, ok := s.balance.Get(pair.Base())
+			if !ok {
+				slog.Info("[PayeerMarketTrader] Zero base balance for", "base", pair.Base())
+				continue
+			}
+
+			// Resolving orderAmount based on the base balance
+			baseBalanceAvailable := decimal.NewFromFloat(baseBalance.Available)
+			orderAmount = decimal.Min(totalAmount, baseBalanceAvailable)
+		}
+
+		// Checking against the minimum amount for the pair
+		minAmount := decimal.NewFromFloat(s.info.Pairs[pair].MinAmount)
+		if orderAmount.LessThan(minAmount) {
+			slog.Info("[PayeerMarketTrader] Order amount less than minAmount", "minAmount", minAmount.String(), "orderAmount", orderAmount.String())
+			continue
+		}
+
+		// Placing the order
+		if halted, reason := s.market.Halted(); halted {
+			slog.Info("[PayeerMarketTrader] Trading halted, not placing", "pair", pair, "action", action, "reason", reason)
+			continue
+		}
+		if s.pauses.Paused(action) {
+			slog.Info("[PayeerMarketTrader] Side paused, not placing", "pair", pair, "action", action)
+			continue
+		}
+		slog.Info("[PayeerMarketTrader] Market order should be placed", "pair", pair, "action", action, "amount", orderAmount.String(), "satisfying orders", satisfyingOrders)
+		if !options.PlaceOrders {
+			continue
+		}
+		// The last satisfying order has the worst price the market order may fill at
+		worstPrice := satisfyingOrders[len(satisfyingOrders)-1].Price
+		// A market order takes the opposite side, so the journal keeps those levels
+		entry := &journal.Entry{
+			Strategy: options.Name,
+			Pair:     pair,
+			Action:   action,
+			Decision: journal.DECISION_PLACE,
+			Book:     orders,
+			Ticker:   s.market.JournalTicker(options.Pairs[pair]),
+			Price:    decimal.RequireFromString(worstPrice),
+			Amount:   orderAmount,
+			PairInfo: s.info.PairInfo(pair),
+		}
+		if err := s.market.Risk.CheckOrder(risk.PayeerOrder(action, pair, orderAmount.String(), worstPrice)); err != nil {
+			entry.Result.Error = err.Error()
+			s.market.Journal.Append(entry)
+			continue
+		}
+		rsp, err := s.placeMarketOrder(action, pair, orderAmount.String())
+		if err != nil {
+			entry.Result.Error = err.Error()
+			s.market.Journal.Append(entry)
+			return err
+		}
+		strategy.CountPlacement(options.Name, string(pair)+"/"+string(action), rsp.Success)
+		entry.Result = journal.Result{Accepted: rsp.Success, OrderId: rsp.OrderId, Error: string(rsp.Error.Code)}
+		s.market.Journal.Append(entry)
+		if !rsp.Success {
+			continue
+		}
+		slog.Info("[PayeerMarketTrader] Market order placed", "orderId", rsp.OrderId, "details", rsp.Params)
+		order, err := s.market.Fetcher.TryOrderDetails(rsp.OrderId)
+		if err != nil {
+			return fmt.Errorf("order %d status: %w", rsp.OrderId, err)
+		}
+		// The recorded fill triggers a reconcile, which compares balances
+		if err := s.fetchAndUpdateBalance(); err != nil {
+			return err
+		}
+		s.market.RecordFill(options.Name, "", order)
+	}
+}
+
+func (s *Trader) fetchOrdersLoop(ctx context.Context) error {
+	pairs := s.getPairs()
+	for ctx.Err() == nil {
+		orders, err := s.fetchOrders(pairs)
+		if err != nil {
+			return err
+		}
+		for p, o := range orders {
+			s.orders.Set(p, o)
+		}
+	}
+	return nil
+}
+
+func (s *Trader) fetchAndUpdateBalance() error {
+	balances, err := s.fetchBalance()
+	if err != nil {
+		return err
+	}
+	for asset, balance := range balances {
+		if balance.Available > 0 {
+			s.balance.Set(asset, balance)
+			slog.Info("[PayeerMarketTrader] Balance update:", "asset", asset, "balance", balance)
+		}
+	}
+	return nil
+}
+
+func (s *Trader) resetInfo() {
+	s.info = s.market.Info()
+}
+
+// placeMarketOrder sends the order once; the risk check is done by the caller.
+func (s *Trader) placeMarketOrder(action payeer.Action, pair payeer.Pair, amount string) (*payeer.PostOrderResponse, error) {
+	rsp, err := s.market.Fetcher.TryPlaceOrder(&payeer.PostOrderRequest{
+		Pair:   pair,
+		Type:   payeer.ORDER_TYPE_MARKET,
+		Action: action,
+		Amount: amount,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("place %s %s: %w", action, pair, err)
+	}
+	s.updateWeights(10)
+	if !rsp.Success {
+		slog.Error("[PayeerMarketTrader] Place order response error", "response", rsp)
+		return rsp, nil
+	}
+	slog.Info("[PayeerMarketTrader] Order placed:", "order", rsp)
+	return rsp, nil
+}
+
+func (s *Trader) fetchOrders(pairs []payeer.Pair) (map[payeer.Pair]payeer.PairsOrderInfo, error) {
+	orders, err := s.market.Fetcher.TryOrders(pairs)
+	if err != nil {
+		return nil, fmt.Errorf("orders: %w", err)
+	}
+	s.updateWeights(len(pairs))
+	return orders, nil
+}
+
+func (s *Trader) fetchBalance() (map[string]payeer.Balance, error) {
+	balances, err := s.market.Fetcher.TryBalance()
+	if err != nil {
+		return nil, fmt.Errorf("balance: %w", err)
+	}
+	s.updateWeights(10)
+	slog.Debug("[PayeerMarketTrader] Payeer balance fetched", "balance", balances)
+	return balances, nil
+}
+
+func (s *Trader) updateWeights(count int) {
+	now := time.Now()
+	if now.Sub(s.weightsTimestamp.Get()).Minutes() > 1 {
+		s.weightsTimestamp.Set(now)
+		s.minWeights.Set(600 - count)
+		return
+	} else {
+		s.minWeights.Update(func(value int) int {
+			return value - count
+		})
+	}
+	slog.Info("[PayeerMarketTrader] Weights info", "remaining/min", s.minWeights.Get())
+}
+
+func (s *Trader) getPairs() []payeer.Pair {
+	options := s.options.Get()
+	pairs := make([]payeer.Pair, 0, len(options.Pairs))
+	for pair := range options.Pairs {
+		pairs = append(pairs, pair)
+	}
+	return pairs
+}
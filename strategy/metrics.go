@@ -0,0 +1,91 @@
+package strategy
+
+import (
+	"automata/client/payeer"
+	"automata/metrics"
+	"automata/pnl"
+)
+
+var (
+	placements = metrics.NewCounterVec("strategy_order_placements_total", "Order placements by strategy, loop and whether Payeer accepted them.", "strategy", "loop", "result")
+	cancels    = metrics.NewCounterVec("strategy_order_cancels_total", "Order cancels by strategy, loop and whether Payeer accepted them.", "strategy", "loop", "result")
+)
+
+func init() {
+	metrics.Register(placements)
+	metrics.Register(cancels)
+}
+
+// CountPlacement counts an order placement of a strategy loop, e.g. the share
+// ID or "BTC_USDT/buy".
+func CountPlacement(strategy, loop string, accepted bool) {
+	placements.Inc(strategy, loop, result(accepted))
+}
+
+// CountCancel counts an order cancel of a strategy loop.
+func CountCancel(strategy, loop string, accepted bool) {
+	cancels.Inc(strategy, loop, result(accepted))
+}
+
+func result(accepted bool) string {
+	if accepted {
+		return "accepted"
+	}
+	return "rejected"
+}
+
+// Collect exports the market data age, the halt, the risk inventory and the
+// PnL ledger.
+func (m *Market) Collect(sink *metrics.Sink) {
+	for symbol, age := range m.TickerAges() {
+		sink.Gauge("market_ticker_age_seconds", "Time since the last Binance ticker by symbol.", age.Seconds(), "symbol", string(symbol))
+	}
+	halted, _ := m.Halted()
+	sink.Gauge("market_halted", "1 while trading is halted.", boolValue(halted))
+	for asset, amount := range m.Risk.Inventory() {
+		sink.Gauge("risk_inventory", "Net amount bought since the process started by asset.", amount.InexactFloat64(), "asset", asset)
+	}
+	for quote, amount := range m.Risk.DailyPnL() {
+		sink.Gauge("risk_daily_pnl", "PnL of the UTC day by quote asset, marked to the reference mid.", amount.InexactFloat64(), "quote", quote)
+	}
+	for _, row := range m.Ledger.Report(pnl.GROUP_SHARE) {
+		labels := []string{"strategy", row.Strategy, "share", row.Share, "pair", row.Pair}
+		sink.Gauge("pnl_position", "Open position in the base asset.", row.Position.InexactFloat64(), labels...)
+		sink.Gauge("pnl_fees", "Fees paid in the quote asset.", row.Fees.InexactFloat64(), labels...)
+		sink.Gauge("pnl_realised", "Realised PnL in the quote asset by cost method, fees included.", row.RealisedFifo.InexactFloat64(), append(labels, "method", "fifo")...)
+		sink.Gauge("pnl_realised", "Realised PnL in the quote asset by cost method, fees included.", row.RealisedAvg.InexactFloat64(), append(labels, "method", "avg")...)
+		if row.Marked {
+			sink.Gauge("pnl_unrealised", "Unrealised PnL in the quote asset by cost method.", row.UnrealisedFifo.InexactFloat64(), append(labels, "method", "fifo")...)
+			sink.Gauge("pnl_unrealised", "Unrealised PnL in the quote asset by cost method.", row.UnrealisedAvg.InexactFloat64(), append(labels, "method", "avg")...)
+		}
+	}
+}
+
+// Collect exports whether the kill switch is engaged.
+func (k *KillSwitch) Collect(sink *metrics.Sink) {
+	sink.Gauge("killswitch_engaged", "1 while the kill switch is engaged.", boolValue(k.State().Engaged))
+}
+
+// Collect exports the state and open orders of every strategy.
+func (r *Runner) Collect(sink *metrics.Sink) {
+	for _, status := range r.Statuses() {
+		sink.Gauge("strategy_open_orders", "Orders a strategy tracks as open.", float64(len(status.OpenOrders)), "strategy", status.Name)
+		sink.Gauge("strategy_state", "1 for the current lifecycle state of a strategy.", 1, "strategy", status.Name, "state", string(status.State))
+	}
+}
+
+// CollectBalances exports the balances a strategy works with, which make up
+// its inventory.
+func CollectBalances(sink *metrics.Sink, strategy string, balances map[string]payeer.Balance) {
+	for asset, balance := range balances {
+		sink.Gauge("strategy_balance_available", "Available balance a strategy works with by asset.", balance.Available, "strategy", strategy, "asset", asset)
+		sink.Gauge("strategy_balance_total", "Total balance incl. held amounts a strategy works with by asset.", balance.Total, "strategy", strategy, "asset", asset)
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,153 @@
+package strategy
+
+import (
+	"automata/client/payeer"
+	"automata/pnl"
+	"automata/risk"
+	"cmp"
+	"context"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// OrderRecord is what the state store keeps about an order a strategy placed,
+// so that the strategy can adopt it again after a restart.
+type OrderRecord struct {
+	OrderId  int                `json:"orderId"`
+	Strategy string             `json:"strategy"`
+	Share    string             `json:"share,omitempty"`
+	Params   payeer.OrderParams `json:"params"`
+	Placed   time.Time          `json:"placed"`
+	// ReferencePrice is the Binance price the order was placed against.
+	ReferencePrice decimal.Decimal `json:"referencePrice"`
+	// Trace follows the order through the logs, see logging.NewTraceId.
+	Trace string `json:"trace,omitempty"`
+}
+
+// FillRecord is the last known state of a closed order.
+type FillRecord struct {
+	Strategy string              `json:"strategy"`
+	Share    string              `json:"share,omitempty"`
+	Order    payeer.OrderDetails `json:"order"`
+	Recorded time.Time           `json:"recorded"`
+}
+
+// TrackOrder records that the order belongs to record.Strategy.
+func (m *Market) TrackOrder(record OrderRecord) {
+	m.orders.Set(strconv.Itoa(record.OrderId), record)
+}
+
+// ForgetOrder drops the record of an order that was cancelled or filled.
+func (m *Market) ForgetOrder(orderId int) {
+	m.orders.Delete(strconv.Itoa(orderId))
+}
+
+// RecordFill keeps the final details of an order with any executed amount.
+func (m *Market) RecordFill(strategy, share string, order *payeer.OrderDetails) {
+	if order == nil || decimal.RequireFromString(order.AmountProcessed).IsZero() {
+		return
+	}
+	// A fill may be recorded by the strategy and by the reconciler; only the
+	// trades not seen before count for risk.
+	previous, _ := m.fills.Get(order.Id)
+	unseen := *order
+	unseen.Trades = payeer.OrderDetailsTrades{}
+	for id, trade := range order.Trades {
+		if _, ok := previous.Order.Trades[id]; !ok {
+			unseen.Trades[id] = trade
+		}
+	}
+	for _, fill := range risk.PayeerFills(&unseen) {
+		m.Risk.RecordFill(fill)
+	}
+	m.Ledger.Add(pnl.PayeerTrades(strategy, share, order)...)
+	if trades := pnl.PayeerTrades(strategy, share, &unseen); len(trades) > 0 {
+		m.mu.Lock()
+		hooks := m.fillHooks
+		m.mu.Unlock()
+		for _, hook := range hooks {
+			hook(trades)
+		}
+	}
+	m.fills.Set(order.Id, FillRecord{
+		Strategy: strategy,
+		Share:    share,
+		Order:    *order,
+		Recorded: time.Now(),
+	})
+	select {
+	case m.fillRecorded <- struct{}{}:
+	default:
+	}
+}
+
+// replayFills books the persisted fills into the ledger, oldest trade first.
+func (m *Market) replayFills() {
+	trades := []pnl.Trade{}
+	for _, record := range m.fills.All() {
+		trades = append(trades, pnl.PayeerTrades(record.Strategy, record.Share, &record.Order)...)
+	}
+	slices.SortStableFunc(trades, func(a, b pnl.Trade) int {
+		return cmp.Or(a.Time.Compare(b.Time), cmp.Compare(a.Id, b.Id))
+	})
+	m.Ledger.Add(trades...)
+	if len(trades) > 0 {
+		slog.Info("[Market] Replayed fills into the PnL ledger", "trades", len(trades))
+	}
+}
+
+// Fills signals after a fill was recorded; signals coalesce while nobody reads.
+func (m *Market) Fills() <-chan struct{} {
+	return m.fillRecorded
+}
+
+// OnFill calls hook with the trades of every fill recorded from now on; a trade
+// recorded twice is passed once.
+func (m *Market) OnFill(hook func(trades []pnl.Trade)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fillHooks = append(m.fillHooks, hook)
+}
+
+// SaveBalances keeps the last balances a strategy fetched.
+func (m *Market) SaveBalances(balances map[string]payeer.Balance) {
+	if err := m.state.Put("balances", balances); err != nil {
+		slog.Error("[Market] Saving balances failed", "error", err)
+	}
+}
+
+// AdoptOrders matches the open orders of the account against the records of
+// strategy. Records of orders that are still open are returned for the
+// strategy to take over; records of orders that closed while the process was
+// down are dropped, keeping their fills. Open orders nobody recorded are
+// returned as unowned, orders recorded by another strategy are left alone.
+func (m *Market) AdoptOrders(ctx context.Context, strategy string) (adopted []OrderRecord, unowned []payeer.MyOrdersOrder, err error) {
+	open, err := m.OpenOrders(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	records := m.orders.All()
+	for id, record := range records {
+		if record.Strategy != strategy {
+			continue
+		}
+		if _, ok := open[record.OrderId]; ok {
+			slog.Info("[Market] Adopting order", "strategy", strategy, "orderId", record.OrderId, "share", record.Share, "params", record.Params)
+			adopted = append(adopted, record)
+			continue
+		}
+		slog.Info("[Market] Recorded order closed while stopped", "strategy", strategy, "orderId", record.OrderId)
+		m.RecordFill(strategy, record.Share, m.Fetcher.OrderDetails(record.OrderId))
+		m.orders.Delete(id)
+	}
+	for id, order := range open {
+		if _, ok := records[strconv.Itoa(id)]; !ok {
+			unowned = append(unowned, order)
+		}
+	}
+	return adopted, unowned, nil
+}
@@ -0,0 +1,412 @@
+package strategy
+
+import (
+	"automata/client/payeer"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Reconcilable is implemented by strategies whose local order and balance
+// state the Reconciler checks against the exchange.
+type Reconcilable interface {
+	Strategy
+	// Balances returns the balances the strategy currently works with.
+	Balances() map[string]payeer.Balance
+	// ReconcileOrder drops a tracked order the exchange reports closed.
+	ReconcileOrder(order *payeer.OrderDetails)
+	// ReconcileBalances replaces the cached balances with the exchange ones.
+	ReconcileBalances(balances map[string]payeer.Balance)
+}
+
+type ReconcilerOptions struct {
+	// Interval between scheduled checks; a recorded fill triggers one early.
+	Interval time.Duration
+	// Tolerance is the balance drift, relative to the asset total, below which
+	// local and exchange balances count as equal.
+	Tolerance decimal.Decimal
+	// HaltDrift halts trading when a confirmed balance drift exceeds it.
+	HaltDrift decimal.Decimal
+}
+
+type DiscrepancyKind string
+
+const (
+	// An order the strategy tracks is no longer open on the exchange.
+	DISCREPANCY_ORDER_CLOSED DiscrepancyKind = "order_closed"
+	// An open order is recorded for a strategy that does not track it.
+	DISCREPANCY_ORDER_ORPHANED DiscrepancyKind = "order_orphaned"
+	// A cached balance differs from the exchange by more than the tolerance.
+	DISCREPANCY_BALANCE DiscrepancyKind = "balance"
+)
+
+type Discrepancy struct {
+	Strategy string          `json:"strategy"`
+	Kind     DiscrepancyKind `json:"kind"`
+	Key      string          `json:"key"`
+	Local    string          `json:"local"`
+	Exchange string          `json:"exchange"`
+	Drift    float64         `json:"drift,omitempty"`
+	Repaired bool            `json:"repaired"`
+}
+
+type ReconcileReport struct {
+	Time          time.Time     `json:"time"`
+	Discrepancies []Discrepancy `json:"discrepancies"`
+	Error         string        `json:"error,omitempty"`
+}
+
+// Reconciler periodically compares what each strategy believes about its
+// orders and balances with MyOrders, OrderStatus and Balance, repairs the
+// local state and halts the market when balances drift too far.
+type Reconciler struct {
+	market     *Market
+	options    *ReconcilerOptions
+	mu         sync.Mutex
+	names      []string
+	strategies map[string]Reconcilable
+	last       *ReconcileReport
+	// halts holds the reason of every halt the reconciler placed.
+	halts map[driftKey]string
+	// recheck is how long a drift has to last to be confirmed.
+	recheck time.Duration
+}
+
+type driftKey struct {
+	strategy, asset string
+}
+
+// ReconcilerState is what the admin server shows about the reconciler.
+type ReconcilerState struct {
+	Halts []string         `json:"halts"`
+	Last  *ReconcileReport `json:"last,omitempty"`
+}
+
+func NewReconciler(market *Market, options *ReconcilerOptions) *Reconciler {
+	if options.Interval == 0 {
+		options.Interval = time.Minute
+	}
+	if options.Tolerance.IsZero() {
+		options.Tolerance = decimal.RequireFromString("0.001")
+	}
+	if options.HaltDrift.IsZero() {
+		options.HaltDrift = decimal.RequireFromString("0.05")
+	}
+	return &Reconciler{
+		market:     market,
+		options:    options,
+		strategies: make(map[string]Reconcilable),
+		halts:      make(map[driftKey]string),
+		recheck:    time.Second,
+	}
+}
+
+func (r *Reconciler) Add(name string, strategy Reconcilable) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.strategies[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.strategies[name] = strategy
+}
+
+// Run reconciles every Interval and after every recorded fill until ctx is done.
+func (r *Reconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.market.Fills():
+			slog.Info("[Reconciler] Fill recorded, reconciling")
+		}
+		r.Reconcile(ctx)
+		// Fills recorded by the pass itself need no second pass
+		select {
+		case <-r.market.Fills():
+		default:
+		}
+	}
+}
+
+// LastReport returns the report of the latest pass, nil before the first one.
+func (r *Reconciler) LastReport() *ReconcileReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
+
+// Reconcile runs one pass over all strategies.
+func (r *Reconciler) Reconcile(ctx context.Context) *ReconcileReport {
+	r.mu.Lock()
+	names := slices.Clone(r.names)
+	r.mu.Unlock()
+
+	report := &ReconcileReport{Time: time.Now(), Discrepancies: []Discrepancy{}}
+	var errs []error
+	if err := r.reconcileOrders(ctx, names, report); err != nil {
+		errs = append(errs, err)
+		slog.Error("[Reconciler] Reconciling orders failed", "error", err)
+	}
+	if err := r.reconcileBalances(ctx, names, report); err != nil {
+		errs = append(errs, err)
+		slog.Error("[Reconciler] Reconciling balances failed", "error", err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		report.Error = err.Error()
+	}
+
+	if len(report.Discrepancies) == 0 {
+		slog.Info("[Reconciler] Local state matches the exchange")
+	}
+	r.mu.Lock()
+	r.last = report
+	r.mu.Unlock()
+	return report
+}
+
+// Resume lifts every halt placed for balance drift, e.g. once the drift has
+// been looked into by hand.
+func (r *Reconciler) Resume() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, reason := range r.halts {
+		slog.Warn("[Reconciler] Halt lifted by hand", "strategy", key.strategy, "asset", key.asset)
+		delete(r.halts, key)
+		r.market.Resume(reason)
+	}
+}
+
+func (r *Reconciler) State() ReconcilerState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state := ReconcilerState{Halts: []string{}, Last: r.last}
+	for _, reason := range r.halts {
+		state.Halts = append(state.Halts, reason)
+	}
+	slices.Sort(state.Halts)
+	return state
+}
+
+// ServeHTTP shows the latest report and the drift halts on GET and lifts the
+// halts on POST with action=resume.
+func (r *Reconciler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch {
+	case req.Method == http.MethodGet:
+	case req.Method == http.MethodPost && req.URL.Query().Get("action") == "resume":
+		r.Resume()
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "use GET, or POST with action=resume"})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(r.State())
+}
+
+func (r *Reconciler) reconcileOrders(ctx context.Context, names []string, report *ReconcileReport) error {
+	// Local state is read before the exchange, so an order placed in between
+	// is not mistaken for one that closed.
+	snapshot := time.Now()
+	tracked := map[int]string{}
+	for _, name := range names {
+		for _, id := range r.strategies[name].Status().OpenOrders {
+			tracked[id] = name
+		}
+	}
+	open, err := r.market.OpenOrders(ctx)
+	if err != nil {
+		return err
+	}
+
+	for id, name := range tracked {
+		if _, ok := open[id]; ok {
+			continue
+		}
+		order, err := r.market.Fetcher.TryOrderDetails(id)
+		if err != nil {
+			r.add(report, Discrepancy{
+				Strategy: name,
+				Kind:     DISCREPANCY_ORDER_CLOSED,
+				Key:      strconv.Itoa(id),
+				Local:    "open",
+				Exchange: "unknown: " + err.Error(),
+			})
+			continue
+		}
+		record, _ := r.market.orders.Get(strconv.Itoa(id))
+		r.strategies[name].ReconcileOrder(order)
+		r.market.RecordFill(name, record.Share, order)
+		r.market.ForgetOrder(id)
+		r.add(report, Discrepancy{
+			Strategy: name,
+			Kind:     DISCREPANCY_ORDER_CLOSED,
+			Key:      order.Id,
+			Local:    "open",
+			Exchange: string(order.Status),
+			Repaired: true,
+		})
+	}
+
+	for _, record := range r.market.orders.All() {
+		order, ok := open[record.OrderId]
+		if !ok || tracked[record.OrderId] != "" {
+			continue
+		}
+		owner, ok := r.strategies[record.Strategy]
+		if !ok {
+			continue
+		}
+		// An order placed after the snapshot is open but not in it; the
+		// strategy is asked again before its order is cancelled.
+		if !record.Placed.Before(snapshot) || slices.Contains(owner.Status().OpenOrders, record.OrderId) {
+			continue
+		}
+		// Recorded as placed by a running strategy that lost track of it:
+		// nothing would ever cancel it, so cancel it now.
+		err := CancelOrder(ctx, r.market.Payeer, record.OrderId, time.Unix(order.Date, 0))
+		if err == nil {
+			r.market.ForgetOrder(record.OrderId)
+		}
+		r.add(report, Discrepancy{
+			Strategy: record.Strategy,
+			Kind:     DISCREPANCY_ORDER_ORPHANED,
+			Key:      order.Id,
+			Local:    "untracked",
+			Exchange: "open",
+			Repaired: err == nil,
+		})
+	}
+	return nil
+}
+
+// reconcileBalances compares twice before repairing, so that a balance caught
+// between a placement and the strategy's own update is not reported as drift.
+// A halt for drift is lifted once the asset is back within the tolerance.
+func (r *Reconciler) reconcileBalances(ctx context.Context, names []string, report *ReconcileReport) error {
+	var exchange map[string]payeer.Balance
+	drifting := func(name string) (map[string]float64, error) {
+		local := r.strategies[name].Balances()
+		var err error
+		if exchange, err = r.market.Fetcher.TryBalance(); err != nil {
+			return nil, err
+		}
+		drifts := map[string]float64{}
+		for asset, balance := range local {
+			if drift := balanceDrift(balance, exchange[asset]); drift > r.options.Tolerance.InexactFloat64() {
+				drifts[asset] = drift
+			}
+		}
+		return drifts, nil
+	}
+
+	for _, name := range names {
+		first, err := drifting(name)
+		if err != nil {
+			return err
+		}
+		confirmed := map[string]float64{}
+		if len(first) > 0 {
+			if !Sleep(ctx, r.recheck) {
+				return nil
+			}
+			second, err := drifting(name)
+			if err != nil {
+				return err
+			}
+			for asset, drift := range second {
+				if _, ok := first[asset]; ok {
+					confirmed[asset] = drift
+				}
+			}
+		}
+		r.lift(name, confirmed)
+		if len(confirmed) == 0 {
+			continue
+		}
+
+		local := r.strategies[name].Balances()
+		r.strategies[name].ReconcileBalances(exchange)
+		r.market.SaveBalances(exchange)
+		for asset, drift := range confirmed {
+			r.add(report, Discrepancy{
+				Strategy: name,
+				Kind:     DISCREPANCY_BALANCE,
+				Key:      asset,
+				Local:    formatBalance(local[asset]),
+				Exchange: formatBalance(exchange[asset]),
+				Drift:    drift,
+				Repaired: true,
+			})
+			if drift > r.options.HaltDrift.InexactFloat64() {
+				r.halt(name, asset)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Reconciler) halt(name, asset string) {
+	reason := "balance drift of " + asset + " in " + name + " above threshold"
+	r.mu.Lock()
+	r.halts[driftKey{name, asset}] = reason
+	r.mu.Unlock()
+	r.market.Halt(reason)
+}
+
+// lift resumes trading halted for drift in assets of the strategy that are
+// no longer drifting.
+func (r *Reconciler) lift(name string, drifting map[string]float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, reason := range r.halts {
+		if _, ok := drifting[key.asset]; key.strategy != name || ok {
+			continue
+		}
+		slog.Info("[Reconciler] Balance back within tolerance, lifting the halt", "strategy", name, "asset", key.asset)
+		delete(r.halts, key)
+		r.market.Resume(reason)
+	}
+}
+
+func (r *Reconciler) add(report *ReconcileReport, d Discrepancy) {
+	slog.Warn("[Reconciler] Discrepancy",
+		"strategy", d.Strategy,
+		"kind", d.Kind,
+		"key", d.Key,
+		"local", d.Local,
+		"exchange", d.Exchange,
+		"drift", d.Drift,
+		"repaired", d.Repaired,
+	)
+	report.Discrepancies = append(report.Discrepancies, d)
+}
+
+// balanceDrift is the largest difference of the available and held amounts
+// relative to the exchange total.
+func balanceDrift(local, exchange payeer.Balance) float64 {
+	diff := math.Max(math.Abs(local.Available-exchange.Available), math.Abs(local.Hold-exchange.Hold))
+	if diff == 0 {
+		return 0
+	}
+	if exchange.Total == 0 {
+		return 1
+	}
+	return diff / exchange.Total
+}
+
+func formatBalance(b payeer.Balance) string {
+	return decimal.NewFromFloat(b.Available).String() + " available, " + decimal.NewFromFloat(b.Hold).String() + " hold"
+}
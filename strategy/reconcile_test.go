@@ -0,0 +1,259 @@
+package strategy
+
+import (
+	"automata/client/binance"
+	"automata/client/payeer"
+	"automata/client/payeer/paper"
+	"automata/internal/dectest"
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var d = dectest.D
+
+const btcUsdt payeer.Pair = "BTC_USDT"
+
+// fakeStrategy holds the local orders and balances the reconciler checks.
+type fakeStrategy struct {
+	orders   []int
+	balances map[string]payeer.Balance
+	// stuck ignores the exchange balances, as if the drift persisted.
+	stuck  bool
+	closed []string
+}
+
+func (f *fakeStrategy) Init(ctx context.Context) error { return nil }
+func (f *fakeStrategy) Run(ctx context.Context) error  { return nil }
+func (f *fakeStrategy) Stop(ctx context.Context) error { return nil }
+
+func (f *fakeStrategy) Status() Status {
+	return Status{OpenOrders: slices.Clone(f.orders)}
+}
+
+func (f *fakeStrategy) Balances() map[string]payeer.Balance {
+	return maps.Clone(f.balances)
+}
+
+func (f *fakeStrategy) ReconcileOrder(order *payeer.OrderDetails) {
+	f.closed = append(f.closed, order.Id)
+	id, _ := strconv.Atoi(order.Id)
+	f.orders = slices.DeleteFunc(f.orders, func(o int) bool { return o == id })
+}
+
+func (f *fakeStrategy) ReconcileBalances(balances map[string]payeer.Balance) {
+	if !f.stuck {
+		f.balances = maps.Clone(balances)
+	}
+}
+
+// testMarket returns a market trading on a paper exchange holding 1000 USDT
+// whose orders are two minutes old, so they can be cancelled at once.
+func testMarket() (*Market, *paper.Exchange) {
+	exchange := paper.NewExchange(nil, &paper.Options{
+		Balances: map[string]decimal.Decimal{"USDT": d("1000")},
+		Clock:    func() time.Time { return time.Now().Add(-2 * time.Minute) },
+	})
+	exchange.SetInfo(&payeer.InfoResponse{Pairs: map[payeer.Pair]payeer.PairInfo{
+		btcUsdt: {PricePrecision: 2, AmountPrecision: 4, ValuePrecision: 2, MinAmount: 0.0001, MinValue: 0.01},
+	}})
+	exchange.Observe(btcUsdt, payeer.PairsOrderInfo{
+		Bids: []payeer.OrdersOrder{{Price: "99", Amount: "5"}},
+		Asks: []payeer.OrdersOrder{{Price: "100", Amount: "5"}},
+	})
+	return NewMarket(exchange, binance.NewClient()), exchange
+}
+
+func testReconciler(market *Market, strategy *fakeStrategy) *Reconciler {
+	r := NewReconciler(market, &ReconcilerOptions{})
+	r.recheck = 0
+	r.Add("test", strategy)
+	return r
+}
+
+func usdt(available float64) map[string]payeer.Balance {
+	return map[string]payeer.Balance{"USDT": {Total: 1000, Available: available}}
+}
+
+func exchangeBalances(t *testing.T, exchange *paper.Exchange) map[string]payeer.Balance {
+	t.Helper()
+	rsp, err := exchange.Balance()
+	if err != nil || !rsp.Success {
+		t.Fatalf("balance: %v %+v", err, rsp)
+	}
+	return rsp.Balances
+}
+
+func placeOrder(t *testing.T, exchange *paper.Exchange, price string) int {
+	t.Helper()
+	rsp, err := exchange.PlaceOrder(&payeer.PostOrderRequest{Pair: btcUsdt, Type: payeer.ORDER_TYPE_LIMIT, Action: payeer.ACTION_BUY, Amount: "1", Price: price})
+	if err != nil || !rsp.Success {
+		t.Fatalf("placing at %s: %v %+v", price, err, rsp)
+	}
+	return rsp.OrderId
+}
+
+func TestReconcileBalances(t *testing.T) {
+	tests := []struct {
+		name       string
+		available  float64
+		wantDrift  bool
+		wantHalted bool
+	}{
+		{name: "matching", available: 1000},
+		{name: "within the tolerance", available: 1000.5},
+		{name: "drift repaired", available: 990, wantDrift: true},
+		{name: "drift above the halt threshold", available: 900, wantDrift: true, wantHalted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			market, _ := testMarket()
+			strategy := &fakeStrategy{balances: usdt(tt.available)}
+			r := testReconciler(market, strategy)
+
+			report := r.Reconcile(context.Background())
+			if report.Error != "" {
+				t.Fatalf("report error: %s", report.Error)
+			}
+			if got := len(report.Discrepancies) > 0; got != tt.wantDrift {
+				t.Fatalf("discrepancies = %+v, want drift %t", report.Discrepancies, tt.wantDrift)
+			}
+			if tt.wantDrift {
+				got := report.Discrepancies[0]
+				if got.Kind != DISCREPANCY_BALANCE || got.Key != "USDT" || !got.Repaired {
+					t.Errorf("discrepancy = %+v, want a repaired USDT balance", got)
+				}
+				if available := strategy.balances["USDT"].Available; available != 1000 {
+					t.Errorf("local USDT after the repair = %v, want 1000", available)
+				}
+			}
+			if halted, reason := market.Halted(); halted != tt.wantHalted {
+				t.Errorf("halted = %t (%s), want %t", halted, reason, tt.wantHalted)
+			}
+			if halts := r.State().Halts; len(halts) > 0 != tt.wantHalted {
+				t.Errorf("reconciler halts = %v, want halted %t", halts, tt.wantHalted)
+			}
+		})
+	}
+}
+
+func TestReconcileLiftsHaltOnceBalancesMatch(t *testing.T) {
+	market, _ := testMarket()
+	r := testReconciler(market, &fakeStrategy{balances: usdt(900)})
+	market.Halt("kill switch: test")
+
+	r.Reconcile(context.Background())
+	if _, reason := market.Halted(); !strings.Contains(reason, "balance drift of USDT in test") {
+		t.Fatalf("halt reason = %q, want the USDT drift", reason)
+	}
+	// The repaired balances match on the next pass
+	r.Reconcile(context.Background())
+	if halts := r.State().Halts; len(halts) != 0 {
+		t.Errorf("reconciler halts = %v, want none", halts)
+	}
+	if _, reason := market.Halted(); reason != "kill switch: test" {
+		t.Errorf("halt reason = %q, want only the kill switch left", reason)
+	}
+}
+
+func TestReconcileHaltStaysUntilResumed(t *testing.T) {
+	market, _ := testMarket()
+	r := testReconciler(market, &fakeStrategy{balances: usdt(900), stuck: true})
+
+	r.Reconcile(context.Background())
+	r.Reconcile(context.Background())
+	if halted, _ := market.Halted(); !halted {
+		t.Fatal("not halted while the drift lasts")
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/reconcile?action=halt", nil))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("unknown action status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/reconcile?action=resume", nil))
+	var state ReconcilerState
+	if err := json.NewDecoder(rec.Body).Decode(&state); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("resume = %d %v", rec.Code, err)
+	}
+	if len(state.Halts) != 0 || state.Last == nil {
+		t.Errorf("state after resuming = %+v, want no halts and the last report", state)
+	}
+	if halted, reason := market.Halted(); halted {
+		t.Errorf("halted after resuming: %s", reason)
+	}
+}
+
+func TestReconcileOrders(t *testing.T) {
+	t.Run("filled order", func(t *testing.T) {
+		market, exchange := testMarket()
+		id := placeOrder(t, exchange, "100")
+		market.TrackOrder(OrderRecord{OrderId: id, Strategy: "test", Placed: time.Now()})
+		strategy := &fakeStrategy{orders: []int{id}, balances: exchangeBalances(t, exchange)}
+		r := testReconciler(market, strategy)
+
+		report := r.Reconcile(context.Background())
+		want := Discrepancy{Strategy: "test", Kind: DISCREPANCY_ORDER_CLOSED, Key: strconv.Itoa(id), Local: "open", Exchange: string(payeer.ORDER_STATUS_SUCCESS), Repaired: true}
+		if len(report.Discrepancies) != 1 || report.Discrepancies[0] != want {
+			t.Fatalf("discrepancies = %+v, want %+v", report.Discrepancies, want)
+		}
+		if !slices.Equal(strategy.closed, []string{strconv.Itoa(id)}) || len(strategy.orders) != 0 {
+			t.Errorf("strategy closed %v and tracks %v, want the order closed", strategy.closed, strategy.orders)
+		}
+		if _, ok := market.orders.Get(strconv.Itoa(id)); ok {
+			t.Error("order record kept")
+		}
+		if _, ok := market.fills.Get(strconv.Itoa(id)); !ok {
+			t.Error("fill not recorded")
+		}
+	})
+
+	t.Run("unknown order", func(t *testing.T) {
+		market, _ := testMarket()
+		strategy := &fakeStrategy{orders: []int{42}, balances: usdt(1000)}
+		r := testReconciler(market, strategy)
+
+		report := r.Reconcile(context.Background())
+		if len(report.Discrepancies) != 1 {
+			t.Fatalf("discrepancies = %+v, want one", report.Discrepancies)
+		}
+		got := report.Discrepancies[0]
+		if got.Kind != DISCREPANCY_ORDER_CLOSED || got.Repaired || !strings.HasPrefix(got.Exchange, "unknown: ") {
+			t.Errorf("discrepancy = %+v, want an unrepaired closed order of unknown status", got)
+		}
+		if len(strategy.closed) != 0 {
+			t.Errorf("strategy closed %v, want the order kept", strategy.closed)
+		}
+	})
+
+	t.Run("orphaned order", func(t *testing.T) {
+		market, exchange := testMarket()
+		id := placeOrder(t, exchange, "98")
+		market.TrackOrder(OrderRecord{OrderId: id, Strategy: "test", Placed: time.Now().Add(-time.Minute)})
+		strategy := &fakeStrategy{balances: usdt(1000)}
+		r := testReconciler(market, strategy)
+
+		report := r.Reconcile(context.Background())
+		want := Discrepancy{Strategy: "test", Kind: DISCREPANCY_ORDER_ORPHANED, Key: strconv.Itoa(id), Local: "untracked", Exchange: "open", Repaired: true}
+		if len(report.Discrepancies) != 1 || report.Discrepancies[0] != want {
+			t.Fatalf("discrepancies = %+v, want %+v", report.Discrepancies, want)
+		}
+		rsp, _ := exchange.OrderStatus(&payeer.OrderStatusRequest{OrderId: id})
+		if rsp.Order.Status != payeer.ORDER_STATUS_CANCELED {
+			t.Errorf("order status = %s, want canceled", rsp.Order.Status)
+		}
+		if _, ok := market.orders.Get(strconv.Itoa(id)); ok {
+			t.Error("order record kept")
+		}
+	})
+}
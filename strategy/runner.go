@@ -0,0 +1,356 @@
+package strategy
+
+import (
+	"automata/config"
+	"automata/metrics"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+var ErrShutdownTimeout = errors.New("shutdown timed out")
+
+type RunnerOptions struct {
+	// ShutdownTimeout bounds the whole shutdown, from the first stop request
+	// until every order is cancelled and verified. Payeer refuses to cancel an
+	// order younger than a minute, so it should be well above that.
+	ShutdownTimeout time.Duration
+	// Market, when set, is used to verify with MyOrders that no order tracked
+	// by a strategy is still open after shutdown.
+	Market *Market
+	// Reconciler, when set, checks every strategy implementing Reconcilable
+	// while the strategies run.
+	Reconciler *Reconciler
+	// KillSwitch, when set, stops all strategies when it trips.
+	KillSwitch *KillSwitch
+}
+
+type Runner struct {
+	options *RunnerOptions
+	mu      sync.Mutex
+	entries []*entry
+	summary *ShutdownSummary
+}
+
+type entry struct {
+	name      string
+	strategy  Strategy
+	state     State
+	since     time.Time
+	err       error
+	tracked   []int
+	remaining []int
+}
+
+func NewRunner(options *RunnerOptions) *Runner {
+	if options.ShutdownTimeout == 0 {
+		options.ShutdownTimeout = 3 * time.Minute
+	}
+	r := &Runner{options: options}
+	metrics.Register(r)
+	return r
+}
+
+func (r *Runner) Add(name string, strategy Strategy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, &entry{
+		name:     name,
+		strategy: strategy,
+		state:    STATE_NEW,
+		since:    time.Now(),
+	})
+}
+
+// Run initializes every strategy in the order they were added and runs them
+// concurrently until ctx is cancelled or one of them fails; a failing strategy
+// cancels the others. It then shuts down: each strategy is stopped once its
+// Run returned, the orders it tracked are verified against MyOrders and a
+// summary is recorded, see Summary. When the shutdown does not finish within
+// ShutdownTimeout, Run gives up waiting and returns ErrShutdownTimeout.
+//
+// A tripped KillSwitch shuts the strategies down the same way, but Run then
+// waits for the switch to be reset and starts them again from Init.
+func (r *Runner) Run(ctx context.Context) error {
+	killSwitch := r.options.KillSwitch
+	for {
+		if killSwitch != nil && killSwitch.State().Engaged {
+			slog.Warn("[Runner] Kill switch engaged, waiting for a manual reset", "reason", killSwitch.State().Reason)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-killSwitch.Released():
+			}
+		}
+		if err := r.init(ctx); err != nil {
+			return err
+		}
+		err := r.cycle(ctx)
+		if err != nil || ctx.Err() != nil || killSwitch == nil || !killSwitch.State().Engaged {
+			return err
+		}
+	}
+}
+
+func (r *Runner) init(ctx context.Context) error {
+	for _, e := range r.entries {
+		r.setState(e, STATE_INIT, nil)
+		slog.Info("[Runner] Initializing strategy", "strategy", e.name)
+		if err := e.strategy.Init(ctx); err != nil {
+			r.setState(e, STATE_FAILED, err)
+			return fmt.Errorf("init %s: %w", e.name, err)
+		}
+	}
+	return nil
+}
+
+// cycle runs the initialized strategies until ctx is done, one of them fails
+// or the kill switch trips, and stops them.
+func (r *Runner) cycle(ctx context.Context) error {
+	runCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	if r.options.KillSwitch != nil {
+		tripped := r.options.KillSwitch.Tripped()
+		go func() {
+			select {
+			case <-runCtx.Done():
+			case <-tripped:
+				cancel(fmt.Errorf("kill switch: %s", r.options.KillSwitch.State().Reason))
+			}
+		}()
+	}
+
+	// The shutdown deadline starts with the first stop request, so it is only
+	// known once runCtx is done; stopping is closed when stopCtx is set.
+	var stopCtx context.Context
+	stopping := make(chan struct{})
+
+	// The reconciler starts once every strategy adopted its orders and ends
+	// before any of them is stopped.
+	reconciled := make(chan struct{})
+	if r.options.Reconciler != nil {
+		for _, e := range r.entries {
+			if reconcilable, ok := e.strategy.(Reconcilable); ok {
+				r.options.Reconciler.Add(e.name, reconcilable)
+			}
+		}
+		go func() {
+			defer close(reconciled)
+			r.options.Reconciler.Run(runCtx)
+		}()
+	} else {
+		close(reconciled)
+	}
+
+	var wg sync.WaitGroup
+	for _, e := range r.entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.setState(e, STATE_RUNNING, nil)
+			slog.Info("[Runner] Strategy started", "strategy", e.name)
+			if err := e.strategy.Run(runCtx); err != nil && runCtx.Err() == nil {
+				err = fmt.Errorf("run %s: %w", e.name, err)
+				slog.Error("[Runner] Strategy failed, stopping all strategies", "strategy", e.name, "error", err)
+				r.setState(e, STATE_FAILED, err)
+				cancel(err)
+			}
+			<-stopping
+			r.stop(stopCtx, e)
+		}()
+	}
+
+	<-runCtx.Done()
+	<-reconciled
+	summary := &ShutdownSummary{
+		Reason:  fmt.Sprint(context.Cause(runCtx)),
+		Started: time.Now(),
+	}
+	slog.Info("[Runner] Shutting down, no new orders will be placed", "reason", summary.Reason, "timeout", r.options.ShutdownTimeout)
+	var stopCancel context.CancelFunc
+	stopCtx, stopCancel = context.WithTimeout(context.Background(), r.options.ShutdownTimeout)
+	defer stopCancel()
+	close(stopping)
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		r.verify(stopCtx, summary)
+	case <-stopCtx.Done():
+		summary.TimedOut = true
+		slog.Error("[Runner] Shutdown timed out, giving up on the remaining strategies", "timeout", r.options.ShutdownTimeout)
+	}
+	summary.Duration = config.Duration(time.Since(summary.Started).Round(time.Millisecond))
+
+	r.mu.Lock()
+	var errs []error
+	for _, e := range r.entries {
+		summary.Strategies = append(summary.Strategies, e.shutdownSummary())
+		errs = append(errs, e.err)
+	}
+	r.summary = summary
+	r.mu.Unlock()
+
+	summary.Log()
+	if summary.TimedOut {
+		errs = append(errs, ErrShutdownTimeout)
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Runner) stop(ctx context.Context, e *entry) {
+	tracked := e.strategy.Status().OpenOrders
+	// Until Stop returns every tracked order counts as remaining, which is
+	// what the summary reports if the shutdown times out.
+	r.mu.Lock()
+	e.tracked = tracked
+	e.remaining = tracked
+	r.mu.Unlock()
+	r.setState(e, STATE_STOPPING, nil)
+	slog.Info("[Runner] Stopping strategy", "strategy", e.name, "openOrders", tracked)
+	err := e.strategy.Stop(ctx)
+	remaining := e.strategy.Status().OpenOrders
+
+	r.mu.Lock()
+	e.remaining = remaining
+	failed := e.err != nil
+	r.mu.Unlock()
+
+	if err != nil {
+		err = fmt.Errorf("stop %s: %w", e.name, err)
+		r.setState(e, STATE_FAILED, err)
+		slog.Error("[Runner] Strategy did not stop cleanly", "strategy", e.name, "error", err, "remaining", remaining)
+		return
+	}
+	if failed {
+		r.setState(e, STATE_FAILED, nil)
+		return
+	}
+	r.setState(e, STATE_STOPPED, nil)
+	slog.Info("[Runner] Strategy stopped", "strategy", e.name)
+}
+
+// verify looks the tracked orders up in MyOrders, cancels the ones that are
+// still open once more and records what is left.
+func (r *Runner) verify(ctx context.Context, summary *ShutdownSummary) {
+	if r.options.Market == nil {
+		return
+	}
+	r.mu.Lock()
+	tracked := []int{}
+	for _, e := range r.entries {
+		tracked = append(tracked, e.tracked...)
+	}
+	r.mu.Unlock()
+
+	open, err := r.options.Market.OpenOrders(ctx)
+	if err != nil {
+		summary.VerifyError = err.Error()
+		slog.Error("[Runner] Verifying open orders failed", "error", err)
+		return
+	}
+	retried := false
+	for _, id := range tracked {
+		if order, ok := open[id]; ok {
+			slog.Warn("[Runner] Tracked order still open after stop, cancelling again", "orderId", id)
+			if err := CancelOrder(ctx, r.options.Market.Payeer, id, time.Unix(order.Date, 0)); err != nil {
+				slog.Error("[Runner] Cancelling order failed", "orderId", id, "error", err)
+			}
+			retried = true
+		}
+	}
+	if retried {
+		if open, err = r.options.Market.OpenOrders(ctx); err != nil {
+			summary.VerifyError = err.Error()
+			return
+		}
+	}
+	summary.Verified = true
+	for id := range open {
+		if slices.Contains(tracked, id) {
+			summary.StillOpen = append(summary.StillOpen, id)
+		} else {
+			summary.Untracked = append(summary.Untracked, id)
+		}
+	}
+	slices.Sort(summary.StillOpen)
+	slices.Sort(summary.Untracked)
+}
+
+func (r *Runner) setState(e *entry, state State, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e.state = state
+	e.since = time.Now()
+	if err != nil {
+		e.err = errors.Join(e.err, err)
+	}
+}
+
+func (e *entry) shutdownSummary() StrategyShutdown {
+	s := StrategyShutdown{
+		Name:      e.name,
+		State:     e.state,
+		Tracked:   append([]int{}, e.tracked...),
+		Cancelled: []int{},
+		Remaining: append([]int{}, e.remaining...),
+	}
+	for _, id := range e.tracked {
+		if !slices.Contains(e.remaining, id) {
+			s.Cancelled = append(s.Cancelled, id)
+		}
+	}
+	if e.err != nil {
+		s.Error = e.err.Error()
+	}
+	return s
+}
+
+// Strategy returns the strategy registered under name.
+func (r *Runner) Strategy(name string) (Strategy, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.entries {
+		if e.name == name {
+			return e.strategy, true
+		}
+	}
+	return nil, false
+}
+
+// Summary returns the shutdown summary once Run has returned.
+func (r *Runner) Summary() *ShutdownSummary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.summary
+}
+
+func (r *Runner) Statuses() []Status {
+	r.mu.Lock()
+	entries := make([]entry, 0, len(r.entries))
+	for _, e := range r.entries {
+		entries = append(entries, *e)
+	}
+	r.mu.Unlock()
+
+	statuses := make([]Status, 0, len(entries))
+	for _, e := range entries {
+		status := e.strategy.Status()
+		status.Name = e.name
+		status.State = e.state
+		status.Since = e.since
+		if e.err != nil {
+			status.Error = e.err.Error()
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
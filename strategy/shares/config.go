@@ -0,0 +1,159 @@
+package shares
+
+import (
+	"automata/client/binance"
+	"automata/client/payeer"
+	"automata/config"
+	"automata/inventory"
+	"automata/volatility"
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type Config struct {
+	RefetchBalanceDelay config.Duration                 `json:"refetchBalanceDelay" desc:"pause after placing an order before the balance is adjusted"`
+	OrdersFetchInterval config.Duration                 `json:"ordersFetchInterval" desc:"pause between order book fetches"`
+	Shares              []ShareConfig                   `json:"shares"`
+	Volatility          *Volatility                     `json:"volatility,omitempty" desc:"widen the share ratios in fast markets and narrow them in calm ones"`
+	Skew                map[payeer.Pair]*inventory.Skew `json:"skew,omitempty" desc:"target inventory per pair; prices and sizes of its shares shift as the balances deviate from it"`
+}
+
+// Volatility scales how far every share ratio is from 1 by the volatility of
+// its reference over ReferenceBps, within MinFactor and MaxFactor.
+type Volatility struct {
+	Model          volatility.Model `json:"model,omitempty" desc:"ewma or realised; default ewma"`
+	SampleInterval config.Duration  `json:"sampleInterval,omitempty" desc:"time between samples of the reference mid; default 1s"`
+	HalfLife       config.Duration  `json:"halfLife,omitempty" desc:"half-life of a return in the ewma model; default 5m"`
+	Window         config.Duration  `json:"window,omitempty" desc:"window of the realised model; default 15m"`
+	ReferenceBps   decimal.Decimal  `json:"referenceBps" desc:"volatility in basis points per minute at which the configured ratios apply"`
+	MinFactor      decimal.Decimal  `json:"minFactor" desc:"narrowest spread as a multiple of the configured one, e.g. 0.5"`
+	MaxFactor      decimal.Decimal  `json:"maxFactor" desc:"widest spread as a multiple of the configured one, e.g. 2"`
+}
+
+func (v *Volatility) Validate(problems *config.Problems, path string) {
+	if v.Model != "" && !slices.Contains(volatility.Models, v.Model) {
+		problems.Addf(path+".model", "must be one of %v, got %q", volatility.Models, v.Model)
+	}
+	if v.SampleInterval != 0 {
+		problems.Interval(path+".sampleInterval", v.SampleInterval, 100*time.Millisecond)
+	}
+	sampleInterval := cmp.Or(v.SampleInterval.D(), volatility.DefaultSampleInterval)
+	if v.HalfLife != 0 {
+		problems.Interval(path+".halfLife", v.HalfLife, 10*sampleInterval)
+	}
+	if v.Window != 0 {
+		problems.Interval(path+".window", v.Window, 10*sampleInterval)
+	}
+	problems.Positive(path+".referenceBps", v.ReferenceBps)
+	problems.Positive(path+".minFactor", v.MinFactor)
+	problems.DecimalRange(path+".minFactor", v.MinFactor, "", "1")
+	problems.DecimalRange(path+".maxFactor", v.MaxFactor, "1", "5")
+}
+
+func (v *Volatility) Options() *VolatilityOptions {
+	if v == nil {
+		return nil
+	}
+	return &VolatilityOptions{
+		Estimator: volatility.Options{
+			Model:          v.Model,
+			SampleInterval: v.SampleInterval.D(),
+			HalfLife:       v.HalfLife.D(),
+			Window:         v.Window.D(),
+		},
+		ReferenceBps: v.ReferenceBps,
+		MinFactor:    v.MinFactor,
+		MaxFactor:    v.MaxFactor,
+	}
+}
+
+type ShareConfig struct {
+	ID                    string          `json:"id" desc:"unique name used in logs"`
+	Action                payeer.Action   `json:"action"`
+	Pair                  payeer.Pair     `json:"pair"`
+	BinanceSymbol         binance.Symbol  `json:"binanceSymbol" desc:"reference market for the pair, a Binance symbol or a reference name"`
+	BinanceTickerInterval config.Duration `json:"binanceTickerInterval"`
+	Share                 decimal.Decimal `json:"share" desc:"fraction of the spent asset's balance, buy spends quote and sell spends base"`
+	BinancePriceRatio     decimal.Decimal `json:"binancePriceRatio" desc:"limit price relative to the Binance bid (buy) or ask (sell)"`
+	Edge                  *payeer.Edge    `json:"edge,omitempty" desc:"net edge in basis points to earn after the Payeer maker fee and the hedge fee; tightens binancePriceRatio"`
+	LoopInterval          config.Duration `json:"loopInterval"`
+}
+
+func (c *Config) Validate(problems *config.Problems, path string) {
+	problems.Interval(path+".refetchBalanceDelay", c.RefetchBalanceDelay, 0)
+	problems.Interval(path+".ordersFetchInterval", c.OrdersFetchInterval, time.Millisecond)
+	if len(c.Shares) == 0 {
+		problems.Add(path+".shares", "at least one share is required")
+	}
+
+	ids := map[string]bool{}
+	sums := map[string]decimal.Decimal{}
+	for i, share := range c.Shares {
+		p := fmt.Sprintf("%s.shares.%d", path, i)
+		if share.ID == "" {
+			problems.Add(p+".id", "is required")
+		} else if ids[share.ID] {
+			problems.Addf(p+".id", "duplicate id %q", share.ID)
+		}
+		ids[share.ID] = true
+		problems.Action(p+".action", share.Action)
+		problems.Pair(p+".pair", share.Pair)
+		problems.Symbol(p+".binanceSymbol", share.BinanceSymbol)
+		problems.Interval(p+".binanceTickerInterval", share.BinanceTickerInterval, 10*time.Millisecond)
+		problems.Interval(p+".loopInterval", share.LoopInterval, 100*time.Millisecond)
+		problems.Positive(p+".share", share.Share)
+		problems.DecimalRange(p+".share", share.Share, "", "1")
+		if share.Action == payeer.ACTION_BUY {
+			problems.DecimalRange(p+".binancePriceRatio", share.BinancePriceRatio, "0.5", "1")
+		} else {
+			problems.DecimalRange(p+".binancePriceRatio", share.BinancePriceRatio, "1", "2")
+		}
+		problems.Edge(p+".edge", share.Edge)
+		if share.Pair.IsKnown() {
+			asset := share.Pair.Base()
+			if share.Action == payeer.ACTION_BUY {
+				asset = share.Pair.Quote()
+			}
+			sums[asset] = sums[asset].Add(share.Share)
+		}
+	}
+	problems.ShareSums(path+".shares", sums)
+	if c.Volatility != nil {
+		c.Volatility.Validate(problems, path+".volatility")
+	}
+	for pair, skew := range c.Skew {
+		problems.Pair(path+".skew", pair)
+		if !slices.ContainsFunc(c.Shares, func(share ShareConfig) bool { return share.Pair == pair }) {
+			problems.Addf(path+".skew."+string(pair), "no share quotes %s", pair)
+		}
+		problems.Skew(path+".skew."+string(pair), skew)
+	}
+}
+
+func (c *Config) Options() *Options {
+	shares := make([]Share, 0, len(c.Shares))
+	for _, share := range c.Shares {
+		shares = append(shares, Share{
+			ID:                    share.ID,
+			Action:                share.Action,
+			Pair:                  share.Pair,
+			BinanceSymbol:         share.BinanceSymbol,
+			BinanceTickerInterval: share.BinanceTickerInterval.D(),
+			Share:                 share.Share,
+			BinancePriceRatio:     share.BinancePriceRatio,
+			Edge:                  share.Edge,
+			LoopInterval:          share.LoopInterval.D(),
+		})
+	}
+	return &Options{
+		Shares:              shares,
+		RefetchBalanceDelay: c.RefetchBalanceDelay.D(),
+		OrdersFetchInterval: c.OrdersFetchInterval.D(),
+		Volatility:          c.Volatility.Options(),
+		Skew:                c.Skew,
+	}
+}
@@ -0,0 +1,683 @@
+package shares
+
+import (
+	"automata/client/payeer"
+	"automata/inventory"
+	"automata/journal"
+	"automata/logging"
+	"automata/metrics"
+	"automata/strategy"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+/*
+** Lifecycle
+ */
+
+func (s *Strategy) Init(ctx context.Context) error {
+	s.initInfo()
+	if err := s.initMyOrders(ctx); err != nil {
+		return err
+	}
+	s.initBinanceTickers()
+	s.initVolatility()
+	s.initBalance()
+	return nil
+}
+
+func (s *Strategy) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.runOrdersFetchLoop(ctx)
+	}()
+	if s.options.Get().Volatility != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.runVolatilityLoop(ctx)
+		}()
+	}
+	if strategy.Sleep(ctx, time.Second) {
+		for _, share := range s.options.Get().Shares {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.runShareLoop(ctx, share.ID)
+			}()
+		}
+	}
+	wg.Wait()
+	return nil
+}
+
+// Stop cancels the order of every share and records its fills, so the hedger
+// sees them before it closes. The share loops have returned by now, so nothing
+// is placed in the meantime.
+func (s *Strategy) Stop(ctx context.Context) error {
+	return s.cancelAll(ctx)
+}
+
+func (s *Strategy) Status() strategy.Status {
+	orders := map[string]int{}
+	openOrders := []int{}
+	s.store.shareOrders.Range(func(id string, order ShareOrderInfo) bool {
+		orders[id] = order.OrderId
+		openOrders = append(openOrders, order.OrderId)
+		return true
+	})
+	return strategy.Status{
+		OpenOrders: openOrders,
+		Details:    map[string]any{"shareOrders": orders},
+	}
+}
+
+/*
+** Loops
+ */
+func (s *Strategy) runShareLoop(ctx context.Context, id string) {
+	init := true
+	for {
+		if !init {
+			if !strategy.Sleep(ctx, s.options.Get().share(id).LoopInterval) {
+				return
+			}
+		} else {
+			init = false
+		}
+		// One options snapshot per iteration, so a reload never mixes parameters
+		options := s.options.Get()
+		share := options.share(id)
+		orderCached, ok := s.store.shareOrders.Get(share.ID)
+		if !ok {
+			if halted, reason := s.market.Halted(); halted {
+				s.shareLog(share).Info("[Share] Trading halted, not placing", "reason", reason)
+				continue
+			}
+			if s.pauses.Paused(share.Action) {
+				continue
+			}
+			trace := logging.NewTraceId()
+			log := s.shareLog(share).With(logging.Trace(trace))
+			order := s.tryPlaceOrder(share, trace, log)
+			if order != nil {
+				strategy.CountPlacement(options.Name, share.ID, order.Success)
+			}
+			if order != nil && order.Success {
+				log.Info("[Share] Order placed", logging.OrderId(order.OrderId), "price", order.Params.Price, "amount", order.Params.Amount)
+				s.trackShareOrder(share, ShareOrderInfo{
+					OrderId: order.OrderId,
+					Order:   &order.Params,
+					Time:    time.Now(),
+					Trace:   trace,
+				})
+				time.Sleep(options.RefetchBalanceDelay)
+				s.updateBalanceByOrderParams(log, share, &order.Params, true)
+			} else if order != nil && !order.Success {
+				log.Warn("[Share] Order rejected", "error", order.Error)
+				if order.Error.Code == payeer.ERR_INSUFFICIENT_FUNDS ||
+					order.Error.Code == payeer.ERR_INSUFFICIENT_VOLUME {
+					s.initBalance()
+					continue
+				}
+			}
+		} else {
+			log := s.shareLog(share).With(logging.OrderId(orderCached.OrderId), logging.Trace(orderCached.Trace))
+			diff := time.Since(orderCached.Time)
+			if diff < strategy.MinOrderLifetime {
+				if !strategy.Sleep(ctx, strategy.MinOrderLifetime-diff) {
+					return
+				}
+			}
+
+			// Checking if the order has been fulfilled
+			orderFetched := s.fetcher.OrderDetails(orderCached.OrderId)
+			if decimal.RequireFromString(orderFetched.ValueRemaining).IsZero() {
+				log.Info("[Share] Order filled", "amount", orderFetched.AmountProcessed, "value", orderFetched.ValueProcessed)
+				s.updateBalanceByOrderDetails(log, share, orderFetched)
+				s.forgetShareOrder(share, orderFetched)
+				continue
+			}
+
+			// Checking if the price has changed
+			if changed, entry := s.hasPriceChanged(log, share, &orderCached); changed {
+				rsp := s.fetcher.CancelOrder(orderCached.OrderId)
+				strategy.CountCancel(options.Name, share.ID, rsp.Success)
+				entry.Result = journal.Result{Accepted: rsp.Success, OrderId: orderCached.OrderId, Error: string(rsp.Error.Code)}
+				s.market.Journal.Append(entry)
+				if rsp.Success {
+					orderRefetched := s.fetcher.OrderDetails(orderCached.OrderId)
+					log.Info("[Share] Order cancelled", "amountProcessed", orderRefetched.AmountProcessed)
+					s.updateBalanceByOrderDetails(log, share, orderRefetched)
+					s.forgetShareOrder(share, orderRefetched)
+				} else {
+					log.Error("[Share] Cancelling order failed", "error", rsp.Error)
+					continue
+				}
+			}
+		}
+	}
+}
+
+// trackShareOrder remembers the order of a share, in memory and in the state
+// store, so that a restart adopts it.
+func (s *Strategy) trackShareOrder(share *Share, info ShareOrderInfo) {
+	s.store.shareOrders.Set(share.ID, info)
+	s.market.TrackOrder(strategy.OrderRecord{
+		OrderId:  info.OrderId,
+		Strategy: s.options.Get().Name,
+		Share:    share.ID,
+		Params:   *info.Order,
+		Placed:   info.Time,
+		Trace:    info.Trace,
+	})
+}
+
+// forgetShareOrder drops the closed order of a share and keeps its fills;
+// the balances of the share are updated first, since the recorded fill
+// triggers a reconcile.
+func (s *Strategy) forgetShareOrder(share *Share, order *payeer.OrderDetails) {
+	info, _ := s.store.shareOrders.Get(share.ID)
+	s.store.shareOrders.Delete(share.ID)
+	s.market.RecordFill(s.options.Get().Name, share.ID, order)
+	s.market.ForgetOrder(info.OrderId)
+}
+
+func (s *Strategy) updateBalanceByOrderParams(log *slog.Logger, share *Share, order *payeer.OrderParams, in bool) {
+	log.Debug("[Share] Updating balance by order params", "order", order)
+	mul := 1.0
+	if !in {
+		mul = -1.0
+	}
+	if share.Action == payeer.ACTION_SELL {
+		base, _ := s.store.balance.Get(share.Pair.Base())
+		amount := decimal.RequireFromString(order.Amount).InexactFloat64()
+		base.Available -= amount * mul
+		base.Hold += amount * mul
+		s.store.balance.Set(share.Pair.Base(), base)
+	} else {
+		quote, _ := s.store.balance.Get(share.Pair.Quote())
+		value := decimal.RequireFromString(order.Value).InexactFloat64()
+		quote.Available -= value * mul
+		quote.Hold += value * mul
+		s.store.balance.Set(share.Pair.Quote(), quote)
+	}
+}
+
+func (s *Strategy) updateBalanceByOrderDetails(log *slog.Logger, share *Share, order *payeer.OrderDetails) {
+	log.Debug("[Share] Updating balance by order details", "order", order)
+	for _, trade := range order.Trades {
+		base, _ := s.store.balance.Get(share.Pair.Base())
+		quote, _ := s.store.balance.Get(share.Pair.Quote())
+		mul := decimal.NewFromInt(1)
+		if share.Action == payeer.ACTION_SELL {
+			mul = decimal.NewFromInt(-1)
+		}
+		base.Available += decimal.RequireFromString(trade.Amount).Mul(mul).InexactFloat64()
+		quote.Available -= decimal.RequireFromString(trade.Value).Mul(mul).InexactFloat64()
+		s.store.balance.Set(share.Pair.Base(), base)
+		s.store.balance.Set(share.Pair.Quote(), quote)
+	}
+	s.updateBalanceByOrderParams(log, share, &payeer.OrderParams{
+		Amount: order.AmountRemaining,
+		Value:  order.ValueRemaining,
+	}, false)
+}
+
+// hasPriceChanged reports whether the order has to make way for a new price
+// and returns the decision to journal once the cancel is done.
+func (s *Strategy) hasPriceChanged(log *slog.Logger, share *Share, order *ShareOrderInfo) (bool, *journal.Entry) {
+	binanceTickersData, ok := s.store.binanceTickers.Get(share.BinanceSymbol)
+	if !ok {
+		log.Warn("[Share] No Binance ticker cached, skipping", "symbol", share.BinanceSymbol)
+		return false, nil
+	}
+
+	ordersData, ok := s.store.orders.Get(share.Pair)
+	if !ok {
+		log.Warn("[Share] No orders cached, skipping")
+		return false, nil
+	}
+
+	skew := s.inventorySkew(share)
+	ratio := s.priceRatio(share, skew)
+	myPrices := s.getMyPrices(share.Pair, share.Action)
+	price := payeer.ResolvePriceWithElevation(s.store.info.PairInfo(share.Pair), share.Action, ratio, &binanceTickersData, &ordersData, myPrices)
+
+	if skew.IsStopped() {
+		log.Info("[Share] Inventory at its limit, cancelling", "limit", inventory.Limit(share.Action), "baseShare", skew.BaseShare.StringFixed(4))
+		entry := s.journalEntry(share, journal.DECISION_CANCEL, order.Trace, &ordersData, myPrices, ratio, skew, price)
+		entry.Reason = "inventory at " + inventory.Limit(share.Action)
+		entry.OrderId = order.OrderId
+		entry.Price = decimal.RequireFromString(order.Order.Price)
+		entry.Amount = decimal.RequireFromString(order.Order.Amount)
+		return true, entry
+	}
+
+	if decimal.RequireFromString(order.Order.Price).Equal(price) {
+		log.Debug("[Share] Price unchanged", "price", price)
+		return false, nil
+	}
+
+	log.Info("[Share] Price changed, cancelling", "oldPrice", order.Order.Price, "newPrice", price)
+	entry := s.journalEntry(share, journal.DECISION_CANCEL, order.Trace, &ordersData, myPrices, ratio, skew, price)
+	entry.Reason = "price changed to " + price.String()
+	entry.OrderId = order.OrderId
+	entry.Price = decimal.RequireFromString(order.Order.Price)
+	entry.Amount = decimal.RequireFromString(order.Order.Amount)
+	return true, entry
+}
+
+// priceRatio is the Binance price ratio of share scaled by volatility,
+// shifted by the inventory skew and tightened to its target edge.
+func (s *Strategy) priceRatio(share *Share, skew *inventory.Adjustment) decimal.Decimal {
+	return share.Edge.Limit(share.Action, s.store.info.PairInfo(share.Pair).MakerFee(), skew.Ratio(s.volatilityRatio(share)))
+}
+
+// journalEntry describes a decision of share taken on the book and the price
+// ResolvePriceWithElevation picked at ratio.
+func (s *Strategy) journalEntry(share *Share, decision journal.Decision, trace string, book *payeer.PairsOrderInfo, myPrices []payeer.PriceAmount, ratio decimal.Decimal, skew *inventory.Adjustment, price decimal.Decimal) *journal.Entry {
+	return &journal.Entry{
+		Strategy:  s.options.Get().Name,
+		Share:     share.ID,
+		Pair:      share.Pair,
+		Action:    share.Action,
+		Decision:  decision,
+		Trace:     trace,
+		Book:      journal.BookSide(book, share.Action),
+		Ticker:    s.market.JournalTicker(share.BinanceSymbol),
+		Stages:    []payeer.Stage{{Name: payeer.STAGE_ELEVATION, Ok: true, Price: price}},
+		Elevation: &journal.Elevation{Ratio: ratio, MyOrders: myPrices},
+		Skew:      skew,
+		PairInfo:  s.store.info.PairInfo(share.Pair),
+		Price:     price,
+	}
+}
+
+func (s *Strategy) tryPlaceOrder(share *Share, trace string, log *slog.Logger) *payeer.PostOrderResponse {
+	binanceTickersData, ok := s.store.binanceTickers.Get(share.BinanceSymbol)
+	if !ok {
+		log.Warn("[Share] No Binance ticker cached, skipping", "symbol", share.BinanceSymbol)
+		time.Sleep(time.Second * 1)
+		return nil
+	}
+
+	ordersData, ok := s.store.orders.Get(share.Pair)
+	if !ok {
+		log.Warn("[Share] No orders cached, skipping")
+		time.Sleep(time.Second * 1)
+		return nil
+	}
+
+	pairInfo := s.store.info.PairInfo(share.Pair)
+	if pairInfo == nil {
+		log.Warn("[Share] No pair info, skipping")
+		time.Sleep(time.Second * 1)
+		return nil
+	}
+
+	skew := s.inventorySkew(share)
+	if skew.IsStopped() {
+		log.Debug("[Share] Inventory at its limit, skipping", "limit", inventory.Limit(share.Action), "baseShare", skew.BaseShare.StringFixed(4))
+		time.Sleep(time.Second * 1)
+		return nil
+	}
+
+	ratio := s.priceRatio(share, skew)
+	myPrices := s.getMyPrices(share.Pair, share.Action)
+	price := payeer.ResolvePriceWithElevation(pairInfo, share.Action, ratio, &binanceTickersData, &ordersData, myPrices)
+
+	var mainAssetName string
+	var roundMainAsset func(decimal.Decimal) decimal.Decimal
+
+	if share.Action == payeer.ACTION_SELL {
+		mainAssetName = share.Pair.Base()
+		roundMainAsset = pairInfo.RoundAmount
+	} else {
+		mainAssetName = share.Pair.Quote()
+		roundMainAsset = pairInfo.RoundValue
+	}
+
+	balance, ok := s.store.balance.Get(mainAssetName)
+	if !ok {
+		log.Warn("[Share] No balance cached, skipping", "asset", mainAssetName)
+		time.Sleep(time.Second * 1)
+		return nil
+	}
+
+	// The skew shrinks the share of the side that grows the position
+	mainAssetQty := roundMainAsset(skew.Size(decimal.NewFromFloat(balance.Total).Mul(share.Share)))
+
+	if decimal.NewFromFloat(balance.Available).LessThan(mainAssetQty) {
+		log.Warn("[Share] Not enough main asset for share, skipping", "shareOfTotal", share.Share, "asset", mainAssetName, "available", balance.Available, "total", balance.Total, "required", mainAssetQty.String())
+		time.Sleep(time.Second * 1)
+		return nil
+	}
+
+	// Payeer takes the fee from the asset received, so the share only has to
+	// cover the bare amount or value
+	amount := pairInfo.AmountFor(share.Action, mainAssetQty, price)
+
+	minAmount := decimal.NewFromFloat(pairInfo.MinAmount)
+	if amount.LessThan(minAmount) {
+		log.Info("[Share] Order amount less than minAmount, skipping", "minAmount", minAmount.String(), "orderAmount", amount.String())
+		time.Sleep(time.Second * 1)
+		return nil
+	}
+
+	log.Debug("[Share] Prepared order request", "amount", amount, "price", price)
+
+	entry := s.journalEntry(share, journal.DECISION_PLACE, trace, &ordersData, myPrices, ratio, skew, price)
+	entry.Amount = amount
+	rsp := s.fetcher.PlaceOrder(share.Action, share.Pair, amount.String(), price.String())
+	entry.Result = journal.Result{Accepted: rsp.Success, OrderId: rsp.OrderId, Error: string(rsp.Error.Code)}
+	s.market.Journal.Append(entry)
+	return rsp
+}
+
+func (s *Strategy) runOrdersFetchLoop(ctx context.Context) {
+	shares := s.options.Get().Shares
+	pairs := make([]payeer.Pair, 0, len(shares))
+	for _, share := range shares {
+		if !slices.Contains(pairs, share.Pair) {
+			pairs = append(pairs, share.Pair)
+		}
+	}
+	init := true
+	for {
+		if !init {
+			if !strategy.Sleep(ctx, s.options.Get().OrdersFetchInterval) {
+				return
+			}
+		} else {
+			init = false
+		}
+		orders := s.fetcher.OrdersByPairs(pairs)
+		for pair, info := range orders {
+			s.store.orders.Set(pair, info)
+		}
+	}
+}
+
+/*
+** Initializations
+ */
+
+func (s *Strategy) initInfo() {
+	s.store.info = s.market.Info()
+}
+
+func (s *Strategy) initBinanceTickers() {
+	slog.Info("[PayeerSharesStrategy] Initializing binance tickers...")
+	for _, share := range s.options.Get().Shares {
+		if share.BinanceTickerInterval.Milliseconds() == int64(0) {
+			share.BinanceTickerInterval = time.Millisecond * 200
+		}
+		s.market.SubscribeReference(share.BinanceSymbol, share.BinanceTickerInterval)
+	}
+	s.store.binanceTickers = s.market.ReferenceTickers()
+	s.logInfo("Binance tickers initialized")
+}
+
+// initMyOrders takes over the orders the state store records for this
+// strategy and cancels every other open order, unless another strategy of the
+// process owns it.
+func (s *Strategy) initMyOrders(ctx context.Context) error {
+	options := s.options.Get()
+	adopted, unowned, err := s.market.AdoptOrders(ctx, options.Name)
+	if err != nil {
+		return err
+	}
+	for _, record := range adopted {
+		share := options.share(record.Share)
+		if share == nil || share.Pair != record.Params.Pair || share.Action != record.Params.Action {
+			s.logInfo("Recorded order does not match a share anymore, cancelling", "orderId", record.OrderId, "share", record.Share)
+			if err := strategy.CancelOrder(ctx, s.market.Payeer, record.OrderId, record.Placed); err != nil {
+				return err
+			}
+			s.market.ForgetOrder(record.OrderId)
+			continue
+		}
+		s.store.shareOrders.Set(share.ID, ShareOrderInfo{
+			OrderId: record.OrderId,
+			Order:   &record.Params,
+			Time:    record.Placed,
+			Trace:   record.Trace,
+		})
+	}
+	s.logInfo("Cancelling pending orders...", "adopted", len(adopted), "unowned", len(unowned))
+	for _, order := range unowned {
+		orderTime := time.Unix(order.Date, 0)
+		diff := time.Since(orderTime)
+		if diff.Minutes() <= 1 {
+			wait := time.Minute - diff
+			s.logInfo("Wait for order cancel...", "orderId", order.Id, "time", wait)
+			if !strategy.Sleep(ctx, wait) {
+				return ctx.Err()
+			}
+		}
+		orderId, _ := strconv.Atoi(order.Id)
+		s.fetcher.CancelOrder(orderId)
+		time.Sleep(200 * time.Millisecond)
+	}
+	s.logInfo("Pending orders canceled")
+	return nil
+}
+
+func (s *Strategy) initBalance() {
+	s.logInfo("(Re-)initializing balance...")
+	balances := s.fetcher.Balance()
+	s.market.SaveBalances(balances)
+	for asset, balance := range balances {
+		if balance.Available > 0 {
+			s.store.balance.Set(asset, balance)
+			slog.Debug("[PayeerSharesStrategy] Balance update", "asset", asset, "balance", balance)
+		}
+	}
+	s.logInfo("Balance (re-)initialized")
+}
+
+/*
+** Reconfiguration
+ */
+
+// Reconfigure swaps in new share sizes, price ratios and intervals; every loop
+// picks them up at its next iteration. Shares are bound to their pair, side and
+// Binance stream when the strategy starts, so adding, removing or rebinding a
+// share needs a restart.
+func (s *Strategy) Reconfigure(options *Options) error {
+	current := s.options.Get()
+	if len(options.Shares) != len(current.Shares) {
+		return fmt.Errorf("number of shares changed from %d to %d, restart required", len(current.Shares), len(options.Shares))
+	}
+	for _, share := range options.Shares {
+		old := current.share(share.ID)
+		if old == nil {
+			return fmt.Errorf("share %s is new, restart required", share.ID)
+		}
+		if old.Action != share.Action || old.Pair != share.Pair || old.BinanceSymbol != share.BinanceSymbol || old.BinanceTickerInterval != share.BinanceTickerInterval {
+			return fmt.Errorf("share %s changed action, pair or Binance stream, restart required", share.ID)
+		}
+	}
+	if (options.Volatility == nil) != (current.Volatility == nil) ||
+		options.Volatility != nil && options.Volatility.Estimator != current.Volatility.Estimator {
+		return errors.New("volatility model changed, restart required")
+	}
+	options.Name = current.Name
+	options.setHedgeFee(s.market.HedgeFeePercent)
+	s.options.Set(options)
+	s.logInfo("Options reconfigured")
+	return nil
+}
+
+/*
+** Reconciliation
+ */
+
+var _ strategy.Reconcilable = (*Strategy)(nil)
+
+func (s *Strategy) Balances() map[string]payeer.Balance {
+	return s.store.balance.Clone()
+}
+
+/*
+** Control
+ */
+
+var _ strategy.Controllable = (*Strategy)(nil)
+
+func (s *Strategy) Quotes() []strategy.Quote {
+	quotes := []strategy.Quote{}
+	for _, share := range s.options.Get().Shares {
+		quote := strategy.Quote{Share: share.ID, Pair: share.Pair, Action: share.Action}
+		if order, ok := s.store.shareOrders.Get(share.ID); ok {
+			quote.OrderId = order.OrderId
+			quote.Price = order.Order.Price
+			quote.Amount = order.Order.Amount
+			quote.Placed = &order.Time
+		}
+		quotes = append(quotes, quote)
+	}
+	return quotes
+}
+
+func (s *Strategy) Pauses() *strategy.Pauses {
+	return &s.pauses
+}
+
+// CancelAll cancels the order of every share and refetches the balances.
+func (s *Strategy) CancelAll(ctx context.Context) error {
+	err := s.cancelAll(ctx)
+	s.initBalance()
+	return err
+}
+
+// cancelAll cancels the orders of all shares at once, each waits out its own
+// minimum lifetime, and records what filled before the cancel.
+func (s *Strategy) cancelAll(ctx context.Context) error {
+	name := s.options.Get().Name
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	for id, order := range s.store.shareOrders.Clone() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := strategy.CancelOrder(ctx, s.market.Payeer, order.OrderId, order.Time)
+			strategy.CountCancel(name, id, err == nil)
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("share %s: %w", id, err))
+				mu.Unlock()
+				return
+			}
+			s.store.shareOrders.Delete(id)
+			s.market.RecordFill(name, id, s.fetcher.OrderDetails(order.OrderId))
+			s.market.ForgetOrder(order.OrderId)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (s *Strategy) RefreshBalances() {
+	s.initBalance()
+}
+
+// Collect exports the balances and whether each share has an open order.
+func (s *Strategy) Collect(sink *metrics.Sink) {
+	options := s.options.Get()
+	strategy.CollectBalances(sink, options.Name, s.Balances())
+	for _, share := range options.Shares {
+		_, open := s.store.shareOrders.Get(share.ID)
+		value := 0.0
+		if open {
+			value = 1
+		}
+		sink.Gauge("shares_order_open", "1 while a share has an open order.", value, "strategy", options.Name, "share", share.ID, "pair", string(share.Pair), "action", string(share.Action))
+		if adjustment, ok := s.store.adjustments.Get(share.ID); ok {
+			sink.Gauge("shares_volatility_factor", "Multiple of the configured spread the volatility of the reference applies to a share.", adjustment.Factor.InexactFloat64(), "strategy", options.Name, "share", share.ID)
+			sink.Gauge("shares_volatility_ratio", "Binance price ratio of a share after the volatility adjustment.", adjustment.Ratio.InexactFloat64(), "strategy", options.Name, "share", share.ID)
+		}
+		if skew, ok := s.store.skews.Get(share.ID); ok {
+			skew.Collect(sink, "strategy", options.Name, "share", share.ID, "pair", string(share.Pair), "action", string(share.Action))
+		}
+	}
+	for symbol, estimator := range s.store.volatility.Clone() {
+		if bps, ok := estimator.Bps(); ok {
+			sink.Gauge("shares_volatility_bps", "Volatility of a reference mid in basis points per minute.", bps, "strategy", options.Name, "symbol", string(symbol))
+		}
+	}
+}
+
+// ReconcileOrder drops the share order the exchange reports closed; the
+// reconciler records its fills and repairs the balances.
+func (s *Strategy) ReconcileOrder(order *payeer.OrderDetails) {
+	orderId, _ := strconv.Atoi(order.Id)
+	for id, info := range s.store.shareOrders.Clone() {
+		if info.OrderId == orderId {
+			s.store.shareOrders.Delete(id)
+			s.logInfo("Share order closed on the exchange", "share", id, "orderId", orderId, "status", order.Status)
+		}
+	}
+}
+
+func (s *Strategy) ReconcileBalances(balances map[string]payeer.Balance) {
+	for asset, balance := range balances {
+		s.store.balance.Set(asset, balance)
+	}
+	s.logInfo("Balances reconciled")
+}
+
+/*
+** Logging
+ */
+
+// shareLog returns a logger carrying the strategy, share, pair and side.
+func (s *Strategy) shareLog(share *Share) *slog.Logger {
+	return slog.With(logging.Strategy(s.options.Get().Name), logging.Share(share.ID), logging.Pair(share.Pair), "action", share.Action)
+}
+
+func (s *Strategy) logError(msg string, args ...any) {
+	slog.With(logging.Strategy(s.options.Get().Name)).Error("[PayeerSharesStrategy] "+msg, args...)
+}
+
+func (s *Strategy) logInfo(msg string, args ...any) {
+	slog.With(logging.Strategy(s.options.Get().Name)).Info("[PayeerSharesStrategy] "+msg, args...)
+}
+
+/*
+** Helpers
+ */
+func (s *Strategy) getMyPrices(pair payeer.Pair, action payeer.Action) []payeer.PriceAmount {
+	prices := []payeer.PriceAmount{}
+	s.store.shareOrders.Range(func(_ string, order ShareOrderInfo) bool {
+		if order.Order.Pair == pair && order.Order.Action == action {
+			orderPrice := decimal.RequireFromString(order.Order.Price)
+			orderAmount := decimal.RequireFromString(order.Order.Amount)
+			index := slices.IndexFunc(prices, func(p payeer.PriceAmount) bool {
+				return p.Price.Equal(orderPrice)
+			})
+			if index != -1 {
+				prices[index].Amount = prices[index].Amount.Add(orderAmount)
+			} else {
+				prices = append(prices, payeer.PriceAmount{
+					Price:  orderPrice,
+					Amount: orderAmount,
+				})
+			}
+		}
+		return true
+	})
+	return prices
+}
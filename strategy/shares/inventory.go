@@ -0,0 +1,40 @@
+package shares
+
+import (
+	"automata/inventory"
+
+	"github.com/shopspring/decimal"
+)
+
+// inventorySkew returns the skew of share at the balances of its pair valued
+// at the reference mid; nil without a skew for the pair or while the mid is
+// unknown. The balances count what is on hold in open orders, as it is still
+// inventory. A side that reaches or leaves its limit is logged.
+func (s *Strategy) inventorySkew(share *Share) *inventory.Adjustment {
+	skew := s.options.Get().Skew[share.Pair]
+	if skew == nil {
+		return nil
+	}
+	mid, ok := s.referenceMid(share.BinanceSymbol)
+	if !ok {
+		return nil
+	}
+	base, _ := s.store.balance.Get(share.Pair.Base())
+	quote, _ := s.store.balance.Get(share.Pair.Quote())
+	adjustment := skew.Adjust(share.Action,
+		decimal.NewFromFloat(base.Available+base.Hold),
+		decimal.NewFromFloat(quote.Available+quote.Hold),
+		mid)
+	if adjustment == nil {
+		s.store.skews.Delete(share.ID)
+		return nil
+	}
+	previous, ok := s.store.skews.Get(share.ID)
+	if adjustment.Stopped && (!ok || !previous.Stopped) {
+		s.logInfo("Inventory at its limit, share stopped", "share", share.ID, "limit", inventory.Limit(share.Action), "baseShare", adjustment.BaseShare.StringFixed(4))
+	} else if !adjustment.Stopped && ok && previous.Stopped {
+		s.logInfo("Inventory back within its limits, share resumed", "share", share.ID, "baseShare", adjustment.BaseShare.StringFixed(4))
+	}
+	s.store.skews.Set(share.ID, adjustment)
+	return adjustment
+}
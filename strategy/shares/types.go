@@ -0,0 +1,126 @@
+package shares
+
+import (
+	"automata/client/binance"
+	"automata/client/payeer"
+	payeerFetcher "automata/client/payeer/fetcher"
+	"automata/inventory"
+	"automata/metrics"
+	"automata/msync"
+	"automata/strategy"
+	"automata/volatility"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type Options struct {
+	// Name identifies the orders of this strategy in the state store;
+	// defaults to "shares".
+	Name                string
+	Shares              []Share
+	RefetchBalanceDelay time.Duration
+	OrdersFetchInterval time.Duration
+	// Volatility adapts the share ratios to the volatility of their
+	// references; nil keeps them as configured.
+	Volatility *VolatilityOptions
+	// Skew shifts the prices and sizes of the shares of a pair towards its
+	// target inventory.
+	Skew map[payeer.Pair]*inventory.Skew
+}
+
+type VolatilityOptions struct {
+	Estimator    volatility.Options
+	ReferenceBps decimal.Decimal
+	MinFactor    decimal.Decimal
+	MaxFactor    decimal.Decimal
+}
+
+type Share struct {
+	ID                    string
+	Action                payeer.Action
+	Pair                  payeer.Pair
+	BinanceSymbol         binance.Symbol
+	BinanceTickerInterval time.Duration
+	Share                 decimal.Decimal
+	BinancePriceRatio     decimal.Decimal
+	Edge                  *payeer.Edge
+	RemainingAmountChange decimal.Decimal
+	LoopInterval          time.Duration
+}
+
+func (o *Options) share(id string) *Share {
+	for i := range o.Shares {
+		if o.Shares[i].ID == id {
+			return &o.Shares[i]
+		}
+	}
+	return nil
+}
+
+// setHedgeFee prices the edge of every share with the hedge fee of the market.
+func (o *Options) setHedgeFee(percent decimal.Decimal) {
+	for i := range o.Shares {
+		o.Shares[i].Edge = o.Shares[i].Edge.WithHedgeFee(percent)
+	}
+}
+
+type ShareOrderInfo struct {
+	OrderId int
+	Order   *payeer.OrderParams
+	Time    time.Time
+	Trace   string
+}
+
+type store struct {
+	info           *payeer.InfoResponse
+	binanceTickers payeer.Tickers
+	balance        *msync.MuMap[string, payeer.Balance]
+	orders         *msync.MuMap[payeer.Pair, payeer.PairsOrderInfo]
+	shareOrders    *msync.MuMap[string, ShareOrderInfo]
+	volatility     *msync.MuMap[binance.Symbol, *volatility.Estimator]
+	adjustments    *msync.MuMap[string, RatioAdjustment]
+	skews          *msync.MuMap[string, *inventory.Adjustment]
+}
+
+type state struct {
+}
+
+type Strategy struct {
+	options       *msync.Mu[*Options]
+	market        *strategy.Market
+	fetcher       *payeerFetcher.Fetcher
+	binanceClient *binance.Client
+	store         *store
+	state         *state
+	pauses        strategy.Pauses
+}
+
+var _ strategy.Strategy = (*Strategy)(nil)
+
+func NewStrategy(
+	market *strategy.Market,
+	options *Options,
+) *Strategy {
+	if options.Name == "" {
+		options.Name = "shares"
+	}
+	options.setHedgeFee(market.HedgeFeePercent)
+	s := &Strategy{
+		binanceClient: market.Binance,
+		market:        market,
+		options:       msync.NewMu(options),
+		fetcher:       market.Fetcher,
+		store: &store{
+			orders:         msync.NewMuMap[payeer.Pair, payeer.PairsOrderInfo](),
+			balance:        msync.NewMuMap[string, payeer.Balance](),
+			binanceTickers: msync.NewMuMap[binance.Symbol, binance.OrderBookTickerStreamResult](),
+			shareOrders:    msync.NewMuMap[string, ShareOrderInfo](),
+			volatility:     msync.NewMuMap[binance.Symbol, *volatility.Estimator](),
+			adjustments:    msync.NewMuMap[string, RatioAdjustment](),
+			skews:          msync.NewMuMap[string, *inventory.Adjustment](),
+		},
+	}
+	metrics.Register(s)
+	return s
+}
@@ -0,0 +1,124 @@
+package shares
+
+import (
+	"automata/client/binance"
+	"automata/client/payeer"
+	"automata/strategy"
+	"automata/volatility"
+	"cmp"
+	"context"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	one = decimal.NewFromInt(1)
+	two = decimal.NewFromInt(2)
+	// minBuyRatio and maxSellRatio bound an adjusted ratio like the config
+	// bounds the configured one.
+	minBuyRatio  = decimal.RequireFromString("0.5")
+	maxSellRatio = two
+	// logFactorStep is how far the factor of a share has to move before the
+	// adjustment is logged again.
+	logFactorStep = decimal.RequireFromString("0.05")
+)
+
+// RatioAdjustment is the volatility adjustment last applied to the ratio of a
+// share.
+type RatioAdjustment struct {
+	Bps    float64
+	Factor decimal.Decimal
+	Ratio  decimal.Decimal
+	logged decimal.Decimal
+}
+
+// Factor returns the multiple of the configured spread for a volatility of
+// bps, within MinFactor and MaxFactor.
+func (v *VolatilityOptions) Factor(bps float64) decimal.Decimal {
+	factor := decimal.NewFromFloat(bps).Div(v.ReferenceBps)
+	return decimal.Min(decimal.Max(factor, v.MinFactor), v.MaxFactor)
+}
+
+// scaleRatio moves ratio towards or away from 1 by factor: a buy ratio of 0.98
+// with factor 2 becomes 0.96, a sell ratio of 1.02 becomes 1.04.
+func scaleRatio(action payeer.Action, ratio decimal.Decimal, factor decimal.Decimal) decimal.Decimal {
+	if action == payeer.ACTION_SELL {
+		return decimal.Min(one.Add(ratio.Sub(one).Mul(factor)), maxSellRatio)
+	}
+	return decimal.Max(one.Sub(one.Sub(ratio).Mul(factor)), minBuyRatio)
+}
+
+// initVolatility creates one estimator per reference of the shares.
+func (s *Strategy) initVolatility() {
+	options := s.options.Get()
+	if options.Volatility == nil {
+		return
+	}
+	for _, share := range options.Shares {
+		if _, ok := s.store.volatility.Get(share.BinanceSymbol); !ok {
+			s.store.volatility.Set(share.BinanceSymbol, volatility.NewEstimator(&options.Volatility.Estimator))
+		}
+	}
+	s.logInfo("Volatility estimators initialized", "model", cmp.Or(options.Volatility.Estimator.Model, volatility.MODEL_EWMA), "symbols", s.store.volatility.Len())
+}
+
+// runVolatilityLoop samples the reference mid of every estimator.
+func (s *Strategy) runVolatilityLoop(ctx context.Context) {
+	interval := cmp.Or(s.options.Get().Volatility.Estimator.SampleInterval, volatility.DefaultSampleInterval)
+	for strategy.Sleep(ctx, interval) {
+		now := time.Now()
+		for symbol, estimator := range s.store.volatility.Clone() {
+			if mid, ok := s.referenceMid(symbol); ok {
+				estimator.Add(now, mid)
+			}
+		}
+	}
+}
+
+func (s *Strategy) referenceMid(symbol binance.Symbol) (decimal.Decimal, bool) {
+	ticker, ok := s.store.binanceTickers.Get(symbol)
+	if !ok {
+		return decimal.Zero, false
+	}
+	bid, err := decimal.NewFromString(ticker.BidPrice)
+	if err != nil {
+		return decimal.Zero, false
+	}
+	ask, err := decimal.NewFromString(ticker.AskPrice)
+	if err != nil {
+		return decimal.Zero, false
+	}
+	return bid.Add(ask).Div(two), true
+}
+
+// volatilityRatio returns the Binance price ratio of share scaled by the
+// volatility of its reference, or as configured while there is no estimate.
+// An adjustment that moved by logFactorStep since it was last logged is
+// logged again.
+func (s *Strategy) volatilityRatio(share *Share) decimal.Decimal {
+	options := s.options.Get().Volatility
+	if options == nil {
+		return share.BinancePriceRatio
+	}
+	estimator, ok := s.store.volatility.Get(share.BinanceSymbol)
+	if !ok {
+		return share.BinancePriceRatio
+	}
+	bps, ok := estimator.Bps()
+	if !ok {
+		return share.BinancePriceRatio
+	}
+	factor := options.Factor(bps)
+	ratio := scaleRatio(share.Action, share.BinancePriceRatio, factor)
+	adjustment := RatioAdjustment{Bps: bps, Factor: factor, Ratio: ratio}
+	previous, ok := s.store.adjustments.Get(share.ID)
+	adjustment.logged = previous.logged
+	if !ok || factor.Sub(previous.logged).Abs().GreaterThanOrEqual(logFactorStep) {
+		adjustment.logged = factor
+		s.logInfo("Volatility adjusted ratio", "share", share.ID, "volatilityBps", decimal.NewFromFloat(bps).StringFixed(2),
+			"factor", factor.StringFixed(3), "configuredRatio", share.BinancePriceRatio.String(), "ratio", ratio.StringFixed(6))
+	}
+	s.store.adjustments.Set(share.ID, adjustment)
+	return ratio
+}
@@ -0,0 +1,112 @@
+package strategy
+
+import (
+	"automata/config"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// ShutdownSummary records how a Runner shut down and which orders may still
+// be resting on the exchange.
+type ShutdownSummary struct {
+	Reason     string             `json:"reason"`
+	Started    time.Time          `json:"started"`
+	Duration   config.Duration    `json:"duration"`
+	TimedOut   bool               `json:"timedOut"`
+	Strategies []StrategyShutdown `json:"strategies"`
+	// Verified is set when MyOrders was checked after every strategy stopped.
+	Verified    bool   `json:"verified"`
+	VerifyError string `json:"verifyError,omitempty"`
+	// StillOpen are tracked orders MyOrders still lists after a second cancel.
+	StillOpen []int `json:"stillOpen,omitempty"`
+	// Untracked are open orders no strategy knew about, placed by hand or by
+	// another process; they are left alone.
+	Untracked []int `json:"untracked,omitempty"`
+}
+
+type StrategyShutdown struct {
+	Name      string `json:"name"`
+	State     State  `json:"state"`
+	Tracked   []int  `json:"tracked"`
+	Cancelled []int  `json:"cancelled"`
+	Remaining []int  `json:"remaining"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Clean reports whether every strategy stopped and no tracked order is known
+// to be open.
+func (s *ShutdownSummary) Clean() bool {
+	if s.TimedOut || s.VerifyError != "" || len(s.StillOpen) > 0 {
+		return false
+	}
+	for _, strategy := range s.Strategies {
+		if strategy.State != STATE_STOPPED || len(strategy.Remaining) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *ShutdownSummary) Log() {
+	for _, strategy := range s.Strategies {
+		slog.Info("[Runner] Shutdown summary",
+			"strategy", strategy.Name,
+			"state", strategy.State,
+			"tracked", len(strategy.Tracked),
+			"cancelled", len(strategy.Cancelled),
+			"remaining", strategy.Remaining,
+			"error", strategy.Error,
+		)
+	}
+	attrs := []any{
+		"reason", s.Reason,
+		"duration", s.Duration,
+		"verified", s.Verified,
+		"stillOpen", s.StillOpen,
+		"untracked", s.Untracked,
+	}
+	if s.VerifyError != "" {
+		attrs = append(attrs, "verifyError", s.VerifyError)
+	}
+	if s.Clean() {
+		slog.Info("[Runner] Shutdown complete", attrs...)
+	} else {
+		slog.Error("[Runner] Shutdown incomplete, check the exchange for open orders", append(attrs, "timedOut", s.TimedOut)...)
+	}
+}
+
+// WriteFile writes the summary as JSON to path.
+func (s *ShutdownSummary) WriteFile(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// NotifyShutdown returns a context cancelled by the first SIGINT or SIGTERM,
+// with the signal as cause. A second signal exits the process immediately,
+// without waiting for orders to be cancelled.
+func NotifyShutdown(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		slog.Info("[Runner] Signal received, shutting down. Send it again to exit immediately", "signal", sig)
+		cancel(fmt.Errorf("received %s", sig))
+		sig = <-signals
+		slog.Error("[Runner] Second signal received, exiting without waiting for cancellations", "signal", sig)
+		os.Exit(130)
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel(context.Canceled)
+	}
+}
@@ -0,0 +1,83 @@
+// Package strategy defines the lifecycle every trading strategy implements and
+// a runner that drives several of them in one process over shared clients.
+package strategy
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Strategy is driven by a Runner through Init, Run and Stop, in that order.
+type Strategy interface {
+	// Init loads exchange info, balances and open orders; nothing is placed yet.
+	Init(ctx context.Context) error
+	// Run places and maintains orders until ctx is cancelled, then returns
+	// without placing anything new.
+	Run(ctx context.Context) error
+	// Stop cancels the orders the strategy still has open and waits until the
+	// exchange acknowledged every cancellation or ctx expires.
+	Stop(ctx context.Context) error
+	Status() Status
+}
+
+type State string
+
+const (
+	STATE_NEW      State = "new"
+	STATE_INIT     State = "init"
+	STATE_RUNNING  State = "running"
+	STATE_STOPPING State = "stopping"
+	STATE_STOPPED  State = "stopped"
+	STATE_FAILED   State = "failed"
+)
+
+// Status is reported by the strategy; the runner fills in Name, State, Since
+// and Error.
+type Status struct {
+	Name       string         `json:"name"`
+	State      State          `json:"state"`
+	Since      time.Time      `json:"since"`
+	OpenOrders []int          `json:"openOrders"`
+	Details    map[string]any `json:"details,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// Sleep waits for d or until ctx is done and reports whether the caller
+// should continue.
+func Sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// RunLoops runs every loop in its own goroutine until all of them returned.
+// The first loop to fail cancels the others and its error is returned, so a
+// strategy's Run fails instead of the process exiting.
+func RunLoops(ctx context.Context, loops ...func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var first error
+	for _, loop := range loops {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := loop(ctx); err != nil {
+				once.Do(func() { first = err })
+				cancel(err)
+			}
+		}()
+	}
+	wg.Wait()
+	return first
+}
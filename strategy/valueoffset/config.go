@@ -0,0 +1,123 @@
+package valueoffset
+
+import (
+	"automata/client/binance"
+	"automata/client/payeer"
+	"automata/config"
+	"automata/inventory"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type Config struct {
+	Pairs                  map[payeer.Pair]binance.Symbol  `json:"pairs" desc:"Payeer pairs to quote and their Binance symbols or reference names, e.g. BTCRUB"`
+	BinanceTickerInterval  config.Duration                 `json:"binanceTickerInterval"`
+	MaxPriceRatio          decimal.Decimal                 `json:"maxPriceRatio" desc:"replace an order once Binance moved by more than this ratio"`
+	ReplacementValueOffset decimal.Decimal                 `json:"replacementValueOffset" desc:"replace an order once the value ahead of it moved by more than this"`
+	Selector               SelectorConfig                  `json:"selector"`
+	BuyEnabled             bool                            `json:"buyEnabled"`
+	SellEnabled            bool                            `json:"sellEnabled"`
+	Amount                 decimal.Decimal                 `json:"amount" desc:"order amount in base asset"`
+	Skew                   map[payeer.Pair]*inventory.Skew `json:"skew,omitempty" desc:"target inventory per pair; prices and amounts shift as the balances deviate from it"`
+}
+
+type SelectorConfig struct {
+	Symbol                  binance.Symbol  `json:"symbol"`
+	PlacementValueOffset    decimal.Decimal `json:"placementValueOffset" desc:"quote value allowed ahead of the order"`
+	ElevationPriceFraction  decimal.Decimal `json:"elevationPriceFraction" desc:"maximum price step over the selected level, as a fraction of price"`
+	MaxWmaSurplus           decimal.Decimal `json:"maxWmaSurplus"`
+	WmaTake                 int             `json:"wmaTake"`
+	WmaTakeAmount           decimal.Decimal `json:"wmaTakeAmount"`
+	BidMaxBinancePriceRatio decimal.Decimal `json:"bidMaxBinancePriceRatio" desc:"highest bid relative to the Binance bid"`
+	AskMinBinancePriceRatio decimal.Decimal `json:"askMinBinancePriceRatio" desc:"lowest ask relative to the Binance ask"`
+	Edge                    *payeer.Edge    `json:"edge,omitempty" desc:"net edge in basis points to earn after the Payeer maker fee and the hedge fee; tightens the Binance ratios"`
+	// Pipelines replace the default value offset, elevation and Binance
+	// filter chain for a pair and side.
+	Pipelines map[payeer.Pair]map[payeer.Action]*payeer.PipelineConfig `json:"pipelines,omitempty" desc:"selector stages per pair and side, see cmd/selector -stages"`
+}
+
+func (c *Config) Validate(problems *config.Problems, path string) {
+	if len(c.Pairs) == 0 {
+		problems.Add(path+".pairs", "at least one pair is required")
+	}
+	for pair, symbol := range c.Pairs {
+		problems.Pair(path+".pairs", pair)
+		problems.Symbol(path+".pairs."+string(pair), symbol)
+	}
+	problems.Interval(path+".binanceTickerInterval", c.BinanceTickerInterval, 10*time.Millisecond)
+	problems.DecimalRange(path+".maxPriceRatio", c.MaxPriceRatio, "1", "1.1")
+	problems.NotNegative(path+".replacementValueOffset", c.ReplacementValueOffset)
+	problems.Positive(path+".amount", c.Amount)
+	if !c.BuyEnabled && !c.SellEnabled {
+		problems.Add(path, "neither buyEnabled nor sellEnabled is set")
+	}
+	c.Selector.validate(problems, path+".selector")
+	for pair, skew := range c.Skew {
+		if _, ok := c.Pairs[pair]; !ok {
+			problems.Add(path+".skew."+string(pair), "pair is not listed in pairs")
+		}
+		problems.Skew(path+".skew."+string(pair), skew)
+	}
+	symbols := map[binance.Symbol]bool{}
+	for _, symbol := range c.Pairs {
+		symbols[symbol] = true
+	}
+	for pair, sides := range c.Selector.Pipelines {
+		pairPath := path + ".selector.pipelines." + string(pair)
+		if _, ok := c.Pairs[pair]; !ok {
+			problems.Add(pairPath, "pair is not listed in pairs")
+		}
+		for action, pipeline := range sides {
+			problems.Action(pairPath, action)
+			if pipeline == nil {
+				continue
+			}
+			if pipeline.Symbol != "" && !symbols[pipeline.Symbol] {
+				problems.Addf(pairPath+"."+string(action)+".symbol", "%s is not a symbol of pairs, its ticker is not subscribed", pipeline.Symbol)
+			}
+			if _, err := payeer.NewPipeline(pipeline); err != nil {
+				problems.Add(pairPath+"."+string(action), err.Error())
+			}
+		}
+	}
+}
+
+func (c *SelectorConfig) validate(problems *config.Problems, path string) {
+	problems.Symbol(path+".symbol", c.Symbol)
+	problems.NotNegative(path+".placementValueOffset", c.PlacementValueOffset)
+	problems.DecimalRange(path+".elevationPriceFraction", c.ElevationPriceFraction, "0", "0.01")
+	problems.DecimalRange(path+".maxWmaSurplus", c.MaxWmaSurplus, "0", "1")
+	if c.WmaTake < 0 {
+		problems.Addf(path+".wmaTake", "must not be negative, got %d", c.WmaTake)
+	}
+	problems.NotNegative(path+".wmaTakeAmount", c.WmaTakeAmount)
+	problems.DecimalRange(path+".bidMaxBinancePriceRatio", c.BidMaxBinancePriceRatio, "0.5", "1")
+	problems.DecimalRange(path+".askMinBinancePriceRatio", c.AskMinBinancePriceRatio, "1", "2")
+	problems.Edge(path+".edge", c.Edge)
+}
+
+func (c *Config) Options() *Options {
+	return &Options{
+		Pairs:                  c.Pairs,
+		BinanceTickerInterval:  c.BinanceTickerInterval.D(),
+		MaxPriceRatio:          c.MaxPriceRatio.String(),
+		ReplacementValueOffset: c.ReplacementValueOffset.String(),
+		SelectorConfig: &payeer.PayeerPriceSelectorConfig{
+			PlacementValueOffset:    c.Selector.PlacementValueOffset,
+			ElevationPriceFraction:  c.Selector.ElevationPriceFraction,
+			MaxWmaSurplus:           c.Selector.MaxWmaSurplus,
+			WmaTakeAmount:           c.Selector.WmaTakeAmount,
+			WmaTake:                 c.Selector.WmaTake,
+			Symbol:                  c.Selector.Symbol,
+			BidMaxBinancePriceRatio: c.Selector.BidMaxBinancePriceRatio,
+			AskMinBinancePriceRatio: c.Selector.AskMinBinancePriceRatio,
+			Edge:                    c.Selector.Edge,
+			Pipelines:               c.Selector.Pipelines,
+		},
+		BuyEnabled:  c.BuyEnabled,
+		SellEnabled: c.SellEnabled,
+		Amount:      c.Amount,
+		Skew:        c.Skew,
+	}
+}
@@ -0,0 +1,53 @@
+package valueoffset
+
+import (
+	"automata/client/payeer"
+	"automata/inventory"
+	"log/slog"
+
+	"github.com/shopspring/decimal"
+)
+
+type skewKey struct {
+	pair   payeer.Pair
+	action payeer.Action
+}
+
+// inventorySkew returns the skew of action on pair at its balances valued at
+// the mid of the pair's Binance ticker; nil without a skew for the pair or
+// while the ticker is unknown. A side that reaches or leaves its limit is
+// logged.
+func (s *Strategy) inventorySkew(p *valueOffsetParams, pair payeer.Pair, action payeer.Action) *inventory.Adjustment {
+	key := skewKey{pair, action}
+	skew := p.options.Skew[pair]
+	if skew == nil {
+		return nil
+	}
+	ticker, ok := s.binanceTickers.Get(p.options.Pairs[pair])
+	if !ok {
+		return nil
+	}
+	bid, bidErr := decimal.NewFromString(ticker.BidPrice)
+	ask, askErr := decimal.NewFromString(ticker.AskPrice)
+	if bidErr != nil || askErr != nil {
+		return nil
+	}
+	base, _ := s.balance.Get(pair.Base())
+	quote, _ := s.balance.Get(pair.Quote())
+	adjustment := skew.Adjust(action,
+		decimal.NewFromFloat(base.Available+base.Hold),
+		decimal.NewFromFloat(quote.Available+quote.Hold),
+		bid.Add(ask).Div(decimal.NewFromInt(2)))
+	if adjustment == nil {
+		s.skews.Delete(key)
+		return nil
+	}
+	previous, ok := s.skews.Get(key)
+	if adjustment.Stopped && (!ok || !previous.Stopped) {
+		slog.Info("[ValueOffsetStrategy] Inventory at its limit, side stopped", "pair", pair, "action", action, "limit", inventory.Limit(action), "baseShare", adjustment.BaseShare.StringFixed(4))
+	} else if !adjustment.Stopped && ok && previous.Stopped {
+		slog.Info("[ValueOffsetStrategy] Inventory back within its limits, side resumed", "pair", pair, "action", action, "baseShare", adjustment.BaseShare.StringFixed(4))
+	}
+	s.skews.Set(key, adjustment)
+	return adjustment
+}
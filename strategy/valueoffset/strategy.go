@@ -0,0 +1,917 @@
+package valueoffset
+
+import (
+	"automata/client/binance"
+	"automata/client/payeer"
+	"automata/inventory"
+	"automata/journal"
+	"automata/logging"
+	"automata/metrics"
+	"automata/msync"
+	"automata/risk"
+	"automata/strategy"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type Options struct {
+	// Name identifies the orders of this strategy in the state store;
+	// defaults to "value-offset".
+	Name string
+	// PlacementValueOffset   string
+	MaxPriceRatio          string
+	ReplacementValueOffset string
+	SelectorConfig         *payeer.PayeerPriceSelectorConfig
+	Pairs                  map[payeer.Pair]binance.Symbol
+	BinanceTickerInterval  time.Duration
+	BuyEnabled             bool
+	SellEnabled            bool
+	Amount                 decimal.Decimal
+	// Skew shifts the prices and amounts of a pair towards its target
+	// inventory.
+	Skew map[payeer.Pair]*inventory.Skew
+}
+
+type constansts struct {
+	// placementValueOffset   decimal.Decimal
+	replacementValueOffset decimal.Decimal
+	maxPriceRatio          decimal.Decimal
+}
+
+type placedMetadata struct {
+	binancePrice decimal.Decimal
+	action       payeer.Action
+}
+
+type store struct {
+	orders             *msync.MuMap[int, payeer.OrderParams]
+	times              *msync.MuMap[int, time.Time]
+	binancePricePlaced *msync.MuMap[int, placedMetadata]
+	traces             *msync.MuMap[int, string]
+	minWeights         *msync.Mu[int]
+	weightsTimestamp   *msync.Mu[time.Time]
+	binanceTickers     payeer.Tickers
+	wait               *msync.MuMap[payeer.Pair, bool]
+	balance            *msync.MuMap[string, payeer.Balance]
+	info               *payeer.InfoResponse
+	skews              *msync.MuMap[skewKey, *inventory.Adjustment]
+}
+
+// valueOffsetParams is the reloadable part of the strategy. It is replaced as a
+// whole and every loop iteration works on a single snapshot.
+type valueOffsetParams struct {
+	options  *Options
+	selector *payeer.PayeerPriceSelector
+	constansts
+}
+
+type Strategy struct {
+	params        *msync.Mu[*valueOffsetParams]
+	market        *strategy.Market
+	binanceClient *binance.Client
+	payeerClient  payeer.Api
+	pauses        strategy.Pauses
+	store
+}
+
+var _ strategy.Strategy = (*Strategy)(nil)
+
+func NewStrategy(
+	market *strategy.Market,
+	options *Options,
+) (*Strategy, error) {
+	if options.Name == "" {
+		options.Name = "value-offset"
+	}
+	options.SelectorConfig.Edge = options.SelectorConfig.Edge.WithHedgeFee(market.HedgeFeePercent)
+	for _, symbol := range options.Pairs {
+		market.SubscribeReference(symbol, options.BinanceTickerInterval)
+	}
+	binanceTickers := market.ReferenceTickers()
+	params, err := newValueOffsetParams(options, binanceTickers)
+	if err != nil {
+		return nil, err
+	}
+	s := &Strategy{
+		params:        msync.NewMu(params),
+		market:        market,
+		binanceClient: market.Binance,
+		payeerClient:  market.Payeer,
+		store: store{
+			orders:             msync.NewMuMap[int, payeer.OrderParams](),
+			times:              msync.NewMuMap[int, time.Time](),
+			binancePricePlaced: msync.NewMuMap[int, placedMetadata](),
+			traces:             msync.NewMuMap[int, string](),
+			minWeights:         msync.NewMu(600),
+			weightsTimestamp:   msync.NewMu(time.Now()),
+			binanceTickers:     binanceTickers,
+			wait:               msync.NewMuMap[payeer.Pair, bool](),
+			balance:            msync.NewMuMap[string, payeer.Balance](),
+			skews:              msync.NewMuMap[skewKey, *inventory.Adjustment](),
+		},
+	}
+	metrics.Register(s)
+	return s, nil
+}
+
+func newValueOffsetParams(
+	options *Options,
+	binanceTickers payeer.Tickers,
+) (*valueOffsetParams, error) {
+	maxPriceDelta, err := decimal.NewFromString(options.MaxPriceRatio)
+	if err != nil {
+		return nil, err
+	}
+	replacementValueOffset, err := decimal.NewFromString(options.ReplacementValueOffset)
+	if err != nil {
+		return nil, err
+	}
+	selector, err := payeer.NewPipelineSelector(options.SelectorConfig, binanceTickers)
+	if err != nil {
+		return nil, err
+	}
+	return &valueOffsetParams{
+		options: options,
+		constansts: constansts{
+			// placementValueOffset:   valueOffset,
+			replacementValueOffset: replacementValueOffset,
+			maxPriceRatio:          maxPriceDelta,
+		},
+		selector: selector,
+	}, nil
+}
+
+// Reconfigure swaps in new selector settings, price ratios, value offsets and
+// the order amount; loops pick them up at their next iteration. Pairs, sides
+// and Binance streams are wired up in Run and need a restart.
+func (s *Strategy) Reconfigure(options *Options) error {
+	current := s.params.Get().options
+	if !maps.Equal(current.Pairs, options.Pairs) ||
+		current.BuyEnabled != options.BuyEnabled ||
+		current.SellEnabled != options.SellEnabled ||
+		current.BinanceTickerInterval != options.BinanceTickerInterval {
+		return errors.New("pairs, sides or Binance ticker interval changed, restart required")
+	}
+	options.Name = current.Name
+	options.SelectorConfig.Edge = options.SelectorConfig.Edge.WithHedgeFee(s.market.HedgeFeePercent)
+	params, err := newValueOffsetParams(options, s.binanceTickers)
+	if err != nil {
+		return err
+	}
+	params.selector.SetInfo(s.info)
+	s.params.Set(params)
+	slog.Info("[ValueOffsetStrategy] Options reconfigured")
+	return nil
+}
+
+func (s *Strategy) Init(ctx context.Context) error {
+	if err := s.cancelInitialOrders(ctx); err != nil {
+		return err
+	}
+	if err := s.resetBalance(); err != nil {
+		return err
+	}
+	s.resetInfo()
+	return nil
+}
+
+// Run returns the first failed exchange request of any loop; the other loops
+// stop with it.
+func (s *Strategy) Run(ctx context.Context) error {
+	loops := []func(ctx context.Context) error{s.OrdersUpdateLoop}
+	options := s.params.Get().options
+	for pair := range options.Pairs {
+		if options.BuyEnabled {
+			loops = append(loops, func(ctx context.Context) error { return s.PlaceOrderLoop(ctx, payeer.ACTION_BUY, pair) })
+		}
+		if options.SellEnabled {
+			loops = append(loops, func(ctx context.Context) error { return s.PlaceOrderLoop(ctx, payeer.ACTION_SELL, pair) })
+		}
+		if options.SellEnabled || options.BuyEnabled {
+			loops = append(loops, func(ctx context.Context) error { return s.CheckAndCancelLoop(ctx, pair) })
+		}
+	}
+	return strategy.RunLoops(ctx, loops...)
+}
+
+// Stop cancels every order the strategy placed and records their fills; the
+// loops have returned by now.
+func (s *Strategy) Stop(ctx context.Context) error {
+	return s.cancelAll(ctx, "shutdown")
+}
+
+func (s *Strategy) Status() strategy.Status {
+	return strategy.Status{
+		OpenOrders: s.orders.Keys(),
+		Details:    map[string]any{"weightRemaining": s.minWeights.Get()},
+	}
+}
+
+var _ strategy.Reconcilable = (*Strategy)(nil)
+var _ strategy.Controllable = (*Strategy)(nil)
+
+func (s *Strategy) Balances() map[string]payeer.Balance {
+	return s.balance.Clone()
+}
+
+// Quotes lists the enabled pair sides with the order each has open.
+func (s *Strategy) Quotes() []strategy.Quote {
+	options := s.params.Get().options
+	open := s.orders.Clone()
+	quotes := []strategy.Quote{}
+	for pair := range options.Pairs {
+		for _, action := range []payeer.Action{payeer.ACTION_BUY, payeer.ACTION_SELL} {
+			if (action == payeer.ACTION_BUY && !options.BuyEnabled) || (action == payeer.ACTION_SELL && !options.SellEnabled) {
+				continue
+			}
+			quote := strategy.Quote{Pair: pair, Action: action}
+			for orderId, params := range open {
+				if params.Pair == pair && params.Action == action {
+					placed, _ := s.times.Get(orderId)
+					quote.OrderId = orderId
+					quote.Price = params.Price
+					quote.Amount = params.Amount
+					quote.Placed = &placed
+				}
+			}
+			quotes = append(quotes, quote)
+		}
+	}
+	return quotes
+}
+
+func (s *Strategy) Pauses() *strategy.Pauses {
+	return &s.pauses
+}
+
+// CancelAll cancels every order the strategy placed and refetches the
+// balances.
+func (s *Strategy) CancelAll(ctx context.Context) error {
+	err := s.cancelAll(ctx, "admin")
+	return errors.Join(err, s.resetBalance())
+}
+
+// cancelAll cancels every open order and records what filled before the
+// cancel.
+func (s *Strategy) cancelAll(ctx context.Context, loop string) error {
+	name := s.params.Get().options.Name
+	var errs []error
+	for _, orderId := range s.orders.Keys() {
+		placed, _ := s.times.Get(orderId)
+		err := strategy.CancelOrder(ctx, s.payeerClient, orderId, placed)
+		strategy.CountCancel(name, loop, err == nil)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := s.closeOrder(orderId); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Strategy) RefreshBalances() {
+	if err := s.resetBalance(); err != nil {
+		slog.Error("[ValueOffsetStrategy] Fetching balances failed", "error", err)
+	}
+}
+
+// Collect exports the balances and the inventory skew of every side.
+func (s *Strategy) Collect(sink *metrics.Sink) {
+	name := s.params.Get().options.Name
+	strategy.CollectBalances(sink, name, s.Balances())
+	for key, skew := range s.skews.Clone() {
+		skew.Collect(sink, "strategy", name, "pair", string(key.pair), "action", string(key.action))
+	}
+}
+
+// ReconcileOrder drops an order the exchange reports closed.
+func (s *Strategy) ReconcileOrder(order *payeer.OrderDetails) {
+	orderId, _ := strconv.Atoi(order.Id)
+	s.times.Delete(orderId)
+	s.orders.Delete(orderId)
+	s.binancePricePlaced.Delete(orderId)
+	slog.Info("[ValueOffsetStrategy] Order closed on the exchange", "orderId", orderId, "status", order.Status)
+}
+
+func (s *Strategy) ReconcileBalances(balances map[string]payeer.Balance) {
+	for currency, balance := range balances {
+		// Assets already cached are overwritten even when emptied, so they
+		// do not keep drifting
+		if _, ok := s.balance.Get(currency); ok || balance.Available > 0 {
+			s.balance.Set(currency, balance)
+		}
+	}
+	slog.Info("[ValueOffsetStrategy] Balances reconciled")
+}
+
+func (s *Strategy) OrdersUpdateLoop(ctx context.Context) error {
+	for {
+		if !strategy.Sleep(ctx, time.Second*5) {
+			return nil
+		}
+		slog.Info("[ValueOffsetStrategy] checking orders inner list", "orderIds", s.orders.Keys())
+		filled := []*payeer.OrderDetails{}
+		var err error
+		s.orders.Range(func(orderId int, details payeer.OrderParams) bool {
+			var order *payeer.OrderDetails
+			if order, err = s.fetchOrderDetails(orderId); err != nil {
+				return false
+			}
+			if decimal.RequireFromString(order.ValueRemaining).IsZero() {
+				s.orderLog(orderId).Info("[ValueOffsetStrategy] Order filled", "amount", order.AmountProcessed, "value", order.ValueProcessed)
+				filled = append(filled, order)
+			}
+			slog.Debug("[ValueOffsetStrategy] order details", "order", *order)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		if len(filled) == 0 {
+			continue
+		}
+		// A recorded fill triggers a reconcile, so the balances are fetched
+		// before the fills are recorded
+		if err := s.resetBalance(); err != nil {
+			return err
+		}
+		for _, order := range filled {
+			orderId, _ := strconv.Atoi(order.Id)
+			s.market.RecordFill(s.params.Get().options.Name, "", order)
+			s.forgetOrder(orderId)
+		}
+	}
+}
+
+func (s *Strategy) PlaceOrderLoop(ctx context.Context, action payeer.Action, pair payeer.Pair) error {
+	for {
+		if !strategy.Sleep(ctx, time.Second*2) {
+			return nil
+		}
+		p := s.params.Get()
+		if shouldWait, ok := s.wait.Get(pair); ok {
+			if shouldWait {
+				continue
+			}
+		}
+		skip := false
+		s.orders.Range(func(key int, value payeer.OrderParams) bool {
+			if value.Action == action {
+				skip = true
+				return false
+			}
+			return true
+		})
+		if skip {
+			continue
+		}
+		if halted, reason := s.market.Halted(); halted {
+			slog.Info("[ValueOffsetStrategy] Trading halted, not placing", "pair", pair, "action", action, "reason", reason)
+			continue
+		}
+		if s.pauses.Paused(action) {
+			continue
+		}
+		if !strategy.Sleep(ctx, 500*time.Millisecond) {
+			return nil
+		}
+		orders, err := s.fetchOrders(pair)
+		if err != nil {
+			return err
+		}
+		selection := p.selector.SelectPair(pair, action, &orders)
+		ok, price := selection.Ok, selection.Price
+		pairInfo := s.info.PairInfo(pair)
+		skew := s.inventorySkew(p, pair, action)
+		if skew.IsStopped() {
+			continue
+		}
+		amount := pairInfo.RoundAmount(skew.Size(p.options.Amount))
+		if ok && skew != nil {
+			reference, found := s.binanceTickers.Get(p.selector.SymbolFor(pair, action))
+			if !found {
+				slog.Warn("[ValueOffsetStrategy] no binance ticker found", "symbol", p.selector.SymbolFor(pair, action))
+				continue
+			}
+			referencePrice := decimal.RequireFromString(reference.BidPrice)
+			if action == payeer.ACTION_SELL {
+				referencePrice = decimal.RequireFromString(reference.AskPrice)
+			}
+			price = skew.Price(action, price, referencePrice, p.selector.Config.Edge, pairInfo)
+			selection.Stages = append(selection.Stages, payeer.Stage{Name: inventory.STAGE_SKEW, Ok: true, Price: price})
+			if minAmount := decimal.NewFromFloat(pairInfo.MinAmount); amount.LessThan(minAmount) {
+				slog.Debug("[ValueOffsetStrategy] Skewed amount below minAmount, skipping", "pair", pair, "action", action, "amount", amount.String(), "minAmount", minAmount.String())
+				continue
+			}
+		}
+		if ok {
+			trace := logging.NewTraceId()
+			slog.Debug("[ValueOffsetStrategy] Price selected", logging.Strategy(p.options.Name), logging.Pair(pair), "action", action, "price", price, logging.Trace(trace))
+			if action == payeer.ACTION_BUY {
+				quote, ok := s.balance.Get(pair.Quote())
+				if !ok {
+					slog.Warn("[ValueOffsetStrategy] no balance found for", "quote", pair.Quote())
+					continue
+				}
+				available := decimal.NewFromFloat(quote.Available)
+				required := pairInfo.Required(action, amount, price)
+				if available.LessThan(required) {
+					slog.Warn("[ValueOffsetStrategy] not enough quote", "action", action, "quote", pair.Quote(), "required", required.String(), "available", available.String())
+					continue
+				}
+			} else {
+				base, ok := s.balance.Get(pair.Base())
+				if !ok {
+					slog.Warn("[ValueOffsetStrategy] no balance found for", "base", pair.Base())
+					continue
+				}
+				available := decimal.NewFromFloat(base.Available)
+				required := pairInfo.Required(action, amount, price)
+				if available.LessThan(required) {
+					slog.Warn("[ValueOffsetStrategy] not enough base", "action", action, "base", pair.Base(), "required", required.String(), "available", available.String())
+					continue
+				}
+			}
+			binancePrices, ok := s.binanceTickers.Get(p.options.Pairs[pair])
+			if !ok {
+				slog.Warn("[ValueOffsetStrategy] no binance ticker found", "symbol", p.options.Pairs[pair])
+				continue
+			}
+			selectorConfig := *p.selector.Config
+			entry := &journal.Entry{
+				Strategy: p.options.Name,
+				Pair:     pair,
+				Action:   action,
+				Decision: journal.DECISION_PLACE,
+				Trace:    trace,
+				Book:     journal.BookSide(&orders, action),
+				Ticker:   s.market.JournalTicker(p.selector.SymbolFor(pair, action)),
+				Stages:   selection.Stages,
+				Selector: &selectorConfig,
+				Skew:     skew,
+				Price:    price,
+				Amount:   amount,
+				PairInfo: pairInfo,
+			}
+			rsp, err := s.placeOrder(trace, action, pair, amount.String(), price.String())
+			if err != nil {
+				entry.Result.Error = err.Error()
+				s.market.Journal.Append(entry)
+				return err
+			}
+			if rsp == nil {
+				entry.Result.Error = string(payeer.ERR_RISK_REJECTED)
+				s.market.Journal.Append(entry)
+				continue
+			}
+			entry.Result = journal.Result{Accepted: rsp.Success, OrderId: rsp.OrderId, Error: string(rsp.Error.Code)}
+			s.market.Journal.Append(entry)
+			if !rsp.Success {
+				continue
+			}
+			var binancePrice decimal.Decimal
+			if action == payeer.ACTION_SELL {
+				binancePrice = decimal.RequireFromString(binancePrices.AskPrice)
+			} else {
+				binancePrice = decimal.RequireFromString(binancePrices.BidPrice)
+			}
+			s.binancePricePlaced.Set(rsp.OrderId, placedMetadata{
+				binancePrice: binancePrice,
+				action:       action,
+			})
+			s.trackOrder(rsp, binancePrice)
+			if err := s.resetBalance(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Strategy) CheckAndCancelLoop(ctx context.Context, pair payeer.Pair) error {
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if len(s.orders.Keys()) == 0 {
+			continue
+		}
+		p := s.params.Get()
+		binancePrices, ok := s.binanceTickers.Get(p.options.Pairs[pair])
+		if !ok {
+			slog.Warn("[ValueOffsetStrategy] no binance ticker found", "symbol", p.options.Pairs[pair])
+			continue
+		}
+		if !strategy.Sleep(ctx, 500*time.Millisecond) {
+			return nil
+		}
+		orders, err := s.fetchOrders(pair)
+		if err != nil {
+			return err
+		}
+		priceChangedOrderIds := []int{}
+		// s.binancePricePlaced.Range(func(key int, data placedMetadata) bool {
+		// 	t, ok := s.times.Get(key)
+		// 	if !ok {
+		// 		panic("order time not found")
+		// 	}
+		// 	if time.Since(t).Minutes() < 1 {
+		// 		return true
+		// 	}
+		// 	var violatesRatio bool
+		// 	if data.action == payeer.ACTION_SELL {
+		// 		binanceAskPrice := decimal.RequireFromString(binancePrices.AskPrice)
+		// 		violatesRatio = binanceAskPrice.Div(data.binancePrice).GreaterThan(s.maxPriceRatio)
+		// 	} else {
+		// 		binanceBidPrice := decimal.RequireFromString(binancePrices.BidPrice)
+		// 		violatesRatio = data.binancePrice.Div(binanceBidPrice).GreaterThan(s.maxPriceRatio)
+		// 	}
+		// 	if violatesRatio {
+		// 		priceChangedOrderIds = append(priceChangedOrderIds, key)
+		// 		s.wait.Set(pair, true)
+		// 		time.AfterFunc(time.Minute, func() {
+		// 			s.wait.Delete(pair)
+		// 		})
+		// 		return true
+		// 	}
+		// 	return true
+		// })
+		// s.cancelOrders(priceChangedOrderIds)
+		cancelableOrderIds := []int{}
+		decisions := map[int]*journal.Entry{}
+		decide := func(orderId int, order payeer.OrderParams, reason string) {
+			trace, _ := s.traces.Get(orderId)
+			decisions[orderId] = &journal.Entry{
+				Strategy: p.options.Name,
+				Pair:     pair,
+				Action:   order.Action,
+				Decision: journal.DECISION_CANCEL,
+				Reason:   reason,
+				Trace:    trace,
+				OrderId:  orderId,
+				Book:     journal.BookSide(&orders, order.Action),
+				Ticker:   s.market.JournalTicker(p.options.Pairs[pair]),
+				Price:    decimal.RequireFromString(order.Price),
+				Amount:   decimal.RequireFromString(order.Amount),
+			}
+			cancelableOrderIds = append(cancelableOrderIds, orderId)
+		}
+		makerFee := s.info.PairInfo(pair).MakerFee()
+		s.orders.Range(func(key int, value payeer.OrderParams) bool {
+			t, ok := s.times.Get(key)
+			if !ok {
+				panic("order time not found")
+			}
+			if time.Since(t).Minutes() < 1 {
+				return true
+			}
+			skew := s.inventorySkew(p, pair, value.Action)
+			if skew.IsStopped() {
+				slog.Info("[ValueOffsetStrategy] order should be cancelled at the inventory limit", "orderId", key, "action", value.Action, "baseShare", skew.BaseShare.StringFixed(4))
+				decide(key, value, "inventory at "+inventory.Limit(value.Action))
+				return true
+			}
+			price, err := decimal.NewFromString(value.Price)
+			if err != nil {
+				panic(err)
+			}
+			// cancel by value offset
+			if payeer.TopValueOffset(price, &orders, value.Action).GreaterThan(p.replacementValueOffset) {
+				slog.Info("[ValueOffsetStrategy] order should be replaced due to top value offset", "orderId", key)
+				decide(key, value, "top value offset above replacementValueOffset")
+				return true
+			}
+			// cancel by binance price
+			if value.Action == payeer.ACTION_BUY {
+				binPrice := decimal.RequireFromString(binancePrices.BidPrice)
+				ratio := p.selector.Config.Edge.Limit(value.Action, makerFee, skew.Ratio(p.selector.Config.BidMaxBinancePriceRatio))
+				ok := price.Div(binPrice).LessThan(ratio)
+				if !ok {
+					slog.Info("[PayeerPriceSelector] canceled by binance price", "orderId", key, "action", value.Action, "ok", ok, "binance bid price", binPrice.String(), "price", price.String())
+					decide(key, value, "price above bidMaxBinancePriceRatio or the target edge")
+					return true
+				}
+			} else {
+				binPrice := decimal.RequireFromString(binancePrices.AskPrice)
+				ratio := p.selector.Config.Edge.Limit(value.Action, makerFee, skew.Ratio(p.selector.Config.AskMinBinancePriceRatio))
+				ok := price.Div(binPrice).GreaterThan(ratio)
+				if !ok {
+					slog.Info("[PayeerPriceSelector] canceled by binance price", "orderId", key, "action", value.Action, "ok", ok, "binance ask price", binPrice.String(), "price", price.String())
+					decide(key, value, "price below askMinBinancePriceRatio or the target edge")
+					return true
+				}
+			}
+			return true
+		})
+		if err := s.cancelOrders(cancelableOrderIds, decisions); err != nil {
+			return err
+		}
+		if len(priceChangedOrderIds) > 0 || len(cancelableOrderIds) > 0 {
+			if err := s.resetBalance(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// cancelInitialOrders takes over the orders the state store records for this
+// strategy and cancels every other open order, unless another strategy of the
+// process owns it.
+func (s *Strategy) cancelInitialOrders(ctx context.Context) error {
+	options := s.params.Get().options
+	adopted, unowned, err := s.market.AdoptOrders(ctx, options.Name)
+	s.updateWeights(60)
+	if err != nil {
+		return err
+	}
+	for _, record := range adopted {
+		if _, ok := options.Pairs[record.Params.Pair]; !ok {
+			slog.Info("[ValueOffsetStrategy] Recorded order is not on a configured pair, cancelling", "orderId", record.OrderId, "pair", record.Params.Pair)
+			if err := strategy.CancelOrder(ctx, s.payeerClient, record.OrderId, record.Placed); err != nil {
+				return err
+			}
+			order, err := s.market.Fetcher.TryOrderDetails(record.OrderId)
+			if err != nil {
+				return err
+			}
+			s.market.RecordFill(options.Name, "", order)
+			s.market.ForgetOrder(record.OrderId)
+			continue
+		}
+		s.orders.Set(record.OrderId, record.Params)
+		s.times.Set(record.OrderId, record.Placed)
+		s.traces.Set(record.OrderId, record.Trace)
+		s.binancePricePlaced.Set(record.OrderId, placedMetadata{
+			binancePrice: record.ReferencePrice,
+			action:       record.Params.Action,
+		})
+	}
+	slog.Info("[ValueOffsetStrategy] Cancelling pending orders...", "adopted", len(adopted), "unowned", len(unowned))
+	for _, order := range unowned {
+		orderTime := time.Unix(order.Date, 0)
+		diff := time.Since(orderTime)
+		if diff.Minutes() <= 1 {
+			wait := time.Minute - diff
+			slog.Info("[ValueOffsetStrategy] Init should wait for order cancel", "orderId", order.Id, "time", wait)
+			if !strategy.Sleep(ctx, wait) {
+				return ctx.Err()
+			}
+		}
+		orderId, _ := strconv.Atoi(order.Id)
+		if _, err := s.cancelOrder(orderId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Strategy) resetBalance() error {
+	slog.Info("[ValueOffsetStrategy] Fetching balances...")
+	balances, err := s.fetchBalance()
+	if err != nil {
+		return err
+	}
+	s.market.SaveBalances(balances)
+	for currency, balance := range balances {
+		// Cached assets are overwritten even when emptied by a fill
+		if _, ok := s.balance.Get(currency); ok || balance.Available > 0 {
+			s.balance.Set(currency, balance)
+		}
+	}
+	return nil
+}
+
+func (s *Strategy) resetInfo() {
+	s.info = s.market.Info()
+	s.params.Get().selector.SetInfo(s.info)
+}
+
+func (s *Strategy) fetchOrderDetails(orderId int) (*payeer.OrderDetails, error) {
+	order, err := s.market.Fetcher.TryOrderDetails(orderId)
+	if err != nil {
+		return nil, fmt.Errorf("order %d status: %w", orderId, err)
+	}
+	s.updateWeights(5)
+	return order, nil
+}
+
+// placeOrder returns a nil response when the risk manager rejects the order;
+// an order the exchange rejects, e.g. for insufficient funds, is not tracked.
+func (s *Strategy) placeOrder(trace string, action payeer.Action, pair payeer.Pair, amount string, price string) (*payeer.PostOrderResponse, error) {
+	if err := s.market.Risk.CheckOrder(risk.PayeerOrder(action, pair, amount, price)); err != nil {
+		return nil, nil
+	}
+	rsp, err := s.market.Fetcher.TryPlaceOrder(&payeer.PostOrderRequest{
+		Pair:   pair,
+		Type:   payeer.ORDER_TYPE_LIMIT,
+		Action: action,
+		Amount: amount,
+		Price:  price,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("place %s %s: %w", action, pair, err)
+	}
+	s.updateWeights(5)
+	strategy.CountPlacement(s.params.Get().options.Name, string(pair)+"/"+string(action), rsp.Success)
+	if !rsp.Success {
+		slog.Error("[ValueOffsetStrategy] Place order response error", logging.Pair(pair), "action", action, "error", rsp.Error, logging.Trace(trace))
+		return rsp, nil
+	}
+	s.times.Set(rsp.OrderId, time.Now())
+	s.orders.Set(rsp.OrderId, rsp.Params)
+	s.traces.Set(rsp.OrderId, trace)
+	s.orderLog(rsp.OrderId).Info("[ValueOffsetStrategy] Order placed", logging.Pair(pair), "action", action, "amount", amount, "price", price)
+	return rsp, nil
+}
+
+// cancelOrders cancels the orders in turn and journals the decision about
+// each with its result; it stops at the first failed cancel.
+func (s *Strategy) cancelOrders(orderIds []int, decisions map[int]*journal.Entry) error {
+	for _, orderId := range orderIds {
+		entry := decisions[orderId]
+		rsp, err := s.cancelOrder(orderId)
+		entry.Result = journal.Result{OrderId: orderId}
+		switch {
+		case err != nil:
+			entry.Result.Error = err.Error()
+		case rsp.Success:
+			entry.Result.Accepted = true
+		default:
+			entry.Result.Error = string(rsp.Error.Code)
+		}
+		s.market.Journal.Append(entry)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cancelOrder cancels a tracked order and records what filled of it. An order
+// that closed in the meantime counts as cancelled, any other failure is
+// returned.
+func (s *Strategy) cancelOrder(orderId int) (*payeer.CancelOrderResponse, error) {
+	rsp, err := s.market.Fetcher.TryCancelOrder(orderId)
+	if err != nil {
+		return nil, fmt.Errorf("cancel order %d: %w", orderId, err)
+	}
+	s.updateWeights(10)
+	strategy.CountCancel(s.params.Get().options.Name, "cancel", rsp.Success)
+	if !rsp.Success {
+		log := s.orderLog(orderId)
+		if rsp.Error.Code == payeer.ERR_INVALID_STATUS_FOR_REFUND {
+			log.Info("[ValueOffsetStrategy] Order not cancelled, already closed", "error", rsp.Error)
+			return rsp, s.closeOrder(orderId)
+		}
+		log.Error("[ValueOffsetStrategy] Cancel order error", "error", rsp.Error)
+		return rsp, fmt.Errorf("cancel order %d: %s", orderId, rsp.Error.Code)
+	}
+	s.orderLog(orderId).Info("[ValueOffsetStrategy] Order canceled")
+	return rsp, s.closeOrder(orderId)
+}
+
+// trackOrder records a placed order in the state store so that a restart
+// adopts it together with the Binance price it was placed against.
+func (s *Strategy) trackOrder(rsp *payeer.PostOrderResponse, binancePrice decimal.Decimal) {
+	placed, _ := s.times.Get(rsp.OrderId)
+	trace, _ := s.traces.Get(rsp.OrderId)
+	s.market.TrackOrder(strategy.OrderRecord{
+		OrderId:        rsp.OrderId,
+		Strategy:       s.params.Get().options.Name,
+		Params:         rsp.Params,
+		Placed:         placed,
+		ReferencePrice: binancePrice,
+		Trace:          trace,
+	})
+}
+
+// orderLog returns a logger carrying the strategy, order and trace ID.
+func (s *Strategy) orderLog(orderId int) *slog.Logger {
+	trace, _ := s.traces.Get(orderId)
+	return slog.With(logging.Strategy(s.params.Get().options.Name), logging.OrderId(orderId), logging.Trace(trace))
+}
+
+// closeOrder records what filled of a tracked order that was cancelled or
+// closed before the cancel, then forgets it.
+func (s *Strategy) closeOrder(orderId int) error {
+	if _, ok := s.orders.Get(orderId); ok {
+		order, err := s.fetchOrderDetails(orderId)
+		if err != nil {
+			return err
+		}
+		s.market.RecordFill(s.params.Get().options.Name, "", order)
+	}
+	s.forgetOrder(orderId)
+	return nil
+}
+
+func (s *Strategy) forgetOrder(orderId int) {
+	s.times.Delete(orderId)
+	s.traces.Delete(orderId)
+	s.orders.Delete(orderId)
+	s.binancePricePlaced.Delete(orderId)
+	s.market.ForgetOrder(orderId)
+}
+
+// func (s *Strategy) selectPriceFromPayeerOrders(isSell bool, info payeer.PairsOrderInfo) decimal.Decimal {
+// 	acc := decimal.NewFromInt(0)
+// 	var selectedPrice decimal.Decimal
+// 	orders := info.Bids
+// 	if isSell {
+// 		orders = info.Asks
+// 	}
+// 	for _, order := range orders {
+// 		value, _ := decimal.NewFromString(order.Value)
+// 		acc = acc.Add(value)
+// 		if acc.GreaterThanOrEqual(s.placementValueOffset) {
+// 			p, err := decimal.NewFromString(order.Price)
+// 			if err != nil {
+// 				panic(err)
+// 			}
+// 			cent, _ := decimal.NewFromString(".01")
+// 			if isSell {
+// 				selectedPrice = p.Sub(cent)
+// 			} else {
+// 				selectedPrice = p.Add(cent)
+// 			}
+// 			slog.Info("Payeer price chosen:", "price", selectedPrice.String())
+// 			break
+// 		}
+// 	}
+// 	return selectedPrice
+// }
+
+func (s *Strategy) fetchBalance() (map[string]payeer.Balance, error) {
+	balances, err := s.market.Fetcher.TryBalance()
+	if err != nil {
+		return nil, fmt.Errorf("balance: %w", err)
+	}
+	s.updateWeights(10)
+	slog.Debug("[ValueOffsetStrategy] Payeer balance", "balance", balances)
+	return balances, nil
+}
+
+func (s *Strategy) fetchOrders(pair payeer.Pair) (payeer.PairsOrderInfo, error) {
+	orders, err := s.market.Fetcher.TryOrders([]payeer.Pair{pair})
+	if err != nil {
+		return payeer.PairsOrderInfo{}, fmt.Errorf("%s orders: %w", pair, err)
+	}
+	s.updateWeights(1)
+	return orders[pair], nil
+}
+
+type DecimalPrices struct {
+	Bid decimal.Decimal
+	Ask decimal.Decimal
+}
+
+// func (s *Strategy) fetchBinancePrice() *DecimalPrices {
+// 	rsp, err := s.binanceClient.GetOrderBookTickers([]binance.Symbol{binance.SYMBOL_BTCUSDT})
+// 	if err != nil {
+// 		panic(err)
+// 	}
+// 	tickers := rsp.Result[0]
+// 	slog.Debug("Binance data:", "tickers", tickers)
+// 	bid, err := decimal.NewFromString(tickers.BidPrice)
+// 	if err != nil {
+// 		panic(err)
+// 	}
+// 	ask, err := decimal.NewFromString(tickers.AskPrice)
+// 	if err != nil {
+// 		panic(err)
+// 	}
+
+// 	return &DecimalPrices{Bid: bid, Ask: ask}
+// }
+
+func (s *Strategy) updateWeights(count int) {
+	now := time.Now()
+	if now.Sub(s.weightsTimestamp.Get()).Minutes() > 1 {
+		s.weightsTimestamp.Set(now)
+		s.minWeights.Set(600 - count)
+		return
+	} else {
+		s.minWeights.Update(func(value int) int {
+			return value - count
+		})
+	}
+	slog.Info("[ValueOffsetStrategy] Weights info", "remaining/min", s.minWeights.Get())
+}
+
+// func (s *Strategy) waitForWeights(count int) {
+// 	for {
+// 		if s.minWeights.Get() >= count {
+// 			return
+// 		}
+// 		time.Sleep(time.Millisecond * 10)
+// 		slog.Info("Waiting for weights to reach", "count", count)
+// 	}
+// }
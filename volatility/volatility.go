@@ -0,0 +1,141 @@
+// Package volatility estimates how fast a reference mid moves, from samples
+// of it taken at a fixed interval, as an EWMA of squared log returns or as
+// the realised volatility over a window. Both are expressed in basis points
+// per minute so that thresholds do not depend on the sample interval.
+package volatility
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type Model string
+
+const (
+	MODEL_EWMA     Model = "ewma"
+	MODEL_REALISED Model = "realised"
+)
+
+var Models = []Model{MODEL_EWMA, MODEL_REALISED}
+
+// minReturns is how many returns an estimator needs before it reports.
+const minReturns = 10
+
+const DefaultSampleInterval = time.Second
+
+type Options struct {
+	Model Model
+	// SampleInterval between mid samples. Default 1s.
+	SampleInterval time.Duration
+	// HalfLife of the weight of a return in the EWMA. Default 5m.
+	HalfLife time.Duration
+	// Window of the realised volatility. Default 15m.
+	Window time.Duration
+}
+
+func (o *Options) withDefaults() *Options {
+	options := *o
+	if options.Model == "" {
+		options.Model = MODEL_EWMA
+	}
+	if options.SampleInterval == 0 {
+		options.SampleInterval = DefaultSampleInterval
+	}
+	if options.HalfLife == 0 {
+		options.HalfLife = 5 * time.Minute
+	}
+	if options.Window == 0 {
+		options.Window = 15 * time.Minute
+	}
+	return &options
+}
+
+type sample struct {
+	time time.Time
+	// variance is the squared log return over seconds.
+	variance float64
+	seconds  float64
+}
+
+// Estimator is safe for concurrent use.
+type Estimator struct {
+	Options *Options
+
+	mu       sync.Mutex
+	lastTime time.Time
+	lastLog  float64
+	returns  int
+	// ewma is the variance per second of the EWMA model.
+	ewma float64
+	// window holds the returns of the realised model.
+	window []sample
+}
+
+func NewEstimator(options *Options) *Estimator {
+	return &Estimator{Options: options.withDefaults()}
+}
+
+// Add takes a sample of mid at time at. Samples out of order or with a
+// non-positive mid are ignored.
+func (e *Estimator) Add(at time.Time, mid decimal.Decimal) {
+	if !mid.IsPositive() {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	logMid := math.Log(mid.InexactFloat64())
+	if e.lastTime.IsZero() {
+		e.lastTime, e.lastLog = at, logMid
+		return
+	}
+	seconds := at.Sub(e.lastTime).Seconds()
+	if seconds <= 0 {
+		return
+	}
+	r := logMid - e.lastLog
+	e.lastTime, e.lastLog = at, logMid
+	e.returns++
+
+	switch e.Options.Model {
+	case MODEL_REALISED:
+		e.window = append(e.window, sample{at, r * r, seconds})
+		cutoff := at.Add(-e.Options.Window)
+		drop := 0
+		for drop < len(e.window) && e.window[drop].time.Before(cutoff) {
+			drop++
+		}
+		e.window = e.window[drop:]
+	default:
+		alpha := 1 - math.Exp2(-seconds/e.Options.HalfLife.Seconds())
+		if e.returns == 1 {
+			alpha = 1
+		}
+		e.ewma += alpha * (r*r/seconds - e.ewma)
+	}
+}
+
+// Bps returns the volatility in basis points per minute, false until enough
+// returns were sampled.
+func (e *Estimator) Bps() (float64, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.returns < minReturns {
+		return 0, false
+	}
+	variance := e.ewma
+	if e.Options.Model == MODEL_REALISED {
+		var sum, seconds float64
+		for _, s := range e.window {
+			sum += s.variance
+			seconds += s.seconds
+		}
+		if seconds == 0 {
+			return 0, false
+		}
+		variance = sum / seconds
+	}
+	return math.Sqrt(variance*60) * 1e4, true
+}